@@ -0,0 +1,265 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sp3dr4/bloggogrator/api/middleware"
+	"github.com/sp3dr4/bloggogrator/internal/database"
+	"github.com/sp3dr4/bloggogrator/internal/rss"
+)
+
+// deleteAccountConfirmation must be sent to delete an account.
+const deleteAccountConfirmation = "delete my account"
+
+// maxPreferencesSize bounds the encoded preferences of a user.
+const maxPreferencesSize = 16 << 10
+
+func (a *apiConfig) handlerUpdateUser(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.AuthUser).(database.User)
+
+	var request struct {
+		Name        *string         `json:"name"`
+		Email       *string         `json:"email"`
+		Preferences json.RawMessage `json:"preferences"`
+		// Password is the current password, required to change the email
+		// of the users who have one.
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondWithError(w, 400, "error decoding request body")
+		return
+	}
+
+	params := database.UpdateUserParams{
+		ID:          user.ID,
+		Name:        user.Name,
+		Email:       user.Email,
+		Preferences: user.Preferences,
+		UpdatedAt:   time.Now(),
+	}
+	var changed []string
+	if request.Name != nil {
+		if len(*request.Name) > 255 {
+			respondWithError(w, 400, "invalid name")
+			return
+		}
+		params.Name = *request.Name
+		changed = append(changed, "name")
+	}
+	if request.Email != nil {
+		if *request.Email == "" {
+			if user.PasswordHash.Valid {
+				respondWithError(w, 400, "email is required to log in with a password")
+				return
+			}
+			params.Email = sql.NullString{}
+		} else {
+			address, err := mail.ParseAddress(*request.Email)
+			if err != nil || address.Address != *request.Email || len(address.Address) > 255 {
+				respondWithError(w, 400, "invalid email")
+				return
+			}
+			params.Email = sql.NullString{String: strings.ToLower(address.Address), Valid: true}
+		}
+		// the email is an identity: it logs the user in and bootstraps the
+		// admins, so changing it takes the full credentials of the account
+		if !middleware.HasScope(r.Context(), middleware.ScopeAll) {
+			respondWithError(w, 403, "operation not allowed")
+			return
+		}
+		if user.PasswordHash.Valid && !checkPassword(user, request.Password) {
+			respondWithError(w, 403, "invalid password")
+			return
+		}
+		changed = append(changed, "email")
+	}
+	if request.Preferences != nil {
+		var prefs map[string]interface{}
+		if len(request.Preferences) > maxPreferencesSize || json.Unmarshal(request.Preferences, &prefs) != nil || prefs == nil {
+			respondWithError(w, 400, "preferences must be a JSON object")
+			return
+		}
+		params.Preferences = request.Preferences
+		changed = append(changed, "preferences")
+	}
+	if len(changed) == 0 {
+		respondWithError(w, 400, "nothing to update")
+		return
+	}
+
+	updated, err := a.DB.UpdateUser(r.Context(), params)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "unique_violation" {
+			respondWithError(w, 409, "email already registered")
+			return
+		}
+		log.Printf("user %v update error: %v\n", user.ID, err)
+		respondWithError(w, 500, "error updating user")
+		return
+	}
+	a.audit(r, user.ID, auditUserUpdated, map[string]interface{}{"fields": changed})
+
+	respondWithJSON(w, 200, dbUserToUser(updated))
+}
+
+// handlerDeleteUser deletes the account with its follows, post states and
+// the rest of its data. The feeds it added stay for their other followers,
+// the ones nobody else follows are deleted with it.
+func (a *apiConfig) handlerDeleteUser(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.AuthUser).(database.User)
+	// like the key rotation, deleting the account takes more than a scoped key
+	if !middleware.HasScope(r.Context(), middleware.ScopeAll) {
+		respondWithError(w, 403, "operation not allowed")
+		return
+	}
+
+	var request struct {
+		Confirm  string `json:"confirm"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondWithError(w, 400, "error decoding request body")
+		return
+	}
+	if request.Confirm != deleteAccountConfirmation {
+		respondWithError(w, 400, fmt.Sprintf("confirm must be %q", deleteAccountConfirmation))
+		return
+	}
+	if user.PasswordHash.Valid && !checkPassword(user, request.Password) {
+		respondWithError(w, 403, "invalid password")
+		return
+	}
+
+	if _, err := a.DB.DeleteUser(r.Context(), user.ID); err != nil {
+		log.Printf("user %v deletion error: %v\n", user.ID, err)
+		respondWithError(w, 500, "error deleting user")
+		return
+	}
+	a.audit(r, user.ID, auditUserDeleted, map[string]interface{}{"name": user.Name})
+
+	respondWithJSON(w, 204, struct{}{})
+}
+
+type exportResponse struct {
+	Profile      userResponse         `json:"profile"`
+	Follows      []feedResponse       `json:"follows"`
+	StarredPosts []postResponse       `json:"starred_posts"`
+	FilterRules  []filterRuleResponse `json:"filter_rules"`
+}
+
+// handlerExportUser returns the data of the user, as a ZIP archive with the
+// follows as an OPML file, or as a single JSON document with format=json.
+func (a *apiConfig) handlerExportUser(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.AuthUser).(database.User)
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "zip" && format != "json" {
+		respondWithError(w, 400, "invalid format query parameter")
+		return
+	}
+
+	feeds, err := a.DB.ListUserFollowedFeeds(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("user %v export error: %v\n", user.ID, err)
+		respondWithError(w, 500, "error exporting user")
+		return
+	}
+	starred, err := a.DB.ListStarredPosts(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("user %v export error: %v\n", user.ID, err)
+		respondWithError(w, 500, "error exporting user")
+		return
+	}
+	rules, err := a.DB.ListUserFilterRules(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("user %v export error: %v\n", user.ID, err)
+		respondWithError(w, 500, "error exporting user")
+		return
+	}
+
+	export := exportResponse{
+		Profile:      dbUserToUser(user),
+		Follows:      make([]feedResponse, 0, len(feeds)),
+		StarredPosts: make([]postResponse, 0, len(starred)),
+		FilterRules:  make([]filterRuleResponse, 0, len(rules)),
+	}
+	for _, o := range feeds {
+		export.Follows = append(export.Follows, dbFeedToFeed(o))
+	}
+	for _, o := range starred {
+		post := dbPostToPost(o.Post)
+		post.State = &postStateResponse{Read: o.PostState.Read, Starred: o.PostState.Starred, Tags: o.PostState.Tags}
+		export.StarredPosts = append(export.StarredPosts, post)
+	}
+	for _, o := range rules {
+		export.FilterRules = append(export.FilterRules, dbFilterRuleToFilterRule(o))
+	}
+
+	if format == "json" {
+		respondWithJSON(w, 200, export)
+		return
+	}
+
+	now := time.Now()
+	archive, err := exportArchive(export, feeds, now)
+	if err != nil {
+		log.Printf("user %v export error: %v\n", user.ID, err)
+		respondWithError(w, 500, "error exporting user")
+		return
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="bloggogrator-export-%s.zip"`, now.Format("20060102")))
+	w.WriteHeader(200)
+	w.Write(archive)
+}
+
+// exportArchive builds the ZIP archive of an export.
+func exportArchive(export exportResponse, feeds []database.Feed, now time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	writeJSON := func(name string, v interface{}) error {
+		f, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	if err := writeJSON("profile.json", export.Profile); err != nil {
+		return nil, err
+	}
+	if err := writeJSON("starred_posts.json", export.StarredPosts); err != nil {
+		return nil, err
+	}
+	if err := writeJSON("filter_rules.json", export.FilterRules); err != nil {
+		return nil, err
+	}
+	outlines := make([]rss.Outline, 0, len(feeds))
+	for _, o := range feeds {
+		outlines = append(outlines, rss.Outline{Title: o.Name, XmlUrl: o.Url})
+	}
+	f, err := zw.Create("follows.opml")
+	if err != nil {
+		return nil, err
+	}
+	if err := rss.WriteOPML(f, export.Profile.Name+"'s subscriptions", now, outlines); err != nil {
+		return nil, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
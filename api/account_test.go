@@ -0,0 +1,257 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/sp3dr4/bloggogrator/api/middleware"
+	"github.com/sp3dr4/bloggogrator/internal/database"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateUserHandler(t *testing.T) {
+	t.Run("return 200", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		user := setupUser()
+		updated := user
+		updated.Name = "Baz"
+		updated.Preferences = json.RawMessage(`{"theme":"dark"}`)
+		mockDbApi.On("UpdateUser", mock.Anything, mock.MatchedBy(func(p database.UpdateUserParams) bool {
+			return p.ID == user.ID && p.Name == "Baz" && p.Email == user.Email && string(p.Preferences) == `{"theme":"dark"}`
+		})).Return(updated, nil)
+		mockDbApi.On("CreateAuditEvent", mock.Anything, mock.MatchedBy(func(p database.CreateAuditEventParams) bool {
+			return p.Action == auditUserUpdated && string(p.Details) == `{"fields":["name","preferences"]}`
+		})).Return(database.AuditEvent{}, nil)
+		rw, req := setupApiKeyTest(t, user, []string{middleware.ScopeAll}, http.MethodPatch, "/v1/users", `{"name":"Baz","preferences":{"theme":"dark"}}`)
+
+		testApi.handlerUpdateUser(rw, req)
+
+		require.Equal(t, http.StatusOK, rw.Code)
+		var resp userResponse
+		require.NoError(t, json.NewDecoder(rw.Body).Decode(&resp))
+		require.Equal(t, "Baz", resp.Name)
+		require.JSONEq(t, `{"theme":"dark"}`, string(resp.Preferences))
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	passwordUser := setupUser()
+	passwordUser.Email = sql.NullString{String: "foo@example.com", Valid: true}
+	passwordUser.PasswordHash = sql.NullString{String: dummyPasswordHash, Valid: true}
+	tests := map[string]struct {
+		user   database.User
+		body   string
+		errMsg string
+	}{
+		"nothing":           {setupUser(), `{}`, "nothing to update"},
+		"invalid email":     {setupUser(), `{"email":"nope"}`, "invalid email"},
+		"password no email": {passwordUser, `{"email":""}`, "email is required to log in with a password"},
+		"array preferences": {setupUser(), `{"preferences":[1]}`, "preferences must be a JSON object"},
+		"null preferences":  {setupUser(), `{"preferences":null}`, "preferences must be a JSON object"},
+		"invalid body":      {setupUser(), `{"name":1}`, "error decoding request body"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockDbApi := new(MockedDbApi)
+			testApi := apiConfig{DB: mockDbApi}
+			rw, req := setupApiKeyTest(t, tc.user, []string{middleware.ScopeAll}, http.MethodPatch, "/v1/users", tc.body)
+
+			testApi.handlerUpdateUser(rw, req)
+
+			compareError(t, rw, http.StatusBadRequest, tc.errMsg)
+
+			mockDbApi.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUpdateUserEmailHandler(t *testing.T) {
+	t.Run("return 200 with the password", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		user := setupLoginUser(t, "s3cret-pass")
+		updated := user
+		updated.Email = sql.NullString{String: "new@example.com", Valid: true}
+		mockDbApi.On("UpdateUser", mock.Anything, mock.MatchedBy(func(p database.UpdateUserParams) bool {
+			return p.ID == user.ID && p.Email == updated.Email
+		})).Return(updated, nil)
+		mockDbApi.On("CreateAuditEvent", mock.Anything, mock.Anything).Return(database.AuditEvent{}, nil)
+		rw, req := setupApiKeyTest(t, user, []string{middleware.ScopeAll}, http.MethodPatch, "/v1/users", `{"email":"new@example.com","password":"s3cret-pass"}`)
+
+		testApi.handlerUpdateUser(rw, req)
+
+		require.Equal(t, http.StatusOK, rw.Code)
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	tests := map[string]struct {
+		user   database.User
+		scopes []string
+		body   string
+		errMsg string
+	}{
+		"scoped key":     {setupUser(), []string{"write:account"}, `{"email":"new@example.com"}`, "operation not allowed"},
+		"no password":    {setupLoginUser(t, "s3cret-pass"), []string{middleware.ScopeAll}, `{"email":"new@example.com"}`, "invalid password"},
+		"wrong password": {setupLoginUser(t, "s3cret-pass"), []string{middleware.ScopeAll}, `{"email":"new@example.com","password":"wrong-pass"}`, "invalid password"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockDbApi := new(MockedDbApi)
+			testApi := apiConfig{DB: mockDbApi}
+			rw, req := setupApiKeyTest(t, tc.user, tc.scopes, http.MethodPatch, "/v1/users", tc.body)
+
+			testApi.handlerUpdateUser(rw, req)
+
+			compareError(t, rw, http.StatusForbidden, tc.errMsg)
+
+			mockDbApi.AssertExpectations(t)
+			mockDbApi.AssertNotCalled(t, "UpdateUser", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestDeleteUserHandler(t *testing.T) {
+	t.Run("return 204", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		user := setupLoginUser(t, "s3cret-pass")
+		mockDbApi.On("DeleteUser", mock.Anything, user.ID).Return(int64(1), nil)
+		mockDbApi.On("CreateAuditEvent", mock.Anything, mock.MatchedBy(func(p database.CreateAuditEventParams) bool {
+			return p.UserID == user.ID && p.Action == auditUserDeleted
+		})).Return(database.AuditEvent{}, nil)
+		rw, req := setupApiKeyTest(t, user, []string{middleware.ScopeAll}, http.MethodDelete, "/v1/users", `{"confirm":"delete my account","password":"s3cret-pass"}`)
+
+		testApi.handlerDeleteUser(rw, req)
+
+		require.Equal(t, http.StatusNoContent, rw.Code)
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	t.Run("return 400 without confirmation", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		rw, req := setupApiKeyTest(t, setupUser(), []string{middleware.ScopeAll}, http.MethodDelete, "/v1/users", `{"confirm":"yes"}`)
+
+		testApi.handlerDeleteUser(rw, req)
+
+		compareError(t, rw, http.StatusBadRequest, `confirm must be "delete my account"`)
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	t.Run("return 403 on wrong password", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		user := setupLoginUser(t, "s3cret-pass")
+		rw, req := setupApiKeyTest(t, user, []string{middleware.ScopeAll}, http.MethodDelete, "/v1/users", `{"confirm":"delete my account","password":"wrong-pass"}`)
+
+		testApi.handlerDeleteUser(rw, req)
+
+		compareError(t, rw, http.StatusForbidden, "invalid password")
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	t.Run("return 403 on scoped key", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		rw, req := setupApiKeyTest(t, setupUser(), []string{"write:account"}, http.MethodDelete, "/v1/users", `{"confirm":"delete my account"}`)
+
+		testApi.handlerDeleteUser(rw, req)
+
+		compareError(t, rw, http.StatusForbidden, "operation not allowed")
+
+		mockDbApi.AssertExpectations(t)
+		mockDbApi.AssertNotCalled(t, "DeleteUser", mock.Anything, mock.Anything)
+	})
+
+	t.Run("return 500 on deletion error", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		user := setupLoginUser(t, "s3cret-pass")
+		mockDbApi.On("DeleteUser", mock.Anything, user.ID).Return(int64(0), sql.ErrConnDone)
+		rw, req := setupApiKeyTest(t, user, []string{middleware.ScopeAll}, http.MethodDelete, "/v1/users", `{"confirm":"delete my account","password":"s3cret-pass"}`)
+
+		testApi.handlerDeleteUser(rw, req)
+
+		compareError(t, rw, http.StatusInternalServerError, "error deleting user")
+
+		mockDbApi.AssertExpectations(t)
+		mockDbApi.AssertNotCalled(t, "CreateAuditEvent", mock.Anything, mock.Anything)
+	})
+}
+
+func setupExportTest(mockDbApi *MockedDbApi, user database.User) {
+	feed := setupFeed()
+	post := setupPost(feed.ID)
+	state := database.PostState{UserID: user.ID, PostID: post.ID, Starred: true, Tags: []string{"later"}}
+	rule := database.FilterRule{ID: uuid.New(), UserID: user.ID, Field: "title", Match: "contains", Pattern: "ad", Action: "hide"}
+	mockDbApi.On("ListUserFollowedFeeds", mock.Anything, user.ID).Return([]database.Feed{feed}, nil)
+	mockDbApi.On("ListStarredPosts", mock.Anything, user.ID).Return([]database.ListStarredPostsRow{{Post: post, PostState: state}}, nil)
+	mockDbApi.On("ListUserFilterRules", mock.Anything, user.ID).Return([]database.FilterRule{rule}, nil)
+}
+
+func TestExportUserHandler(t *testing.T) {
+	t.Run("zip", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		user := setupUser()
+		setupExportTest(mockDbApi, user)
+		rw, req := setupApiKeyTest(t, user, []string{middleware.ScopeAll}, http.MethodGet, "/v1/users/export", "")
+
+		testApi.handlerExportUser(rw, req)
+
+		require.Equal(t, http.StatusOK, rw.Code)
+		require.Equal(t, "application/zip", rw.Header().Get("Content-Type"))
+		zr, err := zip.NewReader(bytes.NewReader(rw.Body.Bytes()), int64(rw.Body.Len()))
+		require.NoError(t, err)
+		files := map[string]string{}
+		for _, f := range zr.File {
+			rc, err := f.Open()
+			require.NoError(t, err)
+			content, err := io.ReadAll(rc)
+			require.NoError(t, err)
+			files[f.Name] = string(content)
+		}
+		require.Len(t, files, 4)
+		require.Contains(t, files["follows.opml"], `xmlUrl="http://example.com"`)
+		var starred []postResponse
+		require.NoError(t, json.Unmarshal([]byte(files["starred_posts.json"]), &starred))
+		require.Len(t, starred, 1)
+		require.Equal(t, []string{"later"}, starred[0].State.Tags)
+		require.Contains(t, files["profile.json"], user.ID.String())
+		require.Contains(t, files["filter_rules.json"], `"pattern": "ad"`)
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		user := setupUser()
+		setupExportTest(mockDbApi, user)
+		rw, req := setupApiKeyTest(t, user, []string{middleware.ScopeAll}, http.MethodGet, "/v1/users/export?format=json", "")
+
+		testApi.handlerExportUser(rw, req)
+
+		require.Equal(t, http.StatusOK, rw.Code)
+		var resp exportResponse
+		require.NoError(t, json.NewDecoder(rw.Body).Decode(&resp))
+		require.Equal(t, user.ID.String(), resp.Profile.Id)
+		require.Len(t, resp.Follows, 1)
+		require.Len(t, resp.StarredPosts, 1)
+		require.Len(t, resp.FilterRules, 1)
+
+		mockDbApi.AssertExpectations(t)
+	})
+}
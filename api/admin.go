@@ -0,0 +1,436 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sp3dr4/bloggogrator/api/middleware"
+	"github.com/sp3dr4/bloggogrator/internal/database"
+)
+
+// workerStatsWindow is the period the recent fetches are counted over.
+const workerStatsWindow = time.Hour
+
+// pageParams parses the limit and offset query parameters of the admin
+// listings.
+func pageParams(r *http.Request) (limit, offset int32, err error) {
+	limit = 100
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		limitInt, err := strconv.ParseInt(limitStr, 10, 32)
+		if err != nil || limitInt < 1 {
+			return 0, 0, errors.New("invalid limit query parameter")
+		}
+		limit = int32(limitInt)
+	}
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		offsetInt, err := strconv.ParseInt(offsetStr, 10, 32)
+		if err != nil || offsetInt < 0 {
+			return 0, 0, errors.New("invalid offset query parameter")
+		}
+		offset = int32(offsetInt)
+	}
+	return limit, offset, nil
+}
+
+// adminAudit records an action of an admin on target.
+func (a *apiConfig) adminAudit(r *http.Request, operation string, target uuid.UUID, details map[string]interface{}) {
+	admin := r.Context().Value(middleware.AuthUser).(database.User)
+	if details == nil {
+		details = map[string]interface{}{}
+	}
+	details["operation"] = operation
+	details["target_id"] = target
+	a.audit(r, admin.ID, auditAdminAction, details)
+}
+
+type adminUserResponse struct {
+	userResponse
+	DisabledAt *time.Time `json:"disabled_at"`
+}
+
+func dbUserToAdminUser(o database.User) adminUserResponse {
+	var disabledAt *time.Time
+	if o.DisabledAt.Valid {
+		disabledAt = &o.DisabledAt.Time
+	}
+	return adminUserResponse{userResponse: dbUserToUser(o), DisabledAt: disabledAt}
+}
+
+func (a *apiConfig) handlerAdminListUsers(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := pageParams(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	users, err := a.DB.ListUsers(r.Context(), database.ListUsersParams{Limit: limit, Offset: offset})
+	if err != nil {
+		respondWithError(w, 500, "error retrieving users")
+		return
+	}
+
+	respUsers := make([]adminUserResponse, 0, len(users))
+	for _, o := range users {
+		respUsers = append(respUsers, dbUserToAdminUser(o))
+	}
+	respondWithJSON(w, 200, respUsers)
+}
+
+func (a *apiConfig) handlerAdminUpdateUser(w http.ResponseWriter, r *http.Request) {
+	admin := r.Context().Value(middleware.AuthUser).(database.User)
+	userId, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, 400, "invalid user id")
+		return
+	}
+
+	var request struct {
+		Disabled *bool `json:"disabled"`
+		IsAdmin  *bool `json:"is_admin"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondWithError(w, 400, "error decoding request body")
+		return
+	}
+	if request.Disabled == nil && request.IsAdmin == nil {
+		respondWithError(w, 400, "nothing to update")
+		return
+	}
+	if userId == admin.ID {
+		respondWithError(w, 400, "admins can't update themselves")
+		return
+	}
+
+	user, err := a.DB.GetUser(r.Context(), userId)
+	if err != nil {
+		respondWithError(w, 404, "user not found")
+		return
+	}
+	now := time.Now()
+	if request.Disabled != nil {
+		params := database.SetUserDisabledParams{
+			ID:         user.ID,
+			DisabledAt: sql.NullTime{Time: now, Valid: *request.Disabled},
+			UpdatedAt:  now,
+		}
+		if user, err = a.DB.SetUserDisabled(r.Context(), params); err != nil {
+			log.Printf("user %v disabling error: %v\n", userId, err)
+			respondWithError(w, 500, "error updating user")
+			return
+		}
+		a.adminAudit(r, "user.disable", user.ID, map[string]interface{}{"disabled": *request.Disabled})
+	}
+	if request.IsAdmin != nil {
+		params := database.SetUserAdminParams{ID: user.ID, IsAdmin: *request.IsAdmin, UpdatedAt: now}
+		if user, err = a.DB.SetUserAdmin(r.Context(), params); err != nil {
+			log.Printf("user %v admin update error: %v\n", userId, err)
+			respondWithError(w, 500, "error updating user")
+			return
+		}
+		a.adminAudit(r, "user.admin", user.ID, map[string]interface{}{"is_admin": *request.IsAdmin})
+	}
+
+	respondWithJSON(w, 200, dbUserToAdminUser(user))
+}
+
+type adminFeedResponse struct {
+	feedResponse
+	Followers     int64 `json:"followers"`
+	Fetches       int64 `json:"fetches"`
+	FailedFetches int64 `json:"failed_fetches"`
+	AvgDurationMs int32 `json:"avg_duration_ms"`
+	PostsInserted int64 `json:"posts_inserted"`
+}
+
+func (a *apiConfig) handlerAdminListFeeds(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := pageParams(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	rows, err := a.DB.ListFeedsWithStats(r.Context(), database.ListFeedsWithStatsParams{Limit: limit, Offset: offset})
+	if err != nil {
+		log.Printf("feeds stats listing error: %v\n", err)
+		respondWithError(w, 500, "error retrieving feeds")
+		return
+	}
+
+	respFeeds := make([]adminFeedResponse, 0, len(rows))
+	for _, o := range rows {
+		respFeeds = append(respFeeds, adminFeedResponse{
+			feedResponse:  dbFeedToFeed(o.Feed),
+			Followers:     o.Followers,
+			Fetches:       o.Fetches,
+			FailedFetches: o.FailedFetches,
+			AvgDurationMs: o.AvgDurationMs,
+			PostsInserted: o.PostsInserted,
+		})
+	}
+	respondWithJSON(w, 200, respFeeds)
+}
+
+func (a *apiConfig) handlerAdminUpdateFeed(w http.ResponseWriter, r *http.Request) {
+	feedId, err := uuid.Parse(r.PathValue("feedID"))
+	if err != nil {
+		respondWithError(w, 400, "invalid feed id")
+		return
+	}
+
+	var request struct {
+		Disabled *bool `json:"disabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondWithError(w, 400, "error decoding request body")
+		return
+	}
+	if request.Disabled == nil {
+		respondWithError(w, 400, "nothing to update")
+		return
+	}
+
+	now := time.Now()
+	params := database.SetFeedDisabledParams{
+		ID:         feedId,
+		DisabledAt: sql.NullTime{Time: now, Valid: *request.Disabled},
+		UpdatedAt:  now,
+	}
+	feed, err := a.DB.SetFeedDisabled(r.Context(), params)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "feed not found")
+		return
+	}
+	if err != nil {
+		log.Printf("feed %v disabling error: %v\n", feedId, err)
+		respondWithError(w, 500, "error updating feed")
+		return
+	}
+	a.adminAudit(r, "feed.disable", feed.ID, map[string]interface{}{"disabled": *request.Disabled})
+
+	respondWithJSON(w, 200, dbFeedToFeed(feed))
+}
+
+// handlerAdminRefreshFeed schedules the feed for the next poll of the
+// worker, reviving it if it was dead. The poll happens even if the feed has
+// an active WebSub lease.
+func (a *apiConfig) handlerAdminRefreshFeed(w http.ResponseWriter, r *http.Request) {
+	feedId, err := uuid.Parse(r.PathValue("feedID"))
+	if err != nil {
+		respondWithError(w, 400, "invalid feed id")
+		return
+	}
+
+	feed, err := a.DB.GetFeed(r.Context(), feedId)
+	if err != nil {
+		respondWithError(w, 404, "feed not found")
+		return
+	}
+	if feed.DisabledAt.Valid {
+		respondWithError(w, 409, "feed is disabled")
+		return
+	}
+
+	params := database.ScheduleFeedFetchParams{ID: feed.ID, NextFetchAt: sql.NullTime{Time: time.Now(), Valid: true}}
+	if feed, err = a.DB.ScheduleFeedFetch(r.Context(), params); err != nil {
+		log.Printf("feed %v refresh error: %v\n", feedId, err)
+		respondWithError(w, 500, "error refreshing feed")
+		return
+	}
+	a.adminAudit(r, "feed.refresh", feed.ID, nil)
+
+	respondWithJSON(w, 202, dbFeedToFeed(feed))
+}
+
+// handlerAdminDeletePost deletes a post. Its url is remembered so the post
+// isn't fetched again.
+func (a *apiConfig) handlerAdminDeletePost(w http.ResponseWriter, r *http.Request) {
+	postId, err := uuid.Parse(r.PathValue("postID"))
+	if err != nil {
+		respondWithError(w, 400, "invalid post id")
+		return
+	}
+
+	deleted, err := a.DB.DeletePost(r.Context(), database.DeletePostParams{ID: postId, DeletedAt: time.Now()})
+	if err != nil {
+		log.Printf("post %v deletion error: %v\n", postId, err)
+		respondWithError(w, 500, "error deleting post")
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, 404, "post not found")
+		return
+	}
+	a.adminAudit(r, "post.delete", postId, nil)
+
+	respondWithJSON(w, 204, struct{}{})
+}
+
+type workerStatusResponse struct {
+	Feeds                    int64      `json:"feeds"`
+	DeadFeeds                int64      `json:"dead_feeds"`
+	DisabledFeeds            int64      `json:"disabled_feeds"`
+	DueFeeds                 int64      `json:"due_feeds"`
+	RecentFetches            int64      `json:"recent_fetches"`
+	RecentFailedFetches      int64      `json:"recent_failed_fetches"`
+	LastFetchedAt            *time.Time `json:"last_fetched_at"`
+	PendingWebhookDeliveries int64      `json:"pending_webhook_deliveries"`
+}
+
+// handlerAdminWorkerStatus reports the state of the fetch and webhook
+// workers, as seen from the database since they may run elsewhere. The
+// recent fetches are those of the last workerStatsWindow.
+func (a *apiConfig) handlerAdminWorkerStatus(w http.ResponseWriter, r *http.Request) {
+	stats, err := a.DB.GetWorkerStats(r.Context(), time.Now().Add(-workerStatsWindow))
+	if err != nil {
+		log.Printf("worker stats error: %v\n", err)
+		respondWithError(w, 500, "error retrieving worker status")
+		return
+	}
+
+	var lastFetchedAt *time.Time
+	if stats.LastFetchedAt.Valid {
+		lastFetchedAt = &stats.LastFetchedAt.Time
+	}
+	respondWithJSON(w, 200, workerStatusResponse{
+		Feeds:                    stats.Feeds,
+		DeadFeeds:                stats.DeadFeeds,
+		DisabledFeeds:            stats.DisabledFeeds,
+		DueFeeds:                 stats.DueFeeds,
+		RecentFetches:            stats.RecentFetches,
+		RecentFailedFetches:      stats.RecentFailedFetches,
+		LastFetchedAt:            lastFetchedAt,
+		PendingWebhookDeliveries: stats.PendingWebhookDeliveries,
+	})
+}
+
+// maxInviteCodeUses bounds the uses of an invite code.
+const maxInviteCodeUses = 1000
+
+type inviteCodeResponse struct {
+	Id         string     `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	CreatedBy  *string    `json:"created_by"`
+	CodePrefix string     `json:"code_prefix"`
+	MaxUses    int32      `json:"max_uses"`
+	Uses       int32      `json:"uses"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	// Code is only returned when the invite code is created.
+	Code string `json:"code,omitempty"`
+}
+
+func dbInviteCodeToInviteCode(o database.InviteCode) inviteCodeResponse {
+	resp := inviteCodeResponse{
+		Id:         o.ID.String(),
+		CreatedAt:  o.CreatedAt,
+		CodePrefix: o.CodePrefix,
+		MaxUses:    o.MaxUses,
+		Uses:       o.Uses,
+	}
+	if o.CreatedBy.Valid {
+		createdBy := o.CreatedBy.UUID.String()
+		resp.CreatedBy = &createdBy
+	}
+	if o.ExpiresAt.Valid {
+		resp.ExpiresAt = &o.ExpiresAt.Time
+	}
+	if o.RevokedAt.Valid {
+		resp.RevokedAt = &o.RevokedAt.Time
+	}
+	return resp
+}
+
+func (a *apiConfig) handlerAdminCreateInviteCode(w http.ResponseWriter, r *http.Request) {
+	admin := r.Context().Value(middleware.AuthUser).(database.User)
+
+	request := struct {
+		MaxUses   int32      `json:"max_uses"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}{MaxUses: 1}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+		respondWithError(w, 400, "error decoding request body")
+		return
+	}
+	if request.MaxUses < 1 || request.MaxUses > maxInviteCodeUses {
+		respondWithError(w, 400, "invalid max_uses")
+		return
+	}
+	now := time.Now()
+	var expiresAt sql.NullTime
+	if request.ExpiresAt != nil {
+		if !request.ExpiresAt.After(now) {
+			respondWithError(w, 400, "expires_at must be in the future")
+			return
+		}
+		expiresAt = sql.NullTime{Time: *request.ExpiresAt, Valid: true}
+	}
+
+	code, hash, prefix, err := newApiKey()
+	if err != nil {
+		respondWithError(w, 500, "error creating invite code")
+		return
+	}
+	params := database.CreateInviteCodeParams{
+		ID:         uuid.New(),
+		CreatedAt:  now,
+		CreatedBy:  uuid.NullUUID{UUID: admin.ID, Valid: true},
+		CodeHash:   hash,
+		CodePrefix: prefix,
+		MaxUses:    request.MaxUses,
+		ExpiresAt:  expiresAt,
+	}
+	inviteCode, err := a.DB.CreateInviteCode(r.Context(), params)
+	if err != nil {
+		log.Printf("invite code creation error: %v\n", err)
+		respondWithError(w, 500, "error creating invite code")
+		return
+	}
+	a.adminAudit(r, "invite_code.create", inviteCode.ID, map[string]interface{}{"max_uses": request.MaxUses})
+
+	resp := dbInviteCodeToInviteCode(inviteCode)
+	resp.Code = code
+	respondWithJSON(w, 201, resp)
+}
+
+func (a *apiConfig) handlerAdminListInviteCodes(w http.ResponseWriter, r *http.Request) {
+	codes, err := a.DB.ListInviteCodes(r.Context())
+	if err != nil {
+		respondWithError(w, 500, "error retrieving invite codes")
+		return
+	}
+
+	respCodes := make([]inviteCodeResponse, 0, len(codes))
+	for _, o := range codes {
+		respCodes = append(respCodes, dbInviteCodeToInviteCode(o))
+	}
+	respondWithJSON(w, 200, respCodes)
+}
+
+func (a *apiConfig) handlerAdminRevokeInviteCode(w http.ResponseWriter, r *http.Request) {
+	codeId, err := uuid.Parse(r.PathValue("inviteCodeID"))
+	if err != nil {
+		respondWithError(w, 400, "invalid invite code id")
+		return
+	}
+
+	params := database.RevokeInviteCodeParams{ID: codeId, RevokedAt: sql.NullTime{Time: time.Now(), Valid: true}}
+	revoked, err := a.DB.RevokeInviteCode(r.Context(), params)
+	if err != nil {
+		respondWithError(w, 500, "error revoking invite code")
+		return
+	}
+	if revoked == 0 {
+		respondWithError(w, 404, "invite code not found")
+		return
+	}
+	a.adminAudit(r, "invite_code.revoke", codeId, nil)
+
+	respondWithJSON(w, 204, struct{}{})
+}
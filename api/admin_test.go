@@ -0,0 +1,306 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sp3dr4/bloggogrator/api/middleware"
+	"github.com/sp3dr4/bloggogrator/internal/database"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func setupAdmin() database.User {
+	admin := setupUser()
+	admin.IsAdmin = true
+	return admin
+}
+
+func setupAdminTest(t *testing.T, method, path, body string) (*httptest.ResponseRecorder, *http.Request) {
+	t.Helper()
+	return setupApiKeyTest(t, setupAdmin(), []string{middleware.ScopeAll}, method, path, body)
+}
+
+func TestRequireAdmin(t *testing.T) {
+	tests := map[string]struct {
+		user   database.User
+		scopes []string
+		code   int
+	}{
+		"admin":             {setupAdmin(), []string{middleware.ScopeAll}, http.StatusNoContent},
+		"admin scoped key":  {setupAdmin(), []string{scopeAdmin}, http.StatusNoContent},
+		"admin without key": {setupAdmin(), []string{scopeWriteAccount}, http.StatusForbidden},
+		"user":              {setupUser(), []string{middleware.ScopeAll}, http.StatusForbidden},
+		"user admin scope":  {setupUser(), []string{scopeAdmin}, http.StatusForbidden},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			fetcher := func(ctx context.Context, scheme, token string) (interface{}, []string, error) {
+				return tc.user, tc.scopes, nil
+			}
+			handler := middleware.CreateStack(
+				middleware.AuthFactory(fetcher),
+				middleware.RequireAdmin(isAdmin),
+				middleware.RequireScope(scopeAdmin),
+			)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }))
+			req := httptest.NewRequest(http.MethodGet, "/worker", nil)
+			req.Header.Set("Authorization", "ApiKey k3y")
+			rw := httptest.NewRecorder()
+
+			handler.ServeHTTP(rw, req)
+
+			require.Equal(t, tc.code, rw.Code)
+		})
+	}
+}
+
+func TestAdminUpdateUserHandler(t *testing.T) {
+	t.Run("disable user", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		user := setupUser()
+		disabled := user
+		disabled.DisabledAt = sql.NullTime{Time: now, Valid: true}
+		mockDbApi.On("GetUser", mock.Anything, user.ID).Return(user, nil)
+		mockDbApi.On("SetUserDisabled", mock.Anything, mock.MatchedBy(func(p database.SetUserDisabledParams) bool {
+			return p.ID == user.ID && p.DisabledAt.Valid
+		})).Return(disabled, nil)
+		mockDbApi.On("CreateAuditEvent", mock.Anything, mock.MatchedBy(func(p database.CreateAuditEventParams) bool {
+			var details map[string]interface{}
+			require.NoError(t, json.Unmarshal(p.Details, &details))
+			return p.Action == auditAdminAction && details["operation"] == "user.disable" && details["target_id"] == user.ID.String()
+		})).Return(database.AuditEvent{}, nil)
+		rw, req := setupAdminTest(t, http.MethodPatch, "/v1/admin/users/"+user.ID.String(), `{"disabled":true}`)
+		req.SetPathValue("userID", user.ID.String())
+
+		testApi.handlerAdminUpdateUser(rw, req)
+
+		require.Equal(t, http.StatusOK, rw.Code)
+		var resp adminUserResponse
+		require.NoError(t, json.NewDecoder(rw.Body).Decode(&resp))
+		require.NotNil(t, resp.DisabledAt)
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	t.Run("return 400 on self update", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		admin := setupAdmin()
+		rw, req := setupApiKeyTest(t, admin, []string{middleware.ScopeAll}, http.MethodPatch, "/v1/admin/users/"+admin.ID.String(), `{"is_admin":false}`)
+		req.SetPathValue("userID", admin.ID.String())
+
+		testApi.handlerAdminUpdateUser(rw, req)
+
+		compareError(t, rw, http.StatusBadRequest, "admins can't update themselves")
+
+		mockDbApi.AssertExpectations(t)
+	})
+}
+
+func TestAdminRefreshFeedHandler(t *testing.T) {
+	t.Run("return 202", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		feed := setupFeed()
+		mockDbApi.On("GetFeed", mock.Anything, feed.ID).Return(feed, nil)
+		mockDbApi.On("ScheduleFeedFetch", mock.Anything, mock.MatchedBy(func(p database.ScheduleFeedFetchParams) bool {
+			return p.ID == feed.ID && p.NextFetchAt.Valid
+		})).Return(feed, nil)
+		mockDbApi.On("CreateAuditEvent", mock.Anything, mock.Anything).Return(database.AuditEvent{}, nil)
+		rw, req := setupAdminTest(t, http.MethodPost, "/v1/admin/feeds/"+feed.ID.String()+"/refresh", "")
+		req.SetPathValue("feedID", feed.ID.String())
+
+		testApi.handlerAdminRefreshFeed(rw, req)
+
+		require.Equal(t, http.StatusAccepted, rw.Code)
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	t.Run("return 409 on disabled feed", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		feed := setupFeed()
+		feed.DisabledAt = sql.NullTime{Time: now, Valid: true}
+		mockDbApi.On("GetFeed", mock.Anything, feed.ID).Return(feed, nil)
+		rw, req := setupAdminTest(t, http.MethodPost, "/v1/admin/feeds/"+feed.ID.String()+"/refresh", "")
+		req.SetPathValue("feedID", feed.ID.String())
+
+		testApi.handlerAdminRefreshFeed(rw, req)
+
+		compareError(t, rw, http.StatusConflict, "feed is disabled")
+
+		mockDbApi.AssertExpectations(t)
+	})
+}
+
+func TestAdminDeletePostHandler(t *testing.T) {
+	tests := map[string]struct {
+		deleted int64
+		code    int
+	}{
+		"deleted":   {1, http.StatusNoContent},
+		"not found": {0, http.StatusNotFound},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockDbApi := new(MockedDbApi)
+			testApi := apiConfig{DB: mockDbApi}
+			postId := uuid.New()
+			mockDbApi.On("DeletePost", mock.Anything, mock.MatchedBy(func(p database.DeletePostParams) bool {
+				return p.ID == postId
+			})).Return(tc.deleted, nil)
+			if tc.deleted > 0 {
+				mockDbApi.On("CreateAuditEvent", mock.Anything, mock.Anything).Return(database.AuditEvent{}, nil)
+			}
+			rw, req := setupAdminTest(t, http.MethodDelete, "/v1/admin/posts/"+postId.String(), "")
+			req.SetPathValue("postID", postId.String())
+
+			testApi.handlerAdminDeletePost(rw, req)
+
+			require.Equal(t, tc.code, rw.Code)
+
+			mockDbApi.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAdminWorkerStatusHandler(t *testing.T) {
+	mockDbApi := new(MockedDbApi)
+	testApi := apiConfig{DB: mockDbApi}
+	stats := database.GetWorkerStatsRow{Feeds: 10, DeadFeeds: 1, DueFeeds: 3, RecentFetches: 42}
+	mockDbApi.On("GetWorkerStats", mock.Anything, mock.MatchedBy(func(since time.Time) bool {
+		return time.Since(since) >= workerStatsWindow
+	})).Return(stats, nil)
+	rw, req := setupAdminTest(t, http.MethodGet, "/v1/admin/worker", "")
+
+	testApi.handlerAdminWorkerStatus(rw, req)
+
+	require.Equal(t, http.StatusOK, rw.Code)
+	var resp workerStatusResponse
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&resp))
+	require.Equal(t, workerStatusResponse{Feeds: 10, DeadFeeds: 1, DueFeeds: 3, RecentFetches: 42}, resp)
+
+	mockDbApi.AssertExpectations(t)
+}
+
+func TestAdminCreateInviteCodeHandler(t *testing.T) {
+	t.Run("return 201 with the code", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		var created database.CreateInviteCodeParams
+		mockDbApi.On("CreateInviteCode", mock.Anything, mock.MatchedBy(func(p database.CreateInviteCodeParams) bool {
+			created = p
+			return p.MaxUses == 5 && p.ExpiresAt.Valid && p.CreatedBy.Valid
+		})).Return(database.InviteCode{ID: uuid.New(), MaxUses: 5}, nil)
+		mockDbApi.On("CreateAuditEvent", mock.Anything, mock.Anything).Return(database.AuditEvent{}, nil)
+		body := mustJSON(t, map[string]interface{}{"max_uses": 5, "expires_at": time.Now().Add(time.Hour)})
+		rw, req := setupAdminTest(t, http.MethodPost, "/v1/admin/invite_codes", body)
+
+		testApi.handlerAdminCreateInviteCode(rw, req)
+
+		require.Equal(t, http.StatusCreated, rw.Code)
+		var resp inviteCodeResponse
+		require.NoError(t, json.NewDecoder(rw.Body).Decode(&resp))
+		require.Equal(t, hashApiKey(resp.Code), created.CodeHash)
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	tests := map[string]struct {
+		body   string
+		errMsg string
+	}{
+		"no uses":      {`{"max_uses":0}`, "invalid max_uses"},
+		"past expiry":  {`{"expires_at":"2000-01-01T00:00:00Z"}`, "expires_at must be in the future"},
+		"invalid body": {`{"max_uses":"many"}`, "error decoding request body"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockDbApi := new(MockedDbApi)
+			testApi := apiConfig{DB: mockDbApi}
+			rw, req := setupAdminTest(t, http.MethodPost, "/v1/admin/invite_codes", tc.body)
+
+			testApi.handlerAdminCreateInviteCode(rw, req)
+
+			compareError(t, rw, http.StatusBadRequest, tc.errMsg)
+
+			mockDbApi.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCreateUserRegistrationModes(t *testing.T) {
+	newRequest := func(t *testing.T, body string) (*httptest.ResponseRecorder, *http.Request) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(body))
+		require.NoError(t, err)
+		return httptest.NewRecorder(), req
+	}
+
+	t.Run("closed", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi, Registration: RegistrationClosed}
+		rw, req := newRequest(t, `{"name":"FooBar"}`)
+
+		testApi.handlerCreateUser(rw, req)
+
+		compareError(t, rw, http.StatusForbidden, "registration is closed")
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	t.Run("invite with valid code", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi, Registration: RegistrationInvite}
+		code := database.InviteCode{ID: uuid.New()}
+		user := setupUser()
+		mockDbApi.On("UseInviteCode", mock.Anything, hashApiKey("inv1te")).Return(code, nil)
+		mockDbApi.On("CreateUser", mock.Anything, mock.MatchedBy(func(p database.CreateUserParams) bool {
+			return p.InviteCodeID.UUID == code.ID && p.InviteCodeID.Valid
+		})).Return(user, nil)
+		rw, req := newRequest(t, `{"name":"FooBar","invite_code":"inv1te"}`)
+
+		testApi.handlerCreateUser(rw, req)
+
+		require.Equal(t, http.StatusCreated, rw.Code)
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	t.Run("invite with used up code", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi, Registration: RegistrationInvite}
+		mockDbApi.On("UseInviteCode", mock.Anything, hashApiKey("inv1te")).Return(database.InviteCode{}, sql.ErrNoRows)
+		rw, req := newRequest(t, `{"name":"FooBar","invite_code":"inv1te"}`)
+
+		testApi.handlerCreateUser(rw, req)
+
+		compareError(t, rw, http.StatusForbidden, "invalid invite code")
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	t.Run("invite released on failure", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi, Registration: RegistrationInvite}
+		code := database.InviteCode{ID: uuid.New()}
+		mockDbApi.On("UseInviteCode", mock.Anything, hashApiKey("inv1te")).Return(code, nil)
+		mockDbApi.On("CreateUser", mock.Anything, mock.Anything).Return(database.User{}, context.DeadlineExceeded)
+		mockDbApi.On("ReleaseInviteCode", mock.Anything, code.ID).Return(nil)
+		rw, req := newRequest(t, `{"name":"FooBar","invite_code":"inv1te"}`)
+
+		testApi.handlerCreateUser(rw, req)
+
+		compareError(t, rw, http.StatusInternalServerError, "error creating user")
+
+		mockDbApi.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,245 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sp3dr4/bloggogrator/api/middleware"
+	"github.com/sp3dr4/bloggogrator/internal/database"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Scopes granted by the API keys. A write scope also grants the read scope
+// of the same resource.
+const (
+	scopeReadAccount    = "read:account"
+	scopeWriteAccount   = "write:account"
+	scopeReadFeeds      = "read:feeds"
+	scopeWriteFeeds     = "write:feeds"
+	scopeReadPosts      = "read:posts"
+	scopeWritePosts     = "write:posts"
+	scopeReadWebhooks   = "read:webhooks"
+	scopeWriteWebhooks  = "write:webhooks"
+	scopeReadPublished  = "read:published"
+	scopeWritePublished = "write:published"
+	scopeReadDigest     = "read:digest"
+	scopeWriteDigest    = "write:digest"
+	// scopeAdmin is only effective on the keys of admins.
+	scopeAdmin = "admin"
+)
+
+var validScopes = map[string]bool{
+	middleware.ScopeAll: true,
+	scopeReadAccount:    true,
+	scopeWriteAccount:   true,
+	scopeReadFeeds:      true,
+	scopeWriteFeeds:     true,
+	scopeReadPosts:      true,
+	scopeWritePosts:     true,
+	scopeReadWebhooks:   true,
+	scopeWriteWebhooks:  true,
+	scopeReadPublished:  true,
+	scopeWritePublished: true,
+	scopeReadDigest:     true,
+	scopeWriteDigest:    true,
+	scopeAdmin:          true,
+}
+
+// Actions of the audit events.
+const (
+	auditApiKeyRotated = "api_key.rotated"
+	auditLogin         = "session.created"
+	auditLogout        = "session.revoked"
+	auditAdminAction   = "admin.action"
+	auditUserUpdated   = "user.updated"
+	auditUserDeleted   = "user.deleted"
+)
+
+// apiKeyTouchInterval limits the writes of last_used_at to one per key and
+// interval.
+const apiKeyTouchInterval = time.Minute
+
+// apiKeyPrefixLength is the length of the key prefix kept in clear to
+// identify the keys.
+const apiKeyPrefixLength = 8
+
+// Lifetimes of the session tokens. The refresh token is replaced, and its
+// lifetime renewed, each time it's used.
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// Bounds of the passwords. bcrypt ignores the bytes past the 72nd.
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 72
+)
+
+// dummyPasswordHash is compared against on logins with an unknown email, so
+// they take as long as the others.
+const dummyPasswordHash = "$2a$10$s/s4DbaIDlsdmunKxATrCOOV935GO1V5POpakidaWXmhjCk0HV0BG"
+
+// hashApiKey returns the digest a key is stored and looked up by. Keys are
+// random, so they need neither salt nor a slow hash.
+func hashApiKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// newApiKey returns a new key, its digest and its prefix. The key itself is
+// never stored.
+func newApiKey() (key, hash, prefix string, err error) {
+	key, err = newToken()
+	if err != nil {
+		return "", "", "", err
+	}
+	return key, hashApiKey(key), key[:apiKeyPrefixLength], nil
+}
+
+// authenticate returns the user owning apiKey and the scopes it grants. The
+// key created with the user grants every scope.
+func (a *apiConfig) authenticate(ctx context.Context, apiKey string) (database.User, []string, error) {
+	hash := hashApiKey(apiKey)
+	row, err := a.DB.GetUserByScopedApiKeyHash(ctx, hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		user, err := a.DB.GetUserByApiKeyHash(ctx, hash)
+		if err != nil {
+			return database.User{}, nil, err
+		}
+		return user, []string{middleware.ScopeAll}, nil
+	}
+	if err != nil {
+		return database.User{}, nil, err
+	}
+
+	now := time.Now()
+	params := database.TouchApiKeyParams{
+		ID:         row.ApiKey.ID,
+		UsedAt:     sql.NullTime{Time: now, Valid: true},
+		UsedBefore: sql.NullTime{Time: now.Add(-apiKeyTouchInterval), Valid: true},
+	}
+	if err := a.DB.TouchApiKey(ctx, params); err != nil {
+		log.Printf("err touching api key %v: %v\n", row.ApiKey.ID, err)
+	}
+	return row.User, row.ApiKey.Scopes, nil
+}
+
+// hashPassword returns the bcrypt hash of password.
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// checkPassword reports whether password matches the hash of the user. Users
+// without a password never match.
+func checkPassword(user database.User, password string) bool {
+	hash := dummyPasswordHash
+	if user.PasswordHash.Valid {
+		hash = user.PasswordHash.String
+	}
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	return err == nil && user.PasswordHash.Valid
+}
+
+// sessionTokens are the tokens of a session, returned to the client once.
+type sessionTokens struct {
+	AccessToken      string
+	AccessExpiresAt  time.Time
+	RefreshToken     string
+	RefreshExpiresAt time.Time
+}
+
+// newSessionTokens returns new tokens valid from now. Like the API keys,
+// they are only stored as digests.
+func newSessionTokens(now time.Time) (sessionTokens, error) {
+	access, err := newToken()
+	if err != nil {
+		return sessionTokens{}, err
+	}
+	refresh, err := newToken()
+	if err != nil {
+		return sessionTokens{}, err
+	}
+	return sessionTokens{
+		AccessToken:      access,
+		AccessExpiresAt:  now.Add(accessTokenTTL),
+		RefreshToken:     refresh,
+		RefreshExpiresAt: now.Add(refreshTokenTTL),
+	}, nil
+}
+
+// authenticateSession returns the user owning the access token of a session.
+// Sessions grant every scope.
+func (a *apiConfig) authenticateSession(ctx context.Context, accessToken string) (database.User, []string, error) {
+	row, err := a.DB.GetUserBySessionAccessHash(ctx, hashApiKey(accessToken))
+	if err != nil {
+		return database.User{}, nil, err
+	}
+	return row.User, []string{middleware.ScopeAll}, nil
+}
+
+// fetchUser authenticates the token of an Authorization header scheme.
+func (a *apiConfig) fetchUser(ctx context.Context, scheme, token string) (database.User, []string, error) {
+	if scheme == middleware.SchemeBearer {
+		return a.authenticateSession(ctx, token)
+	}
+	return a.authenticate(ctx, token)
+}
+
+// stillAuthorized reports whether the credentials of r still authenticate
+// user with scope. Long-lived requests check it to end once the key is
+// rotated or revoked, the session ends or the account is disabled or
+// deleted.
+func (a *apiConfig) stillAuthorized(r *http.Request, user database.User, scope string) bool {
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	current, scopes, err := a.fetchUser(r.Context(), scheme, token)
+	if err != nil || current.ID != user.ID {
+		return false
+	}
+	return middleware.HasScope(context.WithValue(r.Context(), middleware.AuthScopes, scopes), scope)
+}
+
+// isAdmin reports whether the authenticated user is an admin.
+func isAdmin(user interface{}) bool {
+	u, ok := user.(database.User)
+	return ok && u.IsAdmin
+}
+
+// audit records an action of the user. Failures are only logged: the
+// action already happened.
+func (a *apiConfig) audit(r *http.Request, userId uuid.UUID, action string, details map[string]interface{}) {
+	dat, err := json.Marshal(details)
+	if err != nil {
+		log.Printf("err encoding audit event %s of user %v: %v\n", action, userId, err)
+		return
+	}
+	var remoteAddr sql.NullString
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		remoteAddr = sql.NullString{String: host, Valid: true}
+	}
+	params := database.CreateAuditEventParams{
+		ID:         uuid.New(),
+		CreatedAt:  time.Now(),
+		UserID:     userId,
+		Action:     action,
+		RemoteAddr: remoteAddr,
+		Details:    dat,
+	}
+	if _, err := a.DB.CreateAuditEvent(r.Context(), params); err != nil {
+		log.Printf("err recording audit event %s of user %v: %v\n", action, userId, err)
+	}
+}
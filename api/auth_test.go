@@ -0,0 +1,525 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sp3dr4/bloggogrator/api/middleware"
+	"github.com/sp3dr4/bloggogrator/internal/database"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthenticate(t *testing.T) {
+	t.Run("scoped key", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		user := setupUser()
+		apiKey := database.ApiKey{ID: uuid.New(), UserID: user.ID, Scopes: []string{scopeReadPosts}}
+		row := database.GetUserByScopedApiKeyHashRow{User: user, ApiKey: apiKey}
+		mockDbApi.On("GetUserByScopedApiKeyHash", mock.Anything, hashApiKey("k3y")).Return(row, nil)
+		mockDbApi.On("TouchApiKey", mock.Anything, mock.MatchedBy(func(p database.TouchApiKeyParams) bool {
+			return p.ID == apiKey.ID && p.UsedAt.Time.Sub(p.UsedBefore.Time) == apiKeyTouchInterval
+		})).Return(nil)
+
+		got, scopes, err := testApi.authenticate(context.Background(), "k3y")
+
+		require.NoError(t, err)
+		require.Equal(t, user, got)
+		require.Equal(t, []string{scopeReadPosts}, scopes)
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	t.Run("user key", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		user := setupUser()
+		mockDbApi.On("GetUserByScopedApiKeyHash", mock.Anything, hashApiKey("k3y")).Return(database.GetUserByScopedApiKeyHashRow{}, sql.ErrNoRows)
+		mockDbApi.On("GetUserByApiKeyHash", mock.Anything, hashApiKey("k3y")).Return(user, nil)
+
+		got, scopes, err := testApi.authenticate(context.Background(), "k3y")
+
+		require.NoError(t, err)
+		require.Equal(t, user, got)
+		require.Equal(t, []string{middleware.ScopeAll}, scopes)
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		mockDbApi.On("GetUserByScopedApiKeyHash", mock.Anything, hashApiKey("nope")).Return(database.GetUserByScopedApiKeyHashRow{}, sql.ErrNoRows)
+		mockDbApi.On("GetUserByApiKeyHash", mock.Anything, hashApiKey("nope")).Return(database.User{}, sql.ErrNoRows)
+
+		_, _, err := testApi.authenticate(context.Background(), "nope")
+
+		require.ErrorIs(t, err, sql.ErrNoRows)
+
+		mockDbApi.AssertExpectations(t)
+	})
+}
+
+func TestStillAuthorized(t *testing.T) {
+	user := setupUser()
+	cases := map[string]struct {
+		scopes []string
+		err    error
+		userId uuid.UUID
+		want   bool
+	}{
+		"valid key":     {scopes: []string{scopeReadPosts}, userId: user.ID, want: true},
+		"revoked key":   {err: sql.ErrNoRows, want: false},
+		"missing scope": {scopes: []string{scopeReadFeeds}, userId: user.ID, want: false},
+		"other user":    {scopes: []string{scopeReadPosts}, userId: uuid.New(), want: false},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			mockDbApi := new(MockedDbApi)
+			testApi := apiConfig{DB: mockDbApi}
+			row := database.GetUserByScopedApiKeyHashRow{
+				User:   database.User{ID: tc.userId},
+				ApiKey: database.ApiKey{ID: uuid.New(), Scopes: tc.scopes},
+			}
+			mockDbApi.On("GetUserByScopedApiKeyHash", mock.Anything, hashApiKey("k3y")).Return(row, tc.err)
+			mockDbApi.On("GetUserByApiKeyHash", mock.Anything, hashApiKey("k3y")).Return(database.User{}, tc.err)
+			mockDbApi.On("TouchApiKey", mock.Anything, mock.Anything).Return(nil)
+			r := httptest.NewRequest(http.MethodGet, "/v1/posts/stream", nil)
+			r.Header.Set("Authorization", "ApiKey k3y")
+
+			require.Equal(t, tc.want, testApi.stillAuthorized(r, user, scopeReadPosts))
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	fetcher := func(ctx context.Context, scheme, token string) (interface{}, []string, error) {
+		return setupUser(), strings.Split(token, ","), nil
+	}
+	handler := middleware.AuthFactory(fetcher)(middleware.RequireScope(scopeReadFeeds)(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) },
+	)))
+
+	tests := map[string]struct {
+		scopes string
+		code   int
+	}{
+		"exact scope":    {scopeReadFeeds, http.StatusNoContent},
+		"write scope":    {scopeWriteFeeds, http.StatusNoContent},
+		"all scopes":     {middleware.ScopeAll, http.StatusNoContent},
+		"other resource": {scopeWritePosts + "," + scopeReadAccount, http.StatusForbidden},
+		"similar scope":  {"read:feedsx", http.StatusForbidden},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/feeds", nil)
+			req.Header.Set("Authorization", "ApiKey "+tc.scopes)
+			rw := httptest.NewRecorder()
+
+			handler.ServeHTTP(rw, req)
+
+			require.Equal(t, tc.code, rw.Code)
+		})
+	}
+}
+
+func setupApiKeyTest(t *testing.T, user database.User, scopes []string, method, path, body string) (*httptest.ResponseRecorder, *http.Request) {
+	t.Helper()
+	req, err := http.NewRequest(method, path, strings.NewReader(body))
+	require.NoError(t, err)
+	ctx := context.WithValue(req.Context(), middleware.AuthUser, user)
+	ctx = context.WithValue(ctx, middleware.AuthScopes, scopes)
+	return httptest.NewRecorder(), req.WithContext(ctx)
+}
+
+func TestCreateApiKeyHandler(t *testing.T) {
+	t.Run("return 201 with the key", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		user := setupUser()
+		var created database.CreateApiKeyParams
+		mockDbApi.On("CreateApiKey", mock.Anything, mock.MatchedBy(func(p database.CreateApiKeyParams) bool {
+			created = p
+			return p.UserID == user.ID && p.Label == "ci" && len(p.Scopes) == 1 && p.Scopes[0] == scopeReadPosts && !p.ExpiresAt.Valid
+		})).Return(database.ApiKey{ID: uuid.New(), UserID: user.ID, Label: "ci", Scopes: []string{scopeReadPosts}}, nil)
+		rw, req := setupApiKeyTest(t, user, []string{scopeWritePosts}, http.MethodPost, "/v1/api_keys", `{"label":"ci","scopes":["read:posts"]}`)
+
+		testApi.handlerCreateApiKey(rw, req)
+
+		require.Equal(t, http.StatusCreated, rw.Code)
+		var resp apiKeyResponse
+		require.NoError(t, json.NewDecoder(rw.Body).Decode(&resp))
+		require.Len(t, resp.Key, 64)
+		require.Equal(t, hashApiKey(resp.Key), created.KeyHash)
+		require.Equal(t, resp.Key[:apiKeyPrefixLength], created.KeyPrefix)
+		require.Equal(t, []string{scopeReadPosts}, resp.Scopes)
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	tests := map[string]struct {
+		body   string
+		code   int
+		errMsg string
+	}{
+		"missing label":    {`{"scopes":["read:posts"]}`, http.StatusBadRequest, "invalid label"},
+		"missing scopes":   {`{"label":"ci"}`, http.StatusBadRequest, "scopes required"},
+		"unknown scope":    {`{"label":"ci","scopes":["read:everything"]}`, http.StatusBadRequest, `invalid scope "read:everything"`},
+		"scope escalation": {`{"label":"ci","scopes":["write:feeds"]}`, http.StatusForbidden, `scope "write:feeds" not granted`},
+		"past expiry":      {`{"label":"ci","scopes":["read:posts"],"expires_at":"2020-01-01T00:00:00Z"}`, http.StatusBadRequest, "expires_at must be in the future"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockDbApi := new(MockedDbApi)
+			testApi := apiConfig{DB: mockDbApi}
+			rw, req := setupApiKeyTest(t, setupUser(), []string{scopeWritePosts, scopeReadFeeds}, http.MethodPost, "/v1/api_keys", tc.body)
+
+			testApi.handlerCreateApiKey(rw, req)
+
+			compareError(t, rw, tc.code, tc.errMsg)
+
+			mockDbApi.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRevokeApiKeyHandler(t *testing.T) {
+	t.Run("return 204", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		user := setupUser()
+		apiKey := database.ApiKey{ID: uuid.New(), UserID: user.ID}
+		mockDbApi.On("GetApiKey", mock.Anything, apiKey.ID).Return(apiKey, nil)
+		mockDbApi.On("RevokeApiKey", mock.Anything, mock.MatchedBy(func(p database.RevokeApiKeyParams) bool {
+			return p.ID == apiKey.ID && p.RevokedAt.Valid
+		})).Return(nil)
+		rw, req := setupApiKeyTest(t, user, []string{middleware.ScopeAll}, http.MethodDelete, "/v1/api_keys/"+apiKey.ID.String(), "")
+		req.SetPathValue("apiKeyID", apiKey.ID.String())
+
+		testApi.handlerRevokeApiKey(rw, req)
+
+		require.Equal(t, http.StatusNoContent, rw.Code)
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	t.Run("return 403 on key of another user", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		apiKey := database.ApiKey{ID: uuid.New(), UserID: uuid.New()}
+		mockDbApi.On("GetApiKey", mock.Anything, apiKey.ID).Return(apiKey, nil)
+		rw, req := setupApiKeyTest(t, setupUser(), []string{middleware.ScopeAll}, http.MethodDelete, "/v1/api_keys/"+apiKey.ID.String(), "")
+		req.SetPathValue("apiKeyID", apiKey.ID.String())
+
+		testApi.handlerRevokeApiKey(rw, req)
+
+		compareError(t, rw, http.StatusForbidden, "operation not allowed")
+
+		mockDbApi.AssertExpectations(t)
+	})
+}
+
+func TestRotateApiKeyHandler(t *testing.T) {
+	t.Run("keep previous key for the grace period", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		user := setupUser()
+		var rotation database.RotateUserApiKeyParams
+		mockDbApi.On("RotateUserApiKey", mock.Anything, mock.MatchedBy(func(p database.RotateUserApiKeyParams) bool {
+			rotation = p
+			return p.ID == user.ID && p.PreviousApiKeyHash.String == user.ApiKeyHash &&
+				p.PreviousApiKeyExpiresAt.Time.Sub(p.UpdatedAt) == time.Hour
+		})).Return(user, nil)
+		mockDbApi.On("CreateAuditEvent", mock.Anything, mock.MatchedBy(func(p database.CreateAuditEventParams) bool {
+			var details map[string]interface{}
+			require.NoError(t, json.Unmarshal(p.Details, &details))
+			return p.UserID == user.ID && p.Action == auditApiKeyRotated && p.RemoteAddr.String == "192.0.2.1" &&
+				details["previous_prefix"] == user.ApiKeyPrefix && details["prefix"] == rotation.ApiKeyPrefix &&
+				details["previous_expires_at"] != nil
+		})).Return(database.AuditEvent{}, nil)
+		rw, req := setupApiKeyTest(t, user, []string{middleware.ScopeAll}, http.MethodPost, "/v1/users/api_key/rotate", `{"grace_period_seconds":3600}`)
+		req.RemoteAddr = "192.0.2.1:41234"
+
+		testApi.handlerRotateApiKey(rw, req)
+
+		require.Equal(t, http.StatusOK, rw.Code)
+		var resp userResponse
+		require.NoError(t, json.NewDecoder(rw.Body).Decode(&resp))
+		require.Len(t, resp.ApiKey, 64)
+		require.Equal(t, hashApiKey(resp.ApiKey), rotation.ApiKeyHash)
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	t.Run("invalidate previous key without body", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		user := setupUser()
+		mockDbApi.On("RotateUserApiKey", mock.Anything, mock.MatchedBy(func(p database.RotateUserApiKeyParams) bool {
+			return p.ID == user.ID && !p.PreviousApiKeyHash.Valid && !p.PreviousApiKeyExpiresAt.Valid
+		})).Return(user, nil)
+		mockDbApi.On("CreateAuditEvent", mock.Anything, mock.Anything).Return(database.AuditEvent{}, sql.ErrConnDone)
+		rw, req := setupApiKeyTest(t, user, []string{middleware.ScopeAll}, http.MethodPost, "/v1/users/api_key/rotate", "")
+
+		testApi.handlerRotateApiKey(rw, req)
+
+		require.Equal(t, http.StatusOK, rw.Code)
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	t.Run("return 400 on excessive grace period", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		rw, req := setupApiKeyTest(t, setupUser(), []string{middleware.ScopeAll}, http.MethodPost, "/v1/users/api_key/rotate", `{"grace_period_seconds":99999999}`)
+
+		testApi.handlerRotateApiKey(rw, req)
+
+		compareError(t, rw, http.StatusBadRequest, "invalid grace_period_seconds")
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	t.Run("return 403 on scoped key", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		rw, req := setupApiKeyTest(t, setupUser(), []string{"write:account"}, http.MethodPost, "/v1/users/api_key/rotate", "")
+
+		testApi.handlerRotateApiKey(rw, req)
+
+		compareError(t, rw, http.StatusForbidden, "operation not allowed")
+
+		mockDbApi.AssertExpectations(t)
+		mockDbApi.AssertNotCalled(t, "RotateUserApiKey", mock.Anything, mock.Anything)
+	})
+}
+
+func TestAuthFactorySchemes(t *testing.T) {
+	fetcher := func(ctx context.Context, scheme, token string) (interface{}, []string, error) {
+		if token != "t0k3n" {
+			return nil, nil, sql.ErrNoRows
+		}
+		return setupUser(), []string{scheme}, nil
+	}
+	handler := middleware.AuthFactory(fetcher)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Context().Value(middleware.AuthScopes).([]string)[0]))
+	}))
+
+	tests := map[string]struct {
+		authorization string
+		code          int
+		scheme        string
+	}{
+		"api key":        {"ApiKey t0k3n", http.StatusOK, middleware.SchemeApiKey},
+		"bearer":         {"Bearer t0k3n", http.StatusOK, middleware.SchemeBearer},
+		"unknown scheme": {"Basic t0k3n", http.StatusUnauthorized, ""},
+		"empty token":    {"Bearer  ", http.StatusUnauthorized, ""},
+		"unknown token":  {"Bearer nope", http.StatusUnauthorized, ""},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/users", nil)
+			req.Header.Set("Authorization", tc.authorization)
+			rw := httptest.NewRecorder()
+
+			handler.ServeHTTP(rw, req)
+
+			require.Equal(t, tc.code, rw.Code)
+			if tc.scheme != "" {
+				require.Equal(t, tc.scheme, rw.Body.String())
+			}
+		})
+	}
+}
+
+func TestCreateUserWithPassword(t *testing.T) {
+	t.Run("return 201 with the email", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		user := setupUser()
+		user.Email = sql.NullString{String: "foo@example.com", Valid: true}
+		var created database.CreateUserParams
+		mockDbApi.On("CreateUser", mock.Anything, mock.MatchedBy(func(p database.CreateUserParams) bool {
+			created = p
+			return p.Email == user.Email && p.PasswordHash.Valid
+		})).Return(user, nil)
+		req, err := http.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(`{"name":"FooBar","email":"Foo@example.com","password":"s3cret-pass"}`))
+		require.NoError(t, err)
+		rw := httptest.NewRecorder()
+
+		testApi.handlerCreateUser(rw, req)
+
+		require.Equal(t, http.StatusCreated, rw.Code)
+		var resp userResponse
+		require.NoError(t, json.NewDecoder(rw.Body).Decode(&resp))
+		require.Equal(t, "foo@example.com", *resp.Email)
+		user.PasswordHash = created.PasswordHash
+		require.True(t, checkPassword(user, "s3cret-pass"))
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	tests := map[string]struct {
+		body   string
+		errMsg string
+	}{
+		"missing password": {`{"name":"FooBar","email":"foo@example.com"}`, "password must be 8 to 72 bytes long"},
+		"short password":   {`{"name":"FooBar","email":"foo@example.com","password":"short"}`, "password must be 8 to 72 bytes long"},
+		"missing email":    {`{"name":"FooBar","password":"s3cret-pass"}`, "invalid email"},
+		"display name":     {`{"name":"FooBar","email":"Foo <foo@example.com>","password":"s3cret-pass"}`, "invalid email"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockDbApi := new(MockedDbApi)
+			testApi := apiConfig{DB: mockDbApi}
+			req, err := http.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(tc.body))
+			require.NoError(t, err)
+			rw := httptest.NewRecorder()
+
+			testApi.handlerCreateUser(rw, req)
+
+			compareError(t, rw, http.StatusBadRequest, tc.errMsg)
+
+			mockDbApi.AssertExpectations(t)
+		})
+	}
+}
+
+func setupLoginUser(t *testing.T, password string) database.User {
+	t.Helper()
+	hash, err := hashPassword(password)
+	require.NoError(t, err)
+	user := setupUser()
+	user.Email = sql.NullString{String: "foo@example.com", Valid: true}
+	user.PasswordHash = sql.NullString{String: hash, Valid: true}
+	return user
+}
+
+func TestLoginHandler(t *testing.T) {
+	t.Run("return 200 with the tokens", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		user := setupLoginUser(t, "s3cret-pass")
+		var created database.CreateSessionParams
+		mockDbApi.On("GetUserByEmail", mock.Anything, user.Email).Return(user, nil)
+		mockDbApi.On("CreateSession", mock.Anything, mock.MatchedBy(func(p database.CreateSessionParams) bool {
+			created = p
+			return p.UserID == user.ID && p.AccessExpiresAt.Sub(p.CreatedAt) == accessTokenTTL &&
+				p.RefreshExpiresAt.Sub(p.CreatedAt) == refreshTokenTTL
+		})).Return(database.Session{ID: uuid.New(), UserID: user.ID}, nil)
+		mockDbApi.On("CreateAuditEvent", mock.Anything, mock.MatchedBy(func(p database.CreateAuditEventParams) bool {
+			return p.UserID == user.ID && p.Action == auditLogin
+		})).Return(database.AuditEvent{}, nil)
+		req, err := http.NewRequest(http.MethodPost, "/v1/auth/login", strings.NewReader(`{"email":"FOO@example.com","password":"s3cret-pass"}`))
+		require.NoError(t, err)
+		rw := httptest.NewRecorder()
+
+		testApi.handlerLogin(rw, req)
+
+		require.Equal(t, http.StatusOK, rw.Code)
+		var resp sessionTokensResponse
+		require.NoError(t, json.NewDecoder(rw.Body).Decode(&resp))
+		require.Equal(t, middleware.SchemeBearer, resp.TokenType)
+		require.Equal(t, int64(900), resp.ExpiresIn)
+		require.Equal(t, hashApiKey(resp.AccessToken), created.AccessTokenHash)
+		require.Equal(t, hashApiKey(resp.RefreshToken), created.RefreshTokenHash)
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	tests := map[string]struct {
+		user     func(t *testing.T) (database.User, error)
+		password string
+	}{
+		"wrong password": {func(t *testing.T) (database.User, error) { return setupLoginUser(t, "s3cret-pass"), nil }, "wrong-pass"},
+		"unknown email":  {func(t *testing.T) (database.User, error) { return database.User{}, sql.ErrNoRows }, "s3cret-pass"},
+		"no password":    {func(t *testing.T) (database.User, error) { return setupUser(), nil }, ""},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockDbApi := new(MockedDbApi)
+			testApi := apiConfig{DB: mockDbApi}
+			user, err := tc.user(t)
+			mockDbApi.On("GetUserByEmail", mock.Anything, mock.Anything).Return(user, err)
+			body := mustJSON(t, map[string]string{"email": "foo@example.com", "password": tc.password})
+			req, err := http.NewRequest(http.MethodPost, "/v1/auth/login", strings.NewReader(body))
+			require.NoError(t, err)
+			rw := httptest.NewRecorder()
+
+			testApi.handlerLogin(rw, req)
+
+			compareError(t, rw, http.StatusUnauthorized, "invalid email or password")
+
+			mockDbApi.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRefreshSessionHandler(t *testing.T) {
+	t.Run("return 200 with new tokens", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		var refreshed database.RefreshSessionParams
+		mockDbApi.On("RefreshSession", mock.Anything, mock.MatchedBy(func(p database.RefreshSessionParams) bool {
+			refreshed = p
+			return p.PreviousRefreshTokenHash == hashApiKey("r3fr3sh") && p.AccessExpiresAt.Sub(p.RefreshedAt.Time) == accessTokenTTL
+		})).Return(database.Session{ID: uuid.New()}, nil)
+		req, err := http.NewRequest(http.MethodPost, "/v1/auth/refresh", strings.NewReader(`{"refresh_token":"r3fr3sh"}`))
+		require.NoError(t, err)
+		rw := httptest.NewRecorder()
+
+		testApi.handlerRefreshSession(rw, req)
+
+		require.Equal(t, http.StatusOK, rw.Code)
+		var resp sessionTokensResponse
+		require.NoError(t, json.NewDecoder(rw.Body).Decode(&resp))
+		require.Equal(t, hashApiKey(resp.RefreshToken), refreshed.RefreshTokenHash)
+		require.NotEqual(t, "r3fr3sh", resp.RefreshToken)
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	t.Run("return 401 on unknown token", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		mockDbApi.On("RefreshSession", mock.Anything, mock.Anything).Return(database.Session{}, sql.ErrNoRows)
+		req, err := http.NewRequest(http.MethodPost, "/v1/auth/refresh", strings.NewReader(`{"refresh_token":"used"}`))
+		require.NoError(t, err)
+		rw := httptest.NewRecorder()
+
+		testApi.handlerRefreshSession(rw, req)
+
+		compareError(t, rw, http.StatusUnauthorized, "invalid refresh token")
+
+		mockDbApi.AssertExpectations(t)
+	})
+}
+
+func TestLogoutHandler(t *testing.T) {
+	mockDbApi := new(MockedDbApi)
+	testApi := apiConfig{DB: mockDbApi}
+	session := database.Session{ID: uuid.New(), UserID: uuid.New()}
+	mockDbApi.On("RevokeSessionByRefreshHash", mock.Anything, mock.MatchedBy(func(p database.RevokeSessionByRefreshHashParams) bool {
+		return p.RefreshTokenHash == hashApiKey("r3fr3sh") && p.RevokedAt.Valid
+	})).Return(session, nil)
+	mockDbApi.On("CreateAuditEvent", mock.Anything, mock.MatchedBy(func(p database.CreateAuditEventParams) bool {
+		return p.UserID == session.UserID && p.Action == auditLogout
+	})).Return(database.AuditEvent{}, nil)
+	req, err := http.NewRequest(http.MethodPost, "/v1/auth/logout", strings.NewReader(`{"refresh_token":"r3fr3sh"}`))
+	require.NoError(t, err)
+	rw := httptest.NewRecorder()
+
+	testApi.handlerLogout(rw, req)
+
+	require.Equal(t, http.StatusNoContent, rw.Code)
+
+	mockDbApi.AssertExpectations(t)
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// PostEvent announces a post inserted by the worker, as notified by the
+// posts table trigger.
+type PostEvent struct {
+	ID     uuid.UUID `json:"id"`
+	FeedID uuid.UUID `json:"feed_id"`
+	Seq    int64     `json:"seq"`
+}
+
+// subscriberBuffer is how many events a stream may lag behind before being
+// dropped. A dropped client reconnects and catches up with Last-Event-ID.
+const subscriberBuffer = 64
+
+// postBroker fans out post events to the open streams.
+type postBroker struct {
+	mu   sync.Mutex
+	subs map[chan PostEvent]struct{}
+}
+
+func newPostBroker() *postBroker {
+	return &postBroker{subs: map[chan PostEvent]struct{}{}}
+}
+
+func (b *postBroker) subscribe() chan PostEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ch := make(chan PostEvent, subscriberBuffer)
+	b.subs[ch] = struct{}{}
+	return ch
+}
+
+func (b *postBroker) unsubscribe(ch chan PostEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[ch]; ok {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
+
+// publish sends ev to every subscriber, closing the ones that are full
+// instead of blocking on them.
+func (b *postBroker) publish(ev PostEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- ev:
+		default:
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+func (b *postBroker) run(events <-chan PostEvent) {
+	for ev := range events {
+		b.publish(ev)
+	}
+}
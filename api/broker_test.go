@@ -0,0 +1,26 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostBrokerDropsLaggingSubscribers(t *testing.T) {
+	broker := newPostBroker()
+	lagging := broker.subscribe()
+	reading := broker.subscribe()
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		broker.publish(PostEvent{ID: uuid.New(), Seq: int64(i)})
+		<-reading
+	}
+
+	for range lagging {
+	}
+	require.Len(t, broker.subs, 1)
+	broker.unsubscribe(reading)
+	broker.unsubscribe(lagging)
+	require.Empty(t, broker.subs)
+}
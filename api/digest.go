@@ -0,0 +1,190 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/mail"
+	"time"
+
+	"github.com/sp3dr4/bloggogrator/api/middleware"
+	"github.com/sp3dr4/bloggogrator/internal/database"
+	"github.com/sp3dr4/bloggogrator/internal/digest"
+)
+
+type digestResponse struct {
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Email     string    `json:"email"`
+	Frequency string    `json:"frequency"`
+	Hour      int32     `json:"hour"`
+	Weekday   int32     `json:"weekday"`
+	Timezone  string    `json:"timezone"`
+	// Confirmed is false until the link sent to the email is followed. No
+	// digest is sent before.
+	Confirmed bool `json:"confirmed"`
+}
+
+func dbDigestToDigest(o database.DigestSubscription) digestResponse {
+	return digestResponse{
+		CreatedAt: o.CreatedAt,
+		UpdatedAt: o.UpdatedAt,
+		Email:     o.Email,
+		Frequency: o.Frequency,
+		Hour:      o.SendHour,
+		Weekday:   o.Weekday,
+		Timezone:  o.Timezone,
+		Confirmed: o.ConfirmedAt.Valid,
+	}
+}
+
+func (a *apiConfig) handlerPutDigest(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.AuthUser).(database.User)
+
+	request := struct {
+		Email     string `json:"email"`
+		Frequency string `json:"frequency"`
+		Hour      int32  `json:"hour"`
+		Weekday   int32  `json:"weekday"`
+		Timezone  string `json:"timezone"`
+	}{Hour: 8, Weekday: int32(time.Monday), Timezone: "UTC"}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondWithError(w, 400, "error decoding request body")
+		return
+	}
+	// the digest goes to the account email unless told otherwise; either
+	// way the address has to confirm it first
+	if request.Email == "" {
+		request.Email = user.Email.String
+	}
+	email, err := mail.ParseAddress(request.Email)
+	if err != nil {
+		respondWithError(w, 400, "invalid email")
+		return
+	}
+	if request.Frequency != digest.FrequencyDaily && request.Frequency != digest.FrequencyWeekly {
+		respondWithError(w, 400, "invalid frequency")
+		return
+	}
+	if request.Hour < 0 || request.Hour > 23 {
+		respondWithError(w, 400, "invalid hour")
+		return
+	}
+	if request.Weekday < 0 || request.Weekday > 6 {
+		respondWithError(w, 400, "invalid weekday")
+		return
+	}
+	if _, err := time.LoadLocation(request.Timezone); err != nil {
+		respondWithError(w, 400, "invalid timezone")
+		return
+	}
+
+	// the unsubscribe token is only used when creating the subscription,
+	// the confirmation token when the email changes
+	token, err := newToken()
+	if err != nil {
+		respondWithError(w, 500, "error saving digest subscription")
+		return
+	}
+	confirmationToken, err := newToken()
+	if err != nil {
+		respondWithError(w, 500, "error saving digest subscription")
+		return
+	}
+	params := database.UpsertDigestSubscriptionParams{
+		UserID:            user.ID,
+		CreatedAt:         time.Now(),
+		Email:             email.Address,
+		Frequency:         request.Frequency,
+		SendHour:          request.Hour,
+		Weekday:           request.Weekday,
+		Timezone:          request.Timezone,
+		UnsubscribeToken:  token,
+		ConfirmationToken: confirmationToken,
+	}
+	sub, err := a.DB.UpsertDigestSubscription(r.Context(), params)
+	if err != nil {
+		log.Printf("digest subscription error: %v\n", err)
+		respondWithError(w, 500, "error saving digest subscription")
+		return
+	}
+	respondWithJSON(w, 200, dbDigestToDigest(sub))
+}
+
+func (a *apiConfig) handlerGetDigest(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.AuthUser).(database.User)
+
+	sub, err := a.DB.GetDigestSubscription(r.Context(), user.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "digest subscription not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 500, "error retrieving digest subscription")
+		return
+	}
+	respondWithJSON(w, 200, dbDigestToDigest(sub))
+}
+
+func (a *apiConfig) handlerDeleteDigest(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.AuthUser).(database.User)
+
+	if err := a.DB.DeleteDigestSubscription(r.Context(), user.ID); err != nil {
+		respondWithError(w, 500, "error deleting digest subscription")
+		return
+	}
+	respondWithJSON(w, 204, struct{}{})
+}
+
+// handlerConfirmDigest serves the links of the confirmation emails, which
+// start the digests of a subscription.
+func (a *apiConfig) handlerConfirmDigest(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		respondWithError(w, 400, "missing token")
+		return
+	}
+
+	params := database.ConfirmDigestSubscriptionParams{ConfirmationToken: token, ConfirmedAt: time.Now()}
+	confirmed, err := a.DB.ConfirmDigestSubscription(r.Context(), params)
+	if err != nil {
+		log.Printf("digest confirmation error: %v\n", err)
+		respondWithError(w, 500, "error confirming digest subscription")
+		return
+	}
+	if confirmed == 0 {
+		respondWithError(w, 404, "digest subscription not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(200)
+	w.Write([]byte("Your bloggogrator digest subscription is confirmed.\n"))
+}
+
+// handlerUnsubscribeDigest serves the unsubscribe links of the digests,
+// followed by users (GET) or by mail clients supporting one-click
+// unsubscribe (POST).
+func (a *apiConfig) handlerUnsubscribeDigest(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		respondWithError(w, 400, "missing token")
+		return
+	}
+
+	deleted, err := a.DB.DeleteDigestSubscriptionByToken(r.Context(), token)
+	if err != nil {
+		respondWithError(w, 500, "error deleting digest subscription")
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, 404, "digest subscription not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(200)
+	w.Write([]byte("You have been unsubscribed from the bloggogrator digest.\n"))
+}
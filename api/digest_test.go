@@ -0,0 +1,153 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sp3dr4/bloggogrator/api/middleware"
+	"github.com/sp3dr4/bloggogrator/internal/database"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPutDigestHandler(t *testing.T) {
+	setupPutDigest := func(t *testing.T, user database.User, body string) (*httptest.ResponseRecorder, *http.Request) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPut, "/v1/digest", strings.NewReader(body))
+		require.NoError(t, err)
+		req = req.WithContext(context.WithValue(req.Context(), middleware.AuthUser, user))
+		return httptest.NewRecorder(), req
+	}
+
+	t.Run("save subscription", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		user := setupUser()
+		sub := database.DigestSubscription{
+			UserID: user.ID, CreatedAt: now, UpdatedAt: now, Email: "user@example.com",
+			Frequency: "weekly", SendHour: 18, Weekday: 5, Timezone: "Europe/Rome", UnsubscribeToken: "t0ken",
+		}
+		mockDbApi.On("UpsertDigestSubscription", mock.Anything, mock.MatchedBy(func(p database.UpsertDigestSubscriptionParams) bool {
+			return p.UserID == user.ID && p.Email == "user@example.com" && p.Frequency == "weekly" &&
+				p.SendHour == 18 && p.Weekday == 5 && p.Timezone == "Europe/Rome" && len(p.UnsubscribeToken) == 64 &&
+				len(p.ConfirmationToken) == 64 && p.ConfirmationToken != p.UnsubscribeToken
+		})).Return(sub, nil)
+		rw, req := setupPutDigest(t, user, `{"email":"Some User <user@example.com>","frequency":"weekly","hour":18,"weekday":5,"timezone":"Europe/Rome"}`)
+
+		testApi.handlerPutDigest(rw, req)
+
+		require.Equal(t, http.StatusOK, rw.Code)
+		var resp digestResponse
+		require.NoError(t, json.NewDecoder(rw.Body).Decode(&resp))
+		require.Equal(t, "user@example.com", resp.Email)
+		require.Equal(t, "weekly", resp.Frequency)
+		require.Equal(t, int32(18), resp.Hour)
+		require.Equal(t, int32(5), resp.Weekday)
+		require.Equal(t, "Europe/Rome", resp.Timezone)
+		require.False(t, resp.Confirmed)
+		require.NotContains(t, rw.Body.String(), "t0ken")
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	t.Run("default to the account email", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		user := setupUser()
+		user.Email = sql.NullString{String: "foo@example.com", Valid: true}
+		mockDbApi.On("UpsertDigestSubscription", mock.Anything, mock.MatchedBy(func(p database.UpsertDigestSubscriptionParams) bool {
+			return p.Email == "foo@example.com"
+		})).Return(database.DigestSubscription{UserID: user.ID, Email: "foo@example.com"}, nil)
+		rw, req := setupPutDigest(t, user, `{"frequency":"daily"}`)
+
+		testApi.handlerPutDigest(rw, req)
+
+		require.Equal(t, http.StatusOK, rw.Code)
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	tests := map[string]struct {
+		body   string
+		errMsg string
+	}{
+		"invalid email":     {`{"email":"nope","frequency":"daily"}`, "invalid email"},
+		"no email":          {`{"frequency":"daily"}`, "invalid email"},
+		"invalid frequency": {`{"email":"user@example.com","frequency":"hourly"}`, "invalid frequency"},
+		"invalid hour":      {`{"email":"user@example.com","frequency":"daily","hour":24}`, "invalid hour"},
+		"invalid weekday":   {`{"email":"user@example.com","frequency":"weekly","weekday":7}`, "invalid weekday"},
+		"invalid timezone":  {`{"email":"user@example.com","frequency":"daily","timezone":"Nowhere/Town"}`, "invalid timezone"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockDbApi := new(MockedDbApi)
+			testApi := apiConfig{DB: mockDbApi}
+			rw, req := setupPutDigest(t, setupUser(), tc.body)
+
+			testApi.handlerPutDigest(rw, req)
+
+			compareError(t, rw, http.StatusBadRequest, tc.errMsg)
+
+			mockDbApi.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUnsubscribeDigestHandler(t *testing.T) {
+	tests := map[string]struct {
+		deleted int64
+		code    int
+	}{
+		"unsubscribe":   {1, http.StatusOK},
+		"unknown token": {0, http.StatusNotFound},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockDbApi := new(MockedDbApi)
+			testApi := apiConfig{DB: mockDbApi}
+			mockDbApi.On("DeleteDigestSubscriptionByToken", mock.Anything, "t0ken").Return(tc.deleted, nil)
+			req, err := http.NewRequest(http.MethodPost, "/v1/digest/unsubscribe?token=t0ken", nil)
+			require.NoError(t, err)
+			rw := httptest.NewRecorder()
+
+			testApi.handlerUnsubscribeDigest(rw, req)
+
+			require.Equal(t, tc.code, rw.Code)
+
+			mockDbApi.AssertExpectations(t)
+		})
+	}
+}
+
+func TestConfirmDigestHandler(t *testing.T) {
+	tests := map[string]struct {
+		confirmed int64
+		code      int
+	}{
+		"confirm":       {1, http.StatusOK},
+		"unknown token": {0, http.StatusNotFound},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockDbApi := new(MockedDbApi)
+			testApi := apiConfig{DB: mockDbApi}
+			mockDbApi.On("ConfirmDigestSubscription", mock.Anything, mock.MatchedBy(func(p database.ConfirmDigestSubscriptionParams) bool {
+				return p.ConfirmationToken == "c0nfirm"
+			})).Return(tc.confirmed, nil)
+			req, err := http.NewRequest(http.MethodGet, "/v1/digest/confirm?token=c0nfirm", nil)
+			require.NoError(t, err)
+			rw := httptest.NewRecorder()
+
+			testApi.handlerConfirmDigest(rw, req)
+
+			require.Equal(t, tc.code, rw.Code)
+
+			mockDbApi.AssertExpectations(t)
+		})
+	}
+}
@@ -2,8 +2,10 @@ package api
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/sp3dr4/bloggogrator/internal/database"
@@ -28,8 +30,8 @@ func (m *MockedDbApi) CreateUser(ctx context.Context, arg database.CreateUserPar
 	return args.Get(0).(database.User), args.Error(1)
 }
 
-func (m *MockedDbApi) GetUserByApiKey(ctx context.Context, apiKey string) (database.User, error) {
-	args := m.Called(ctx, apiKey)
+func (m *MockedDbApi) GetUserByApiKeyHash(ctx context.Context, hash string) (database.User, error) {
+	args := m.Called(ctx, hash)
 	return args.Get(0).(database.User), args.Error(1)
 }
 
@@ -48,6 +50,21 @@ func (m *MockedDbApi) GetFeed(ctx context.Context, id uuid.UUID) (database.Feed,
 	return args.Get(0).(database.Feed), args.Error(1)
 }
 
+func (m *MockedDbApi) SetFeedFetchFullContent(ctx context.Context, arg database.SetFeedFetchFullContentParams) (database.Feed, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.Feed), args.Error(1)
+}
+
+func (m *MockedDbApi) SetFeedProxyUrl(ctx context.Context, arg database.SetFeedProxyUrlParams) (database.Feed, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.Feed), args.Error(1)
+}
+
+func (m *MockedDbApi) ListFeedFetches(ctx context.Context, arg database.ListFeedFetchesParams) ([]database.FeedFetch, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).([]database.FeedFetch), args.Error(1)
+}
+
 func (m *MockedDbApi) CreateFeedFollow(ctx context.Context, arg database.CreateFeedFollowParams) (database.FeedFollow, error) {
 	args := m.Called(ctx, arg)
 	return args.Get(0).(database.FeedFollow), args.Error(1)
@@ -82,3 +99,373 @@ func (m *MockedDbApi) GetUserPosts(ctx context.Context, arg database.GetUserPost
 	args := m.Called(ctx, arg)
 	return args.Get(0).([]database.Post), args.Error(1)
 }
+
+func (m *MockedDbApi) GetUserPostsWithEnclosure(ctx context.Context, arg database.GetUserPostsWithEnclosureParams) ([]database.GetUserPostsWithEnclosureRow, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).([]database.GetUserPostsWithEnclosureRow), args.Error(1)
+}
+
+func (m *MockedDbApi) GetWebsubSubscription(ctx context.Context, feedId uuid.UUID) (database.WebsubSubscription, error) {
+	args := m.Called(ctx, feedId)
+	return args.Get(0).(database.WebsubSubscription), args.Error(1)
+}
+
+func (m *MockedDbApi) VerifyWebsubSubscription(ctx context.Context, arg database.VerifyWebsubSubscriptionParams) (database.WebsubSubscription, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.WebsubSubscription), args.Error(1)
+}
+
+func (m *MockedDbApi) DeleteWebsubSubscription(ctx context.Context, feedId uuid.UUID) error {
+	args := m.Called(ctx, feedId)
+	return args.Error(0)
+}
+
+func (m *MockedDbApi) GetVisiblePost(ctx context.Context, arg database.GetVisiblePostParams) (database.Post, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.Post), args.Error(1)
+}
+
+func (m *MockedDbApi) GetStreamResumeSeq(ctx context.Context, arg database.GetStreamResumeSeqParams) (int64, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockedDbApi) GetFollowedPostsAfter(ctx context.Context, arg database.GetFollowedPostsAfterParams) ([]database.Post, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).([]database.Post), args.Error(1)
+}
+
+func (m *MockedDbApi) CreateWebhook(ctx context.Context, arg database.CreateWebhookParams) (database.Webhook, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.Webhook), args.Error(1)
+}
+
+func (m *MockedDbApi) GetWebhook(ctx context.Context, id uuid.UUID) (database.Webhook, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(database.Webhook), args.Error(1)
+}
+
+func (m *MockedDbApi) ListUserWebhooks(ctx context.Context, userId uuid.UUID) ([]database.Webhook, error) {
+	args := m.Called(ctx, userId)
+	return args.Get(0).([]database.Webhook), args.Error(1)
+}
+
+func (m *MockedDbApi) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockedDbApi) CreateWebhookDelivery(ctx context.Context, arg database.CreateWebhookDeliveryParams) (database.WebhookDelivery, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.WebhookDelivery), args.Error(1)
+}
+
+func (m *MockedDbApi) RecordWebhookDeliveryAttempt(ctx context.Context, arg database.RecordWebhookDeliveryAttemptParams) (database.WebhookDelivery, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.WebhookDelivery), args.Error(1)
+}
+
+func (m *MockedDbApi) ListWebhookDeliveries(ctx context.Context, arg database.ListWebhookDeliveriesParams) ([]database.WebhookDelivery, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).([]database.WebhookDelivery), args.Error(1)
+}
+
+func (m *MockedDbApi) GetUser(ctx context.Context, id uuid.UUID) (database.User, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(database.User), args.Error(1)
+}
+
+func (m *MockedDbApi) GetFollowedPosts(ctx context.Context, arg database.GetFollowedPostsParams) ([]database.Post, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).([]database.Post), args.Error(1)
+}
+
+func (m *MockedDbApi) CreatePublishedFeed(ctx context.Context, arg database.CreatePublishedFeedParams) (database.PublishedFeed, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.PublishedFeed), args.Error(1)
+}
+
+func (m *MockedDbApi) GetPublishedFeed(ctx context.Context, id uuid.UUID) (database.PublishedFeed, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(database.PublishedFeed), args.Error(1)
+}
+
+func (m *MockedDbApi) GetPublishedFeedByToken(ctx context.Context, token string) (database.PublishedFeed, error) {
+	args := m.Called(ctx, token)
+	return args.Get(0).(database.PublishedFeed), args.Error(1)
+}
+
+func (m *MockedDbApi) ListUserPublishedFeeds(ctx context.Context, userId uuid.UUID) ([]database.PublishedFeed, error) {
+	args := m.Called(ctx, userId)
+	return args.Get(0).([]database.PublishedFeed), args.Error(1)
+}
+
+func (m *MockedDbApi) DeletePublishedFeed(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockedDbApi) UpsertDigestSubscription(ctx context.Context, arg database.UpsertDigestSubscriptionParams) (database.DigestSubscription, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.DigestSubscription), args.Error(1)
+}
+
+func (m *MockedDbApi) GetDigestSubscription(ctx context.Context, userId uuid.UUID) (database.DigestSubscription, error) {
+	args := m.Called(ctx, userId)
+	return args.Get(0).(database.DigestSubscription), args.Error(1)
+}
+
+func (m *MockedDbApi) DeleteDigestSubscription(ctx context.Context, userId uuid.UUID) error {
+	args := m.Called(ctx, userId)
+	return args.Error(0)
+}
+
+func (m *MockedDbApi) ConfirmDigestSubscription(ctx context.Context, arg database.ConfirmDigestSubscriptionParams) (int64, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockedDbApi) DeleteDigestSubscriptionByToken(ctx context.Context, token string) (int64, error) {
+	args := m.Called(ctx, token)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockedDbApi) CreateFilterRule(ctx context.Context, arg database.CreateFilterRuleParams) (database.FilterRule, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.FilterRule), args.Error(1)
+}
+
+func (m *MockedDbApi) GetFilterRule(ctx context.Context, id uuid.UUID) (database.FilterRule, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(database.FilterRule), args.Error(1)
+}
+
+func (m *MockedDbApi) ListUserFilterRules(ctx context.Context, userId uuid.UUID) ([]database.FilterRule, error) {
+	args := m.Called(ctx, userId)
+	return args.Get(0).([]database.FilterRule), args.Error(1)
+}
+
+func (m *MockedDbApi) DeleteFilterRule(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockedDbApi) GetFollowedPostsWithFeedAfter(ctx context.Context, arg database.GetFollowedPostsWithFeedAfterParams) ([]database.GetFollowedPostsWithFeedAfterRow, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).([]database.GetFollowedPostsWithFeedAfterRow), args.Error(1)
+}
+
+func (m *MockedDbApi) UpsertPostState(ctx context.Context, arg database.UpsertPostStateParams) error {
+	args := m.Called(ctx, arg)
+	return args.Error(0)
+}
+
+func (m *MockedDbApi) ListPostStates(ctx context.Context, arg database.ListPostStatesParams) ([]database.PostState, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).([]database.PostState), args.Error(1)
+}
+
+func (m *MockedDbApi) CreateSavedSearch(ctx context.Context, arg database.CreateSavedSearchParams) (database.SavedSearch, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.SavedSearch), args.Error(1)
+}
+
+func (m *MockedDbApi) GetSavedSearch(ctx context.Context, id uuid.UUID) (database.SavedSearch, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(database.SavedSearch), args.Error(1)
+}
+
+func (m *MockedDbApi) ListUserSavedSearches(ctx context.Context, userId uuid.UUID) ([]database.ListUserSavedSearchesRow, error) {
+	args := m.Called(ctx, userId)
+	return args.Get(0).([]database.ListUserSavedSearchesRow), args.Error(1)
+}
+
+func (m *MockedDbApi) DeleteSavedSearch(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockedDbApi) GetSavedSearchPosts(ctx context.Context, arg database.GetSavedSearchPostsParams) ([]database.Post, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).([]database.Post), args.Error(1)
+}
+
+func (m *MockedDbApi) CreateApiKey(ctx context.Context, arg database.CreateApiKeyParams) (database.ApiKey, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.ApiKey), args.Error(1)
+}
+
+func (m *MockedDbApi) GetApiKey(ctx context.Context, id uuid.UUID) (database.ApiKey, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(database.ApiKey), args.Error(1)
+}
+
+func (m *MockedDbApi) ListUserApiKeys(ctx context.Context, userId uuid.UUID) ([]database.ApiKey, error) {
+	args := m.Called(ctx, userId)
+	return args.Get(0).([]database.ApiKey), args.Error(1)
+}
+
+func (m *MockedDbApi) RevokeApiKey(ctx context.Context, arg database.RevokeApiKeyParams) error {
+	args := m.Called(ctx, arg)
+	return args.Error(0)
+}
+
+func (m *MockedDbApi) GetUserByScopedApiKeyHash(ctx context.Context, hash string) (database.GetUserByScopedApiKeyHashRow, error) {
+	args := m.Called(ctx, hash)
+	return args.Get(0).(database.GetUserByScopedApiKeyHashRow), args.Error(1)
+}
+
+func (m *MockedDbApi) TouchApiKey(ctx context.Context, arg database.TouchApiKeyParams) error {
+	args := m.Called(ctx, arg)
+	return args.Error(0)
+}
+
+func (m *MockedDbApi) RotateUserApiKey(ctx context.Context, arg database.RotateUserApiKeyParams) (database.User, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.User), args.Error(1)
+}
+
+func (m *MockedDbApi) CreateAuditEvent(ctx context.Context, arg database.CreateAuditEventParams) (database.AuditEvent, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.AuditEvent), args.Error(1)
+}
+
+func (m *MockedDbApi) ListUserAuditEvents(ctx context.Context, arg database.ListUserAuditEventsParams) ([]database.AuditEvent, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).([]database.AuditEvent), args.Error(1)
+}
+
+func (m *MockedDbApi) GetUserByEmail(ctx context.Context, email sql.NullString) (database.User, error) {
+	args := m.Called(ctx, email)
+	return args.Get(0).(database.User), args.Error(1)
+}
+
+func (m *MockedDbApi) CreateSession(ctx context.Context, arg database.CreateSessionParams) (database.Session, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.Session), args.Error(1)
+}
+
+func (m *MockedDbApi) GetSession(ctx context.Context, id uuid.UUID) (database.Session, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(database.Session), args.Error(1)
+}
+
+func (m *MockedDbApi) ListUserSessions(ctx context.Context, userID uuid.UUID) ([]database.Session, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]database.Session), args.Error(1)
+}
+
+func (m *MockedDbApi) GetUserBySessionAccessHash(ctx context.Context, hash string) (database.GetUserBySessionAccessHashRow, error) {
+	args := m.Called(ctx, hash)
+	return args.Get(0).(database.GetUserBySessionAccessHashRow), args.Error(1)
+}
+
+func (m *MockedDbApi) RefreshSession(ctx context.Context, arg database.RefreshSessionParams) (database.Session, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.Session), args.Error(1)
+}
+
+func (m *MockedDbApi) RevokeSession(ctx context.Context, arg database.RevokeSessionParams) error {
+	args := m.Called(ctx, arg)
+	return args.Error(0)
+}
+
+func (m *MockedDbApi) RevokeSessionByRefreshHash(ctx context.Context, arg database.RevokeSessionByRefreshHashParams) (database.Session, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.Session), args.Error(1)
+}
+
+func (m *MockedDbApi) ListUsers(ctx context.Context, arg database.ListUsersParams) ([]database.User, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).([]database.User), args.Error(1)
+}
+
+func (m *MockedDbApi) SetUserDisabled(ctx context.Context, arg database.SetUserDisabledParams) (database.User, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.User), args.Error(1)
+}
+
+func (m *MockedDbApi) SetUserAdmin(ctx context.Context, arg database.SetUserAdminParams) (database.User, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.User), args.Error(1)
+}
+
+func (m *MockedDbApi) ListFeedsWithStats(ctx context.Context, arg database.ListFeedsWithStatsParams) ([]database.ListFeedsWithStatsRow, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).([]database.ListFeedsWithStatsRow), args.Error(1)
+}
+
+func (m *MockedDbApi) SetFeedDisabled(ctx context.Context, arg database.SetFeedDisabledParams) (database.Feed, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.Feed), args.Error(1)
+}
+
+func (m *MockedDbApi) ScheduleFeedFetch(ctx context.Context, arg database.ScheduleFeedFetchParams) (database.Feed, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.Feed), args.Error(1)
+}
+
+func (m *MockedDbApi) DeletePost(ctx context.Context, arg database.DeletePostParams) (int64, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockedDbApi) GetWorkerStats(ctx context.Context, since time.Time) (database.GetWorkerStatsRow, error) {
+	args := m.Called(ctx, since)
+	return args.Get(0).(database.GetWorkerStatsRow), args.Error(1)
+}
+
+func (m *MockedDbApi) CreateInviteCode(ctx context.Context, arg database.CreateInviteCodeParams) (database.InviteCode, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.InviteCode), args.Error(1)
+}
+
+func (m *MockedDbApi) ListInviteCodes(ctx context.Context) ([]database.InviteCode, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]database.InviteCode), args.Error(1)
+}
+
+func (m *MockedDbApi) RevokeInviteCode(ctx context.Context, arg database.RevokeInviteCodeParams) (int64, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockedDbApi) UseInviteCode(ctx context.Context, hash string) (database.InviteCode, error) {
+	args := m.Called(ctx, hash)
+	return args.Get(0).(database.InviteCode), args.Error(1)
+}
+
+func (m *MockedDbApi) ReleaseInviteCode(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockedDbApi) UpdateUser(ctx context.Context, arg database.UpdateUserParams) (database.User, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.User), args.Error(1)
+}
+
+func (m *MockedDbApi) DeleteUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockedDbApi) ListUserFollowedFeeds(ctx context.Context, userID uuid.UUID) ([]database.Feed, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]database.Feed), args.Error(1)
+}
+
+func (m *MockedDbApi) ListStarredPosts(ctx context.Context, userID uuid.UUID) ([]database.ListStarredPostsRow, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]database.ListStarredPostsRow), args.Error(1)
+}
+
+func (m *MockedDbApi) TakeRateLimitToken(ctx context.Context, params database.TakeRateLimitTokenParams) (database.TakeRateLimitTokenRow, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(database.TakeRateLimitTokenRow), args.Error(1)
+}
+
+func (m *MockedDbApi) DeleteIdleRateLimitBuckets(ctx context.Context, idleSeconds float64) (int64, error) {
+	args := m.Called(ctx, idleSeconds)
+	return args.Get(0).(int64), args.Error(1)
+}
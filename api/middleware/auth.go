@@ -9,38 +9,100 @@ import (
 type MiddlewareContextKey string
 
 const AuthUser MiddlewareContextKey = "middleware.auth.user"
+const AuthScopes MiddlewareContextKey = "middleware.auth.scopes"
+
+// ScopeAll grants every scope.
+const ScopeAll = "*"
 
 func writeUnauthed(w http.ResponseWriter) {
 	w.WriteHeader(http.StatusUnauthorized)
 	w.Write([]byte(http.StatusText(http.StatusUnauthorized)))
 }
 
-type UserFetcher func(ctx context.Context, apikey string) (interface{}, error)
+func writeForbidden(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusForbidden)
+	w.Write([]byte("insufficient scope"))
+}
+
+// Authorization schemes accepted by AuthFactory.
+const (
+	SchemeApiKey = "ApiKey"
+	SchemeBearer = "Bearer"
+)
+
+// UserFetcher returns the user owning the token presented with scheme and
+// the scopes the token grants.
+type UserFetcher func(ctx context.Context, scheme, token string) (interface{}, []string, error)
 
 func AuthFactory(fetchUser UserFetcher) Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			authorization := r.Header.Get("Authorization")
 
-			if !strings.HasPrefix(authorization, "ApiKey ") {
+			scheme, token, ok := strings.Cut(authorization, " ")
+			if !ok || (scheme != SchemeApiKey && scheme != SchemeBearer) {
 				writeUnauthed(w)
 				return
 			}
 
-			key := strings.TrimPrefix(authorization, "ApiKey ")
-			if len(strings.TrimSpace(key)) < 1 {
+			if len(strings.TrimSpace(token)) < 1 {
 				writeUnauthed(w)
 				return
 			}
 
-			user, err := fetchUser(r.Context(), key)
+			user, scopes, err := fetchUser(r.Context(), scheme, token)
 			if err != nil {
 				writeUnauthed(w)
 				return
 			}
 
 			ctx := context.WithValue(r.Context(), AuthUser, user)
+			ctx = context.WithValue(ctx, AuthScopes, scopes)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
+
+// HasScope reports whether the authenticated request was granted scope.
+// A "write:x" scope also grants "read:x".
+func HasScope(ctx context.Context, scope string) bool {
+	scopes, _ := ctx.Value(AuthScopes).([]string)
+	for _, s := range scopes {
+		if s == ScopeAll || s == scope {
+			return true
+		}
+		if resource, ok := strings.CutPrefix(scope, "read:"); ok && s == "write:"+resource {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireScope rejects the requests that weren't granted scope. It must
+// follow AuthFactory.
+func RequireScope(scope string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !HasScope(r.Context(), scope) {
+				writeForbidden(w)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireAdmin rejects the requests of the users isAdmin doesn't accept. It
+// must follow AuthFactory.
+func RequireAdmin(isAdmin func(user interface{}) bool) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !isAdmin(r.Context().Value(AuthUser)) {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte("admin only"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
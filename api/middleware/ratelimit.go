@@ -0,0 +1,190 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimit allows Limit requests per Period, in bursts of up to Limit
+// requests. A zero Limit disables the rate limiting.
+type RateLimit struct {
+	Limit  int
+	Period time.Duration
+}
+
+// ParseRateLimit parses a rate limit written as "limit/period", like
+// "60/1m". "0" disables the rate limiting.
+func ParseRateLimit(s string) (RateLimit, error) {
+	if s == "0" {
+		return RateLimit{}, nil
+	}
+	limitStr, periodStr, ok := strings.Cut(s, "/")
+	if !ok {
+		return RateLimit{}, fmt.Errorf("rate limit %q is not limit/period", s)
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q", limitStr)
+	}
+	period, err := time.ParseDuration(periodStr)
+	if err != nil || period < time.Second {
+		return RateLimit{}, fmt.Errorf("invalid rate limit period %q", periodStr)
+	}
+	return RateLimit{Limit: limit, Period: period}, nil
+}
+
+// rate is the number of tokens added to a bucket per second.
+func (l RateLimit) rate() float64 {
+	return float64(l.Limit) / l.Period.Seconds()
+}
+
+// Result returns the outcome of a request finding tokens in its bucket,
+// once its token is taken when allowed.
+func (l RateLimit) Result(allowed bool, tokens float64) RateLimitResult {
+	result := RateLimitResult{
+		Allowed:   allowed,
+		Remaining: int(math.Max(0, math.Floor(tokens))),
+		Reset:     time.Duration((float64(l.Limit) - tokens) / l.rate() * float64(time.Second)),
+	}
+	if !allowed {
+		result.RetryAfter = time.Duration((1 - tokens) / l.rate() * float64(time.Second))
+	}
+	return result
+}
+
+// RateLimitResult is the outcome of a request against its rate limit.
+type RateLimitResult struct {
+	Allowed   bool
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed, when this
+	// one wasn't.
+	RetryAfter time.Duration
+}
+
+// RateLimitStore keeps the token buckets of the rate limits.
+type RateLimitStore interface {
+	// Take takes a token from the bucket of key, refilled at the rate of
+	// limit.
+	Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error)
+}
+
+// RateLimitKey returns the key of the bucket of a request.
+type RateLimitKey func(r *http.Request) string
+
+// RateLimitFactory limits the requests of each key to limit. name tells
+// apart the buckets of the route groups sharing store. The requests are let
+// through when store fails.
+func RateLimitFactory(store RateLimitStore, name string, limit RateLimit, key RateLimitKey) Middleware {
+	if limit.Limit == 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	policy := fmt.Sprintf("%d;w=%d", limit.Limit, int(limit.Period.Seconds()))
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			result, err := store.Take(r.Context(), name+":"+key(r), limit)
+			if err != nil {
+				log.Printf("rate limit %v error: %v\n", name, err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("RateLimit-Policy", policy)
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+			if !result.Allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+				w.WriteHeader(http.StatusTooManyRequests)
+				w.Write([]byte("rate limit exceeded"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// ClientIP returns the IP address of the client of r. With trustProxy, it is
+// the last address of X-Forwarded-For, appended by the proxy in front of
+// the API.
+func ClientIP(r *http.Request, trustProxy bool) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); trustProxy && forwarded != "" {
+		addrs := strings.Split(forwarded, ",")
+		return strings.TrimSpace(addrs[len(addrs)-1])
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// rateLimitSweepInterval is the minimum delay between two removals of the
+// full buckets of a MemoryRateLimitStore.
+const rateLimitSweepInterval = time.Minute
+
+type rateLimitBucket struct {
+	tokens  float64
+	updated time.Time
+	// full is when the bucket is full again, and can be forgotten.
+	full time.Time
+}
+
+// MemoryRateLimitStore keeps the token buckets in memory, so the limits hold
+// per process.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*rateLimitBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets: map[string]*rateLimitBucket{},
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.lastSweep) >= rateLimitSweepInterval {
+		for k, b := range s.buckets {
+			if !now.Before(b.full) {
+				delete(s.buckets, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &rateLimitBucket{tokens: float64(limit.Limit), updated: now}
+		s.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(limit.Limit), b.tokens+now.Sub(b.updated).Seconds()*limit.rate())
+	b.updated = now
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	result := limit.Result(allowed, b.tokens)
+	b.full = now.Add(result.Reset)
+	return result, nil
+}
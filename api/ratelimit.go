@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sp3dr4/bloggogrator/api/middleware"
+	"github.com/sp3dr4/bloggogrator/internal/database"
+)
+
+// RateLimitConfig sets the rate limits of the route groups of the API.
+type RateLimitConfig struct {
+	// Public limits the unauthenticated routes, per client IP.
+	Public middleware.RateLimit
+	// Auth limits the authenticated routes per client IP, before the
+	// credentials are checked, so that guessing them is slow.
+	Auth middleware.RateLimit
+	// Read and Write limit the authenticated routes, per API key or per
+	// session user, depending on the scope they require.
+	Read  middleware.RateLimit
+	Write middleware.RateLimit
+	Admin middleware.RateLimit
+	// Shared keeps the buckets in Postgres, so that the limits hold across
+	// the replicas of the API.
+	Shared bool
+	// TrustProxy takes the client IP from X-Forwarded-For.
+	TrustProxy bool
+}
+
+// maxPeriod returns the longest period of the limits, after which an
+// untouched bucket is full.
+func (c RateLimitConfig) maxPeriod() time.Duration {
+	period := time.Duration(0)
+	for _, l := range []middleware.RateLimit{c.Public, c.Auth, c.Read, c.Write, c.Admin} {
+		period = max(period, l.Period)
+	}
+	return period
+}
+
+// dbRateLimitStore keeps the token buckets in Postgres.
+type dbRateLimitStore struct {
+	DB DbApi
+}
+
+func (s dbRateLimitStore) Take(ctx context.Context, key string, limit middleware.RateLimit) (middleware.RateLimitResult, error) {
+	bucket, err := s.DB.TakeRateLimitToken(ctx, database.TakeRateLimitTokenParams{
+		Key:      key,
+		Capacity: float64(limit.Limit),
+		Rate:     float64(limit.Limit) / limit.Period.Seconds(),
+	})
+	if err != nil {
+		return middleware.RateLimitResult{}, err
+	}
+	return limit.Result(bucket.Allowed, bucket.Tokens), nil
+}
+
+// pruneRateLimitBuckets periodically deletes the buckets untouched for
+// longer than idle, which are full.
+func pruneRateLimitBuckets(db DbApi, interval, idle time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		deleted, err := db.DeleteIdleRateLimitBuckets(context.Background(), idle.Seconds())
+		if err != nil {
+			log.Printf("err pruning rate limit buckets: %v\n", err)
+			continue
+		}
+		if deleted > 0 {
+			log.Printf("pruned %d rate limit buckets\n", deleted)
+		}
+	}
+}
+
+// rateLimitKey keys the buckets of the authenticated requests by API key,
+// and by user for the sessions, whose tokens change on every refresh. It
+// must follow AuthFactory.
+func rateLimitKey(r *http.Request) string {
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if scheme == middleware.SchemeApiKey {
+		return "key:" + hashApiKey(token)
+	}
+	user := r.Context().Value(middleware.AuthUser).(database.User)
+	return "user:" + user.ID.String()
+}
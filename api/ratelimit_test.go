@@ -0,0 +1,167 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sp3dr4/bloggogrator/api/middleware"
+	"github.com/sp3dr4/bloggogrator/internal/database"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func setupRateLimitHandler(store middleware.RateLimitStore, limit middleware.RateLimit, key middleware.RateLimitKey) http.Handler {
+	return middleware.RateLimitFactory(store, "test", limit, key)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
+
+func TestRateLimitFactory(t *testing.T) {
+	t.Run("return 429 once the bucket is empty", func(t *testing.T) {
+		handler := setupRateLimitHandler(middleware.NewMemoryRateLimitStore(), middleware.RateLimit{Limit: 2, Period: time.Hour}, func(r *http.Request) string {
+			return middleware.ClientIP(r, false)
+		})
+
+		for _, remaining := range []string{"1", "0"} {
+			rw := httptest.NewRecorder()
+			handler.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/users", nil))
+			require.Equal(t, http.StatusNoContent, rw.Code)
+			require.Equal(t, "2", rw.Header().Get("RateLimit-Limit"))
+			require.Equal(t, remaining, rw.Header().Get("RateLimit-Remaining"))
+			require.Equal(t, "2;w=3600", rw.Header().Get("RateLimit-Policy"))
+			require.Empty(t, rw.Header().Get("Retry-After"))
+		}
+
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/users", nil))
+		require.Equal(t, http.StatusTooManyRequests, rw.Code)
+		require.Equal(t, "0", rw.Header().Get("RateLimit-Remaining"))
+		require.Equal(t, "3600", rw.Header().Get("RateLimit-Reset"))
+		require.Equal(t, "1800", rw.Header().Get("Retry-After"))
+
+		// another client has its own bucket
+		req := httptest.NewRequest(http.MethodPost, "/users", nil)
+		req.RemoteAddr = "192.0.2.2:1234"
+		rw = httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+		require.Equal(t, http.StatusNoContent, rw.Code)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		handler := setupRateLimitHandler(middleware.NewMemoryRateLimitStore(), middleware.RateLimit{}, func(r *http.Request) string {
+			return "k"
+		})
+		rw := httptest.NewRecorder()
+
+		handler.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/posts", nil))
+
+		require.Equal(t, http.StatusNoContent, rw.Code)
+		require.Empty(t, rw.Header().Get("RateLimit-Limit"))
+	})
+
+	t.Run("let through when the store fails", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		mockDbApi.On("TakeRateLimitToken", mock.Anything, mock.Anything).Return(database.TakeRateLimitTokenRow{}, errors.New("boom"))
+		handler := setupRateLimitHandler(dbRateLimitStore{DB: mockDbApi}, middleware.RateLimit{Limit: 1, Period: time.Minute}, func(r *http.Request) string {
+			return "k"
+		})
+		rw := httptest.NewRecorder()
+
+		handler.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/posts", nil))
+
+		require.Equal(t, http.StatusNoContent, rw.Code)
+
+		mockDbApi.AssertExpectations(t)
+	})
+}
+
+func TestRateLimitBeforeAuth(t *testing.T) {
+	fetcher := func(ctx context.Context, scheme, token string) (interface{}, []string, error) {
+		return nil, nil, errors.New("invalid key")
+	}
+	clientIP := func(r *http.Request) string {
+		return "ip:" + middleware.ClientIP(r, false)
+	}
+	handler := middleware.CreateStack(
+		middleware.RateLimitFactory(middleware.NewMemoryRateLimitStore(), "auth", middleware.RateLimit{Limit: 2, Period: time.Hour}, clientIP),
+		middleware.AuthFactory(fetcher),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	for _, code := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests} {
+		req := httptest.NewRequest(http.MethodGet, "/posts", nil)
+		req.Header.Set("Authorization", "ApiKey gu3ss")
+		rw := httptest.NewRecorder()
+
+		handler.ServeHTTP(rw, req)
+
+		require.Equal(t, code, rw.Code)
+	}
+}
+
+func TestDbRateLimitStore(t *testing.T) {
+	mockDbApi := new(MockedDbApi)
+	store := dbRateLimitStore{DB: mockDbApi}
+	limit := middleware.RateLimit{Limit: 60, Period: time.Minute}
+	mockDbApi.On("TakeRateLimitToken", mock.Anything, database.TakeRateLimitTokenParams{Key: "read:k", Capacity: 60, Rate: 1}).
+		Return(database.TakeRateLimitTokenRow{Tokens: 0.25, Allowed: false}, nil)
+
+	result, err := store.Take(context.Background(), "read:k", limit)
+
+	require.NoError(t, err)
+	require.False(t, result.Allowed)
+	require.Equal(t, 0, result.Remaining)
+	require.Equal(t, 750*time.Millisecond, result.RetryAfter)
+	require.Equal(t, 59750*time.Millisecond, result.Reset)
+
+	mockDbApi.AssertExpectations(t)
+}
+
+func TestRateLimitKey(t *testing.T) {
+	user := setupUser()
+	tests := map[string]struct {
+		authorization string
+		key           string
+	}{
+		"api key": {"ApiKey k3y", "key:" + hashApiKey("k3y")},
+		"session": {"Bearer t0k3n", "user:" + user.ID.String()},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/posts", nil)
+			req.Header.Set("Authorization", tc.authorization)
+			req = req.WithContext(context.WithValue(req.Context(), middleware.AuthUser, user))
+
+			require.Equal(t, tc.key, rateLimitKey(req))
+		})
+	}
+}
+
+func TestParseRateLimit(t *testing.T) {
+	limit, err := middleware.ParseRateLimit("60/1m")
+	require.NoError(t, err)
+	require.Equal(t, middleware.RateLimit{Limit: 60, Period: time.Minute}, limit)
+
+	limit, err = middleware.ParseRateLimit("0")
+	require.NoError(t, err)
+	require.Zero(t, limit.Limit)
+
+	for _, s := range []string{"60", "0/1m", "60/1ms", "x/1m", "60/x"} {
+		_, err := middleware.ParseRateLimit(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/feeds", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.9, 198.51.100.7")
+
+	require.Equal(t, "192.0.2.1", middleware.ClientIP(req, false))
+	require.Equal(t, "198.51.100.7", middleware.ClientIP(req, true))
+}
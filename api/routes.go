@@ -1,45 +1,90 @@
 package api
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"net/mail"
 	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/sp3dr4/bloggogrator/api/middleware"
 	"github.com/sp3dr4/bloggogrator/internal/database"
+	"github.com/sp3dr4/bloggogrator/internal/filter"
+	"github.com/sp3dr4/bloggogrator/internal/rss"
 )
 
 type userResponse struct {
-	Id        string    `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Name      string    `json:"name"`
-	ApiKey    string    `json:"api_key"`
+	Id           string    `json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	Name         string    `json:"name"`
+	Email        *string   `json:"email"`
+	IsAdmin      bool      `json:"is_admin"`
+	ApiKeyPrefix string    `json:"api_key_prefix"`
+	// Preferences is a JSON object the clients store their settings in.
+	Preferences json.RawMessage `json:"preferences"`
+	// PreviousApiKeyExpiresAt is set while the key replaced by the last
+	// rotation is still valid.
+	PreviousApiKeyExpiresAt *time.Time `json:"previous_api_key_expires_at,omitempty"`
+	// ApiKey is only returned when the user is created or its key rotated.
+	ApiKey string `json:"api_key,omitempty"`
 }
 
 func dbUserToUser(o database.User) userResponse {
+	var previousExpiresAt *time.Time
+	if o.PreviousApiKeyExpiresAt.Valid && o.PreviousApiKeyExpiresAt.Time.After(time.Now()) {
+		previousExpiresAt = &o.PreviousApiKeyExpiresAt.Time
+	}
+	var email *string
+	if o.Email.Valid {
+		email = &o.Email.String
+	}
 	return userResponse{
-		Id:        o.ID.String(),
-		CreatedAt: o.CreatedAt,
-		UpdatedAt: o.UpdatedAt,
-		Name:      o.Name,
-		ApiKey:    o.ApiKey,
+		Id:                      o.ID.String(),
+		CreatedAt:               o.CreatedAt,
+		UpdatedAt:               o.UpdatedAt,
+		Name:                    o.Name,
+		Email:                   email,
+		IsAdmin:                 o.IsAdmin,
+		ApiKeyPrefix:            o.ApiKeyPrefix,
+		Preferences:             o.Preferences,
+		PreviousApiKeyExpiresAt: previousExpiresAt,
 	}
 }
 
 type feedResponse struct {
-	Id            string     `json:"id"`
-	CreatedAt     time.Time  `json:"created_at"`
-	UpdatedAt     time.Time  `json:"updated_at"`
-	Name          string     `json:"name"`
-	Url           string     `json:"url"`
-	LastFetchedAt *time.Time `json:"last_fetched_at"`
-	UserId        string     `json:"user_id"`
+	Id                  string             `json:"id"`
+	CreatedAt           time.Time          `json:"created_at"`
+	UpdatedAt           time.Time          `json:"updated_at"`
+	Name                string             `json:"name"`
+	Url                 string             `json:"url"`
+	LastFetchedAt       *time.Time         `json:"last_fetched_at"`
+	UserId              *string            `json:"user_id"`
+	FetchFullContent    bool               `json:"fetch_full_content"`
+	DeadAt              *time.Time         `json:"dead_at"`
+	ConsecutiveFailures int32              `json:"consecutive_failures"`
+	LastError           *feedErrorResponse `json:"last_error"`
+	NextFetchAt         *time.Time         `json:"next_fetch_at"`
+	DisabledAt          *time.Time         `json:"disabled_at"`
+}
+
+type feedErrorResponse struct {
+	Kind       string    `json:"kind"`
+	Message    string    `json:"message"`
+	StatusCode *int32    `json:"status_code"`
+	At         time.Time `json:"at"`
 }
 
 func dbFeedToFeed(o database.Feed) feedResponse {
@@ -47,14 +92,114 @@ func dbFeedToFeed(o database.Feed) feedResponse {
 	if o.LastFetchedAt.Valid {
 		fetchedAt = &o.LastFetchedAt.Time
 	}
+	var deadAt *time.Time
+	if o.DeadAt.Valid {
+		deadAt = &o.DeadAt.Time
+	}
+	var lastError *feedErrorResponse
+	if o.LastError.Valid {
+		lastError = &feedErrorResponse{
+			Kind:    o.LastErrorKind.String,
+			Message: o.LastError.String,
+			At:      o.LastErrorAt.Time,
+		}
+		if o.LastErrorStatus.Valid {
+			lastError.StatusCode = &o.LastErrorStatus.Int32
+		}
+	}
+	var nextFetchAt *time.Time
+	if o.NextFetchAt.Valid {
+		nextFetchAt = &o.NextFetchAt.Time
+	}
+	var disabledAt *time.Time
+	if o.DisabledAt.Valid {
+		disabledAt = &o.DisabledAt.Time
+	}
+	var userId *string
+	if o.UserID.Valid {
+		id := o.UserID.UUID.String()
+		userId = &id
+	}
 	return feedResponse{
+		Id:                  o.ID.String(),
+		CreatedAt:           o.CreatedAt,
+		UpdatedAt:           o.UpdatedAt,
+		Name:                o.Name,
+		Url:                 o.Url,
+		LastFetchedAt:       fetchedAt,
+		UserId:              userId,
+		FetchFullContent:    o.FetchFullContent,
+		DeadAt:              deadAt,
+		ConsecutiveFailures: o.ConsecutiveFailures,
+		LastError:           lastError,
+		NextFetchAt:         nextFetchAt,
+		DisabledAt:          disabledAt,
+	}
+}
+
+type fetchResponse struct {
+	Id            string    `json:"id"`
+	FeedId        string    `json:"feed_id"`
+	StartedAt     time.Time `json:"started_at"`
+	FinishedAt    time.Time `json:"finished_at"`
+	DurationMs    int32     `json:"duration_ms"`
+	StatusCode    *int32    `json:"status_code"`
+	Bytes         int64     `json:"bytes"`
+	ItemsSeen     int32     `json:"items_seen"`
+	PostsInserted int32     `json:"posts_inserted"`
+	PostsUpdated  int32     `json:"posts_updated"`
+	Error         *string   `json:"error"`
+}
+
+func dbFetchToFetch(o database.FeedFetch) fetchResponse {
+	var statusCode *int32
+	if o.StatusCode.Valid {
+		statusCode = &o.StatusCode.Int32
+	}
+	var fetchErr *string
+	if o.Error.Valid {
+		fetchErr = &o.Error.String
+	}
+	return fetchResponse{
+		Id:            o.ID.String(),
+		FeedId:        o.FeedID.String(),
+		StartedAt:     o.StartedAt,
+		FinishedAt:    o.FinishedAt,
+		DurationMs:    o.DurationMs,
+		StatusCode:    statusCode,
+		Bytes:         o.Bytes,
+		ItemsSeen:     o.ItemsSeen,
+		PostsInserted: o.PostsInserted,
+		PostsUpdated:  o.PostsUpdated,
+		Error:         fetchErr,
+	}
+}
+
+type publishedFeedResponse struct {
+	Id        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Kind      string    `json:"kind"`
+	Token     string    `json:"token"`
+	RssUrl    string    `json:"rss_url"`
+	AtomUrl   string    `json:"atom_url"`
+	// SavedSearchId is set for the search kind.
+	SavedSearchId *string `json:"saved_search_id,omitempty"`
+}
+
+func dbPublishedFeedToPublishedFeed(o database.PublishedFeed, baseUrl string) publishedFeedResponse {
+	var savedSearchId *string
+	if o.SavedSearchID.Valid {
+		id := o.SavedSearchID.UUID.String()
+		savedSearchId = &id
+	}
+	return publishedFeedResponse{
 		Id:            o.ID.String(),
 		CreatedAt:     o.CreatedAt,
-		UpdatedAt:     o.UpdatedAt,
-		Name:          o.Name,
-		Url:           o.Url,
-		LastFetchedAt: fetchedAt,
-		UserId:        o.UserID.String(),
+		Kind:          o.Kind,
+		Token:         o.Token,
+		RssUrl:        baseUrl + "/v1/published/" + o.Token + "/rss",
+		AtomUrl:       baseUrl + "/v1/published/" + o.Token + "/atom",
+		SavedSearchId: savedSearchId,
 	}
 }
 
@@ -75,14 +220,27 @@ func dbFollowToFollow(o database.FeedFollow) followResponse {
 }
 
 type postResponse struct {
-	Id          string    `json:"id"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	Url         string    `json:"url"`
-	Title       *string   `json:"title"`
-	Description *string   `json:"description"`
-	PublishedAt time.Time `json:"published_at"`
-	FeedId      string    `json:"feed_id"`
+	Id              string           `json:"id"`
+	CreatedAt       time.Time        `json:"created_at"`
+	UpdatedAt       time.Time        `json:"updated_at"`
+	Url             string           `json:"url"`
+	Title           *string          `json:"title"`
+	Description     *string          `json:"description"`
+	DescriptionText *string          `json:"description_text"`
+	Content         *string          `json:"content"`
+	PublishedAt     time.Time        `json:"published_at"`
+	FeedId          string           `json:"feed_id"`
+	Author          *string          `json:"author"`
+	Categories      []string         `json:"categories,omitempty"`
+	Episode         *episodeResponse `json:"episode,omitempty"`
+	// State is set by the rules of the user, hidden posts aren't listed.
+	State *postStateResponse `json:"state,omitempty"`
+}
+
+type postStateResponse struct {
+	Read    bool     `json:"read"`
+	Starred bool     `json:"starred"`
+	Tags    []string `json:"tags"`
 }
 
 func dbPostToPost(o database.Post) postResponse {
@@ -94,41 +252,162 @@ func dbPostToPost(o database.Post) postResponse {
 	if o.Description.Valid {
 		descr = &o.Description.String
 	}
+	var descrText *string
+	if o.DescriptionText.Valid {
+		descrText = &o.DescriptionText.String
+	}
+	var content *string
+	if o.Content.Valid {
+		content = &o.Content.String
+	}
+	var author *string
+	if o.Author.Valid {
+		author = &o.Author.String
+	}
 	return postResponse{
-		Id:          o.ID.String(),
-		CreatedAt:   o.CreatedAt,
-		UpdatedAt:   o.UpdatedAt,
-		Url:         o.Url,
-		Title:       title,
-		Description: descr,
-		PublishedAt: o.PublishedAt,
-		FeedId:      o.FeedID.String(),
+		Id:              o.ID.String(),
+		CreatedAt:       o.CreatedAt,
+		UpdatedAt:       o.UpdatedAt,
+		Url:             o.Url,
+		Title:           title,
+		Description:     descr,
+		DescriptionText: descrText,
+		Content:         content,
+		PublishedAt:     o.PublishedAt,
+		FeedId:          o.FeedID.String(),
+		Author:          author,
+		Categories:      o.Categories,
+	}
+}
+
+type episodeResponse struct {
+	EnclosureUrl    string  `json:"enclosure_url"`
+	EnclosureType   string  `json:"enclosure_type"`
+	EnclosureLength *int64  `json:"enclosure_length"`
+	DurationSeconds *int32  `json:"duration_seconds"`
+	ImageUrl        *string `json:"image_url"`
+	Episode         *int32  `json:"episode"`
+	Season          *int32  `json:"season"`
+	Explicit        *bool   `json:"explicit"`
+}
+
+func dbEpisodeToEpisode(o database.PostEpisode) episodeResponse {
+	resp := episodeResponse{
+		EnclosureUrl:  o.EnclosureUrl,
+		EnclosureType: o.EnclosureType,
+	}
+	if o.EnclosureLength.Valid {
+		resp.EnclosureLength = &o.EnclosureLength.Int64
+	}
+	if o.DurationSeconds.Valid {
+		resp.DurationSeconds = &o.DurationSeconds.Int32
+	}
+	if o.ImageUrl.Valid {
+		resp.ImageUrl = &o.ImageUrl.String
+	}
+	if o.Episode.Valid {
+		resp.Episode = &o.Episode.Int32
+	}
+	if o.Season.Valid {
+		resp.Season = &o.Season.Int32
 	}
+	if o.Explicit.Valid {
+		resp.Explicit = &o.Explicit.Bool
+	}
+	return resp
 }
 
+// handlerCreateUser creates a user. Users created with an email and a
+// password can also log in to get session tokens. In invite mode, each user
+// uses up one use of a valid invite code.
 func (a *apiConfig) handlerCreateUser(w http.ResponseWriter, r *http.Request) {
+	if a.Registration == RegistrationClosed {
+		respondWithError(w, 403, "registration is closed")
+		return
+	}
+
 	var request struct {
-		Name string `json:"name"`
+		Name       string `json:"name"`
+		Email      string `json:"email"`
+		Password   string `json:"password"`
+		InviteCode string `json:"invite_code"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
 		respondWithError(w, 400, "error decoding request body")
 		return
 	}
 
+	var email, passwordHash sql.NullString
+	if request.Email != "" || request.Password != "" {
+		address, err := mail.ParseAddress(request.Email)
+		if err != nil || address.Address != request.Email || len(address.Address) > 255 {
+			respondWithError(w, 400, "invalid email")
+			return
+		}
+		if len(request.Password) < minPasswordLength || len(request.Password) > maxPasswordLength {
+			respondWithError(w, 400, fmt.Sprintf("password must be %d to %d bytes long", minPasswordLength, maxPasswordLength))
+			return
+		}
+		hash, err := hashPassword(request.Password)
+		if err != nil {
+			respondWithError(w, 500, "error creating user")
+			return
+		}
+		email = sql.NullString{String: strings.ToLower(address.Address), Valid: true}
+		passwordHash = sql.NullString{String: hash, Valid: true}
+	}
+
+	apiKey, hash, prefix, err := newApiKey()
+	if err != nil {
+		respondWithError(w, 500, "error creating user")
+		return
+	}
+
+	var inviteCodeId uuid.NullUUID
+	if a.Registration == RegistrationInvite {
+		code, err := a.DB.UseInviteCode(r.Context(), hashApiKey(request.InviteCode))
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, 403, "invalid invite code")
+			return
+		}
+		if err != nil {
+			log.Printf("invite code use error: %v\n", err)
+			respondWithError(w, 500, "error creating user")
+			return
+		}
+		inviteCodeId = uuid.NullUUID{UUID: code.ID, Valid: true}
+	}
+
 	createParams := database.CreateUserParams{
-		ID:        uuid.New(),
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-		Name:      request.Name,
+		ID:           uuid.New(),
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+		Name:         request.Name,
+		ApiKeyHash:   hash,
+		ApiKeyPrefix: prefix,
+		Email:        email,
+		PasswordHash: passwordHash,
+		InviteCodeID: inviteCodeId,
 	}
 	user, err := a.DB.CreateUser(r.Context(), createParams)
 	if err != nil {
+		if inviteCodeId.Valid {
+			if err := a.DB.ReleaseInviteCode(r.Context(), inviteCodeId.UUID); err != nil {
+				log.Printf("err releasing invite code %v: %v\n", inviteCodeId.UUID, err)
+			}
+		}
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "unique_violation" && email.Valid {
+			respondWithError(w, 409, "email already registered")
+			return
+		}
 		log.Printf("user creation error: %v\n", err)
 		respondWithError(w, 500, "error creating user")
 		return
 	}
 
-	respondWithJSON(w, 201, dbUserToUser(user))
+	resp := dbUserToUser(user)
+	resp.ApiKey = apiKey
+	respondWithJSON(w, 201, resp)
 }
 
 func (a *apiConfig) handlerGetUser(w http.ResponseWriter, r *http.Request) {
@@ -169,7 +448,7 @@ func (a *apiConfig) handlerCreateFeed(w http.ResponseWriter, r *http.Request) {
 		UpdatedAt: time.Now(),
 		Name:      request.Name,
 		Url:       request.Url,
-		UserID:    user.ID,
+		UserID:    uuid.NullUUID{UUID: user.ID, Valid: true},
 	}
 	feed, err := a.DB.CreateFeed(r.Context(), createParams)
 	if err != nil {
@@ -201,6 +480,112 @@ func (a *apiConfig) handlerCreateFeed(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, 201, resp)
 }
 
+func (a *apiConfig) handlerUpdateFeed(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.AuthUser).(database.User)
+	feedId, err := uuid.Parse(r.PathValue("feedID"))
+	if err != nil {
+		respondWithError(w, 400, "invalid feed id")
+		return
+	}
+
+	var request struct {
+		FetchFullContent *bool   `json:"fetch_full_content"`
+		ProxyUrl         *string `json:"proxy_url"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondWithError(w, 400, "error decoding request body")
+		return
+	}
+	if request.ProxyUrl != nil && *request.ProxyUrl != "" {
+		if _, err := rss.ParseProxyUrl(*request.ProxyUrl); err != nil {
+			respondWithError(w, 400, "invalid proxy url")
+			return
+		}
+	}
+
+	feed, err := a.DB.GetFeed(r.Context(), feedId)
+	if err != nil {
+		respondWithError(w, 404, "feed not found")
+		return
+	}
+	if !feed.UserID.Valid || feed.UserID.UUID != user.ID {
+		respondWithError(w, 403, "operation not allowed")
+		return
+	}
+
+	if request.FetchFullContent != nil {
+		params := database.SetFeedFetchFullContentParams{
+			ID:               feed.ID,
+			FetchFullContent: *request.FetchFullContent,
+			UpdatedAt:        time.Now(),
+		}
+		feed, err = a.DB.SetFeedFetchFullContent(r.Context(), params)
+		if err != nil {
+			log.Printf("feed update error: %v\n", err)
+			respondWithError(w, 500, "error updating feed")
+			return
+		}
+	}
+
+	if request.ProxyUrl != nil {
+		params := database.SetFeedProxyUrlParams{
+			ID:        feed.ID,
+			ProxyUrl:  sql.NullString{String: *request.ProxyUrl, Valid: *request.ProxyUrl != ""},
+			UpdatedAt: time.Now(),
+		}
+		feed, err = a.DB.SetFeedProxyUrl(r.Context(), params)
+		if err != nil {
+			log.Printf("feed update error: %v\n", err)
+			respondWithError(w, 500, "error updating feed")
+			return
+		}
+	}
+
+	respondWithJSON(w, 200, dbFeedToFeed(feed))
+}
+
+func (a *apiConfig) handlerListFeedFetches(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.AuthUser).(database.User)
+	feedId, err := uuid.Parse(r.PathValue("feedID"))
+	if err != nil {
+		respondWithError(w, 400, "invalid feed id")
+		return
+	}
+
+	var limit int32 = 20
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		limitInt, err := strconv.ParseInt(limitStr, 10, 32)
+		if err != nil {
+			respondWithError(w, 400, "invalid limit query parameter")
+			return
+		}
+		limit = int32(limitInt)
+	}
+
+	feed, err := a.DB.GetFeed(r.Context(), feedId)
+	if err != nil {
+		respondWithError(w, 404, "feed not found")
+		return
+	}
+	if !feed.UserID.Valid || feed.UserID.UUID != user.ID {
+		respondWithError(w, 403, "operation not allowed")
+		return
+	}
+
+	params := database.ListFeedFetchesParams{FeedID: feed.ID, Limit: limit}
+	fetches, err := a.DB.ListFeedFetches(r.Context(), params)
+	if err != nil {
+		respondWithError(w, 500, "error retrieving feed fetches")
+		return
+	}
+
+	respFetches := make([]fetchResponse, 0, len(fetches))
+	for _, o := range fetches {
+		respFetches = append(respFetches, dbFetchToFetch(o))
+	}
+	respondWithJSON(w, 200, respFetches)
+}
+
 func (a *apiConfig) handlerCreateFeedFollow(w http.ResponseWriter, r *http.Request) {
 	user := r.Context().Value(middleware.AuthUser).(database.User)
 
@@ -294,6 +679,33 @@ func (a *apiConfig) handlerListPosts(w http.ResponseWriter, r *http.Request) {
 		limit = int32(limitInt)
 	}
 
+	if mediaType := r.URL.Query().Get("has_enclosure"); mediaType != "" {
+		if mediaType != "audio" && mediaType != "video" {
+			respondWithError(w, 400, "invalid has_enclosure query parameter")
+			return
+		}
+		params := database.GetUserPostsWithEnclosureParams{UserID: user.ID, Limit: limit, MediaType: mediaType}
+		rows, err := a.DB.GetUserPostsWithEnclosure(r.Context(), params)
+		if err != nil {
+			respondWithError(w, 500, "error retrieving user posts")
+			return
+		}
+
+		respPosts := make([]postResponse, 0, len(rows))
+		for _, o := range rows {
+			post := dbPostToPost(o.Post)
+			episode := dbEpisodeToEpisode(o.PostEpisode)
+			post.Episode = &episode
+			respPosts = append(respPosts, post)
+		}
+		if err := a.setPostStates(r, user, respPosts); err != nil {
+			respondWithError(w, 500, "error retrieving user posts")
+			return
+		}
+		respondWithJSON(w, 200, respPosts)
+		return
+	}
+
 	params := database.GetUserPostsParams{UserID: user.ID, Limit: limit}
 	posts, err := a.DB.GetUserPosts(r.Context(), params)
 	if err != nil {
@@ -305,5 +717,1021 @@ func (a *apiConfig) handlerListPosts(w http.ResponseWriter, r *http.Request) {
 	for _, o := range posts {
 		respPosts = append(respPosts, dbPostToPost(o))
 	}
+	if err := a.setPostStates(r, user, respPosts); err != nil {
+		respondWithError(w, 500, "error retrieving user posts")
+		return
+	}
 	respondWithJSON(w, 200, respPosts)
 }
+
+// setPostStates sets the state the filter rules of user gave to posts.
+func (a *apiConfig) setPostStates(r *http.Request, user database.User, posts []postResponse) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	params := database.ListPostStatesParams{UserID: user.ID, PostIds: make([]uuid.UUID, 0, len(posts))}
+	for _, o := range posts {
+		params.PostIds = append(params.PostIds, uuid.MustParse(o.Id))
+	}
+	states, err := a.DB.ListPostStates(r.Context(), params)
+	if err != nil {
+		return err
+	}
+	byPost := make(map[string]postStateResponse, len(states))
+	for _, o := range states {
+		byPost[o.PostID.String()] = postStateResponse{Read: o.Read, Starred: o.Starred, Tags: o.Tags}
+	}
+	for i := range posts {
+		if state, ok := byPost[posts[i].Id]; ok {
+			posts[i].State = &state
+		}
+	}
+	return nil
+}
+
+// maxWebsubLease caps the lease granted by a hub, so that polling resumes
+// in time if the hub stops pushing.
+const maxWebsubLease = 10 * 24 * time.Hour
+
+// websubVerifyWindow is how long after a subscription request the hub can
+// verify it.
+const websubVerifyWindow = time.Hour
+
+func (a *apiConfig) handlerWebSubVerify(w http.ResponseWriter, r *http.Request) {
+	feedId, err := uuid.Parse(r.PathValue("feedID"))
+	if err != nil {
+		respondWithError(w, 404, "subscription not found")
+		return
+	}
+	sub, err := a.DB.GetWebsubSubscription(r.Context(), feedId)
+	if err != nil {
+		respondWithError(w, 404, "subscription not found")
+		return
+	}
+
+	query := r.URL.Query()
+	// the token is only known to the hub, from the callback url of the request
+	token := query.Get("token")
+	if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(sub.CallbackToken)) != 1 {
+		respondWithError(w, 404, "subscription not found")
+		return
+	}
+	if query.Get("hub.topic") != sub.Topic {
+		respondWithError(w, 404, "unknown topic")
+		return
+	}
+
+	switch query.Get("hub.mode") {
+	case "subscribe":
+		leaseSeconds, err := strconv.ParseInt(query.Get("hub.lease_seconds"), 10, 64)
+		if err != nil || leaseSeconds <= 0 {
+			respondWithError(w, 400, "invalid lease seconds")
+			return
+		}
+		lease := maxWebsubLease
+		if leaseSeconds < int64(maxWebsubLease/time.Second) {
+			lease = time.Duration(leaseSeconds) * time.Second
+		}
+		now := time.Now()
+		params := database.VerifyWebsubSubscriptionParams{
+			FeedID:         sub.FeedID,
+			VerifiedAt:     sql.NullTime{Time: now, Valid: true},
+			LeaseExpiresAt: sql.NullTime{Time: now.Add(lease), Valid: true},
+			CallbackToken:  token,
+			RequestedAfter: now.Add(-websubVerifyWindow),
+		}
+		_, err = a.DB.VerifyWebsubSubscription(r.Context(), params)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, 404, "no pending subscription")
+			return
+		}
+		if err != nil {
+			log.Printf("websub verify error: %v\n", err)
+			respondWithError(w, 500, "error verifying subscription")
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(200)
+		w.Write([]byte(query.Get("hub.challenge")))
+	case "denied":
+		log.Printf("websub subscription of feed %v denied: %v\n", sub.FeedID, query.Get("hub.reason"))
+		if err := a.DB.DeleteWebsubSubscription(r.Context(), sub.FeedID); err != nil {
+			log.Printf("websub delete error: %v\n", err)
+			respondWithError(w, 500, "error deleting subscription")
+			return
+		}
+		w.WriteHeader(200)
+	default:
+		// subscriptions are only dropped by letting their lease expire, so
+		// unsubscribe requests are never ours
+		respondWithError(w, 404, "unexpected mode")
+	}
+}
+
+func (a *apiConfig) handlerWebSubPush(w http.ResponseWriter, r *http.Request) {
+	feedId, err := uuid.Parse(r.PathValue("feedID"))
+	if err != nil {
+		respondWithError(w, 404, "subscription not found")
+		return
+	}
+	sub, err := a.DB.GetWebsubSubscription(r.Context(), feedId)
+	if err != nil {
+		respondWithError(w, 404, "subscription not found")
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, rss.MaxPushSize+1))
+	if err != nil {
+		respondWithError(w, 400, "error reading request body")
+		return
+	}
+	if int64(len(body)) > rss.MaxPushSize {
+		respondWithError(w, 413, "request body too large")
+		return
+	}
+
+	// content with a missing or invalid signature must be acknowledged but
+	// ignored, so that a forger can't tell whether it was accepted
+	if !rss.VerifySignature(sub.Secret, body, r.Header.Get("X-Hub-Signature")) {
+		log.Printf("websub push for feed %v with invalid signature\n", sub.FeedID)
+		w.WriteHeader(202)
+		return
+	}
+
+	feed, err := a.DB.GetFeed(r.Context(), sub.FeedID)
+	if err != nil {
+		respondWithError(w, 404, "feed not found")
+		return
+	}
+	if err := a.Ingest(feed, body, r.Header.Get("Content-Type")); err != nil {
+		log.Printf("websub ingest error for feed %v: %v\n", feed.ID, err)
+		var parseErr *rss.ParseError
+		if errors.As(err, &parseErr) {
+			respondWithError(w, 400, "invalid feed")
+			return
+		}
+		respondWithError(w, 500, "error ingesting feed")
+		return
+	}
+	w.WriteHeader(202)
+}
+
+// Kinds of published feeds.
+const (
+	publishedTimeline = "timeline"
+	publishedSearch   = "search"
+)
+
+const (
+	defaultPublishedItems = 50
+	maxPublishedItems     = 200
+)
+
+// baseUrl returns the scheme and host the request was sent to.
+func baseUrl(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// newToken returns a random token for urls that authenticate on their own.
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (a *apiConfig) handlerCreatePublishedFeed(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.AuthUser).(database.User)
+
+	var request struct {
+		Kind          string `json:"kind"`
+		SavedSearchId string `json:"saved_search_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondWithError(w, 400, "error decoding request body")
+		return
+	}
+	if request.Kind == "" {
+		request.Kind = publishedTimeline
+	}
+	var savedSearchId uuid.NullUUID
+	switch request.Kind {
+	case publishedTimeline:
+		if request.SavedSearchId != "" {
+			respondWithError(w, 400, "saved_search_id is only valid for the search kind")
+			return
+		}
+	case publishedSearch:
+		id, err := uuid.Parse(request.SavedSearchId)
+		if err != nil {
+			respondWithError(w, 400, "invalid saved search id")
+			return
+		}
+		search, err := a.DB.GetSavedSearch(r.Context(), id)
+		if err != nil {
+			respondWithError(w, 404, "saved search not found")
+			return
+		}
+		if search.UserID != user.ID {
+			respondWithError(w, 403, "operation not allowed")
+			return
+		}
+		savedSearchId = uuid.NullUUID{UUID: search.ID, Valid: true}
+	default:
+		respondWithError(w, 400, "invalid kind")
+		return
+	}
+
+	token, err := newToken()
+	if err != nil {
+		respondWithError(w, 500, "error creating published feed")
+		return
+	}
+	params := database.CreatePublishedFeedParams{
+		ID:            uuid.New(),
+		CreatedAt:     time.Now(),
+		UserID:        user.ID,
+		Kind:          request.Kind,
+		Token:         token,
+		SavedSearchID: savedSearchId,
+	}
+	published, err := a.DB.CreatePublishedFeed(r.Context(), params)
+	if err != nil {
+		log.Printf("published feed creation error: %v\n", err)
+		respondWithError(w, 500, "error creating published feed")
+		return
+	}
+	respondWithJSON(w, 201, dbPublishedFeedToPublishedFeed(published, baseUrl(r)))
+}
+
+func (a *apiConfig) handlerListPublishedFeeds(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.AuthUser).(database.User)
+
+	published, err := a.DB.ListUserPublishedFeeds(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 500, "error retrieving published feeds")
+		return
+	}
+
+	respPublished := make([]publishedFeedResponse, 0, len(published))
+	for _, o := range published {
+		respPublished = append(respPublished, dbPublishedFeedToPublishedFeed(o, baseUrl(r)))
+	}
+	respondWithJSON(w, 200, respPublished)
+}
+
+func (a *apiConfig) handlerDeletePublishedFeed(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.AuthUser).(database.User)
+	publishedId, err := uuid.Parse(r.PathValue("publishedFeedID"))
+	if err != nil {
+		respondWithError(w, 400, "invalid published feed id")
+		return
+	}
+
+	published, err := a.DB.GetPublishedFeed(r.Context(), publishedId)
+	if err != nil {
+		respondWithError(w, 404, "published feed not found")
+		return
+	}
+	if published.UserID != user.ID {
+		respondWithError(w, 403, "operation not allowed")
+		return
+	}
+
+	if err := a.DB.DeletePublishedFeed(r.Context(), published.ID); err != nil {
+		respondWithError(w, 500, "error deleting published feed")
+		return
+	}
+
+	respondWithJSON(w, 204, struct{}{})
+}
+
+func (a *apiConfig) handlerPublishedRss(w http.ResponseWriter, r *http.Request) {
+	a.writePublishedFeed(w, r, "application/rss+xml; charset=utf-8", rss.WriteRSS)
+}
+
+func (a *apiConfig) handlerPublishedAtom(w http.ResponseWriter, r *http.Request) {
+	a.writePublishedFeed(w, r, "application/atom+xml; charset=utf-8", rss.WriteAtom)
+}
+
+// writePublishedFeed renders the published feed whose token is in the
+// request path. The token is the only authentication, so that readers
+// unable to send an Authorization header can subscribe.
+func (a *apiConfig) writePublishedFeed(w http.ResponseWriter, r *http.Request, contentType string, write func(io.Writer, rss.OutputFeed) error) {
+	var limit int32 = defaultPublishedItems
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		limitInt, err := strconv.ParseInt(limitStr, 10, 32)
+		if err != nil || limitInt < 1 {
+			respondWithError(w, 400, "invalid limit query parameter")
+			return
+		}
+		limit = int32(min(limitInt, maxPublishedItems))
+	}
+
+	published, err := a.DB.GetPublishedFeedByToken(r.Context(), r.PathValue("token"))
+	if err != nil {
+		respondWithError(w, 404, "feed not found")
+		return
+	}
+	user, err := a.DB.GetUser(r.Context(), published.UserID)
+	if err != nil {
+		respondWithError(w, 500, "error retrieving user")
+		return
+	}
+	if user.DisabledAt.Valid {
+		respondWithError(w, 404, "feed not found")
+		return
+	}
+
+	feed := rss.OutputFeed{
+		Title:       user.Name + "'s timeline",
+		Description: "Posts from the feeds followed by " + user.Name + " on bloggogrator",
+		SelfUrl:     baseUrl(r) + r.URL.Path,
+		Updated:     published.CreatedAt,
+	}
+	var posts []database.Post
+	if published.Kind == publishedSearch {
+		search, err := a.DB.GetSavedSearch(r.Context(), published.SavedSearchID.UUID)
+		if err != nil {
+			respondWithError(w, 404, "feed not found")
+			return
+		}
+		feed.Title = user.Name + "'s search: " + search.Name
+		feed.Description = "Posts matching the saved search " + search.Name + " of " + user.Name + " on bloggogrator"
+		posts, err = a.DB.GetSavedSearchPosts(r.Context(), database.GetSavedSearchPostsParams{ID: search.ID, Limit: limit})
+	} else {
+		posts, err = a.DB.GetFollowedPosts(r.Context(), database.GetFollowedPostsParams{UserID: user.ID, Limit: limit})
+	}
+	if err != nil {
+		respondWithError(w, 500, "error retrieving user posts")
+		return
+	}
+	for _, o := range posts {
+		title := o.Title.String
+		if title == "" {
+			title = o.Url
+		}
+		feed.Items = append(feed.Items, rss.OutputItem{
+			ID:          "urn:uuid:" + o.ID.String(),
+			Title:       title,
+			Link:        o.Url,
+			Description: o.Description.String,
+			Published:   o.PublishedAt,
+			Updated:     o.UpdatedAt,
+		})
+		if o.UpdatedAt.After(feed.Updated) {
+			feed.Updated = o.UpdatedAt
+		}
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(200)
+	if err := write(w, feed); err != nil {
+		log.Printf("published feed rendering error: %v\n", err)
+	}
+}
+
+type filterRuleResponse struct {
+	Id        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	FeedId    *string   `json:"feed_id"`
+	Field     string    `json:"field"`
+	Match     string    `json:"match"`
+	Pattern   string    `json:"pattern"`
+	Action    string    `json:"action"`
+	Tag       *string   `json:"tag"`
+}
+
+func dbFilterRuleToFilterRule(o database.FilterRule) filterRuleResponse {
+	var feedId *string
+	if o.FeedID.Valid {
+		id := o.FeedID.UUID.String()
+		feedId = &id
+	}
+	var tag *string
+	if o.Tag.Valid {
+		tag = &o.Tag.String
+	}
+	return filterRuleResponse{
+		Id:        o.ID.String(),
+		CreatedAt: o.CreatedAt,
+		UpdatedAt: o.UpdatedAt,
+		FeedId:    feedId,
+		Field:     o.Field,
+		Match:     o.Match,
+		Pattern:   o.Pattern,
+		Action:    o.Action,
+		Tag:       tag,
+	}
+}
+
+func (a *apiConfig) handlerCreateFilterRule(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.AuthUser).(database.User)
+
+	var request struct {
+		FeedId  *string `json:"feed_id"`
+		Field   string  `json:"field"`
+		Match   string  `json:"match"`
+		Pattern string  `json:"pattern"`
+		Action  string  `json:"action"`
+		Tag     string  `json:"tag"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondWithError(w, 400, "error decoding request body")
+		return
+	}
+	request.Tag = strings.TrimSpace(request.Tag)
+	if request.Match == "" {
+		request.Match = filter.MatchContains
+	}
+	if err := filter.Validate(request.Field, request.Match, request.Pattern, request.Action, request.Tag); err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	var feedId uuid.NullUUID
+	if request.FeedId != nil {
+		id, err := uuid.Parse(*request.FeedId)
+		if err != nil {
+			respondWithError(w, 400, "invalid feed id")
+			return
+		}
+		if _, err := a.DB.GetFeed(r.Context(), id); err != nil {
+			respondWithError(w, 404, "feed not found")
+			return
+		}
+		feedId = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	params := database.CreateFilterRuleParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		UserID:    user.ID,
+		FeedID:    feedId,
+		Field:     request.Field,
+		Match:     request.Match,
+		Pattern:   request.Pattern,
+		Action:    request.Action,
+		Tag:       sql.NullString{String: request.Tag, Valid: request.Action == filter.ActionTag},
+	}
+	rule, err := a.DB.CreateFilterRule(r.Context(), params)
+	if err != nil {
+		log.Printf("filter rule creation error: %v\n", err)
+		respondWithError(w, 500, "error creating filter rule")
+		return
+	}
+	respondWithJSON(w, 201, dbFilterRuleToFilterRule(rule))
+}
+
+func (a *apiConfig) handlerListFilterRules(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.AuthUser).(database.User)
+
+	rules, err := a.DB.ListUserFilterRules(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 500, "error retrieving filter rules")
+		return
+	}
+
+	respRules := make([]filterRuleResponse, 0, len(rules))
+	for _, o := range rules {
+		respRules = append(respRules, dbFilterRuleToFilterRule(o))
+	}
+	respondWithJSON(w, 200, respRules)
+}
+
+func (a *apiConfig) handlerDeleteFilterRule(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.AuthUser).(database.User)
+	ruleId, err := uuid.Parse(r.PathValue("filterRuleID"))
+	if err != nil {
+		respondWithError(w, 400, "invalid filter rule id")
+		return
+	}
+	rule, err := a.DB.GetFilterRule(r.Context(), ruleId)
+	if err != nil {
+		respondWithError(w, 404, "filter rule not found")
+		return
+	}
+	if rule.UserID != user.ID {
+		respondWithError(w, 403, "operation not allowed")
+		return
+	}
+
+	if err := a.DB.DeleteFilterRule(r.Context(), rule.ID); err != nil {
+		respondWithError(w, 500, "error deleting filter rule")
+		return
+	}
+	respondWithJSON(w, 204, struct{}{})
+}
+
+// filterApplyBatch is the number of posts read at once when applying the
+// filter rules to the existing posts.
+const filterApplyBatch = 500
+
+// handlerApplyFilterRules applies the filter rules of the user to the posts
+// of the feeds they follow. States are only ever added to, so deleting a
+// rule doesn't revert the posts it matched.
+func (a *apiConfig) handlerApplyFilterRules(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.AuthUser).(database.User)
+
+	dbRules, err := a.DB.ListUserFilterRules(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 500, "error retrieving filter rules")
+		return
+	}
+	rules, err := filter.Compile(dbRules)
+	if err != nil {
+		log.Printf("filter rules of user %v: %v\n", user.ID, err)
+		respondWithError(w, 500, "error applying filter rules")
+		return
+	}
+
+	resp := struct {
+		Posts   int `json:"posts"`
+		Matched int `json:"matched"`
+	}{}
+	params := database.GetFollowedPostsWithFeedAfterParams{UserID: user.ID, Limit: filterApplyBatch}
+	now := time.Now()
+	for len(rules) > 0 {
+		rows, err := a.DB.GetFollowedPostsWithFeedAfter(r.Context(), params)
+		if err != nil {
+			respondWithError(w, 500, "error applying filter rules")
+			return
+		}
+		for _, o := range rows {
+			resp.Posts++
+			state, ok := filter.Evaluate(rules, filter.NewTarget(o.Post, o.FeedName))[user.ID]
+			if !ok {
+				continue
+			}
+			if err := a.DB.UpsertPostState(r.Context(), state.Params(user.ID, o.Post.ID, now)); err != nil {
+				respondWithError(w, 500, "error applying filter rules")
+				return
+			}
+			resp.Matched++
+		}
+		if len(rows) < filterApplyBatch {
+			break
+		}
+		params.Seq = rows[len(rows)-1].Post.Seq
+	}
+	respondWithJSON(w, 200, resp)
+}
+
+type apiKeyResponse struct {
+	Id         string     `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Label      string     `json:"label"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	// Key is only returned when the key is created.
+	Key string `json:"key,omitempty"`
+}
+
+func dbApiKeyToApiKey(o database.ApiKey) apiKeyResponse {
+	var expiresAt, lastUsedAt, revokedAt *time.Time
+	if o.ExpiresAt.Valid {
+		expiresAt = &o.ExpiresAt.Time
+	}
+	if o.LastUsedAt.Valid {
+		lastUsedAt = &o.LastUsedAt.Time
+	}
+	if o.RevokedAt.Valid {
+		revokedAt = &o.RevokedAt.Time
+	}
+	return apiKeyResponse{
+		Id:         o.ID.String(),
+		CreatedAt:  o.CreatedAt,
+		Label:      o.Label,
+		Prefix:     o.KeyPrefix,
+		Scopes:     o.Scopes,
+		ExpiresAt:  expiresAt,
+		LastUsedAt: lastUsedAt,
+		RevokedAt:  revokedAt,
+	}
+}
+
+func (a *apiConfig) handlerCreateApiKey(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.AuthUser).(database.User)
+
+	var request struct {
+		Label     string     `json:"label"`
+		Scopes    []string   `json:"scopes"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondWithError(w, 400, "error decoding request body")
+		return
+	}
+	label := strings.TrimSpace(request.Label)
+	if label == "" || utf8.RuneCountInString(label) > 255 {
+		respondWithError(w, 400, "invalid label")
+		return
+	}
+	if len(request.Scopes) == 0 {
+		respondWithError(w, 400, "scopes required")
+		return
+	}
+	scopes := make([]string, 0, len(request.Scopes))
+	for _, scope := range request.Scopes {
+		if !validScopes[scope] {
+			respondWithError(w, 400, fmt.Sprintf("invalid scope %q", scope))
+			return
+		}
+		// a key can't create a key more powerful than itself
+		if !middleware.HasScope(r.Context(), scope) {
+			respondWithError(w, 403, fmt.Sprintf("scope %q not granted", scope))
+			return
+		}
+		scopes = append(scopes, scope)
+	}
+	var expiresAt sql.NullTime
+	if request.ExpiresAt != nil {
+		if !request.ExpiresAt.After(time.Now()) {
+			respondWithError(w, 400, "expires_at must be in the future")
+			return
+		}
+		expiresAt = sql.NullTime{Time: *request.ExpiresAt, Valid: true}
+	}
+
+	key, hash, prefix, err := newApiKey()
+	if err != nil {
+		respondWithError(w, 500, "error creating api key")
+		return
+	}
+	params := database.CreateApiKeyParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		UserID:    user.ID,
+		Label:     label,
+		KeyHash:   hash,
+		KeyPrefix: prefix,
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	}
+	apiKey, err := a.DB.CreateApiKey(r.Context(), params)
+	if err != nil {
+		log.Printf("api key creation error: %v\n", err)
+		respondWithError(w, 500, "error creating api key")
+		return
+	}
+	resp := dbApiKeyToApiKey(apiKey)
+	resp.Key = key
+	respondWithJSON(w, 201, resp)
+}
+
+func (a *apiConfig) handlerListApiKeys(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.AuthUser).(database.User)
+
+	keys, err := a.DB.ListUserApiKeys(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 500, "error retrieving api keys")
+		return
+	}
+
+	respKeys := make([]apiKeyResponse, 0, len(keys))
+	for _, o := range keys {
+		respKeys = append(respKeys, dbApiKeyToApiKey(o))
+	}
+	respondWithJSON(w, 200, respKeys)
+}
+
+func (a *apiConfig) handlerRevokeApiKey(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.AuthUser).(database.User)
+	keyId, err := uuid.Parse(r.PathValue("apiKeyID"))
+	if err != nil {
+		respondWithError(w, 400, "invalid api key id")
+		return
+	}
+	apiKey, err := a.DB.GetApiKey(r.Context(), keyId)
+	if err != nil {
+		respondWithError(w, 404, "api key not found")
+		return
+	}
+	if apiKey.UserID != user.ID {
+		respondWithError(w, 403, "operation not allowed")
+		return
+	}
+
+	params := database.RevokeApiKeyParams{ID: apiKey.ID, RevokedAt: sql.NullTime{Time: time.Now(), Valid: true}}
+	if err := a.DB.RevokeApiKey(r.Context(), params); err != nil {
+		respondWithError(w, 500, "error revoking api key")
+		return
+	}
+	respondWithJSON(w, 204, struct{}{})
+}
+
+// maxApiKeyGracePeriod bounds how long a rotated key stays valid.
+const maxApiKeyGracePeriod = 7 * 24 * time.Hour
+
+func (a *apiConfig) handlerRotateApiKey(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.AuthUser).(database.User)
+	// The rotated key grants every scope, so only the key it replaces or a
+	// session may ask for it.
+	if !middleware.HasScope(r.Context(), middleware.ScopeAll) {
+		respondWithError(w, 403, "operation not allowed")
+		return
+	}
+
+	var request struct {
+		GracePeriodSeconds int64 `json:"grace_period_seconds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+		respondWithError(w, 400, "error decoding request body")
+		return
+	}
+	gracePeriod := time.Duration(request.GracePeriodSeconds) * time.Second
+	if gracePeriod < 0 || gracePeriod > maxApiKeyGracePeriod {
+		respondWithError(w, 400, "invalid grace_period_seconds")
+		return
+	}
+
+	apiKey, hash, prefix, err := newApiKey()
+	if err != nil {
+		respondWithError(w, 500, "error rotating api key")
+		return
+	}
+	now := time.Now()
+	params := database.RotateUserApiKeyParams{
+		ID:           user.ID,
+		ApiKeyHash:   hash,
+		ApiKeyPrefix: prefix,
+		UpdatedAt:    now,
+	}
+	if gracePeriod > 0 {
+		params.PreviousApiKeyHash = sql.NullString{String: user.ApiKeyHash, Valid: true}
+		params.PreviousApiKeyExpiresAt = sql.NullTime{Time: now.Add(gracePeriod), Valid: true}
+	}
+	rotated, err := a.DB.RotateUserApiKey(r.Context(), params)
+	if err != nil {
+		log.Printf("api key rotation error: %v\n", err)
+		respondWithError(w, 500, "error rotating api key")
+		return
+	}
+
+	details := map[string]interface{}{
+		"previous_prefix": user.ApiKeyPrefix,
+		"prefix":          prefix,
+	}
+	if params.PreviousApiKeyExpiresAt.Valid {
+		details["previous_expires_at"] = params.PreviousApiKeyExpiresAt.Time
+	}
+	a.audit(r, user.ID, auditApiKeyRotated, details)
+
+	resp := dbUserToUser(rotated)
+	resp.ApiKey = apiKey
+	respondWithJSON(w, 200, resp)
+}
+
+type auditEventResponse struct {
+	Id         string          `json:"id"`
+	CreatedAt  time.Time       `json:"created_at"`
+	Action     string          `json:"action"`
+	RemoteAddr *string         `json:"remote_addr"`
+	Details    json.RawMessage `json:"details"`
+}
+
+func dbAuditEventToAuditEvent(o database.AuditEvent) auditEventResponse {
+	var remoteAddr *string
+	if o.RemoteAddr.Valid {
+		remoteAddr = &o.RemoteAddr.String
+	}
+	return auditEventResponse{
+		Id:         o.ID.String(),
+		CreatedAt:  o.CreatedAt,
+		Action:     o.Action,
+		RemoteAddr: remoteAddr,
+		Details:    o.Details,
+	}
+}
+
+func (a *apiConfig) handlerListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.AuthUser).(database.User)
+
+	var limit int32 = 50
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		limitInt, err := strconv.ParseInt(limitStr, 10, 32)
+		if err != nil {
+			respondWithError(w, 400, "invalid limit query parameter")
+			return
+		}
+		limit = int32(limitInt)
+	}
+
+	params := database.ListUserAuditEventsParams{UserID: user.ID, Limit: limit}
+	events, err := a.DB.ListUserAuditEvents(r.Context(), params)
+	if err != nil {
+		respondWithError(w, 500, "error retrieving audit events")
+		return
+	}
+
+	respEvents := make([]auditEventResponse, 0, len(events))
+	for _, o := range events {
+		respEvents = append(respEvents, dbAuditEventToAuditEvent(o))
+	}
+	respondWithJSON(w, 200, respEvents)
+}
+
+type sessionTokensResponse struct {
+	TokenType        string    `json:"token_type"`
+	AccessToken      string    `json:"access_token"`
+	ExpiresIn        int64     `json:"expires_in"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+func tokensToResponse(o sessionTokens) sessionTokensResponse {
+	return sessionTokensResponse{
+		TokenType:        middleware.SchemeBearer,
+		AccessToken:      o.AccessToken,
+		ExpiresIn:        int64(accessTokenTTL / time.Second),
+		RefreshToken:     o.RefreshToken,
+		RefreshExpiresAt: o.RefreshExpiresAt,
+	}
+}
+
+type sessionResponse struct {
+	Id               string     `json:"id"`
+	CreatedAt        time.Time  `json:"created_at"`
+	RefreshedAt      *time.Time `json:"refreshed_at"`
+	RefreshExpiresAt time.Time  `json:"refresh_expires_at"`
+}
+
+func dbSessionToSession(o database.Session) sessionResponse {
+	var refreshedAt *time.Time
+	if o.RefreshedAt.Valid {
+		refreshedAt = &o.RefreshedAt.Time
+	}
+	return sessionResponse{
+		Id:               o.ID.String(),
+		CreatedAt:        o.CreatedAt,
+		RefreshedAt:      refreshedAt,
+		RefreshExpiresAt: o.RefreshExpiresAt,
+	}
+}
+
+func (a *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondWithError(w, 400, "error decoding request body")
+		return
+	}
+
+	email := sql.NullString{String: strings.ToLower(request.Email), Valid: true}
+	user, err := a.DB.GetUserByEmail(r.Context(), email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 500, "error logging in")
+		return
+	}
+	if !checkPassword(user, request.Password) {
+		respondWithError(w, 401, "invalid email or password")
+		return
+	}
+	if user.DisabledAt.Valid {
+		respondWithError(w, 403, "account disabled")
+		return
+	}
+
+	now := time.Now()
+	tokens, err := newSessionTokens(now)
+	if err != nil {
+		respondWithError(w, 500, "error logging in")
+		return
+	}
+	params := database.CreateSessionParams{
+		ID:               uuid.New(),
+		CreatedAt:        now,
+		UserID:           user.ID,
+		AccessTokenHash:  hashApiKey(tokens.AccessToken),
+		AccessExpiresAt:  tokens.AccessExpiresAt,
+		RefreshTokenHash: hashApiKey(tokens.RefreshToken),
+		RefreshExpiresAt: tokens.RefreshExpiresAt,
+	}
+	session, err := a.DB.CreateSession(r.Context(), params)
+	if err != nil {
+		log.Printf("session creation error: %v\n", err)
+		respondWithError(w, 500, "error logging in")
+		return
+	}
+	a.audit(r, user.ID, auditLogin, map[string]interface{}{"session_id": session.ID})
+
+	respondWithJSON(w, 200, tokensToResponse(tokens))
+}
+
+// handlerRefreshSession exchanges a refresh token for new tokens. The used
+// refresh token stops being valid.
+func (a *apiConfig) handlerRefreshSession(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondWithError(w, 400, "error decoding request body")
+		return
+	}
+
+	now := time.Now()
+	tokens, err := newSessionTokens(now)
+	if err != nil {
+		respondWithError(w, 500, "error refreshing session")
+		return
+	}
+	params := database.RefreshSessionParams{
+		AccessTokenHash:          hashApiKey(tokens.AccessToken),
+		AccessExpiresAt:          tokens.AccessExpiresAt,
+		RefreshTokenHash:         hashApiKey(tokens.RefreshToken),
+		RefreshExpiresAt:         tokens.RefreshExpiresAt,
+		RefreshedAt:              sql.NullTime{Time: now, Valid: true},
+		PreviousRefreshTokenHash: hashApiKey(request.RefreshToken),
+	}
+	if _, err := a.DB.RefreshSession(r.Context(), params); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, 401, "invalid refresh token")
+			return
+		}
+		log.Printf("session refresh error: %v\n", err)
+		respondWithError(w, 500, "error refreshing session")
+		return
+	}
+
+	respondWithJSON(w, 200, tokensToResponse(tokens))
+}
+
+// handlerLogout revokes the session of a refresh token. Unknown and already
+// revoked tokens are ignored.
+func (a *apiConfig) handlerLogout(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondWithError(w, 400, "error decoding request body")
+		return
+	}
+
+	params := database.RevokeSessionByRefreshHashParams{
+		RefreshTokenHash: hashApiKey(request.RefreshToken),
+		RevokedAt:        sql.NullTime{Time: time.Now(), Valid: true},
+	}
+	session, err := a.DB.RevokeSessionByRefreshHash(r.Context(), params)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 500, "error logging out")
+		return
+	}
+	if err == nil {
+		a.audit(r, session.UserID, auditLogout, map[string]interface{}{"session_id": session.ID})
+	}
+	respondWithJSON(w, 204, struct{}{})
+}
+
+func (a *apiConfig) handlerListSessions(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.AuthUser).(database.User)
+
+	sessions, err := a.DB.ListUserSessions(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 500, "error retrieving sessions")
+		return
+	}
+
+	respSessions := make([]sessionResponse, 0, len(sessions))
+	for _, o := range sessions {
+		respSessions = append(respSessions, dbSessionToSession(o))
+	}
+	respondWithJSON(w, 200, respSessions)
+}
+
+func (a *apiConfig) handlerRevokeSession(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.AuthUser).(database.User)
+	sessionId, err := uuid.Parse(r.PathValue("sessionID"))
+	if err != nil {
+		respondWithError(w, 400, "invalid session id")
+		return
+	}
+	session, err := a.DB.GetSession(r.Context(), sessionId)
+	if err != nil {
+		respondWithError(w, 404, "session not found")
+		return
+	}
+	if session.UserID != user.ID {
+		respondWithError(w, 403, "operation not allowed")
+		return
+	}
+
+	params := database.RevokeSessionParams{ID: session.ID, RevokedAt: sql.NullTime{Time: time.Now(), Valid: true}}
+	if err := a.DB.RevokeSession(r.Context(), params); err != nil {
+		respondWithError(w, 500, "error revoking session")
+		return
+	}
+	a.audit(r, user.ID, auditLogout, map[string]interface{}{"session_id": session.ID})
+	respondWithJSON(w, 204, struct{}{})
+}
@@ -3,10 +3,15 @@ package api
 import (
 	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -21,11 +26,12 @@ var now = time.Now()
 
 func setupUser() database.User {
 	return database.User{
-		ID:        uuid.New(),
-		CreatedAt: now,
-		UpdatedAt: now,
-		Name:      "FooBar",
-		ApiKey:    "qwerty-12345-asdf",
+		ID:           uuid.New(),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		Name:         "FooBar",
+		ApiKeyHash:   hashApiKey("qwerty-12345-asdf"),
+		ApiKeyPrefix: "qwerty-1",
 	}
 }
 
@@ -35,7 +41,7 @@ func compareUser(t *testing.T, expected database.User, actual userResponse) {
 	require.WithinDuration(t, expected.CreatedAt, actual.CreatedAt, time.Duration(1))
 	require.WithinDuration(t, expected.UpdatedAt, actual.UpdatedAt, time.Duration(1))
 	require.Equal(t, expected.Name, actual.Name)
-	require.Equal(t, expected.ApiKey, actual.ApiKey)
+	require.Equal(t, expected.ApiKeyPrefix, actual.ApiKeyPrefix)
 }
 
 func compareError(t *testing.T, rw *httptest.ResponseRecorder, expectedCode int, expectedMsg string) {
@@ -49,7 +55,9 @@ func compareError(t *testing.T, rw *httptest.ResponseRecorder, expectedCode int,
 func setupCreateUserTest(t *testing.T, mockDbApi *MockedDbApi, user database.User, err error) (*httptest.ResponseRecorder, *http.Request, apiConfig) {
 	t.Helper()
 	testApi := apiConfig{DB: mockDbApi}
-	mockDbApi.On("CreateUser", mock.Anything, mock.Anything).Return(user, err)
+	mockDbApi.On("CreateUser", mock.Anything, mock.MatchedBy(func(p database.CreateUserParams) bool {
+		return p.Name == "FooBar" && len(p.ApiKeyHash) == 64 && len(p.ApiKeyPrefix) == apiKeyPrefixLength
+	})).Return(user, err)
 
 	body, err := json.Marshal(map[string]string{"name": "FooBar"})
 	require.NoError(t, err)
@@ -73,6 +81,10 @@ func TestCreateUserHandler(t *testing.T) {
 		err := json.NewDecoder(rw.Body).Decode(&resp)
 		require.NoError(t, err)
 		compareUser(t, user, resp)
+		require.Len(t, resp.ApiKey, 64)
+		mockDbApi.AssertCalled(t, "CreateUser", mock.Anything, mock.MatchedBy(func(p database.CreateUserParams) bool {
+			return p.ApiKeyHash == hashApiKey(resp.ApiKey) && p.ApiKeyPrefix == resp.ApiKey[:apiKeyPrefixLength]
+		}))
 
 		mockDbApi.AssertExpectations(t)
 	})
@@ -114,6 +126,7 @@ func TestGetUserHandler(t *testing.T) {
 		err := json.NewDecoder(rw.Body).Decode(&resp)
 		require.NoError(t, err)
 		compareUser(t, user, resp)
+		require.Empty(t, resp.ApiKey)
 
 		mockDbApi.AssertExpectations(t)
 	})
@@ -128,7 +141,7 @@ func setupFeed() database.Feed {
 		Name:          "TestFeed",
 		Url:           "http://example.com",
 		LastFetchedAt: sql.NullTime{},
-		UserID:        uuid.New(),
+		UserID:        uuid.NullUUID{UUID: uuid.New(), Valid: true},
 	}
 }
 
@@ -148,7 +161,7 @@ func compareFeed(t *testing.T, expected database.Feed, actual feedResponse) {
 	require.WithinDuration(t, expected.UpdatedAt, actual.UpdatedAt, time.Duration(1))
 	require.Equal(t, expected.Name, actual.Name)
 	require.Equal(t, expected.Url, actual.Url)
-	require.Equal(t, expected.UserID.String(), actual.UserId)
+	require.Equal(t, expected.UserID.UUID.String(), *actual.UserId)
 }
 
 func setupCreateFeedTest(t *testing.T, mockDbApi *MockedDbApi, user database.User, feed database.Feed, follow database.FeedFollow, err error) (*httptest.ResponseRecorder, *http.Request, apiConfig) {
@@ -218,3 +231,782 @@ func TestCreateFeedHandler(t *testing.T) {
 		mockDbApi.AssertExpectations(t)
 	})
 }
+
+func setupPost(feedId uuid.UUID) database.Post {
+	return database.Post{
+		ID:          uuid.New(),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Url:         "http://example.com/" + uuid.NewString(),
+		Title:       sql.NullString{String: "TestPost", Valid: true},
+		Description: sql.NullString{},
+		PublishedAt: now,
+		FeedID:      feedId,
+	}
+}
+
+func setupListPostsTest(t *testing.T, mockDbApi *MockedDbApi, user database.User, query string) (*httptest.ResponseRecorder, *http.Request, apiConfig) {
+	t.Helper()
+	testApi := apiConfig{DB: mockDbApi}
+
+	req, err := http.NewRequest(http.MethodGet, "/v1/posts"+query, nil)
+	require.NoError(t, err)
+	ctx := context.WithValue(req.Context(), middleware.AuthUser, user)
+	rw := httptest.NewRecorder()
+
+	return rw, req.WithContext(ctx), testApi
+}
+
+func TestListPostsHandler(t *testing.T) {
+	t.Run("return 200 with enclosures", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		user := setupUser()
+		post := setupPost(uuid.New())
+		episode := database.PostEpisode{
+			ID:              uuid.New(),
+			PostID:          post.ID,
+			EnclosureUrl:    "http://example.com/ep1.mp3",
+			EnclosureType:   "audio/mpeg",
+			DurationSeconds: sql.NullInt32{Int32: 3723, Valid: true},
+		}
+		params := database.GetUserPostsWithEnclosureParams{UserID: user.ID, Limit: 20, MediaType: "audio"}
+		rows := []database.GetUserPostsWithEnclosureRow{{Post: post, PostEpisode: episode}}
+		mockDbApi.On("GetUserPostsWithEnclosure", mock.Anything, params).Return(rows, nil)
+		statesParams := database.ListPostStatesParams{UserID: user.ID, PostIds: []uuid.UUID{post.ID}}
+		mockDbApi.On("ListPostStates", mock.Anything, statesParams).Return([]database.PostState{}, nil)
+		rw, req, testApi := setupListPostsTest(t, mockDbApi, user, "?has_enclosure=audio")
+
+		testApi.handlerListPosts(rw, req)
+
+		require.Equal(t, http.StatusOK, rw.Code)
+		var resp []postResponse
+		err := json.NewDecoder(rw.Body).Decode(&resp)
+		require.NoError(t, err)
+		require.Len(t, resp, 1)
+		require.Equal(t, post.ID.String(), resp[0].Id)
+		require.NotNil(t, resp[0].Episode)
+		require.Equal(t, episode.EnclosureUrl, resp[0].Episode.EnclosureUrl)
+		require.Equal(t, int32(3723), *resp[0].Episode.DurationSeconds)
+		require.Nil(t, resp[0].State)
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	t.Run("return 200 with filter states", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		user := setupUser()
+		starred, plain := setupPost(uuid.New()), setupPost(uuid.New())
+		params := database.GetUserPostsParams{UserID: user.ID, Limit: 20}
+		mockDbApi.On("GetUserPosts", mock.Anything, params).Return([]database.Post{starred, plain}, nil)
+		statesParams := database.ListPostStatesParams{UserID: user.ID, PostIds: []uuid.UUID{starred.ID, plain.ID}}
+		states := []database.PostState{{UserID: user.ID, PostID: starred.ID, Starred: true, Tags: []string{"jobs"}}}
+		mockDbApi.On("ListPostStates", mock.Anything, statesParams).Return(states, nil)
+		rw, req, testApi := setupListPostsTest(t, mockDbApi, user, "")
+
+		testApi.handlerListPosts(rw, req)
+
+		require.Equal(t, http.StatusOK, rw.Code)
+		var resp []postResponse
+		require.NoError(t, json.NewDecoder(rw.Body).Decode(&resp))
+		require.Len(t, resp, 2)
+		require.Equal(t, &postStateResponse{Starred: true, Tags: []string{"jobs"}}, resp[0].State)
+		require.Nil(t, resp[1].State)
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	t.Run("return 200 with escaped titles", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		user := setupUser()
+		post := setupPost(uuid.New())
+		post.Title = sql.NullString{String: "<script>alert(1)</script>", Valid: true}
+		params := database.GetUserPostsParams{UserID: user.ID, Limit: 20}
+		mockDbApi.On("GetUserPosts", mock.Anything, params).Return([]database.Post{post}, nil)
+		statesParams := database.ListPostStatesParams{UserID: user.ID, PostIds: []uuid.UUID{post.ID}}
+		mockDbApi.On("ListPostStates", mock.Anything, statesParams).Return([]database.PostState{}, nil)
+		rw, req, testApi := setupListPostsTest(t, mockDbApi, user, "")
+
+		testApi.handlerListPosts(rw, req)
+
+		require.Equal(t, http.StatusOK, rw.Code)
+		require.NotContains(t, rw.Body.String(), "<script>")
+		var resp []postResponse
+		require.NoError(t, json.NewDecoder(rw.Body).Decode(&resp))
+		require.Equal(t, "<script>alert(1)</script>", *resp[0].Title)
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	t.Run("return 400 on unknown media type", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		rw, req, testApi := setupListPostsTest(t, mockDbApi, setupUser(), "?has_enclosure=text")
+
+		testApi.handlerListPosts(rw, req)
+
+		compareError(t, rw, http.StatusBadRequest, "invalid has_enclosure query parameter")
+
+		mockDbApi.AssertExpectations(t)
+	})
+}
+
+func setupUpdateFeedTest(t *testing.T, mockDbApi *MockedDbApi, user database.User, feed database.Feed, body string) (*httptest.ResponseRecorder, *http.Request, apiConfig) {
+	t.Helper()
+	testApi := apiConfig{DB: mockDbApi}
+	mockDbApi.On("GetFeed", mock.Anything, feed.ID).Return(feed, nil)
+
+	req, err := http.NewRequest(http.MethodPatch, "/v1/feeds/"+feed.ID.String(), bytes.NewBufferString(body))
+	require.NoError(t, err)
+	req.SetPathValue("feedID", feed.ID.String())
+	ctx := context.WithValue(req.Context(), middleware.AuthUser, user)
+	rw := httptest.NewRecorder()
+
+	return rw, req.WithContext(ctx), testApi
+}
+
+func TestUpdateFeedHandler(t *testing.T) {
+	t.Run("return 200", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		user := setupUser()
+		feed := setupFeed()
+		feed.UserID = uuid.NullUUID{UUID: user.ID, Valid: true}
+		updated := feed
+		updated.FetchFullContent = true
+		mockDbApi.On("SetFeedFetchFullContent", mock.Anything, mock.MatchedBy(func(p database.SetFeedFetchFullContentParams) bool {
+			return p.ID == feed.ID && p.FetchFullContent
+		})).Return(updated, nil)
+		rw, req, testApi := setupUpdateFeedTest(t, mockDbApi, user, feed, `{"fetch_full_content": true}`)
+
+		testApi.handlerUpdateFeed(rw, req)
+
+		require.Equal(t, http.StatusOK, rw.Code)
+		var resp feedResponse
+		err := json.NewDecoder(rw.Body).Decode(&resp)
+		require.NoError(t, err)
+		compareFeed(t, updated, resp)
+		require.True(t, resp.FetchFullContent)
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	t.Run("return 400 on invalid proxy", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		feed := setupFeed()
+		req, err := http.NewRequest(http.MethodPatch, "/v1/feeds/"+feed.ID.String(), bytes.NewBufferString(`{"proxy_url": "ftp://proxy"}`))
+		require.NoError(t, err)
+		req.SetPathValue("feedID", feed.ID.String())
+		ctx := context.WithValue(req.Context(), middleware.AuthUser, setupUser())
+		rw := httptest.NewRecorder()
+
+		testApi.handlerUpdateFeed(rw, req.WithContext(ctx))
+
+		compareError(t, rw, http.StatusBadRequest, "invalid proxy url")
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	t.Run("return 403", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		rw, req, testApi := setupUpdateFeedTest(t, mockDbApi, setupUser(), setupFeed(), `{"fetch_full_content": true}`)
+
+		testApi.handlerUpdateFeed(rw, req)
+
+		compareError(t, rw, http.StatusForbidden, "operation not allowed")
+
+		mockDbApi.AssertExpectations(t)
+	})
+}
+
+func TestListFeedsHandler(t *testing.T) {
+	t.Run("return 200 with fetch errors", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		healthy := setupFeed()
+		failing := setupFeed()
+		failing.ConsecutiveFailures = 2
+		failing.LastError = sql.NullString{String: "unexpected status 404 Not Found", Valid: true}
+		failing.LastErrorKind = sql.NullString{String: "http_status", Valid: true}
+		failing.LastErrorStatus = sql.NullInt32{Int32: 404, Valid: true}
+		failing.LastErrorAt = sql.NullTime{Time: now, Valid: true}
+		failing.NextFetchAt = sql.NullTime{Time: now.Add(time.Hour), Valid: true}
+		mockDbApi.On("ListFeeds", mock.Anything).Return([]database.Feed{healthy, failing}, nil)
+		req, err := http.NewRequest(http.MethodGet, "/v1/feeds", nil)
+		require.NoError(t, err)
+		rw := httptest.NewRecorder()
+
+		testApi.handlerListFeeds(rw, req)
+
+		require.Equal(t, http.StatusOK, rw.Code)
+		var resp []feedResponse
+		err = json.NewDecoder(rw.Body).Decode(&resp)
+		require.NoError(t, err)
+		require.Len(t, resp, 2)
+		require.Nil(t, resp[0].LastError)
+		require.NotNil(t, resp[1].LastError)
+		require.Equal(t, "http_status", resp[1].LastError.Kind)
+		require.Equal(t, int32(404), *resp[1].LastError.StatusCode)
+		require.Equal(t, int32(2), resp[1].ConsecutiveFailures)
+		require.NotNil(t, resp[1].NextFetchAt)
+
+		mockDbApi.AssertExpectations(t)
+	})
+}
+
+func setupListFeedFetchesTest(t *testing.T, mockDbApi *MockedDbApi, user database.User, feed database.Feed) (*httptest.ResponseRecorder, *http.Request, apiConfig) {
+	t.Helper()
+	testApi := apiConfig{DB: mockDbApi}
+	mockDbApi.On("GetFeed", mock.Anything, feed.ID).Return(feed, nil)
+
+	req, err := http.NewRequest(http.MethodGet, "/v1/feeds/"+feed.ID.String()+"/fetches", nil)
+	require.NoError(t, err)
+	req.SetPathValue("feedID", feed.ID.String())
+	ctx := context.WithValue(req.Context(), middleware.AuthUser, user)
+	rw := httptest.NewRecorder()
+
+	return rw, req.WithContext(ctx), testApi
+}
+
+func TestListFeedFetchesHandler(t *testing.T) {
+	t.Run("return 200", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		user := setupUser()
+		feed := setupFeed()
+		feed.UserID = uuid.NullUUID{UUID: user.ID, Valid: true}
+		fetch := database.FeedFetch{
+			ID:            uuid.New(),
+			FeedID:        feed.ID,
+			StartedAt:     now,
+			FinishedAt:    now.Add(time.Second),
+			DurationMs:    1000,
+			StatusCode:    sql.NullInt32{Int32: 200, Valid: true},
+			Bytes:         2048,
+			ItemsSeen:     10,
+			PostsInserted: 3,
+		}
+		params := database.ListFeedFetchesParams{FeedID: feed.ID, Limit: 20}
+		mockDbApi.On("ListFeedFetches", mock.Anything, params).Return([]database.FeedFetch{fetch}, nil)
+		rw, req, testApi := setupListFeedFetchesTest(t, mockDbApi, user, feed)
+
+		testApi.handlerListFeedFetches(rw, req)
+
+		require.Equal(t, http.StatusOK, rw.Code)
+		var resp []fetchResponse
+		err := json.NewDecoder(rw.Body).Decode(&resp)
+		require.NoError(t, err)
+		require.Len(t, resp, 1)
+		require.Equal(t, fetch.ID.String(), resp[0].Id)
+		require.Equal(t, int32(200), *resp[0].StatusCode)
+		require.Equal(t, int32(3), resp[0].PostsInserted)
+		require.Nil(t, resp[0].Error)
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	t.Run("return 403", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		rw, req, testApi := setupListFeedFetchesTest(t, mockDbApi, setupUser(), setupFeed())
+
+		testApi.handlerListFeedFetches(rw, req)
+
+		compareError(t, rw, http.StatusForbidden, "operation not allowed")
+
+		mockDbApi.AssertExpectations(t)
+	})
+}
+
+func setupWebSubSubscription() database.WebsubSubscription {
+	return database.WebsubSubscription{
+		FeedID:        uuid.New(),
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		HubUrl:        "http://hub.example.com/",
+		Topic:         "http://example.com/feed.xml",
+		Secret:        "s3cret",
+		RequestedAt:   now,
+		CallbackToken: "t0k3n",
+	}
+}
+
+func TestWebSubVerifyHandler(t *testing.T) {
+	verifyRequest := func(sub database.WebsubSubscription, query url.Values) *http.Request {
+		req, err := http.NewRequest(http.MethodGet, "/v1/websub/"+sub.FeedID.String()+"?"+query.Encode(), nil)
+		require.NoError(t, err)
+		req.SetPathValue("feedID", sub.FeedID.String())
+		return req
+	}
+
+	t.Run("return 200 with the challenge", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		sub := setupWebSubSubscription()
+		mockDbApi.On("GetWebsubSubscription", mock.Anything, sub.FeedID).Return(sub, nil)
+		mockDbApi.On("VerifyWebsubSubscription", mock.Anything, mock.MatchedBy(func(p database.VerifyWebsubSubscriptionParams) bool {
+			lease := p.LeaseExpiresAt.Time.Sub(p.VerifiedAt.Time)
+			window := p.VerifiedAt.Time.Sub(p.RequestedAfter)
+			return p.FeedID == sub.FeedID && p.CallbackToken == "t0k3n" && lease == time.Hour && window == websubVerifyWindow
+		})).Return(sub, nil)
+		rw := httptest.NewRecorder()
+
+		testApi.handlerWebSubVerify(rw, verifyRequest(sub, url.Values{
+			"token":             {"t0k3n"},
+			"hub.mode":          {"subscribe"},
+			"hub.topic":         {sub.Topic},
+			"hub.challenge":     {"ch4llenge"},
+			"hub.lease_seconds": {"3600"},
+		}))
+
+		require.Equal(t, http.StatusOK, rw.Code)
+		require.Equal(t, "ch4llenge", rw.Body.String())
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	t.Run("return 404 on unknown topic", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		sub := setupWebSubSubscription()
+		mockDbApi.On("GetWebsubSubscription", mock.Anything, sub.FeedID).Return(sub, nil)
+		rw := httptest.NewRecorder()
+
+		testApi.handlerWebSubVerify(rw, verifyRequest(sub, url.Values{
+			"token":             {"t0k3n"},
+			"hub.mode":          {"subscribe"},
+			"hub.topic":         {"http://example.com/other.xml"},
+			"hub.challenge":     {"ch4llenge"},
+			"hub.lease_seconds": {"3600"},
+		}))
+
+		compareError(t, rw, http.StatusNotFound, "unknown topic")
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	t.Run("cap the lease", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		sub := setupWebSubSubscription()
+		mockDbApi.On("GetWebsubSubscription", mock.Anything, sub.FeedID).Return(sub, nil)
+		mockDbApi.On("VerifyWebsubSubscription", mock.Anything, mock.MatchedBy(func(p database.VerifyWebsubSubscriptionParams) bool {
+			return p.LeaseExpiresAt.Time.Sub(p.VerifiedAt.Time) == maxWebsubLease
+		})).Return(sub, nil)
+		rw := httptest.NewRecorder()
+
+		testApi.handlerWebSubVerify(rw, verifyRequest(sub, url.Values{
+			"token":             {"t0k3n"},
+			"hub.mode":          {"subscribe"},
+			"hub.topic":         {sub.Topic},
+			"hub.challenge":     {"ch4llenge"},
+			"hub.lease_seconds": {"999999999"},
+		}))
+
+		require.Equal(t, http.StatusOK, rw.Code)
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	t.Run("return 404 on wrong token", func(t *testing.T) {
+		for _, token := range []string{"", "nope"} {
+			mockDbApi := new(MockedDbApi)
+			testApi := apiConfig{DB: mockDbApi}
+			sub := setupWebSubSubscription()
+			mockDbApi.On("GetWebsubSubscription", mock.Anything, sub.FeedID).Return(sub, nil)
+			rw := httptest.NewRecorder()
+
+			testApi.handlerWebSubVerify(rw, verifyRequest(sub, url.Values{
+				"token":             {token},
+				"hub.mode":          {"subscribe"},
+				"hub.topic":         {sub.Topic},
+				"hub.challenge":     {"ch4llenge"},
+				"hub.lease_seconds": {"3600"},
+			}))
+
+			compareError(t, rw, http.StatusNotFound, "subscription not found")
+
+			mockDbApi.AssertExpectations(t)
+		}
+	})
+
+	t.Run("return 404 without pending request", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		sub := setupWebSubSubscription()
+		mockDbApi.On("GetWebsubSubscription", mock.Anything, sub.FeedID).Return(sub, nil)
+		mockDbApi.On("VerifyWebsubSubscription", mock.Anything, mock.Anything).Return(database.WebsubSubscription{}, sql.ErrNoRows)
+		rw := httptest.NewRecorder()
+
+		testApi.handlerWebSubVerify(rw, verifyRequest(sub, url.Values{
+			"token":             {"t0k3n"},
+			"hub.mode":          {"subscribe"},
+			"hub.topic":         {sub.Topic},
+			"hub.challenge":     {"ch4llenge"},
+			"hub.lease_seconds": {"3600"},
+		}))
+
+		compareError(t, rw, http.StatusNotFound, "no pending subscription")
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	t.Run("delete denied subscriptions", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		sub := setupWebSubSubscription()
+		mockDbApi.On("GetWebsubSubscription", mock.Anything, sub.FeedID).Return(sub, nil)
+		mockDbApi.On("DeleteWebsubSubscription", mock.Anything, sub.FeedID).Return(nil)
+		rw := httptest.NewRecorder()
+
+		testApi.handlerWebSubVerify(rw, verifyRequest(sub, url.Values{
+			"token":      {"t0k3n"},
+			"hub.mode":   {"denied"},
+			"hub.topic":  {sub.Topic},
+			"hub.reason": {"not allowed"},
+		}))
+
+		require.Equal(t, http.StatusOK, rw.Code)
+
+		mockDbApi.AssertExpectations(t)
+	})
+}
+
+func TestWebSubPushHandler(t *testing.T) {
+	body := []byte(`<rss version="2.0"><channel><title>Blog</title></channel></rss>`)
+	sign := func(secret string) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	setupPush := func(mockDbApi *MockedDbApi, sub database.WebsubSubscription, signature string) (*httptest.ResponseRecorder, *http.Request) {
+		mockDbApi.On("GetWebsubSubscription", mock.Anything, sub.FeedID).Return(sub, nil)
+		req, err := http.NewRequest(http.MethodPost, "/v1/websub/"+sub.FeedID.String(), bytes.NewReader(body))
+		require.NoError(t, err)
+		req.SetPathValue("feedID", sub.FeedID.String())
+		req.Header.Set("Content-Type", "application/rss+xml")
+		req.Header.Set("X-Hub-Signature", signature)
+		return httptest.NewRecorder(), req
+	}
+
+	t.Run("ingest signed content", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		sub := setupWebSubSubscription()
+		feed := setupFeed()
+		feed.ID = sub.FeedID
+		mockDbApi.On("GetFeed", mock.Anything, feed.ID).Return(feed, nil)
+		var ingested []byte
+		testApi := apiConfig{DB: mockDbApi, Ingest: func(f database.Feed, b []byte, contentType string) error {
+			require.Equal(t, feed.ID, f.ID)
+			require.Equal(t, "application/rss+xml", contentType)
+			ingested = b
+			return nil
+		}}
+		rw, req := setupPush(mockDbApi, sub, sign(sub.Secret))
+
+		testApi.handlerWebSubPush(rw, req)
+
+		require.Equal(t, http.StatusAccepted, rw.Code)
+		require.Equal(t, body, ingested)
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	t.Run("ignore content with an invalid signature", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		sub := setupWebSubSubscription()
+		testApi := apiConfig{DB: mockDbApi, Ingest: func(f database.Feed, b []byte, contentType string) error {
+			t.Fatal("unexpected ingest")
+			return nil
+		}}
+		rw, req := setupPush(mockDbApi, sub, sign("forged"))
+
+		testApi.handlerWebSubPush(rw, req)
+
+		require.Equal(t, http.StatusAccepted, rw.Code)
+
+		mockDbApi.AssertExpectations(t)
+	})
+}
+
+func TestPublishedFeedHandlers(t *testing.T) {
+	setupPublished := func(t *testing.T, mockDbApi *MockedDbApi, path string) (*httptest.ResponseRecorder, *http.Request, database.Post) {
+		t.Helper()
+		user := setupUser()
+		published := database.PublishedFeed{ID: uuid.New(), CreatedAt: now, UserID: user.ID, Kind: publishedTimeline, Token: "t0ken"}
+		post := setupPost(uuid.New())
+		mockDbApi.On("GetPublishedFeedByToken", mock.Anything, "t0ken").Return(published, nil)
+		mockDbApi.On("GetUser", mock.Anything, user.ID).Return(user, nil)
+		params := database.GetFollowedPostsParams{UserID: user.ID, Limit: defaultPublishedItems}
+		mockDbApi.On("GetFollowedPosts", mock.Anything, params).Return([]database.Post{post}, nil)
+
+		req, err := http.NewRequest(http.MethodGet, "http://bloggogrator.example.com"+path, nil)
+		require.NoError(t, err)
+		req.SetPathValue("token", "t0ken")
+		return httptest.NewRecorder(), req, post
+	}
+
+	t.Run("render rss", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		rw, req, post := setupPublished(t, mockDbApi, "/v1/published/t0ken/rss")
+
+		testApi.handlerPublishedRss(rw, req)
+
+		require.Equal(t, http.StatusOK, rw.Code)
+		require.Equal(t, "application/rss+xml; charset=utf-8", rw.Header().Get("Content-Type"))
+		body := rw.Body.String()
+		require.Contains(t, body, `<atom:link rel="self" type="application/rss+xml" href="http://bloggogrator.example.com/v1/published/t0ken/rss"></atom:link>`)
+		require.Contains(t, body, "<guid isPermaLink=\"false\">urn:uuid:"+post.ID.String()+"</guid>")
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	t.Run("render atom", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		rw, req, post := setupPublished(t, mockDbApi, "/v1/published/t0ken/atom")
+
+		testApi.handlerPublishedAtom(rw, req)
+
+		require.Equal(t, http.StatusOK, rw.Code)
+		require.Equal(t, "application/atom+xml; charset=utf-8", rw.Header().Get("Content-Type"))
+		require.Contains(t, rw.Body.String(), "<id>urn:uuid:"+post.ID.String()+"</id>")
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	t.Run("return 404 on unknown token", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		mockDbApi.On("GetPublishedFeedByToken", mock.Anything, "unknown").Return(database.PublishedFeed{}, sql.ErrNoRows)
+		req, err := http.NewRequest(http.MethodGet, "/v1/published/unknown/rss", nil)
+		require.NoError(t, err)
+		req.SetPathValue("token", "unknown")
+		rw := httptest.NewRecorder()
+
+		testApi.handlerPublishedRss(rw, req)
+
+		compareError(t, rw, http.StatusNotFound, "feed not found")
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	t.Run("return 404 when the owner is disabled", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		user := setupUser()
+		user.DisabledAt = sql.NullTime{Time: now, Valid: true}
+		published := database.PublishedFeed{ID: uuid.New(), CreatedAt: now, UserID: user.ID, Kind: publishedTimeline, Token: "t0ken"}
+		mockDbApi.On("GetPublishedFeedByToken", mock.Anything, "t0ken").Return(published, nil)
+		mockDbApi.On("GetUser", mock.Anything, user.ID).Return(user, nil)
+		req, err := http.NewRequest(http.MethodGet, "/v1/published/t0ken/rss", nil)
+		require.NoError(t, err)
+		req.SetPathValue("token", "t0ken")
+		rw := httptest.NewRecorder()
+
+		testApi.handlerPublishedRss(rw, req)
+
+		compareError(t, rw, http.StatusNotFound, "feed not found")
+
+		mockDbApi.AssertExpectations(t)
+		mockDbApi.AssertNotCalled(t, "GetFollowedPosts", mock.Anything, mock.Anything)
+	})
+
+	t.Run("render saved search", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		user := setupUser()
+		search := database.SavedSearch{ID: uuid.New(), UserID: user.ID, Name: "golang"}
+		published := database.PublishedFeed{
+			ID: uuid.New(), CreatedAt: now, UserID: user.ID, Kind: publishedSearch, Token: "t0ken",
+			SavedSearchID: uuid.NullUUID{UUID: search.ID, Valid: true},
+		}
+		post := setupPost(uuid.New())
+		mockDbApi.On("GetPublishedFeedByToken", mock.Anything, "t0ken").Return(published, nil)
+		mockDbApi.On("GetUser", mock.Anything, user.ID).Return(user, nil)
+		mockDbApi.On("GetSavedSearch", mock.Anything, search.ID).Return(search, nil)
+		params := database.GetSavedSearchPostsParams{ID: search.ID, Limit: defaultPublishedItems}
+		mockDbApi.On("GetSavedSearchPosts", mock.Anything, params).Return([]database.Post{post}, nil)
+		req, err := http.NewRequest(http.MethodGet, "/v1/published/t0ken/rss", nil)
+		require.NoError(t, err)
+		req.SetPathValue("token", "t0ken")
+		rw := httptest.NewRecorder()
+
+		testApi.handlerPublishedRss(rw, req)
+
+		require.Equal(t, http.StatusOK, rw.Code)
+		body := rw.Body.String()
+		require.Contains(t, body, "search: golang")
+		require.Contains(t, body, "<guid isPermaLink=\"false\">urn:uuid:"+post.ID.String()+"</guid>")
+
+		mockDbApi.AssertExpectations(t)
+		mockDbApi.AssertNotCalled(t, "GetFollowedPosts", mock.Anything, mock.Anything)
+	})
+}
+
+func TestCreatePublishedFeedHandler(t *testing.T) {
+	setupCreatePublished := func(t *testing.T, user database.User, body string) (*httptest.ResponseRecorder, *http.Request) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, "/v1/published", strings.NewReader(body))
+		require.NoError(t, err)
+		req = req.WithContext(context.WithValue(req.Context(), middleware.AuthUser, user))
+		return httptest.NewRecorder(), req
+	}
+
+	t.Run("publish saved search", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		user := setupUser()
+		search := database.SavedSearch{ID: uuid.New(), UserID: user.ID, Name: "golang"}
+		mockDbApi.On("GetSavedSearch", mock.Anything, search.ID).Return(search, nil)
+		mockDbApi.On("CreatePublishedFeed", mock.Anything, mock.MatchedBy(func(p database.CreatePublishedFeedParams) bool {
+			return p.Kind == publishedSearch && p.SavedSearchID.Valid && p.SavedSearchID.UUID == search.ID
+		})).Return(database.PublishedFeed{
+			ID: uuid.New(), CreatedAt: now, UserID: user.ID, Kind: publishedSearch, Token: "t0ken",
+			SavedSearchID: uuid.NullUUID{UUID: search.ID, Valid: true},
+		}, nil)
+		rw, req := setupCreatePublished(t, user, `{"kind": "search", "saved_search_id": "`+search.ID.String()+`"}`)
+
+		testApi.handlerCreatePublishedFeed(rw, req)
+
+		require.Equal(t, http.StatusCreated, rw.Code)
+		var got publishedFeedResponse
+		require.NoError(t, json.NewDecoder(rw.Body).Decode(&got))
+		require.Equal(t, publishedSearch, got.Kind)
+		require.NotNil(t, got.SavedSearchId)
+		require.Equal(t, search.ID.String(), *got.SavedSearchId)
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	t.Run("return 403 on saved search of another user", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		user := setupUser()
+		search := database.SavedSearch{ID: uuid.New(), UserID: uuid.New()}
+		mockDbApi.On("GetSavedSearch", mock.Anything, search.ID).Return(search, nil)
+		rw, req := setupCreatePublished(t, user, `{"kind": "search", "saved_search_id": "`+search.ID.String()+`"}`)
+
+		testApi.handlerCreatePublishedFeed(rw, req)
+
+		compareError(t, rw, http.StatusForbidden, "operation not allowed")
+
+		mockDbApi.AssertExpectations(t)
+		mockDbApi.AssertNotCalled(t, "CreatePublishedFeed", mock.Anything, mock.Anything)
+	})
+
+	t.Run("return 400 on search without saved search id", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		rw, req := setupCreatePublished(t, setupUser(), `{"kind": "search"}`)
+
+		testApi.handlerCreatePublishedFeed(rw, req)
+
+		compareError(t, rw, http.StatusBadRequest, "invalid saved search id")
+
+		mockDbApi.AssertExpectations(t)
+	})
+}
+
+func setupFilterRuleTest(t *testing.T, user database.User, method, path, body string) (*httptest.ResponseRecorder, *http.Request) {
+	t.Helper()
+	req, err := http.NewRequest(method, path, strings.NewReader(body))
+	require.NoError(t, err)
+	return httptest.NewRecorder(), req.WithContext(context.WithValue(req.Context(), middleware.AuthUser, user))
+}
+
+func TestCreateFilterRuleHandler(t *testing.T) {
+	t.Run("return 201", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		user := setupUser()
+		feed := setupFeed()
+		mockDbApi.On("GetFeed", mock.Anything, feed.ID).Return(feed, nil)
+		rule := database.FilterRule{
+			ID: uuid.New(), CreatedAt: now, UpdatedAt: now, UserID: user.ID,
+			FeedID: uuid.NullUUID{UUID: feed.ID, Valid: true}, Field: "category", Match: "regex",
+			Pattern: "^Jobs$", Action: "tag", Tag: sql.NullString{String: "jobs", Valid: true},
+		}
+		mockDbApi.On("CreateFilterRule", mock.Anything, mock.MatchedBy(func(p database.CreateFilterRuleParams) bool {
+			return p.UserID == user.ID && p.FeedID == rule.FeedID && p.Field == "category" && p.Match == "regex" &&
+				p.Pattern == "^Jobs$" && p.Action == "tag" && p.Tag == rule.Tag
+		})).Return(rule, nil)
+		body := `{"feed_id":"` + feed.ID.String() + `","field":"category","match":"regex","pattern":"^Jobs$","action":"tag","tag":" jobs "}`
+		rw, req := setupFilterRuleTest(t, user, http.MethodPost, "/v1/filter_rules", body)
+
+		testApi.handlerCreateFilterRule(rw, req)
+
+		require.Equal(t, http.StatusCreated, rw.Code)
+		var resp filterRuleResponse
+		require.NoError(t, json.NewDecoder(rw.Body).Decode(&resp))
+		require.Equal(t, rule.ID.String(), resp.Id)
+		require.Equal(t, feed.ID.String(), *resp.FeedId)
+		require.Equal(t, "jobs", *resp.Tag)
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	tests := map[string]struct {
+		body   string
+		errMsg string
+	}{
+		"unknown field":  {`{"field":"body","pattern":"x","action":"hide"}`, `invalid field "body"`},
+		"invalid regex":  {`{"field":"title","match":"regex","pattern":"(","action":"hide"}`, "invalid regex pattern"},
+		"unknown action": {`{"field":"title","pattern":"x","action":"delete"}`, `invalid action "delete"`},
+		"missing tag":    {`{"field":"title","pattern":"x","action":"tag"}`, "invalid tag"},
+		"invalid feed":   {`{"feed_id":"nope","field":"title","pattern":"x","action":"hide"}`, "invalid feed id"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockDbApi := new(MockedDbApi)
+			testApi := apiConfig{DB: mockDbApi}
+			rw, req := setupFilterRuleTest(t, setupUser(), http.MethodPost, "/v1/filter_rules", tc.body)
+
+			testApi.handlerCreateFilterRule(rw, req)
+
+			compareError(t, rw, http.StatusBadRequest, tc.errMsg)
+
+			mockDbApi.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDeleteFilterRuleHandler(t *testing.T) {
+	t.Run("return 403 on rule of another user", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		rule := database.FilterRule{ID: uuid.New(), UserID: uuid.New()}
+		mockDbApi.On("GetFilterRule", mock.Anything, rule.ID).Return(rule, nil)
+		rw, req := setupFilterRuleTest(t, setupUser(), http.MethodDelete, "/v1/filter_rules/"+rule.ID.String(), "")
+		req.SetPathValue("filterRuleID", rule.ID.String())
+
+		testApi.handlerDeleteFilterRule(rw, req)
+
+		compareError(t, rw, http.StatusForbidden, "operation not allowed")
+
+		mockDbApi.AssertExpectations(t)
+	})
+}
+
+func TestApplyFilterRulesHandler(t *testing.T) {
+	mockDbApi := new(MockedDbApi)
+	testApi := apiConfig{DB: mockDbApi}
+	user := setupUser()
+	rules := []database.FilterRule{
+		{ID: uuid.New(), UserID: user.ID, Field: "title", Match: "contains", Pattern: "sponsored", Action: "hide"},
+	}
+	mockDbApi.On("ListUserFilterRules", mock.Anything, user.ID).Return(rules, nil)
+	muted, kept := setupPost(uuid.New()), setupPost(uuid.New())
+	muted.Title.String, muted.Seq, kept.Seq = "Sponsored: a product", 7, 9
+	rows := []database.GetFollowedPostsWithFeedAfterRow{{Post: muted, FeedName: "a"}, {Post: kept, FeedName: "b"}}
+	params := database.GetFollowedPostsWithFeedAfterParams{UserID: user.ID, Limit: filterApplyBatch}
+	mockDbApi.On("GetFollowedPostsWithFeedAfter", mock.Anything, params).Return(rows, nil)
+	mockDbApi.On("UpsertPostState", mock.Anything, mock.MatchedBy(func(p database.UpsertPostStateParams) bool {
+		return p.UserID == user.ID && p.PostID == muted.ID && p.Hidden && !p.Read && !p.Starred
+	})).Return(nil)
+	rw, req := setupFilterRuleTest(t, user, http.MethodPost, "/v1/filter_rules/apply", "")
+
+	testApi.handlerApplyFilterRules(rw, req)
+
+	require.Equal(t, http.StatusOK, rw.Code)
+	require.JSONEq(t, `{"posts":2,"matched":1}`, rw.Body.String())
+
+	mockDbApi.AssertExpectations(t)
+}
@@ -0,0 +1,214 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/sp3dr4/bloggogrator/api/middleware"
+	"github.com/sp3dr4/bloggogrator/internal/database"
+)
+
+type savedSearchResponse struct {
+	Id          string     `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Name        string     `json:"name"`
+	Keywords    []string   `json:"keywords"`
+	FeedIds     []string   `json:"feed_ids"`
+	Since       *time.Time `json:"since"`
+	Until       *time.Time `json:"until"`
+	UnreadCount *int64     `json:"unread_count,omitempty"`
+}
+
+func dbSavedSearchToSavedSearch(o database.SavedSearch) savedSearchResponse {
+	feedIds := make([]string, 0, len(o.FeedIds))
+	for _, id := range o.FeedIds {
+		feedIds = append(feedIds, id.String())
+	}
+	keywords := o.Keywords
+	if keywords == nil {
+		keywords = []string{}
+	}
+	var since, until *time.Time
+	if o.Since.Valid {
+		since = &o.Since.Time
+	}
+	if o.Until.Valid {
+		until = &o.Until.Time
+	}
+	return savedSearchResponse{
+		Id:        o.ID.String(),
+		CreatedAt: o.CreatedAt,
+		UpdatedAt: o.UpdatedAt,
+		Name:      o.Name,
+		Keywords:  keywords,
+		FeedIds:   feedIds,
+		Since:     since,
+		Until:     until,
+	}
+}
+
+func (a *apiConfig) handlerCreateSavedSearch(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.AuthUser).(database.User)
+
+	var request struct {
+		Name     string     `json:"name"`
+		Keywords []string   `json:"keywords"`
+		FeedIds  []string   `json:"feed_ids"`
+		Since    *time.Time `json:"since"`
+		Until    *time.Time `json:"until"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondWithError(w, 400, "error decoding request body")
+		return
+	}
+	name := strings.TrimSpace(request.Name)
+	if name == "" || utf8.RuneCountInString(name) > 255 {
+		respondWithError(w, 400, "invalid name")
+		return
+	}
+	keywords := make([]string, 0, len(request.Keywords))
+	for _, keyword := range request.Keywords {
+		if keyword = strings.TrimSpace(keyword); keyword != "" {
+			keywords = append(keywords, keyword)
+		}
+	}
+	feedIds := make([]uuid.UUID, 0, len(request.FeedIds))
+	for _, idStr := range request.FeedIds {
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			respondWithError(w, 400, "invalid feed id")
+			return
+		}
+		feedIds = append(feedIds, id)
+	}
+	if len(keywords) == 0 && len(feedIds) == 0 {
+		respondWithError(w, 400, "keywords or feed_ids required")
+		return
+	}
+	var since, until sql.NullTime
+	if request.Since != nil {
+		since = sql.NullTime{Time: *request.Since, Valid: true}
+	}
+	if request.Until != nil {
+		until = sql.NullTime{Time: *request.Until, Valid: true}
+	}
+	if since.Valid && until.Valid && !until.Time.After(since.Time) {
+		respondWithError(w, 400, "until must be after since")
+		return
+	}
+
+	params := database.CreateSavedSearchParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		UserID:    user.ID,
+		Name:      name,
+		Keywords:  keywords,
+		FeedIds:   feedIds,
+		Since:     since,
+		Until:     until,
+	}
+	search, err := a.DB.CreateSavedSearch(r.Context(), params)
+	if err != nil {
+		log.Printf("saved search creation error: %v\n", err)
+		respondWithError(w, 500, "error creating saved search")
+		return
+	}
+	respondWithJSON(w, 201, dbSavedSearchToSavedSearch(search))
+}
+
+func (a *apiConfig) handlerListSavedSearches(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.AuthUser).(database.User)
+
+	rows, err := a.DB.ListUserSavedSearches(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 500, "error retrieving saved searches")
+		return
+	}
+
+	respSearches := make([]savedSearchResponse, 0, len(rows))
+	for _, o := range rows {
+		search := dbSavedSearchToSavedSearch(o.SavedSearch)
+		unread := o.Unread
+		search.UnreadCount = &unread
+		respSearches = append(respSearches, search)
+	}
+	respondWithJSON(w, 200, respSearches)
+}
+
+// userSavedSearch returns the saved search of the request path, responding
+// with an error unless it belongs to the authenticated user.
+func (a *apiConfig) userSavedSearch(w http.ResponseWriter, r *http.Request) (database.SavedSearch, bool) {
+	user := r.Context().Value(middleware.AuthUser).(database.User)
+	searchId, err := uuid.Parse(r.PathValue("savedSearchID"))
+	if err != nil {
+		respondWithError(w, 400, "invalid saved search id")
+		return database.SavedSearch{}, false
+	}
+	search, err := a.DB.GetSavedSearch(r.Context(), searchId)
+	if err != nil {
+		respondWithError(w, 404, "saved search not found")
+		return database.SavedSearch{}, false
+	}
+	if search.UserID != user.ID {
+		respondWithError(w, 403, "operation not allowed")
+		return database.SavedSearch{}, false
+	}
+	return search, true
+}
+
+func (a *apiConfig) handlerDeleteSavedSearch(w http.ResponseWriter, r *http.Request) {
+	search, ok := a.userSavedSearch(w, r)
+	if !ok {
+		return
+	}
+
+	if err := a.DB.DeleteSavedSearch(r.Context(), search.ID); err != nil {
+		respondWithError(w, 500, "error deleting saved search")
+		return
+	}
+	respondWithJSON(w, 204, struct{}{})
+}
+
+func (a *apiConfig) handlerListSavedSearchPosts(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.AuthUser).(database.User)
+	search, ok := a.userSavedSearch(w, r)
+	if !ok {
+		return
+	}
+
+	var limit int32 = 20
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		limitInt, err := strconv.ParseInt(limitStr, 10, 32)
+		if err != nil {
+			respondWithError(w, 400, "invalid limit query parameter")
+			return
+		}
+		limit = int32(limitInt)
+	}
+
+	params := database.GetSavedSearchPostsParams{ID: search.ID, Limit: limit}
+	posts, err := a.DB.GetSavedSearchPosts(r.Context(), params)
+	if err != nil {
+		respondWithError(w, 500, "error retrieving saved search posts")
+		return
+	}
+
+	respPosts := make([]postResponse, 0, len(posts))
+	for _, o := range posts {
+		respPosts = append(respPosts, dbPostToPost(o))
+	}
+	if err := a.setPostStates(r, user, respPosts); err != nil {
+		respondWithError(w, 500, "error retrieving saved search posts")
+		return
+	}
+	respondWithJSON(w, 200, respPosts)
+}
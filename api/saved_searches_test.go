@@ -0,0 +1,144 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sp3dr4/bloggogrator/api/middleware"
+	"github.com/sp3dr4/bloggogrator/internal/database"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSavedSearchHandlers(t *testing.T) {
+	setupRequest := func(t *testing.T, user database.User, method, path, body string) (*httptest.ResponseRecorder, *http.Request) {
+		t.Helper()
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		require.NoError(t, err)
+		return httptest.NewRecorder(), req.WithContext(context.WithValue(req.Context(), middleware.AuthUser, user))
+	}
+
+	t.Run("create", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		user := setupUser()
+		feedId := uuid.New()
+		since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		search := database.SavedSearch{
+			ID: uuid.New(), CreatedAt: now, UpdatedAt: now, UserID: user.ID, Name: "postgres",
+			Keywords: []string{"postgres"}, FeedIds: []uuid.UUID{feedId}, Since: sql.NullTime{Time: since, Valid: true},
+		}
+		mockDbApi.On("CreateSavedSearch", mock.Anything, mock.MatchedBy(func(p database.CreateSavedSearchParams) bool {
+			return p.UserID == user.ID && p.Name == "postgres" && len(p.Keywords) == 1 && p.Keywords[0] == "postgres" &&
+				len(p.FeedIds) == 1 && p.FeedIds[0] == feedId && p.Since.Valid && p.Since.Time.Equal(since) && !p.Until.Valid
+		})).Return(search, nil)
+		body := `{"name":" postgres ","keywords":["postgres"," "],"feed_ids":["` + feedId.String() + `"],"since":"2024-01-01T00:00:00Z"}`
+		rw, req := setupRequest(t, user, http.MethodPost, "/v1/saved_searches", body)
+
+		testApi.handlerCreateSavedSearch(rw, req)
+
+		require.Equal(t, http.StatusCreated, rw.Code)
+		var resp savedSearchResponse
+		require.NoError(t, json.NewDecoder(rw.Body).Decode(&resp))
+		require.Equal(t, search.ID.String(), resp.Id)
+		require.Equal(t, []string{feedId.String()}, resp.FeedIds)
+		require.Nil(t, resp.Until)
+		require.Nil(t, resp.UnreadCount)
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	invalid := map[string]struct {
+		body   string
+		errMsg string
+	}{
+		"missing name":     {`{"keywords":["postgres"]}`, "invalid name"},
+		"missing criteria": {`{"name":"all","keywords":[" "]}`, "keywords or feed_ids required"},
+		"invalid feed":     {`{"name":"x","feed_ids":["nope"]}`, "invalid feed id"},
+		"inverted dates": {
+			`{"name":"x","keywords":["a"],"since":"2024-02-01T00:00:00Z","until":"2024-01-01T00:00:00Z"}`,
+			"until must be after since",
+		},
+	}
+	for name, tc := range invalid {
+		t.Run(name, func(t *testing.T) {
+			mockDbApi := new(MockedDbApi)
+			testApi := apiConfig{DB: mockDbApi}
+			rw, req := setupRequest(t, setupUser(), http.MethodPost, "/v1/saved_searches", tc.body)
+
+			testApi.handlerCreateSavedSearch(rw, req)
+
+			compareError(t, rw, http.StatusBadRequest, tc.errMsg)
+
+			mockDbApi.AssertExpectations(t)
+		})
+	}
+
+	t.Run("list with unread counts", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		user := setupUser()
+		rows := []database.ListUserSavedSearchesRow{
+			{SavedSearch: database.SavedSearch{ID: uuid.New(), UserID: user.ID, Name: "postgres", Keywords: []string{"postgres"}}, Unread: 3},
+		}
+		mockDbApi.On("ListUserSavedSearches", mock.Anything, user.ID).Return(rows, nil)
+		rw, req := setupRequest(t, user, http.MethodGet, "/v1/saved_searches", "")
+
+		testApi.handlerListSavedSearches(rw, req)
+
+		require.Equal(t, http.StatusOK, rw.Code)
+		var resp []savedSearchResponse
+		require.NoError(t, json.NewDecoder(rw.Body).Decode(&resp))
+		require.Len(t, resp, 1)
+		require.Equal(t, int64(3), *resp[0].UnreadCount)
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	t.Run("list posts", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		user := setupUser()
+		search := database.SavedSearch{ID: uuid.New(), UserID: user.ID, Name: "postgres"}
+		post := setupPost(uuid.New())
+		mockDbApi.On("GetSavedSearch", mock.Anything, search.ID).Return(search, nil)
+		params := database.GetSavedSearchPostsParams{ID: search.ID, Limit: 5}
+		mockDbApi.On("GetSavedSearchPosts", mock.Anything, params).Return([]database.Post{post}, nil)
+		statesParams := database.ListPostStatesParams{UserID: user.ID, PostIds: []uuid.UUID{post.ID}}
+		mockDbApi.On("ListPostStates", mock.Anything, statesParams).Return([]database.PostState{}, nil)
+		rw, req := setupRequest(t, user, http.MethodGet, "/v1/saved_searches/"+search.ID.String()+"/posts?limit=5", "")
+		req.SetPathValue("savedSearchID", search.ID.String())
+
+		testApi.handlerListSavedSearchPosts(rw, req)
+
+		require.Equal(t, http.StatusOK, rw.Code)
+		var resp []postResponse
+		require.NoError(t, json.NewDecoder(rw.Body).Decode(&resp))
+		require.Len(t, resp, 1)
+		require.Equal(t, post.ID.String(), resp[0].Id)
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	t.Run("return 403 on search of another user", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		search := database.SavedSearch{ID: uuid.New(), UserID: uuid.New()}
+		mockDbApi.On("GetSavedSearch", mock.Anything, search.ID).Return(search, nil)
+		rw, req := setupRequest(t, setupUser(), http.MethodGet, "/v1/saved_searches/"+search.ID.String()+"/posts", "")
+		req.SetPathValue("savedSearchID", search.ID.String())
+
+		testApi.handlerListSavedSearchPosts(rw, req)
+
+		compareError(t, rw, http.StatusForbidden, "operation not allowed")
+
+		mockDbApi.AssertExpectations(t)
+	})
+}
@@ -2,24 +2,31 @@ package api
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	_ "github.com/lib/pq"
 	"github.com/sp3dr4/bloggogrator/api/middleware"
 	"github.com/sp3dr4/bloggogrator/internal/database"
+	"github.com/sp3dr4/bloggogrator/internal/webhook"
 )
 
 type DbApi interface {
 	CreateUser(context.Context, database.CreateUserParams) (database.User, error)
-	GetUserByApiKey(context.Context, string) (database.User, error)
+	GetUserByApiKeyHash(context.Context, string) (database.User, error)
 	CreateFeed(context.Context, database.CreateFeedParams) (database.Feed, error)
 	ListFeeds(context.Context) ([]database.Feed, error)
 	GetFeed(context.Context, uuid.UUID) (database.Feed, error)
+	SetFeedFetchFullContent(context.Context, database.SetFeedFetchFullContentParams) (database.Feed, error)
+	SetFeedProxyUrl(context.Context, database.SetFeedProxyUrlParams) (database.Feed, error)
+	ListFeedFetches(context.Context, database.ListFeedFetchesParams) ([]database.FeedFetch, error)
 	CreateFeedFollow(context.Context, database.CreateFeedFollowParams) (database.FeedFollow, error)
 	GetFeedFollow(context.Context, uuid.UUID) (database.FeedFollow, error)
 	ListUserFeedFollows(context.Context, uuid.UUID) ([]database.FeedFollow, error)
@@ -27,10 +34,99 @@ type DbApi interface {
 	GetNextFeedsToFetch(context.Context, int32) ([]database.Feed, error)
 	MarkFeedFetched(context.Context, database.MarkFeedFetchedParams) (database.Feed, error)
 	GetUserPosts(context.Context, database.GetUserPostsParams) ([]database.Post, error)
+	GetUserPostsWithEnclosure(context.Context, database.GetUserPostsWithEnclosureParams) ([]database.GetUserPostsWithEnclosureRow, error)
+	GetWebsubSubscription(context.Context, uuid.UUID) (database.WebsubSubscription, error)
+	VerifyWebsubSubscription(context.Context, database.VerifyWebsubSubscriptionParams) (database.WebsubSubscription, error)
+	DeleteWebsubSubscription(context.Context, uuid.UUID) error
+	GetVisiblePost(context.Context, database.GetVisiblePostParams) (database.Post, error)
+	GetFollowedPostsAfter(context.Context, database.GetFollowedPostsAfterParams) ([]database.Post, error)
+	GetStreamResumeSeq(context.Context, database.GetStreamResumeSeqParams) (int64, error)
+	CreateWebhook(context.Context, database.CreateWebhookParams) (database.Webhook, error)
+	GetWebhook(context.Context, uuid.UUID) (database.Webhook, error)
+	ListUserWebhooks(context.Context, uuid.UUID) ([]database.Webhook, error)
+	DeleteWebhook(context.Context, uuid.UUID) error
+	CreateWebhookDelivery(context.Context, database.CreateWebhookDeliveryParams) (database.WebhookDelivery, error)
+	RecordWebhookDeliveryAttempt(context.Context, database.RecordWebhookDeliveryAttemptParams) (database.WebhookDelivery, error)
+	ListWebhookDeliveries(context.Context, database.ListWebhookDeliveriesParams) ([]database.WebhookDelivery, error)
+	GetUser(context.Context, uuid.UUID) (database.User, error)
+	GetFollowedPosts(context.Context, database.GetFollowedPostsParams) ([]database.Post, error)
+	CreatePublishedFeed(context.Context, database.CreatePublishedFeedParams) (database.PublishedFeed, error)
+	GetPublishedFeed(context.Context, uuid.UUID) (database.PublishedFeed, error)
+	GetPublishedFeedByToken(context.Context, string) (database.PublishedFeed, error)
+	ListUserPublishedFeeds(context.Context, uuid.UUID) ([]database.PublishedFeed, error)
+	DeletePublishedFeed(context.Context, uuid.UUID) error
+	UpsertDigestSubscription(context.Context, database.UpsertDigestSubscriptionParams) (database.DigestSubscription, error)
+	GetDigestSubscription(context.Context, uuid.UUID) (database.DigestSubscription, error)
+	DeleteDigestSubscription(context.Context, uuid.UUID) error
+	DeleteDigestSubscriptionByToken(context.Context, string) (int64, error)
+	ConfirmDigestSubscription(context.Context, database.ConfirmDigestSubscriptionParams) (int64, error)
+	CreateFilterRule(context.Context, database.CreateFilterRuleParams) (database.FilterRule, error)
+	GetFilterRule(context.Context, uuid.UUID) (database.FilterRule, error)
+	ListUserFilterRules(context.Context, uuid.UUID) ([]database.FilterRule, error)
+	DeleteFilterRule(context.Context, uuid.UUID) error
+	GetFollowedPostsWithFeedAfter(context.Context, database.GetFollowedPostsWithFeedAfterParams) ([]database.GetFollowedPostsWithFeedAfterRow, error)
+	UpsertPostState(context.Context, database.UpsertPostStateParams) error
+	ListPostStates(context.Context, database.ListPostStatesParams) ([]database.PostState, error)
+	CreateSavedSearch(context.Context, database.CreateSavedSearchParams) (database.SavedSearch, error)
+	GetSavedSearch(context.Context, uuid.UUID) (database.SavedSearch, error)
+	ListUserSavedSearches(context.Context, uuid.UUID) ([]database.ListUserSavedSearchesRow, error)
+	DeleteSavedSearch(context.Context, uuid.UUID) error
+	GetSavedSearchPosts(context.Context, database.GetSavedSearchPostsParams) ([]database.Post, error)
+	CreateApiKey(context.Context, database.CreateApiKeyParams) (database.ApiKey, error)
+	GetApiKey(context.Context, uuid.UUID) (database.ApiKey, error)
+	ListUserApiKeys(context.Context, uuid.UUID) ([]database.ApiKey, error)
+	RevokeApiKey(context.Context, database.RevokeApiKeyParams) error
+	GetUserByScopedApiKeyHash(context.Context, string) (database.GetUserByScopedApiKeyHashRow, error)
+	TouchApiKey(context.Context, database.TouchApiKeyParams) error
+	RotateUserApiKey(context.Context, database.RotateUserApiKeyParams) (database.User, error)
+	CreateAuditEvent(context.Context, database.CreateAuditEventParams) (database.AuditEvent, error)
+	ListUserAuditEvents(context.Context, database.ListUserAuditEventsParams) ([]database.AuditEvent, error)
+	GetUserByEmail(context.Context, sql.NullString) (database.User, error)
+	CreateSession(context.Context, database.CreateSessionParams) (database.Session, error)
+	GetSession(context.Context, uuid.UUID) (database.Session, error)
+	ListUserSessions(context.Context, uuid.UUID) ([]database.Session, error)
+	GetUserBySessionAccessHash(context.Context, string) (database.GetUserBySessionAccessHashRow, error)
+	RefreshSession(context.Context, database.RefreshSessionParams) (database.Session, error)
+	RevokeSession(context.Context, database.RevokeSessionParams) error
+	RevokeSessionByRefreshHash(context.Context, database.RevokeSessionByRefreshHashParams) (database.Session, error)
+	ListUsers(context.Context, database.ListUsersParams) ([]database.User, error)
+	SetUserDisabled(context.Context, database.SetUserDisabledParams) (database.User, error)
+	SetUserAdmin(context.Context, database.SetUserAdminParams) (database.User, error)
+	ListFeedsWithStats(context.Context, database.ListFeedsWithStatsParams) ([]database.ListFeedsWithStatsRow, error)
+	SetFeedDisabled(context.Context, database.SetFeedDisabledParams) (database.Feed, error)
+	ScheduleFeedFetch(context.Context, database.ScheduleFeedFetchParams) (database.Feed, error)
+	DeletePost(context.Context, database.DeletePostParams) (int64, error)
+	GetWorkerStats(context.Context, time.Time) (database.GetWorkerStatsRow, error)
+	CreateInviteCode(context.Context, database.CreateInviteCodeParams) (database.InviteCode, error)
+	ListInviteCodes(context.Context) ([]database.InviteCode, error)
+	RevokeInviteCode(context.Context, database.RevokeInviteCodeParams) (int64, error)
+	UseInviteCode(context.Context, string) (database.InviteCode, error)
+	ReleaseInviteCode(context.Context, uuid.UUID) error
+	UpdateUser(context.Context, database.UpdateUserParams) (database.User, error)
+	DeleteUser(context.Context, uuid.UUID) (int64, error)
+	ListUserFollowedFeeds(context.Context, uuid.UUID) ([]database.Feed, error)
+	ListStarredPosts(context.Context, uuid.UUID) ([]database.ListStarredPostsRow, error)
+	TakeRateLimitToken(context.Context, database.TakeRateLimitTokenParams) (database.TakeRateLimitTokenRow, error)
+	DeleteIdleRateLimitBuckets(context.Context, float64) (int64, error)
 }
 
+// PushIngester saves the feed content pushed by a WebSub hub.
+type PushIngester func(feed database.Feed, body []byte, contentType string) error
+
+// Registration modes of POST /v1/users.
+const (
+	RegistrationOpen   = "open"
+	RegistrationInvite = "invite"
+	RegistrationClosed = "closed"
+)
+
 type apiConfig struct {
-	DB DbApi
+	DB       DbApi
+	Ingest   PushIngester
+	Posts    *postBroker
+	Webhooks *webhook.Sender
+	// Registration is one of the registration modes, open when empty.
+	Registration string
 }
 
 func respondWithError(w http.ResponseWriter, code int, msg string) {
@@ -62,31 +158,125 @@ func (a *apiConfig) handlerErr(w http.ResponseWriter, r *http.Request) {
 	respondWithError(w, 500, "Internal Server Error")
 }
 
-func Run(db DbApi) {
+// Run serves the API. events are the posts inserted by the worker, pushed
+// to the clients of GET /v1/posts/stream, webhooks sends the test events and
+// registration is the registration mode and limits the rate limits.
+func Run(db DbApi, ingest PushIngester, events <-chan PostEvent, webhooks *webhook.Sender, registration string, limits RateLimitConfig) {
 	cfg := apiConfig{
-		DB: db,
+		DB:           db,
+		Ingest:       ingest,
+		Posts:        newPostBroker(),
+		Webhooks:     webhooks,
+		Registration: registration,
+	}
+	go cfg.Posts.run(events)
+
+	userFetcher := func(ctx context.Context, scheme, token string) (interface{}, []string, error) {
+		return cfg.fetchUser(ctx, scheme, token)
 	}
 
-	userFetcher := func(ctx context.Context, apiKey string) (interface{}, error) {
-		return cfg.DB.GetUserByApiKey(ctx, apiKey)
+	var limitStore middleware.RateLimitStore = middleware.NewMemoryRateLimitStore()
+	if limits.Shared {
+		limitStore = dbRateLimitStore{DB: db}
+		go pruneRateLimitBuckets(db, 10*time.Minute, limits.maxPeriod())
+	}
+	clientIP := func(r *http.Request) string {
+		return "ip:" + middleware.ClientIP(r, limits.TrustProxy)
+	}
+	publicLimit := middleware.RateLimitFactory(limitStore, "public", limits.Public, clientIP)
+	authLimit := middleware.RateLimitFactory(limitStore, "auth", limits.Auth, clientIP)
+	readLimit := middleware.RateLimitFactory(limitStore, "read", limits.Read, rateLimitKey)
+	writeLimit := middleware.RateLimitFactory(limitStore, "write", limits.Write, rateLimitKey)
+
+	public := func(handler http.HandlerFunc) http.Handler {
+		return publicLimit(handler)
+	}
+	scoped := func(scope string, handler http.HandlerFunc) http.Handler {
+		if strings.HasPrefix(scope, "read:") {
+			return middleware.RequireScope(scope)(readLimit(handler))
+		}
+		return middleware.RequireScope(scope)(writeLimit(handler))
 	}
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("GET /v1/healthz", cfg.handlerHealth)
 	mux.HandleFunc("GET /v1/err", cfg.handlerErr)
-	mux.HandleFunc("POST /v1/users", cfg.handlerCreateUser)
-	mux.HandleFunc("GET /v1/feeds", cfg.handlerListFeeds)
+	mux.Handle("POST /v1/users", public(cfg.handlerCreateUser))
+	mux.Handle("POST /v1/auth/login", public(cfg.handlerLogin))
+	mux.Handle("POST /v1/auth/refresh", public(cfg.handlerRefreshSession))
+	mux.Handle("POST /v1/auth/logout", public(cfg.handlerLogout))
+	mux.Handle("GET /v1/feeds", public(cfg.handlerListFeeds))
+	// the hubs push from a few addresses, limiting them would drop posts
+	mux.HandleFunc("GET /v1/websub/{feedID}", cfg.handlerWebSubVerify)
+	mux.HandleFunc("POST /v1/websub/{feedID}", cfg.handlerWebSubPush)
+	mux.Handle("GET /v1/published/{token}/rss", public(cfg.handlerPublishedRss))
+	mux.Handle("GET /v1/published/{token}/atom", public(cfg.handlerPublishedAtom))
+	mux.Handle("GET /v1/digest/confirm", public(cfg.handlerConfirmDigest))
+	mux.Handle("GET /v1/digest/unsubscribe", public(cfg.handlerUnsubscribeDigest))
+	mux.Handle("POST /v1/digest/unsubscribe", public(cfg.handlerUnsubscribeDigest))
 
 	protectedMux := http.NewServeMux()
-	protectedMux.HandleFunc("GET /users", cfg.handlerGetUser)
-	protectedMux.HandleFunc("POST /feeds", cfg.handlerCreateFeed)
-	protectedMux.HandleFunc("POST /feed_follows", cfg.handlerCreateFeedFollow)
-	protectedMux.HandleFunc("GET /feed_follows", cfg.handlerListUserFeedFollows)
-	protectedMux.HandleFunc("DELETE /feed_follows/{feedFollowID}", cfg.handlerDeleteFeedFollow)
-	protectedMux.HandleFunc("GET /posts", cfg.handlerListPosts)
-	protectedStack := middleware.CreateStack(middleware.AuthFactory(userFetcher))(protectedMux)
+	protectedMux.Handle("GET /users", scoped(scopeReadAccount, cfg.handlerGetUser))
+	protectedMux.Handle("PATCH /users", scoped(scopeWriteAccount, cfg.handlerUpdateUser))
+	protectedMux.Handle("DELETE /users", scoped(scopeWriteAccount, cfg.handlerDeleteUser))
+	protectedMux.Handle("GET /users/export", scoped(scopeReadAccount, cfg.handlerExportUser))
+	protectedMux.Handle("POST /users/api_key/rotate", scoped(scopeWriteAccount, cfg.handlerRotateApiKey))
+	protectedMux.Handle("GET /audit_events", scoped(scopeReadAccount, cfg.handlerListAuditEvents))
+	protectedMux.Handle("POST /feeds", scoped(scopeWriteFeeds, cfg.handlerCreateFeed))
+	protectedMux.Handle("PATCH /feeds/{feedID}", scoped(scopeWriteFeeds, cfg.handlerUpdateFeed))
+	protectedMux.Handle("GET /feeds/{feedID}/fetches", scoped(scopeReadFeeds, cfg.handlerListFeedFetches))
+	protectedMux.Handle("POST /feed_follows", scoped(scopeWriteFeeds, cfg.handlerCreateFeedFollow))
+	protectedMux.Handle("GET /feed_follows", scoped(scopeReadFeeds, cfg.handlerListUserFeedFollows))
+	protectedMux.Handle("DELETE /feed_follows/{feedFollowID}", scoped(scopeWriteFeeds, cfg.handlerDeleteFeedFollow))
+	protectedMux.Handle("GET /posts", scoped(scopeReadPosts, cfg.handlerListPosts))
+	protectedMux.Handle("GET /posts/stream", scoped(scopeReadPosts, cfg.handlerStreamPosts))
+	protectedMux.Handle("POST /webhooks", scoped(scopeWriteWebhooks, cfg.handlerCreateWebhook))
+	protectedMux.Handle("GET /webhooks", scoped(scopeReadWebhooks, cfg.handlerListWebhooks))
+	protectedMux.Handle("DELETE /webhooks/{webhookID}", scoped(scopeWriteWebhooks, cfg.handlerDeleteWebhook))
+	protectedMux.Handle("GET /webhooks/{webhookID}/deliveries", scoped(scopeReadWebhooks, cfg.handlerListWebhookDeliveries))
+	protectedMux.Handle("POST /webhooks/{webhookID}/test", scoped(scopeWriteWebhooks, cfg.handlerTestWebhook))
+	protectedMux.Handle("POST /published_feeds", scoped(scopeWritePublished, cfg.handlerCreatePublishedFeed))
+	protectedMux.Handle("GET /published_feeds", scoped(scopeReadPublished, cfg.handlerListPublishedFeeds))
+	protectedMux.Handle("DELETE /published_feeds/{publishedFeedID}", scoped(scopeWritePublished, cfg.handlerDeletePublishedFeed))
+	protectedMux.Handle("PUT /digest", scoped(scopeWriteDigest, cfg.handlerPutDigest))
+	protectedMux.Handle("GET /digest", scoped(scopeReadDigest, cfg.handlerGetDigest))
+	protectedMux.Handle("DELETE /digest", scoped(scopeWriteDigest, cfg.handlerDeleteDigest))
+	protectedMux.Handle("POST /filter_rules", scoped(scopeWritePosts, cfg.handlerCreateFilterRule))
+	protectedMux.Handle("GET /filter_rules", scoped(scopeReadPosts, cfg.handlerListFilterRules))
+	protectedMux.Handle("DELETE /filter_rules/{filterRuleID}", scoped(scopeWritePosts, cfg.handlerDeleteFilterRule))
+	protectedMux.Handle("POST /filter_rules/apply", scoped(scopeWritePosts, cfg.handlerApplyFilterRules))
+	protectedMux.Handle("POST /saved_searches", scoped(scopeWritePosts, cfg.handlerCreateSavedSearch))
+	protectedMux.Handle("GET /saved_searches", scoped(scopeReadPosts, cfg.handlerListSavedSearches))
+	protectedMux.Handle("DELETE /saved_searches/{savedSearchID}", scoped(scopeWritePosts, cfg.handlerDeleteSavedSearch))
+	protectedMux.Handle("GET /saved_searches/{savedSearchID}/posts", scoped(scopeReadPosts, cfg.handlerListSavedSearchPosts))
+	protectedMux.Handle("POST /api_keys", scoped(scopeWriteAccount, cfg.handlerCreateApiKey))
+	protectedMux.Handle("GET /api_keys", scoped(scopeReadAccount, cfg.handlerListApiKeys))
+	protectedMux.Handle("DELETE /api_keys/{apiKeyID}", scoped(scopeWriteAccount, cfg.handlerRevokeApiKey))
+	protectedMux.Handle("GET /sessions", scoped(scopeReadAccount, cfg.handlerListSessions))
+	protectedMux.Handle("DELETE /sessions/{sessionID}", scoped(scopeWriteAccount, cfg.handlerRevokeSession))
+	protectedStack := middleware.CreateStack(authLimit, middleware.AuthFactory(userFetcher))(protectedMux)
 	mux.Handle("/v1/", http.StripPrefix("/v1", protectedStack))
 
+	adminMux := http.NewServeMux()
+	adminMux.HandleFunc("GET /users", cfg.handlerAdminListUsers)
+	adminMux.HandleFunc("PATCH /users/{userID}", cfg.handlerAdminUpdateUser)
+	adminMux.HandleFunc("GET /feeds", cfg.handlerAdminListFeeds)
+	adminMux.HandleFunc("PATCH /feeds/{feedID}", cfg.handlerAdminUpdateFeed)
+	adminMux.HandleFunc("POST /feeds/{feedID}/refresh", cfg.handlerAdminRefreshFeed)
+	adminMux.HandleFunc("DELETE /posts/{postID}", cfg.handlerAdminDeletePost)
+	adminMux.HandleFunc("GET /worker", cfg.handlerAdminWorkerStatus)
+	adminMux.HandleFunc("POST /invite_codes", cfg.handlerAdminCreateInviteCode)
+	adminMux.HandleFunc("GET /invite_codes", cfg.handlerAdminListInviteCodes)
+	adminMux.HandleFunc("DELETE /invite_codes/{inviteCodeID}", cfg.handlerAdminRevokeInviteCode)
+	adminStack := middleware.CreateStack(
+		authLimit,
+		middleware.AuthFactory(userFetcher),
+		middleware.RequireAdmin(isAdmin),
+		middleware.RequireScope(scopeAdmin),
+		middleware.RateLimitFactory(limitStore, "admin", limits.Admin, rateLimitKey),
+	)(adminMux)
+	mux.Handle("/v1/admin/", http.StripPrefix("/v1/admin", adminStack))
+
 	server := &http.Server{
 		Addr:    fmt.Sprintf(":%s", os.Getenv("PORT")),
 		Handler: middleware.CreateStack(middleware.Logging)(mux),
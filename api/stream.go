@@ -0,0 +1,149 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sp3dr4/bloggogrator/api/middleware"
+	"github.com/sp3dr4/bloggogrator/internal/database"
+)
+
+const (
+	// streamKeepAlive is the interval of the comments sent to keep idle
+	// streams open through proxies.
+	streamKeepAlive = 30 * time.Second
+	// streamReplayPage is how many missed posts are read at once on resume.
+	streamReplayPage = 100
+	// streamResumeOverlap is how long before the last streamed post the
+	// replay starts, to catch the posts committed after it.
+	streamResumeOverlap = 5 * time.Minute
+)
+
+func (a *apiConfig) handlerStreamPosts(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.AuthUser).(database.User)
+
+	var lastSeq int64
+	resume := false
+	if lastEventId := r.Header.Get("Last-Event-ID"); lastEventId != "" {
+		seq, err := strconv.ParseInt(lastEventId, 10, 64)
+		if err != nil || seq < 0 {
+			respondWithError(w, 400, "invalid Last-Event-ID header")
+			return
+		}
+		lastSeq, resume = seq, true
+	}
+	if resume {
+		params := database.GetStreamResumeSeqParams{Seq: lastSeq, OverlapSeconds: streamResumeOverlap.Seconds()}
+		seq, err := a.DB.GetStreamResumeSeq(r.Context(), params)
+		if err != nil {
+			log.Printf("stream resume error: %v\n", err)
+			respondWithError(w, 500, "error retrieving user posts")
+			return
+		}
+		lastSeq = seq
+	}
+
+	followed, err := a.followedFeeds(r, user.ID)
+	if err != nil {
+		respondWithError(w, 500, "error retrieving user feed follows")
+		return
+	}
+
+	// subscribe before replaying so that no post falls in between
+	events := a.Posts.subscribe()
+	defer a.Posts.unsubscribe(events)
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(200)
+
+	replayed := map[uuid.UUID]bool{}
+	for resume {
+		params := database.GetFollowedPostsAfterParams{UserID: user.ID, Seq: lastSeq, Limit: streamReplayPage}
+		posts, err := a.DB.GetFollowedPostsAfter(r.Context(), params)
+		if err != nil {
+			log.Printf("stream replay error: %v\n", err)
+			return
+		}
+		for _, o := range posts {
+			writePostEvent(w, o)
+			replayed[o.ID] = true
+			lastSeq = o.Seq
+		}
+		resume = len(posts) == streamReplayPage
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(streamKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-events:
+			if !ok {
+				// the stream lagged behind and was dropped by the broker
+				return
+			}
+			if !followed[ev.FeedID] || replayed[ev.ID] {
+				continue
+			}
+			// the filter rules ran in the transaction that inserted the post
+			post, err := a.DB.GetVisiblePost(r.Context(), database.GetVisiblePostParams{ID: ev.ID, UserID: user.ID})
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			if err != nil {
+				log.Printf("stream post error: %v\n", err)
+				continue
+			}
+			writePostEvent(w, post)
+		case <-ticker.C:
+			if !a.stillAuthorized(r, user, scopeReadPosts) {
+				return
+			}
+			if f, err := a.followedFeeds(r, user.ID); err == nil {
+				followed = f
+			}
+			fmt.Fprint(w, ": keepalive\n\n")
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (a *apiConfig) followedFeeds(r *http.Request, userId uuid.UUID) (map[uuid.UUID]bool, error) {
+	follows, err := a.DB.ListUserFeedFollows(r.Context(), userId)
+	if err != nil {
+		return nil, err
+	}
+	followed := make(map[uuid.UUID]bool, len(follows))
+	for _, o := range follows {
+		followed[o.FeedID] = true
+	}
+	return followed, nil
+}
+
+// writePostEvent writes post as a server-sent event whose id is the post
+// sequence number, for clients to resume from with Last-Event-ID. A resumed
+// stream replays some posts already sent, clients skip them by post id.
+func writePostEvent(w http.ResponseWriter, post database.Post) {
+	data, err := json.Marshal(dbPostToPost(post))
+	if err != nil {
+		log.Printf("stream encoding error: %v\n", err)
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: post\ndata: %s\n\n", post.Seq, data)
+}
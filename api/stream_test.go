@@ -0,0 +1,98 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sp3dr4/bloggogrator/api/middleware"
+	"github.com/sp3dr4/bloggogrator/internal/database"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamPostsHandler(t *testing.T) {
+	t.Run("replay missed posts then push new ones", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi, Posts: newPostBroker()}
+		user := setupUser()
+		followed := setupFeed()
+		other := setupFeed()
+		missed := setupPost(followed.ID)
+		missed.Seq = 42
+		live := setupPost(followed.ID)
+		live.Seq = 44
+		hidden := setupPost(followed.ID)
+		hidden.Seq = 45
+		mockDbApi.On("ListUserFeedFollows", mock.Anything, user.ID).Return([]database.FeedFollow{setupFollow(user.ID, followed.ID)}, nil)
+		// the replay starts before Last-Event-ID, for the posts committed late
+		resume := database.GetStreamResumeSeqParams{Seq: 41, OverlapSeconds: streamResumeOverlap.Seconds()}
+		mockDbApi.On("GetStreamResumeSeq", mock.Anything, resume).Return(int64(38), nil)
+		params := database.GetFollowedPostsAfterParams{UserID: user.ID, Seq: 38, Limit: streamReplayPage}
+		mockDbApi.On("GetFollowedPostsAfter", mock.Anything, params).Return([]database.Post{missed}, nil)
+		mockDbApi.On("GetVisiblePost", mock.Anything, database.GetVisiblePostParams{ID: live.ID, UserID: user.ID}).Return(live, nil)
+		mockDbApi.On("GetVisiblePost", mock.Anything, database.GetVisiblePostParams{ID: hidden.ID, UserID: user.ID}).Return(database.Post{}, sql.ErrNoRows)
+
+		req, err := http.NewRequest(http.MethodGet, "/v1/posts/stream", nil)
+		require.NoError(t, err)
+		req.Header.Set("Last-Event-ID", "41")
+		ctx, cancel := context.WithCancel(context.WithValue(req.Context(), middleware.AuthUser, user))
+		rw := httptest.NewRecorder()
+
+		done := make(chan struct{})
+		go func() {
+			testApi.handlerStreamPosts(rw, req.WithContext(ctx))
+			close(done)
+		}()
+		require.Eventually(t, func() bool {
+			testApi.Posts.mu.Lock()
+			defer testApi.Posts.mu.Unlock()
+			return len(testApi.Posts.subs) == 1
+		}, time.Second, 10*time.Millisecond)
+
+		testApi.Posts.publish(PostEvent{ID: missed.ID, FeedID: followed.ID, Seq: missed.Seq})
+		testApi.Posts.publish(PostEvent{ID: uuid.New(), FeedID: other.ID, Seq: 43})
+		testApi.Posts.publish(PostEvent{ID: live.ID, FeedID: followed.ID, Seq: live.Seq})
+		testApi.Posts.publish(PostEvent{ID: hidden.ID, FeedID: followed.ID, Seq: hidden.Seq})
+		require.Eventually(t, func() bool {
+			testApi.Posts.mu.Lock()
+			defer testApi.Posts.mu.Unlock()
+			for ch := range testApi.Posts.subs {
+				return len(ch) == 0
+			}
+			return false
+		}, time.Second, 10*time.Millisecond)
+		cancel()
+		<-done
+
+		require.Equal(t, "text/event-stream", rw.Header().Get("Content-Type"))
+		body := rw.Body.String()
+		require.Equal(t, 2, strings.Count(body, "event: post\n"))
+		require.Contains(t, body, "id: 42\nevent: post\ndata: "+mustJSON(t, dbPostToPost(missed))+"\n\n")
+		require.Contains(t, body, "id: 44\nevent: post\ndata: "+mustJSON(t, dbPostToPost(live))+"\n\n")
+		require.Less(t, strings.Index(body, "id: 42"), strings.Index(body, "id: 44"))
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	t.Run("return 400 on invalid Last-Event-ID", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi, Posts: newPostBroker()}
+		req, err := http.NewRequest(http.MethodGet, "/v1/posts/stream", nil)
+		require.NoError(t, err)
+		req.Header.Set("Last-Event-ID", "abc")
+		ctx := context.WithValue(req.Context(), middleware.AuthUser, setupUser())
+		rw := httptest.NewRecorder()
+
+		testApi.handlerStreamPosts(rw, req.WithContext(ctx))
+
+		compareError(t, rw, http.StatusBadRequest, "invalid Last-Event-ID header")
+
+		mockDbApi.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,264 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sp3dr4/bloggogrator/api/middleware"
+	"github.com/sp3dr4/bloggogrator/internal/database"
+	"github.com/sp3dr4/bloggogrator/internal/webhook"
+)
+
+type webhookResponse struct {
+	Id        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Url       string    `json:"url"`
+	Secret    string    `json:"secret"`
+	FeedIds   []string  `json:"feed_ids"`
+	Keywords  []string  `json:"keywords"`
+}
+
+func dbWebhookToWebhook(o database.Webhook) webhookResponse {
+	feedIds := make([]string, 0, len(o.FeedIds))
+	for _, id := range o.FeedIds {
+		feedIds = append(feedIds, id.String())
+	}
+	keywords := o.Keywords
+	if keywords == nil {
+		keywords = []string{}
+	}
+	return webhookResponse{
+		Id:        o.ID.String(),
+		CreatedAt: o.CreatedAt,
+		UpdatedAt: o.UpdatedAt,
+		Url:       o.Url,
+		Secret:    o.Secret,
+		FeedIds:   feedIds,
+		Keywords:  keywords,
+	}
+}
+
+type deliveryResponse struct {
+	Id             string     `json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	Event          string     `json:"event"`
+	PostId         *string    `json:"post_id"`
+	Status         string     `json:"status"`
+	Attempts       int32      `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at"`
+	ResponseStatus *int32     `json:"response_status"`
+	Error          *string    `json:"error"`
+}
+
+func dbDeliveryToDelivery(o database.WebhookDelivery) deliveryResponse {
+	var postId *string
+	if o.PostID.Valid {
+		id := o.PostID.UUID.String()
+		postId = &id
+	}
+	var nextAttemptAt *time.Time
+	if o.NextAttemptAt.Valid && o.Status == webhook.StatusPending {
+		nextAttemptAt = &o.NextAttemptAt.Time
+	}
+	var lastAttemptAt *time.Time
+	if o.LastAttemptAt.Valid {
+		lastAttemptAt = &o.LastAttemptAt.Time
+	}
+	var responseStatus *int32
+	if o.ResponseStatus.Valid {
+		responseStatus = &o.ResponseStatus.Int32
+	}
+	var deliveryErr *string
+	if o.Error.Valid {
+		deliveryErr = &o.Error.String
+	}
+	return deliveryResponse{
+		Id:             o.ID.String(),
+		CreatedAt:      o.CreatedAt,
+		Event:          o.Event,
+		PostId:         postId,
+		Status:         o.Status,
+		Attempts:       o.Attempts,
+		NextAttemptAt:  nextAttemptAt,
+		LastAttemptAt:  lastAttemptAt,
+		ResponseStatus: responseStatus,
+		Error:          deliveryErr,
+	}
+}
+
+func (a *apiConfig) handlerCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.AuthUser).(database.User)
+
+	var request struct {
+		Url      string   `json:"url"`
+		FeedIds  []string `json:"feed_ids"`
+		Keywords []string `json:"keywords"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondWithError(w, 400, "error decoding request body")
+		return
+	}
+	u, err := url.Parse(request.Url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		respondWithError(w, 400, "invalid webhook url")
+		return
+	}
+	feedIds := make([]uuid.UUID, 0, len(request.FeedIds))
+	for _, idStr := range request.FeedIds {
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			respondWithError(w, 400, "invalid feed id")
+			return
+		}
+		feedIds = append(feedIds, id)
+	}
+	keywords := make([]string, 0, len(request.Keywords))
+	for _, keyword := range request.Keywords {
+		if keyword = strings.TrimSpace(keyword); keyword != "" {
+			keywords = append(keywords, keyword)
+		}
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		respondWithError(w, 500, "error creating webhook")
+		return
+	}
+	params := database.CreateWebhookParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		UserID:    user.ID,
+		Url:       u.String(),
+		Secret:    secret,
+		FeedIds:   feedIds,
+		Keywords:  keywords,
+	}
+	hook, err := a.DB.CreateWebhook(r.Context(), params)
+	if err != nil {
+		log.Printf("webhook creation error: %v\n", err)
+		respondWithError(w, 500, "error creating webhook")
+		return
+	}
+	respondWithJSON(w, 201, dbWebhookToWebhook(hook))
+}
+
+func (a *apiConfig) handlerListWebhooks(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.AuthUser).(database.User)
+
+	hooks, err := a.DB.ListUserWebhooks(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 500, "error retrieving webhooks")
+		return
+	}
+
+	respHooks := make([]webhookResponse, 0, len(hooks))
+	for _, o := range hooks {
+		respHooks = append(respHooks, dbWebhookToWebhook(o))
+	}
+	respondWithJSON(w, 200, respHooks)
+}
+
+// userWebhook returns the webhook of the request path, responding with an
+// error unless it belongs to the authenticated user.
+func (a *apiConfig) userWebhook(w http.ResponseWriter, r *http.Request) (database.Webhook, bool) {
+	user := r.Context().Value(middleware.AuthUser).(database.User)
+	webhookId, err := uuid.Parse(r.PathValue("webhookID"))
+	if err != nil {
+		respondWithError(w, 400, "invalid webhook id")
+		return database.Webhook{}, false
+	}
+	hook, err := a.DB.GetWebhook(r.Context(), webhookId)
+	if err != nil {
+		respondWithError(w, 404, "webhook not found")
+		return database.Webhook{}, false
+	}
+	if hook.UserID != user.ID {
+		respondWithError(w, 403, "operation not allowed")
+		return database.Webhook{}, false
+	}
+	return hook, true
+}
+
+func (a *apiConfig) handlerDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := a.userWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	if err := a.DB.DeleteWebhook(r.Context(), hook.ID); err != nil {
+		respondWithError(w, 500, "error deleting webhook")
+		return
+	}
+
+	respondWithJSON(w, 204, struct{}{})
+}
+
+func (a *apiConfig) handlerListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	var limit int32 = 20
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		limitInt, err := strconv.ParseInt(limitStr, 10, 32)
+		if err != nil {
+			respondWithError(w, 400, "invalid limit query parameter")
+			return
+		}
+		limit = int32(limitInt)
+	}
+
+	hook, ok := a.userWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	params := database.ListWebhookDeliveriesParams{WebhookID: hook.ID, Limit: limit}
+	deliveries, err := a.DB.ListWebhookDeliveries(r.Context(), params)
+	if err != nil {
+		respondWithError(w, 500, "error retrieving webhook deliveries")
+		return
+	}
+
+	respDeliveries := make([]deliveryResponse, 0, len(deliveries))
+	for _, o := range deliveries {
+		respDeliveries = append(respDeliveries, dbDeliveryToDelivery(o))
+	}
+	respondWithJSON(w, 200, respDeliveries)
+}
+
+// handlerTestWebhook sends a test event right away, without retries, and
+// responds with the resulting delivery.
+func (a *apiConfig) handlerTestWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := a.userWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	params := database.CreateWebhookDeliveryParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		WebhookID: hook.ID,
+		Event:     webhook.EventTest,
+	}
+	delivery, err := a.DB.CreateWebhookDelivery(r.Context(), params)
+	if err != nil {
+		log.Printf("webhook delivery creation error: %v\n", err)
+		respondWithError(w, 500, "error creating webhook delivery")
+		return
+	}
+
+	status, sendErr := a.Webhooks.Send(hook.Url, hook.Secret, webhook.NewPayload(delivery, nil))
+	delivery, err = a.DB.RecordWebhookDeliveryAttempt(r.Context(), webhook.AttemptParams(delivery, status, sendErr, false, time.Now()))
+	if err != nil {
+		log.Printf("webhook delivery update error: %v\n", err)
+		respondWithError(w, 500, "error recording webhook delivery")
+		return
+	}
+	respondWithJSON(w, 200, dbDeliveryToDelivery(delivery))
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sp3dr4/bloggogrator/api/middleware"
+	"github.com/sp3dr4/bloggogrator/internal/database"
+	"github.com/sp3dr4/bloggogrator/internal/webhook"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func setupWebhook(userId uuid.UUID, url string) database.Webhook {
+	return database.Webhook{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    userId,
+		Url:       url,
+		Secret:    "s3cret",
+	}
+}
+
+func TestCreateWebhookHandler(t *testing.T) {
+	setupCreateWebhook := func(t *testing.T, mockDbApi *MockedDbApi, body string) (*httptest.ResponseRecorder, *http.Request, apiConfig) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, "/v1/webhooks", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		ctx := context.WithValue(req.Context(), middleware.AuthUser, setupUser())
+		return httptest.NewRecorder(), req.WithContext(ctx), apiConfig{DB: mockDbApi}
+	}
+
+	t.Run("return 201", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		feedId := uuid.New()
+		hook := setupWebhook(uuid.New(), "https://chat.example.com/hook")
+		hook.FeedIds = []uuid.UUID{feedId}
+		hook.Keywords = []string{"golang"}
+		mockDbApi.On("CreateWebhook", mock.Anything, mock.MatchedBy(func(p database.CreateWebhookParams) bool {
+			return p.Url == hook.Url && len(p.Secret) == 64 &&
+				len(p.FeedIds) == 1 && p.FeedIds[0] == feedId &&
+				len(p.Keywords) == 1 && p.Keywords[0] == "golang"
+		})).Return(hook, nil)
+		rw, req, testApi := setupCreateWebhook(t, mockDbApi, `{"url": "https://chat.example.com/hook", "feed_ids": ["`+feedId.String()+`"], "keywords": [" golang ", ""]}`)
+
+		testApi.handlerCreateWebhook(rw, req)
+
+		require.Equal(t, http.StatusCreated, rw.Code)
+		var resp webhookResponse
+		require.NoError(t, json.NewDecoder(rw.Body).Decode(&resp))
+		require.Equal(t, hook.ID.String(), resp.Id)
+		require.Equal(t, []string{feedId.String()}, resp.FeedIds)
+		require.Equal(t, []string{"golang"}, resp.Keywords)
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	t.Run("return 400 on invalid url", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		rw, req, testApi := setupCreateWebhook(t, mockDbApi, `{"url": "ftp://chat.example.com/hook"}`)
+
+		testApi.handlerCreateWebhook(rw, req)
+
+		compareError(t, rw, http.StatusBadRequest, "invalid webhook url")
+
+		mockDbApi.AssertExpectations(t)
+	})
+}
+
+func TestTestWebhookHandler(t *testing.T) {
+	t.Run("return 200 with the delivery", func(t *testing.T) {
+		var received webhook.Payload
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer receiver.Close()
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi, Webhooks: webhook.NewSender("test-agent/1.0", time.Second)}
+		user := setupUser()
+		hook := setupWebhook(user.ID, receiver.URL)
+		delivery := database.WebhookDelivery{ID: uuid.New(), CreatedAt: now, WebhookID: hook.ID, Event: webhook.EventTest, Status: webhook.StatusPending}
+		delivered := delivery
+		delivered.Status = webhook.StatusDelivered
+		delivered.Attempts = 1
+		delivered.ResponseStatus = sql.NullInt32{Int32: 200, Valid: true}
+		mockDbApi.On("GetWebhook", mock.Anything, hook.ID).Return(hook, nil)
+		mockDbApi.On("CreateWebhookDelivery", mock.Anything, mock.MatchedBy(func(p database.CreateWebhookDeliveryParams) bool {
+			return p.WebhookID == hook.ID && p.Event == webhook.EventTest
+		})).Return(delivery, nil)
+		mockDbApi.On("RecordWebhookDeliveryAttempt", mock.Anything, mock.MatchedBy(func(p database.RecordWebhookDeliveryAttemptParams) bool {
+			return p.ID == delivery.ID && p.Status == webhook.StatusDelivered && p.ResponseStatus.Int32 == 200
+		})).Return(delivered, nil)
+		req, err := http.NewRequest(http.MethodPost, "/v1/webhooks/"+hook.ID.String()+"/test", nil)
+		require.NoError(t, err)
+		req.SetPathValue("webhookID", hook.ID.String())
+		ctx := context.WithValue(req.Context(), middleware.AuthUser, user)
+		rw := httptest.NewRecorder()
+
+		testApi.handlerTestWebhook(rw, req.WithContext(ctx))
+
+		require.Equal(t, http.StatusOK, rw.Code)
+		var resp deliveryResponse
+		require.NoError(t, json.NewDecoder(rw.Body).Decode(&resp))
+		require.Equal(t, webhook.StatusDelivered, resp.Status)
+		require.Equal(t, int32(200), *resp.ResponseStatus)
+		require.Equal(t, delivery.ID, received.ID)
+		require.Equal(t, webhook.EventTest, received.Event)
+
+		mockDbApi.AssertExpectations(t)
+	})
+
+	t.Run("return 403", func(t *testing.T) {
+		mockDbApi := new(MockedDbApi)
+		testApi := apiConfig{DB: mockDbApi}
+		hook := setupWebhook(uuid.New(), "https://chat.example.com/hook")
+		mockDbApi.On("GetWebhook", mock.Anything, hook.ID).Return(hook, nil)
+		req, err := http.NewRequest(http.MethodPost, "/v1/webhooks/"+hook.ID.String()+"/test", nil)
+		require.NoError(t, err)
+		req.SetPathValue("webhookID", hook.ID.String())
+		ctx := context.WithValue(req.Context(), middleware.AuthUser, setupUser())
+		rw := httptest.NewRecorder()
+
+		testApi.handlerTestWebhook(rw, req.WithContext(ctx))
+
+		compareError(t, rw, http.StatusForbidden, "operation not allowed")
+
+		mockDbApi.AssertExpectations(t)
+	})
+}
@@ -8,8 +8,12 @@ require (
 )
 
 require (
+	github.com/andybalholm/brotli v1.1.0
 	github.com/lib/pq v1.10.9
 	github.com/stretchr/testify v1.9.0
+	golang.org/x/crypto v0.26.0
+	golang.org/x/net v0.28.0
+	golang.org/x/text v0.17.0
 )
 
 require (
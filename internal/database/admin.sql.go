@@ -0,0 +1,363 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: admin.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const bootstrapAdmins = `-- name: BootstrapAdmins :execrows
+UPDATE users SET is_admin = true
+WHERE email = ANY($1::text[])
+  AND NOT EXISTS (SELECT 1 FROM users a WHERE a.is_admin)
+`
+
+// Emails are not verified, so the users are only promoted while there is no
+// admin yet. Later admins are managed through the admin API.
+func (q *Queries) BootstrapAdmins(ctx context.Context, emails []string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, bootstrapAdmins, pq.Array(emails))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deletePost = `-- name: DeletePost :execrows
+WITH deleted AS (
+  DELETE FROM posts WHERE id = $1
+  RETURNING url
+)
+INSERT INTO deleted_post_urls (url, deleted_at)
+SELECT url, $2 FROM deleted
+ON CONFLICT (url) DO UPDATE SET deleted_at = EXCLUDED.deleted_at
+`
+
+type DeletePostParams struct {
+	ID        uuid.UUID
+	DeletedAt time.Time
+}
+
+func (q *Queries) DeletePost(ctx context.Context, arg DeletePostParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deletePost, arg.ID, arg.DeletedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getWorkerStats = `-- name: GetWorkerStats :one
+SELECT
+  (SELECT count(*) FROM feeds) AS feeds,
+  (SELECT count(*) FROM feeds WHERE dead_at IS NOT NULL) AS dead_feeds,
+  (SELECT count(*) FROM feeds WHERE disabled_at IS NOT NULL) AS disabled_feeds,
+  (SELECT count(*) FROM feeds
+    WHERE dead_at IS NULL AND disabled_at IS NULL
+      AND (next_fetch_at IS NULL OR next_fetch_at <= now())) AS due_feeds,
+  (SELECT count(*) FROM feed_fetches rf WHERE rf.started_at > $1) AS recent_fetches,
+  (SELECT count(*) FROM feed_fetches ef WHERE ef.started_at > $1 AND ef.error IS NOT NULL) AS recent_failed_fetches,
+  (SELECT count(*) FROM webhook_deliveries WHERE status = 'pending') AS pending_webhook_deliveries,
+  (SELECT lf.last_fetched_at FROM feeds lf
+    ORDER BY lf.last_fetched_at DESC NULLS LAST LIMIT 1) AS last_fetched_at
+`
+
+type GetWorkerStatsRow struct {
+	Feeds                    int64
+	DeadFeeds                int64
+	DisabledFeeds            int64
+	DueFeeds                 int64
+	RecentFetches            int64
+	RecentFailedFetches      int64
+	PendingWebhookDeliveries int64
+	LastFetchedAt            sql.NullTime
+}
+
+func (q *Queries) GetWorkerStats(ctx context.Context, since time.Time) (GetWorkerStatsRow, error) {
+	row := q.db.QueryRowContext(ctx, getWorkerStats, since)
+	var i GetWorkerStatsRow
+	err := row.Scan(
+		&i.Feeds,
+		&i.DeadFeeds,
+		&i.DisabledFeeds,
+		&i.DueFeeds,
+		&i.RecentFetches,
+		&i.RecentFailedFetches,
+		&i.PendingWebhookDeliveries,
+		&i.LastFetchedAt,
+	)
+	return i, err
+}
+
+const listFeedsWithStats = `-- name: ListFeedsWithStats :many
+SELECT f.id, f.created_at, f.updated_at, f.name, f.url, f.last_fetched_at, f.user_id, f.fetch_full_content, f.proxy_url, f.dead_at, f.consecutive_failures, f.last_error, f.last_error_kind, f.last_error_status, f.last_error_at, f.next_fetch_at, f.disabled_at, f.refresh_requested_at,
+  (SELECT count(*) FROM feed_follows fw WHERE fw.feed_id = f.id) AS followers,
+  count(ff.id) AS fetches,
+  count(ff.error) AS failed_fetches,
+  coalesce(avg(ff.duration_ms), 0)::integer AS avg_duration_ms,
+  coalesce(sum(ff.posts_inserted), 0)::bigint AS posts_inserted
+FROM feeds f
+  LEFT JOIN feed_fetches ff ON ff.feed_id = f.id
+GROUP BY f.id
+ORDER BY f.created_at ASC
+LIMIT $1 OFFSET $2
+`
+
+type ListFeedsWithStatsParams struct {
+	Limit  int32
+	Offset int32
+}
+
+type ListFeedsWithStatsRow struct {
+	Feed          Feed
+	Followers     int64
+	Fetches       int64
+	FailedFetches int64
+	AvgDurationMs int32
+	PostsInserted int64
+}
+
+func (q *Queries) ListFeedsWithStats(ctx context.Context, arg ListFeedsWithStatsParams) ([]ListFeedsWithStatsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listFeedsWithStats, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListFeedsWithStatsRow
+	for rows.Next() {
+		var i ListFeedsWithStatsRow
+		if err := rows.Scan(
+			&i.Feed.ID,
+			&i.Feed.CreatedAt,
+			&i.Feed.UpdatedAt,
+			&i.Feed.Name,
+			&i.Feed.Url,
+			&i.Feed.LastFetchedAt,
+			&i.Feed.UserID,
+			&i.Feed.FetchFullContent,
+			&i.Feed.ProxyUrl,
+			&i.Feed.DeadAt,
+			&i.Feed.ConsecutiveFailures,
+			&i.Feed.LastError,
+			&i.Feed.LastErrorKind,
+			&i.Feed.LastErrorStatus,
+			&i.Feed.LastErrorAt,
+			&i.Feed.NextFetchAt,
+			&i.Feed.DisabledAt,
+			&i.Feed.RefreshRequestedAt,
+			&i.Followers,
+			&i.Fetches,
+			&i.FailedFetches,
+			&i.AvgDurationMs,
+			&i.PostsInserted,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsers = `-- name: ListUsers :many
+SELECT id, created_at, updated_at, name, api_key_hash, api_key_prefix, previous_api_key_hash, previous_api_key_expires_at, email, password_hash, is_admin, disabled_at, invite_code_id, preferences FROM users
+ORDER BY created_at ASC
+LIMIT $1 OFFSET $2
+`
+
+type ListUsersParams struct {
+	Limit  int32
+	Offset int32
+}
+
+func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, listUsers, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.ApiKeyHash,
+			&i.ApiKeyPrefix,
+			&i.PreviousApiKeyHash,
+			&i.PreviousApiKeyExpiresAt,
+			&i.Email,
+			&i.PasswordHash,
+			&i.IsAdmin,
+			&i.DisabledAt,
+			&i.InviteCodeID,
+			&i.Preferences,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const scheduleFeedFetch = `-- name: ScheduleFeedFetch :one
+UPDATE feeds SET next_fetch_at = $2, refresh_requested_at = $2, dead_at = NULL, updated_at = $2
+WHERE id = $1
+RETURNING id, created_at, updated_at, name, url, last_fetched_at, user_id, fetch_full_content, proxy_url, dead_at, consecutive_failures, last_error, last_error_kind, last_error_status, last_error_at, next_fetch_at, disabled_at, refresh_requested_at
+`
+
+type ScheduleFeedFetchParams struct {
+	ID          uuid.UUID
+	NextFetchAt sql.NullTime
+}
+
+func (q *Queries) ScheduleFeedFetch(ctx context.Context, arg ScheduleFeedFetchParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, scheduleFeedFetch, arg.ID, arg.NextFetchAt)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.LastFetchedAt,
+		&i.UserID,
+		&i.FetchFullContent,
+		&i.ProxyUrl,
+		&i.DeadAt,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.LastErrorKind,
+		&i.LastErrorStatus,
+		&i.LastErrorAt,
+		&i.NextFetchAt,
+		&i.DisabledAt,
+		&i.RefreshRequestedAt,
+	)
+	return i, err
+}
+
+const setFeedDisabled = `-- name: SetFeedDisabled :one
+UPDATE feeds SET disabled_at = $2, updated_at = $3
+WHERE id = $1
+RETURNING id, created_at, updated_at, name, url, last_fetched_at, user_id, fetch_full_content, proxy_url, dead_at, consecutive_failures, last_error, last_error_kind, last_error_status, last_error_at, next_fetch_at, disabled_at, refresh_requested_at
+`
+
+type SetFeedDisabledParams struct {
+	ID         uuid.UUID
+	DisabledAt sql.NullTime
+	UpdatedAt  time.Time
+}
+
+func (q *Queries) SetFeedDisabled(ctx context.Context, arg SetFeedDisabledParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, setFeedDisabled, arg.ID, arg.DisabledAt, arg.UpdatedAt)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.LastFetchedAt,
+		&i.UserID,
+		&i.FetchFullContent,
+		&i.ProxyUrl,
+		&i.DeadAt,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.LastErrorKind,
+		&i.LastErrorStatus,
+		&i.LastErrorAt,
+		&i.NextFetchAt,
+		&i.DisabledAt,
+		&i.RefreshRequestedAt,
+	)
+	return i, err
+}
+
+const setUserAdmin = `-- name: SetUserAdmin :one
+UPDATE users SET is_admin = $2, updated_at = $3
+WHERE id = $1
+RETURNING id, created_at, updated_at, name, api_key_hash, api_key_prefix, previous_api_key_hash, previous_api_key_expires_at, email, password_hash, is_admin, disabled_at, invite_code_id, preferences
+`
+
+type SetUserAdminParams struct {
+	ID        uuid.UUID
+	IsAdmin   bool
+	UpdatedAt time.Time
+}
+
+func (q *Queries) SetUserAdmin(ctx context.Context, arg SetUserAdminParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserAdmin, arg.ID, arg.IsAdmin, arg.UpdatedAt)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.ApiKeyHash,
+		&i.ApiKeyPrefix,
+		&i.PreviousApiKeyHash,
+		&i.PreviousApiKeyExpiresAt,
+		&i.Email,
+		&i.PasswordHash,
+		&i.IsAdmin,
+		&i.DisabledAt,
+		&i.InviteCodeID,
+		&i.Preferences,
+	)
+	return i, err
+}
+
+const setUserDisabled = `-- name: SetUserDisabled :one
+UPDATE users SET disabled_at = $2, updated_at = $3
+WHERE id = $1
+RETURNING id, created_at, updated_at, name, api_key_hash, api_key_prefix, previous_api_key_hash, previous_api_key_expires_at, email, password_hash, is_admin, disabled_at, invite_code_id, preferences
+`
+
+type SetUserDisabledParams struct {
+	ID         uuid.UUID
+	DisabledAt sql.NullTime
+	UpdatedAt  time.Time
+}
+
+func (q *Queries) SetUserDisabled(ctx context.Context, arg SetUserDisabledParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserDisabled, arg.ID, arg.DisabledAt, arg.UpdatedAt)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.ApiKeyHash,
+		&i.ApiKeyPrefix,
+		&i.PreviousApiKeyHash,
+		&i.PreviousApiKeyExpiresAt,
+		&i.Email,
+		&i.PasswordHash,
+		&i.IsAdmin,
+		&i.DisabledAt,
+		&i.InviteCodeID,
+		&i.Preferences,
+	)
+	return i, err
+}
@@ -0,0 +1,201 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: api_keys.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const createApiKey = `-- name: CreateApiKey :one
+INSERT INTO api_keys (id, created_at, user_id, label, key_hash, key_prefix, scopes, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING id, created_at, user_id, label, scopes, expires_at, last_used_at, revoked_at, key_hash, key_prefix
+`
+
+type CreateApiKeyParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Label     string
+	KeyHash   string
+	KeyPrefix string
+	Scopes    []string
+	ExpiresAt sql.NullTime
+}
+
+func (q *Queries) CreateApiKey(ctx context.Context, arg CreateApiKeyParams) (ApiKey, error) {
+	row := q.db.QueryRowContext(ctx, createApiKey,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UserID,
+		arg.Label,
+		arg.KeyHash,
+		arg.KeyPrefix,
+		pq.Array(arg.Scopes),
+		arg.ExpiresAt,
+	)
+	var i ApiKey
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.Label,
+		pq.Array(&i.Scopes),
+		&i.ExpiresAt,
+		&i.LastUsedAt,
+		&i.RevokedAt,
+		&i.KeyHash,
+		&i.KeyPrefix,
+	)
+	return i, err
+}
+
+const getApiKey = `-- name: GetApiKey :one
+SELECT id, created_at, user_id, label, scopes, expires_at, last_used_at, revoked_at, key_hash, key_prefix FROM api_keys
+WHERE id = $1
+`
+
+func (q *Queries) GetApiKey(ctx context.Context, id uuid.UUID) (ApiKey, error) {
+	row := q.db.QueryRowContext(ctx, getApiKey, id)
+	var i ApiKey
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.Label,
+		pq.Array(&i.Scopes),
+		&i.ExpiresAt,
+		&i.LastUsedAt,
+		&i.RevokedAt,
+		&i.KeyHash,
+		&i.KeyPrefix,
+	)
+	return i, err
+}
+
+const getUserByScopedApiKeyHash = `-- name: GetUserByScopedApiKeyHash :one
+SELECT u.id, u.created_at, u.updated_at, u.name, u.api_key_hash, u.api_key_prefix, u.previous_api_key_hash, u.previous_api_key_expires_at, u.email, u.password_hash, u.is_admin, u.disabled_at, u.invite_code_id, u.preferences, k.id, k.created_at, k.user_id, k.label, k.scopes, k.expires_at, k.last_used_at, k.revoked_at, k.key_hash, k.key_prefix
+FROM api_keys k
+  INNER JOIN users u ON u.id = k.user_id
+WHERE k.key_hash = $1
+  AND k.revoked_at IS NULL
+  AND (k.expires_at IS NULL OR k.expires_at > now())
+  AND u.disabled_at IS NULL
+`
+
+type GetUserByScopedApiKeyHashRow struct {
+	User   User
+	ApiKey ApiKey
+}
+
+func (q *Queries) GetUserByScopedApiKeyHash(ctx context.Context, keyHash string) (GetUserByScopedApiKeyHashRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserByScopedApiKeyHash, keyHash)
+	var i GetUserByScopedApiKeyHashRow
+	err := row.Scan(
+		&i.User.ID,
+		&i.User.CreatedAt,
+		&i.User.UpdatedAt,
+		&i.User.Name,
+		&i.User.ApiKeyHash,
+		&i.User.ApiKeyPrefix,
+		&i.User.PreviousApiKeyHash,
+		&i.User.PreviousApiKeyExpiresAt,
+		&i.User.Email,
+		&i.User.PasswordHash,
+		&i.User.IsAdmin,
+		&i.User.DisabledAt,
+		&i.User.InviteCodeID,
+		&i.User.Preferences,
+		&i.ApiKey.ID,
+		&i.ApiKey.CreatedAt,
+		&i.ApiKey.UserID,
+		&i.ApiKey.Label,
+		pq.Array(&i.ApiKey.Scopes),
+		&i.ApiKey.ExpiresAt,
+		&i.ApiKey.LastUsedAt,
+		&i.ApiKey.RevokedAt,
+		&i.ApiKey.KeyHash,
+		&i.ApiKey.KeyPrefix,
+	)
+	return i, err
+}
+
+const listUserApiKeys = `-- name: ListUserApiKeys :many
+SELECT id, created_at, user_id, label, scopes, expires_at, last_used_at, revoked_at, key_hash, key_prefix FROM api_keys
+WHERE user_id = $1
+ORDER BY created_at ASC
+`
+
+func (q *Queries) ListUserApiKeys(ctx context.Context, userID uuid.UUID) ([]ApiKey, error) {
+	rows, err := q.db.QueryContext(ctx, listUserApiKeys, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ApiKey
+	for rows.Next() {
+		var i ApiKey
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UserID,
+			&i.Label,
+			pq.Array(&i.Scopes),
+			&i.ExpiresAt,
+			&i.LastUsedAt,
+			&i.RevokedAt,
+			&i.KeyHash,
+			&i.KeyPrefix,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeApiKey = `-- name: RevokeApiKey :exec
+UPDATE api_keys SET revoked_at = $2
+WHERE id = $1 AND revoked_at IS NULL
+`
+
+type RevokeApiKeyParams struct {
+	ID        uuid.UUID
+	RevokedAt sql.NullTime
+}
+
+func (q *Queries) RevokeApiKey(ctx context.Context, arg RevokeApiKeyParams) error {
+	_, err := q.db.ExecContext(ctx, revokeApiKey, arg.ID, arg.RevokedAt)
+	return err
+}
+
+const touchApiKey = `-- name: TouchApiKey :exec
+UPDATE api_keys SET last_used_at = $2
+WHERE id = $1
+  AND (last_used_at IS NULL OR last_used_at < $3)
+`
+
+type TouchApiKeyParams struct {
+	ID         uuid.UUID
+	UsedAt     sql.NullTime
+	UsedBefore sql.NullTime
+}
+
+func (q *Queries) TouchApiKey(ctx context.Context, arg TouchApiKeyParams) error {
+	_, err := q.db.ExecContext(ctx, touchApiKey, arg.ID, arg.UsedAt, arg.UsedBefore)
+	return err
+}
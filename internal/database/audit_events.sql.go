@@ -0,0 +1,93 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: audit_events.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createAuditEvent = `-- name: CreateAuditEvent :one
+INSERT INTO audit_events (id, created_at, user_id, action, remote_addr, details)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, created_at, user_id, action, remote_addr, details
+`
+
+type CreateAuditEventParams struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UserID     uuid.UUID
+	Action     string
+	RemoteAddr sql.NullString
+	Details    json.RawMessage
+}
+
+func (q *Queries) CreateAuditEvent(ctx context.Context, arg CreateAuditEventParams) (AuditEvent, error) {
+	row := q.db.QueryRowContext(ctx, createAuditEvent,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UserID,
+		arg.Action,
+		arg.RemoteAddr,
+		arg.Details,
+	)
+	var i AuditEvent
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.Action,
+		&i.RemoteAddr,
+		&i.Details,
+	)
+	return i, err
+}
+
+const listUserAuditEvents = `-- name: ListUserAuditEvents :many
+SELECT id, created_at, user_id, action, remote_addr, details FROM audit_events
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT $2
+`
+
+type ListUserAuditEventsParams struct {
+	UserID uuid.UUID
+	Limit  int32
+}
+
+func (q *Queries) ListUserAuditEvents(ctx context.Context, arg ListUserAuditEventsParams) ([]AuditEvent, error) {
+	rows, err := q.db.QueryContext(ctx, listUserAuditEvents, arg.UserID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AuditEvent
+	for rows.Next() {
+		var i AuditEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UserID,
+			&i.Action,
+			&i.RemoteAddr,
+			&i.Details,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,339 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: digests.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const claimDigestConfirmation = `-- name: ClaimDigestConfirmation :execrows
+UPDATE digest_subscriptions SET confirmation_sent_at = $1::timestamptz
+WHERE user_id = $2 AND confirmation_token = $3
+  AND confirmation_sent_at IS NULL AND confirmed_at IS NULL
+`
+
+type ClaimDigestConfirmationParams struct {
+	SentAt            time.Time
+	UserID            uuid.UUID
+	ConfirmationToken string
+}
+
+func (q *Queries) ClaimDigestConfirmation(ctx context.Context, arg ClaimDigestConfirmationParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, claimDigestConfirmation, arg.SentAt, arg.UserID, arg.ConfirmationToken)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const claimDigestRun = `-- name: ClaimDigestRun :one
+INSERT INTO digest_runs (id, created_at, user_id, period_start, scheduled_for, status)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (user_id, scheduled_for) DO NOTHING
+RETURNING id, created_at, user_id, period_start, scheduled_for, status, posts, finished_at, error
+`
+
+type ClaimDigestRunParams struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UserID       uuid.UUID
+	PeriodStart  time.Time
+	ScheduledFor time.Time
+	Status       string
+}
+
+func (q *Queries) ClaimDigestRun(ctx context.Context, arg ClaimDigestRunParams) (DigestRun, error) {
+	row := q.db.QueryRowContext(ctx, claimDigestRun,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UserID,
+		arg.PeriodStart,
+		arg.ScheduledFor,
+		arg.Status,
+	)
+	var i DigestRun
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.PeriodStart,
+		&i.ScheduledFor,
+		&i.Status,
+		&i.Posts,
+		&i.FinishedAt,
+		&i.Error,
+	)
+	return i, err
+}
+
+const confirmDigestSubscription = `-- name: ConfirmDigestSubscription :execrows
+UPDATE digest_subscriptions SET confirmed_at = COALESCE(confirmed_at, $1::timestamptz)
+WHERE confirmation_token = $2
+`
+
+type ConfirmDigestSubscriptionParams struct {
+	ConfirmedAt       time.Time
+	ConfirmationToken string
+}
+
+func (q *Queries) ConfirmDigestSubscription(ctx context.Context, arg ConfirmDigestSubscriptionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, confirmDigestSubscription, arg.ConfirmedAt, arg.ConfirmationToken)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteDigestSubscription = `-- name: DeleteDigestSubscription :exec
+DELETE FROM digest_subscriptions
+WHERE user_id = $1
+`
+
+func (q *Queries) DeleteDigestSubscription(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteDigestSubscription, userID)
+	return err
+}
+
+const deleteDigestSubscriptionByToken = `-- name: DeleteDigestSubscriptionByToken :execrows
+DELETE FROM digest_subscriptions
+WHERE unsubscribe_token = $1
+`
+
+func (q *Queries) DeleteDigestSubscriptionByToken(ctx context.Context, unsubscribeToken string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteDigestSubscriptionByToken, unsubscribeToken)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const finishDigestRun = `-- name: FinishDigestRun :one
+UPDATE digest_runs SET status = $2, posts = $3, finished_at = $4, error = $5
+WHERE id = $1
+RETURNING id, created_at, user_id, period_start, scheduled_for, status, posts, finished_at, error
+`
+
+type FinishDigestRunParams struct {
+	ID         uuid.UUID
+	Status     string
+	Posts      int32
+	FinishedAt sql.NullTime
+	Error      sql.NullString
+}
+
+func (q *Queries) FinishDigestRun(ctx context.Context, arg FinishDigestRunParams) (DigestRun, error) {
+	row := q.db.QueryRowContext(ctx, finishDigestRun,
+		arg.ID,
+		arg.Status,
+		arg.Posts,
+		arg.FinishedAt,
+		arg.Error,
+	)
+	var i DigestRun
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.PeriodStart,
+		&i.ScheduledFor,
+		&i.Status,
+		&i.Posts,
+		&i.FinishedAt,
+		&i.Error,
+	)
+	return i, err
+}
+
+const getDigestSubscription = `-- name: GetDigestSubscription :one
+SELECT user_id, created_at, updated_at, email, frequency, send_hour, weekday, timezone, unsubscribe_token, confirmation_token, confirmation_sent_at, confirmed_at FROM digest_subscriptions
+WHERE user_id = $1
+`
+
+func (q *Queries) GetDigestSubscription(ctx context.Context, userID uuid.UUID) (DigestSubscription, error) {
+	row := q.db.QueryRowContext(ctx, getDigestSubscription, userID)
+	var i DigestSubscription
+	err := row.Scan(
+		&i.UserID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.Frequency,
+		&i.SendHour,
+		&i.Weekday,
+		&i.Timezone,
+		&i.UnsubscribeToken,
+		&i.ConfirmationToken,
+		&i.ConfirmationSentAt,
+		&i.ConfirmedAt,
+	)
+	return i, err
+}
+
+const getFollowedPostsBetween = `-- name: GetFollowedPostsBetween :many
+SELECT p.id, p.created_at, p.updated_at, p.url, p.title, p.description, p.published_at, p.feed_id, p.description_text, p.content, p.seq, p.author, p.categories
+FROM posts p
+  INNER JOIN feed_follows ff ON p.feed_id = ff.feed_id
+WHERE ff.user_id = $1
+  AND p.created_at > $2 AND p.created_at <= $3
+  AND NOT EXISTS (
+    SELECT 1 FROM post_states ps
+    WHERE ps.post_id = p.id AND ps.user_id = $1 AND (ps.hidden OR ps.read)
+  )
+ORDER BY p.published_at DESC
+LIMIT $4
+`
+
+type GetFollowedPostsBetweenParams struct {
+	UserID uuid.UUID
+	After  time.Time
+	Until  time.Time
+	Amount int32
+}
+
+func (q *Queries) GetFollowedPostsBetween(ctx context.Context, arg GetFollowedPostsBetweenParams) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, getFollowedPostsBetween,
+		arg.UserID,
+		arg.After,
+		arg.Until,
+		arg.Amount,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Post
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Url,
+			&i.Title,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.DescriptionText,
+			&i.Content,
+			&i.Seq,
+			&i.Author,
+			pq.Array(&i.Categories),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDigestSubscriptions = `-- name: ListDigestSubscriptions :many
+SELECT user_id, created_at, updated_at, email, frequency, send_hour, weekday, timezone, unsubscribe_token, confirmation_token, confirmation_sent_at, confirmed_at FROM digest_subscriptions
+`
+
+func (q *Queries) ListDigestSubscriptions(ctx context.Context) ([]DigestSubscription, error) {
+	rows, err := q.db.QueryContext(ctx, listDigestSubscriptions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []DigestSubscription
+	for rows.Next() {
+		var i DigestSubscription
+		if err := rows.Scan(
+			&i.UserID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Email,
+			&i.Frequency,
+			&i.SendHour,
+			&i.Weekday,
+			&i.Timezone,
+			&i.UnsubscribeToken,
+			&i.ConfirmationToken,
+			&i.ConfirmationSentAt,
+			&i.ConfirmedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const upsertDigestSubscription = `-- name: UpsertDigestSubscription :one
+INSERT INTO digest_subscriptions (user_id, created_at, updated_at, email, frequency, send_hour, weekday, timezone, unsubscribe_token, confirmation_token)
+VALUES ($1, $2, $2, $3, $4, $5, $6, $7, $8, $9)
+ON CONFLICT (user_id) DO UPDATE SET
+  updated_at = EXCLUDED.updated_at, email = EXCLUDED.email, frequency = EXCLUDED.frequency,
+  send_hour = EXCLUDED.send_hour, weekday = EXCLUDED.weekday, timezone = EXCLUDED.timezone,
+  confirmation_token = CASE WHEN digest_subscriptions.email = EXCLUDED.email
+    THEN digest_subscriptions.confirmation_token ELSE EXCLUDED.confirmation_token END,
+  confirmation_sent_at = CASE WHEN digest_subscriptions.email = EXCLUDED.email
+    THEN digest_subscriptions.confirmation_sent_at END,
+  confirmed_at = CASE WHEN digest_subscriptions.email = EXCLUDED.email
+    THEN digest_subscriptions.confirmed_at END
+RETURNING user_id, created_at, updated_at, email, frequency, send_hour, weekday, timezone, unsubscribe_token, confirmation_token, confirmation_sent_at, confirmed_at
+`
+
+type UpsertDigestSubscriptionParams struct {
+	UserID            uuid.UUID
+	CreatedAt         time.Time
+	Email             string
+	Frequency         string
+	SendHour          int32
+	Weekday           int32
+	Timezone          string
+	UnsubscribeToken  string
+	ConfirmationToken string
+}
+
+// A new address has to be confirmed again.
+func (q *Queries) UpsertDigestSubscription(ctx context.Context, arg UpsertDigestSubscriptionParams) (DigestSubscription, error) {
+	row := q.db.QueryRowContext(ctx, upsertDigestSubscription,
+		arg.UserID,
+		arg.CreatedAt,
+		arg.Email,
+		arg.Frequency,
+		arg.SendHour,
+		arg.Weekday,
+		arg.Timezone,
+		arg.UnsubscribeToken,
+		arg.ConfirmationToken,
+	)
+	var i DigestSubscription
+	err := row.Scan(
+		&i.UserID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.Frequency,
+		&i.SendHour,
+		&i.Weekday,
+		&i.Timezone,
+		&i.UnsubscribeToken,
+		&i.ConfirmationToken,
+		&i.ConfirmationSentAt,
+		&i.ConfirmedAt,
+	)
+	return i, err
+}
@@ -0,0 +1,29 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetFollowedPostsBetween(t *testing.T) {
+	q := setupTestQueries(t)
+	ctx := context.Background()
+	now := time.Now()
+	user, feed := setupTestFollow(t, q)
+	unread := setupTestPost(t, q, feed.ID, "unread")
+	read := setupTestPost(t, q, feed.ID, "read")
+	hidden := setupTestPost(t, q, feed.ID, "hidden")
+	require.NoError(t, q.UpsertPostState(ctx, UpsertPostStateParams{UserID: user.ID, PostID: read.ID, CreatedAt: now, Read: true}))
+	require.NoError(t, q.UpsertPostState(ctx, UpsertPostStateParams{UserID: user.ID, PostID: hidden.ID, CreatedAt: now, Hidden: true}))
+
+	got, err := q.GetFollowedPostsBetween(ctx, GetFollowedPostsBetweenParams{
+		UserID: user.ID, After: now.Add(-time.Hour), Until: now.Add(time.Hour), Amount: 10,
+	})
+
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.Equal(t, unread.ID, got[0].ID)
+}
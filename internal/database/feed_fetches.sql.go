@@ -0,0 +1,133 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: feed_fetches.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createFeedFetch = `-- name: CreateFeedFetch :one
+INSERT INTO feed_fetches (id, feed_id, started_at, finished_at, duration_ms, status_code, bytes, items_seen, posts_inserted, posts_updated, error)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+RETURNING id, feed_id, started_at, finished_at, duration_ms, status_code, bytes, items_seen, posts_inserted, posts_updated, error
+`
+
+type CreateFeedFetchParams struct {
+	ID            uuid.UUID
+	FeedID        uuid.UUID
+	StartedAt     time.Time
+	FinishedAt    time.Time
+	DurationMs    int32
+	StatusCode    sql.NullInt32
+	Bytes         int64
+	ItemsSeen     int32
+	PostsInserted int32
+	PostsUpdated  int32
+	Error         sql.NullString
+}
+
+func (q *Queries) CreateFeedFetch(ctx context.Context, arg CreateFeedFetchParams) (FeedFetch, error) {
+	row := q.db.QueryRowContext(ctx, createFeedFetch,
+		arg.ID,
+		arg.FeedID,
+		arg.StartedAt,
+		arg.FinishedAt,
+		arg.DurationMs,
+		arg.StatusCode,
+		arg.Bytes,
+		arg.ItemsSeen,
+		arg.PostsInserted,
+		arg.PostsUpdated,
+		arg.Error,
+	)
+	var i FeedFetch
+	err := row.Scan(
+		&i.ID,
+		&i.FeedID,
+		&i.StartedAt,
+		&i.FinishedAt,
+		&i.DurationMs,
+		&i.StatusCode,
+		&i.Bytes,
+		&i.ItemsSeen,
+		&i.PostsInserted,
+		&i.PostsUpdated,
+		&i.Error,
+	)
+	return i, err
+}
+
+const listFeedFetches = `-- name: ListFeedFetches :many
+SELECT id, feed_id, started_at, finished_at, duration_ms, status_code, bytes, items_seen, posts_inserted, posts_updated, error FROM feed_fetches
+WHERE feed_id = $1
+ORDER BY started_at DESC
+LIMIT $2
+`
+
+type ListFeedFetchesParams struct {
+	FeedID uuid.UUID
+	Limit  int32
+}
+
+func (q *Queries) ListFeedFetches(ctx context.Context, arg ListFeedFetchesParams) ([]FeedFetch, error) {
+	rows, err := q.db.QueryContext(ctx, listFeedFetches, arg.FeedID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FeedFetch
+	for rows.Next() {
+		var i FeedFetch
+		if err := rows.Scan(
+			&i.ID,
+			&i.FeedID,
+			&i.StartedAt,
+			&i.FinishedAt,
+			&i.DurationMs,
+			&i.StatusCode,
+			&i.Bytes,
+			&i.ItemsSeen,
+			&i.PostsInserted,
+			&i.PostsUpdated,
+			&i.Error,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const pruneFeedFetches = `-- name: PruneFeedFetches :exec
+DELETE FROM feed_fetches ff
+WHERE ff.feed_id = $1
+  AND ff.id NOT IN (
+    SELECT kept.id FROM feed_fetches kept
+    WHERE kept.feed_id = $1
+    ORDER BY kept.started_at DESC
+    LIMIT $2
+  )
+`
+
+type PruneFeedFetchesParams struct {
+	FeedID uuid.UUID
+	Keep   int32
+}
+
+func (q *Queries) PruneFeedFetches(ctx context.Context, arg PruneFeedFetchesParams) error {
+	_, err := q.db.ExecContext(ctx, pruneFeedFetches, arg.FeedID, arg.Keep)
+	return err
+}
@@ -101,3 +101,24 @@ func (q *Queries) ListUserFeedFollows(ctx context.Context, userID uuid.UUID) ([]
 	}
 	return items, nil
 }
+
+const moveFeedFollows = `-- name: MoveFeedFollows :exec
+INSERT INTO feed_follows (id, created_at, user_id, feed_id)
+SELECT gen_random_uuid(), ff.created_at, ff.user_id, $1
+FROM feed_follows ff
+WHERE ff.feed_id = $2
+  AND NOT EXISTS (
+    SELECT 1 FROM feed_follows existing
+    WHERE existing.feed_id = $1 AND existing.user_id = ff.user_id
+  )
+`
+
+type MoveFeedFollowsParams struct {
+	ToFeedID   uuid.UUID
+	FromFeedID uuid.UUID
+}
+
+func (q *Queries) MoveFeedFollows(ctx context.Context, arg MoveFeedFollowsParams) error {
+	_, err := q.db.ExecContext(ctx, moveFeedFollows, arg.ToFeedID, arg.FromFeedID)
+	return err
+}
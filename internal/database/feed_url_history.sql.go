@@ -0,0 +1,65 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: feed_url_history.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createFeedUrlHistory = `-- name: CreateFeedUrlHistory :one
+INSERT INTO feed_url_history (id, created_at, feed_id, old_url, new_url, status_code)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, created_at, feed_id, old_url, new_url, status_code
+`
+
+type CreateFeedUrlHistoryParams struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	FeedID     uuid.UUID
+	OldUrl     string
+	NewUrl     sql.NullString
+	StatusCode int32
+}
+
+func (q *Queries) CreateFeedUrlHistory(ctx context.Context, arg CreateFeedUrlHistoryParams) (FeedUrlHistory, error) {
+	row := q.db.QueryRowContext(ctx, createFeedUrlHistory,
+		arg.ID,
+		arg.CreatedAt,
+		arg.FeedID,
+		arg.OldUrl,
+		arg.NewUrl,
+		arg.StatusCode,
+	)
+	var i FeedUrlHistory
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.FeedID,
+		&i.OldUrl,
+		&i.NewUrl,
+		&i.StatusCode,
+	)
+	return i, err
+}
+
+const moveFeedUrlHistory = `-- name: MoveFeedUrlHistory :exec
+UPDATE feed_url_history SET feed_id = $1
+WHERE feed_id = $2
+`
+
+type MoveFeedUrlHistoryParams struct {
+	ToFeedID   uuid.UUID
+	FromFeedID uuid.UUID
+}
+
+func (q *Queries) MoveFeedUrlHistory(ctx context.Context, arg MoveFeedUrlHistoryParams) error {
+	_, err := q.db.ExecContext(ctx, moveFeedUrlHistory, arg.ToFeedID, arg.FromFeedID)
+	return err
+}
@@ -16,7 +16,7 @@ import (
 const createFeed = `-- name: CreateFeed :one
 INSERT INTO feeds (id, created_at, updated_at, name, url, user_id)
 VALUES ($1, $2, $3, $4, $5, $6)
-RETURNING id, created_at, updated_at, name, url, last_fetched_at, user_id
+RETURNING id, created_at, updated_at, name, url, last_fetched_at, user_id, fetch_full_content, proxy_url, dead_at, consecutive_failures, last_error, last_error_kind, last_error_status, last_error_at, next_fetch_at, disabled_at, refresh_requested_at
 `
 
 type CreateFeedParams struct {
@@ -25,7 +25,7 @@ type CreateFeedParams struct {
 	UpdatedAt time.Time
 	Name      string
 	Url       string
-	UserID    uuid.UUID
+	UserID    uuid.NullUUID
 }
 
 func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, error) {
@@ -46,12 +46,33 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.Url,
 		&i.LastFetchedAt,
 		&i.UserID,
+		&i.FetchFullContent,
+		&i.ProxyUrl,
+		&i.DeadAt,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.LastErrorKind,
+		&i.LastErrorStatus,
+		&i.LastErrorAt,
+		&i.NextFetchAt,
+		&i.DisabledAt,
+		&i.RefreshRequestedAt,
 	)
 	return i, err
 }
 
+const deleteFeed = `-- name: DeleteFeed :exec
+DELETE FROM feeds
+WHERE id = $1
+`
+
+func (q *Queries) DeleteFeed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteFeed, id)
+	return err
+}
+
 const getFeed = `-- name: GetFeed :one
-SELECT id, created_at, updated_at, name, url, last_fetched_at, user_id FROM feeds
+SELECT id, created_at, updated_at, name, url, last_fetched_at, user_id, fetch_full_content, proxy_url, dead_at, consecutive_failures, last_error, last_error_kind, last_error_status, last_error_at, next_fetch_at, disabled_at, refresh_requested_at FROM feeds
 WHERE id = $1
 `
 
@@ -66,12 +87,61 @@ func (q *Queries) GetFeed(ctx context.Context, id uuid.UUID) (Feed, error) {
 		&i.Url,
 		&i.LastFetchedAt,
 		&i.UserID,
+		&i.FetchFullContent,
+		&i.ProxyUrl,
+		&i.DeadAt,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.LastErrorKind,
+		&i.LastErrorStatus,
+		&i.LastErrorAt,
+		&i.NextFetchAt,
+		&i.DisabledAt,
+		&i.RefreshRequestedAt,
+	)
+	return i, err
+}
+
+const getFeedByUrl = `-- name: GetFeedByUrl :one
+SELECT id, created_at, updated_at, name, url, last_fetched_at, user_id, fetch_full_content, proxy_url, dead_at, consecutive_failures, last_error, last_error_kind, last_error_status, last_error_at, next_fetch_at, disabled_at, refresh_requested_at FROM feeds
+WHERE url = $1
+`
+
+func (q *Queries) GetFeedByUrl(ctx context.Context, url string) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getFeedByUrl, url)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.LastFetchedAt,
+		&i.UserID,
+		&i.FetchFullContent,
+		&i.ProxyUrl,
+		&i.DeadAt,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.LastErrorKind,
+		&i.LastErrorStatus,
+		&i.LastErrorAt,
+		&i.NextFetchAt,
+		&i.DisabledAt,
+		&i.RefreshRequestedAt,
 	)
 	return i, err
 }
 
 const getNextFeedsToFetch = `-- name: GetNextFeedsToFetch :many
-SELECT id, created_at, updated_at, name, url, last_fetched_at, user_id FROM feeds
+SELECT id, created_at, updated_at, name, url, last_fetched_at, user_id, fetch_full_content, proxy_url, dead_at, consecutive_failures, last_error, last_error_kind, last_error_status, last_error_at, next_fetch_at, disabled_at, refresh_requested_at FROM feeds
+WHERE dead_at IS NULL
+  AND disabled_at IS NULL
+  AND (next_fetch_at IS NULL OR next_fetch_at <= now())
+  AND (refresh_requested_at IS NOT NULL OR NOT EXISTS (
+    SELECT 1 FROM websub_subscriptions ws
+    WHERE ws.feed_id = feeds.id AND ws.lease_expires_at > now()
+  ))
 ORDER BY last_fetched_at ASC NULLS FIRST
 LIMIT $1
 `
@@ -93,6 +163,17 @@ func (q *Queries) GetNextFeedsToFetch(ctx context.Context, limit int32) ([]Feed,
 			&i.Url,
 			&i.LastFetchedAt,
 			&i.UserID,
+			&i.FetchFullContent,
+			&i.ProxyUrl,
+			&i.DeadAt,
+			&i.ConsecutiveFailures,
+			&i.LastError,
+			&i.LastErrorKind,
+			&i.LastErrorStatus,
+			&i.LastErrorAt,
+			&i.NextFetchAt,
+			&i.DisabledAt,
+			&i.RefreshRequestedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -108,7 +189,7 @@ func (q *Queries) GetNextFeedsToFetch(ctx context.Context, limit int32) ([]Feed,
 }
 
 const listFeeds = `-- name: ListFeeds :many
-SELECT id, created_at, updated_at, name, url, last_fetched_at, user_id FROM feeds
+SELECT id, created_at, updated_at, name, url, last_fetched_at, user_id, fetch_full_content, proxy_url, dead_at, consecutive_failures, last_error, last_error_kind, last_error_status, last_error_at, next_fetch_at, disabled_at, refresh_requested_at FROM feeds
 `
 
 func (q *Queries) ListFeeds(ctx context.Context) ([]Feed, error) {
@@ -128,6 +209,17 @@ func (q *Queries) ListFeeds(ctx context.Context) ([]Feed, error) {
 			&i.Url,
 			&i.LastFetchedAt,
 			&i.UserID,
+			&i.FetchFullContent,
+			&i.ProxyUrl,
+			&i.DeadAt,
+			&i.ConsecutiveFailures,
+			&i.LastError,
+			&i.LastErrorKind,
+			&i.LastErrorStatus,
+			&i.LastErrorAt,
+			&i.NextFetchAt,
+			&i.DisabledAt,
+			&i.RefreshRequestedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -142,10 +234,101 @@ func (q *Queries) ListFeeds(ctx context.Context) ([]Feed, error) {
 	return items, nil
 }
 
+const markFeedDead = `-- name: MarkFeedDead :one
+UPDATE feeds SET dead_at = $2, updated_at = $2
+WHERE id = $1
+RETURNING id, created_at, updated_at, name, url, last_fetched_at, user_id, fetch_full_content, proxy_url, dead_at, consecutive_failures, last_error, last_error_kind, last_error_status, last_error_at, next_fetch_at, disabled_at, refresh_requested_at
+`
+
+type MarkFeedDeadParams struct {
+	ID     uuid.UUID
+	DeadAt sql.NullTime
+}
+
+func (q *Queries) MarkFeedDead(ctx context.Context, arg MarkFeedDeadParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, markFeedDead, arg.ID, arg.DeadAt)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.LastFetchedAt,
+		&i.UserID,
+		&i.FetchFullContent,
+		&i.ProxyUrl,
+		&i.DeadAt,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.LastErrorKind,
+		&i.LastErrorStatus,
+		&i.LastErrorAt,
+		&i.NextFetchAt,
+		&i.DisabledAt,
+		&i.RefreshRequestedAt,
+	)
+	return i, err
+}
+
+const markFeedFailed = `-- name: MarkFeedFailed :one
+UPDATE feeds SET last_fetched_at = $2, updated_at = $2,
+  consecutive_failures = consecutive_failures + 1, last_error = $3, last_error_kind = $4,
+  last_error_status = $5, last_error_at = $2, next_fetch_at = $6,
+  refresh_requested_at = NULL
+WHERE id = $1
+RETURNING id, created_at, updated_at, name, url, last_fetched_at, user_id, fetch_full_content, proxy_url, dead_at, consecutive_failures, last_error, last_error_kind, last_error_status, last_error_at, next_fetch_at, disabled_at, refresh_requested_at
+`
+
+type MarkFeedFailedParams struct {
+	ID              uuid.UUID
+	LastFetchedAt   sql.NullTime
+	LastError       sql.NullString
+	LastErrorKind   sql.NullString
+	LastErrorStatus sql.NullInt32
+	NextFetchAt     sql.NullTime
+}
+
+func (q *Queries) MarkFeedFailed(ctx context.Context, arg MarkFeedFailedParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, markFeedFailed,
+		arg.ID,
+		arg.LastFetchedAt,
+		arg.LastError,
+		arg.LastErrorKind,
+		arg.LastErrorStatus,
+		arg.NextFetchAt,
+	)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.LastFetchedAt,
+		&i.UserID,
+		&i.FetchFullContent,
+		&i.ProxyUrl,
+		&i.DeadAt,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.LastErrorKind,
+		&i.LastErrorStatus,
+		&i.LastErrorAt,
+		&i.NextFetchAt,
+		&i.DisabledAt,
+		&i.RefreshRequestedAt,
+	)
+	return i, err
+}
+
 const markFeedFetched = `-- name: MarkFeedFetched :one
-UPDATE feeds SET last_fetched_at = $2, updated_at = $2
+UPDATE feeds SET last_fetched_at = $2, updated_at = $2,
+  consecutive_failures = 0, last_error = NULL, last_error_kind = NULL,
+  last_error_status = NULL, last_error_at = NULL, next_fetch_at = NULL,
+  refresh_requested_at = NULL
 WHERE id = $1
-RETURNING id, created_at, updated_at, name, url, last_fetched_at, user_id
+RETURNING id, created_at, updated_at, name, url, last_fetched_at, user_id, fetch_full_content, proxy_url, dead_at, consecutive_failures, last_error, last_error_kind, last_error_status, last_error_at, next_fetch_at, disabled_at, refresh_requested_at
 `
 
 type MarkFeedFetchedParams struct {
@@ -164,6 +347,131 @@ func (q *Queries) MarkFeedFetched(ctx context.Context, arg MarkFeedFetchedParams
 		&i.Url,
 		&i.LastFetchedAt,
 		&i.UserID,
+		&i.FetchFullContent,
+		&i.ProxyUrl,
+		&i.DeadAt,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.LastErrorKind,
+		&i.LastErrorStatus,
+		&i.LastErrorAt,
+		&i.NextFetchAt,
+		&i.DisabledAt,
+		&i.RefreshRequestedAt,
+	)
+	return i, err
+}
+
+const setFeedFetchFullContent = `-- name: SetFeedFetchFullContent :one
+UPDATE feeds SET fetch_full_content = $2, updated_at = $3
+WHERE id = $1
+RETURNING id, created_at, updated_at, name, url, last_fetched_at, user_id, fetch_full_content, proxy_url, dead_at, consecutive_failures, last_error, last_error_kind, last_error_status, last_error_at, next_fetch_at, disabled_at, refresh_requested_at
+`
+
+type SetFeedFetchFullContentParams struct {
+	ID               uuid.UUID
+	FetchFullContent bool
+	UpdatedAt        time.Time
+}
+
+func (q *Queries) SetFeedFetchFullContent(ctx context.Context, arg SetFeedFetchFullContentParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, setFeedFetchFullContent, arg.ID, arg.FetchFullContent, arg.UpdatedAt)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.LastFetchedAt,
+		&i.UserID,
+		&i.FetchFullContent,
+		&i.ProxyUrl,
+		&i.DeadAt,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.LastErrorKind,
+		&i.LastErrorStatus,
+		&i.LastErrorAt,
+		&i.NextFetchAt,
+		&i.DisabledAt,
+		&i.RefreshRequestedAt,
+	)
+	return i, err
+}
+
+const setFeedProxyUrl = `-- name: SetFeedProxyUrl :one
+UPDATE feeds SET proxy_url = $2, updated_at = $3
+WHERE id = $1
+RETURNING id, created_at, updated_at, name, url, last_fetched_at, user_id, fetch_full_content, proxy_url, dead_at, consecutive_failures, last_error, last_error_kind, last_error_status, last_error_at, next_fetch_at, disabled_at, refresh_requested_at
+`
+
+type SetFeedProxyUrlParams struct {
+	ID        uuid.UUID
+	ProxyUrl  sql.NullString
+	UpdatedAt time.Time
+}
+
+func (q *Queries) SetFeedProxyUrl(ctx context.Context, arg SetFeedProxyUrlParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, setFeedProxyUrl, arg.ID, arg.ProxyUrl, arg.UpdatedAt)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.LastFetchedAt,
+		&i.UserID,
+		&i.FetchFullContent,
+		&i.ProxyUrl,
+		&i.DeadAt,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.LastErrorKind,
+		&i.LastErrorStatus,
+		&i.LastErrorAt,
+		&i.NextFetchAt,
+		&i.DisabledAt,
+		&i.RefreshRequestedAt,
+	)
+	return i, err
+}
+
+const updateFeedUrl = `-- name: UpdateFeedUrl :one
+UPDATE feeds SET url = $2, updated_at = $3
+WHERE id = $1
+RETURNING id, created_at, updated_at, name, url, last_fetched_at, user_id, fetch_full_content, proxy_url, dead_at, consecutive_failures, last_error, last_error_kind, last_error_status, last_error_at, next_fetch_at, disabled_at, refresh_requested_at
+`
+
+type UpdateFeedUrlParams struct {
+	ID        uuid.UUID
+	Url       string
+	UpdatedAt time.Time
+}
+
+func (q *Queries) UpdateFeedUrl(ctx context.Context, arg UpdateFeedUrlParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, updateFeedUrl, arg.ID, arg.Url, arg.UpdatedAt)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.LastFetchedAt,
+		&i.UserID,
+		&i.FetchFullContent,
+		&i.ProxyUrl,
+		&i.DeadAt,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.LastErrorKind,
+		&i.LastErrorStatus,
+		&i.LastErrorAt,
+		&i.NextFetchAt,
+		&i.DisabledAt,
+		&i.RefreshRequestedAt,
 	)
 	return i, err
 }
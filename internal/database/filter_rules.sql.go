@@ -0,0 +1,270 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: filter_rules.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const createFilterRule = `-- name: CreateFilterRule :one
+INSERT INTO filter_rules (id, created_at, updated_at, user_id, feed_id, field, match, pattern, action, tag)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+RETURNING id, created_at, updated_at, user_id, feed_id, field, match, pattern, action, tag
+`
+
+type CreateFilterRuleParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	FeedID    uuid.NullUUID
+	Field     string
+	Match     string
+	Pattern   string
+	Action    string
+	Tag       sql.NullString
+}
+
+func (q *Queries) CreateFilterRule(ctx context.Context, arg CreateFilterRuleParams) (FilterRule, error) {
+	row := q.db.QueryRowContext(ctx, createFilterRule,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.UserID,
+		arg.FeedID,
+		arg.Field,
+		arg.Match,
+		arg.Pattern,
+		arg.Action,
+		arg.Tag,
+	)
+	var i FilterRule
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.FeedID,
+		&i.Field,
+		&i.Match,
+		&i.Pattern,
+		&i.Action,
+		&i.Tag,
+	)
+	return i, err
+}
+
+const deleteFilterRule = `-- name: DeleteFilterRule :exec
+DELETE FROM filter_rules
+WHERE id = $1
+`
+
+func (q *Queries) DeleteFilterRule(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteFilterRule, id)
+	return err
+}
+
+const getFilterRule = `-- name: GetFilterRule :one
+SELECT id, created_at, updated_at, user_id, feed_id, field, match, pattern, action, tag FROM filter_rules
+WHERE id = $1
+`
+
+func (q *Queries) GetFilterRule(ctx context.Context, id uuid.UUID) (FilterRule, error) {
+	row := q.db.QueryRowContext(ctx, getFilterRule, id)
+	var i FilterRule
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.FeedID,
+		&i.Field,
+		&i.Match,
+		&i.Pattern,
+		&i.Action,
+		&i.Tag,
+	)
+	return i, err
+}
+
+const listFeedFilterRules = `-- name: ListFeedFilterRules :many
+SELECT r.id, r.created_at, r.updated_at, r.user_id, r.feed_id, r.field, r.match, r.pattern, r.action, r.tag
+FROM filter_rules r
+  INNER JOIN feed_follows ff ON ff.user_id = r.user_id
+WHERE ff.feed_id = $1
+  AND (r.feed_id IS NULL OR r.feed_id = $1)
+ORDER BY r.created_at ASC
+`
+
+func (q *Queries) ListFeedFilterRules(ctx context.Context, feedID uuid.UUID) ([]FilterRule, error) {
+	rows, err := q.db.QueryContext(ctx, listFeedFilterRules, feedID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FilterRule
+	for rows.Next() {
+		var i FilterRule
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.FeedID,
+			&i.Field,
+			&i.Match,
+			&i.Pattern,
+			&i.Action,
+			&i.Tag,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPostStates = `-- name: ListPostStates :many
+SELECT user_id, post_id, created_at, updated_at, hidden, read, starred, tags FROM post_states
+WHERE user_id = $1 AND post_id = ANY($2::uuid[])
+`
+
+type ListPostStatesParams struct {
+	UserID  uuid.UUID
+	PostIds []uuid.UUID
+}
+
+func (q *Queries) ListPostStates(ctx context.Context, arg ListPostStatesParams) ([]PostState, error) {
+	rows, err := q.db.QueryContext(ctx, listPostStates, arg.UserID, pq.Array(arg.PostIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PostState
+	for rows.Next() {
+		var i PostState
+		if err := rows.Scan(
+			&i.UserID,
+			&i.PostID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Hidden,
+			&i.Read,
+			&i.Starred,
+			pq.Array(&i.Tags),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserFilterRules = `-- name: ListUserFilterRules :many
+SELECT id, created_at, updated_at, user_id, feed_id, field, match, pattern, action, tag FROM filter_rules
+WHERE user_id = $1
+ORDER BY created_at ASC
+`
+
+func (q *Queries) ListUserFilterRules(ctx context.Context, userID uuid.UUID) ([]FilterRule, error) {
+	rows, err := q.db.QueryContext(ctx, listUserFilterRules, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FilterRule
+	for rows.Next() {
+		var i FilterRule
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.FeedID,
+			&i.Field,
+			&i.Match,
+			&i.Pattern,
+			&i.Action,
+			&i.Tag,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const moveFilterRules = `-- name: MoveFilterRules :exec
+UPDATE filter_rules SET feed_id = $1::uuid
+WHERE feed_id = $2::uuid
+`
+
+type MoveFilterRulesParams struct {
+	ToFeedID   uuid.UUID
+	FromFeedID uuid.UUID
+}
+
+func (q *Queries) MoveFilterRules(ctx context.Context, arg MoveFilterRulesParams) error {
+	_, err := q.db.ExecContext(ctx, moveFilterRules, arg.ToFeedID, arg.FromFeedID)
+	return err
+}
+
+const upsertPostState = `-- name: UpsertPostState :exec
+INSERT INTO post_states (user_id, post_id, created_at, updated_at, hidden, read, starred, tags)
+VALUES ($1, $2, $3, $3, $4, $5, $6, $7)
+ON CONFLICT (user_id, post_id) DO UPDATE SET
+  updated_at = EXCLUDED.updated_at,
+  hidden = post_states.hidden OR EXCLUDED.hidden,
+  read = post_states.read OR EXCLUDED.read,
+  starred = post_states.starred OR EXCLUDED.starred,
+  tags = ARRAY(SELECT DISTINCT unnest(post_states.tags || EXCLUDED.tags) ORDER BY 1)
+`
+
+type UpsertPostStateParams struct {
+	UserID    uuid.UUID
+	PostID    uuid.UUID
+	CreatedAt time.Time
+	Hidden    bool
+	Read      bool
+	Starred   bool
+	Tags      []string
+}
+
+func (q *Queries) UpsertPostState(ctx context.Context, arg UpsertPostStateParams) error {
+	_, err := q.db.ExecContext(ctx, upsertPostState,
+		arg.UserID,
+		arg.PostID,
+		arg.CreatedAt,
+		arg.Hidden,
+		arg.Read,
+		arg.Starred,
+		pq.Array(arg.Tags),
+	)
+	return err
+}
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+// setupTestQueries returns queries running in a transaction rolled back at
+// the end of the test. The tests need a migrated database in
+// TEST_DATABASE_URL and are skipped without it.
+func setupTestQueries(t *testing.T) *Queries {
+	t.Helper()
+	dbURL := os.Getenv("TEST_DATABASE_URL")
+	if dbURL == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := sql.Open("postgres", dbURL)
+	require.NoError(t, err)
+	tx, err := db.BeginTx(context.Background(), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		tx.Rollback()
+		db.Close()
+	})
+	return New(tx)
+}
+
+// setupTestFollow creates a user following a new feed.
+func setupTestFollow(t *testing.T, q *Queries) (User, Feed) {
+	t.Helper()
+	ctx := context.Background()
+	now := time.Now()
+	user, err := q.CreateUser(ctx, CreateUserParams{
+		ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Name: "test",
+		ApiKeyHash: uuid.NewString(), ApiKeyPrefix: "test",
+	})
+	require.NoError(t, err)
+	feed, err := q.CreateFeed(ctx, CreateFeedParams{
+		ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Name: "test",
+		Url: "http://example.com/" + uuid.NewString(), UserID: uuid.NullUUID{UUID: user.ID, Valid: true},
+	})
+	require.NoError(t, err)
+	_, err = q.CreateFeedFollow(ctx, CreateFeedFollowParams{ID: uuid.New(), CreatedAt: now, UserID: user.ID, FeedID: feed.ID})
+	require.NoError(t, err)
+	return user, feed
+}
+
+func setupTestPost(t *testing.T, q *Queries, feedId uuid.UUID, title string) Post {
+	t.Helper()
+	now := time.Now()
+	post, err := q.CreatePost(context.Background(), CreatePostParams{
+		ID: uuid.New(), CreatedAt: now, UpdatedAt: now, PublishedAt: now, FeedID: feedId,
+		Url: "http://example.com/" + uuid.NewString(), Title: sql.NullString{String: title, Valid: true},
+	})
+	require.NoError(t, err)
+	return post
+}
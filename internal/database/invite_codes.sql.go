@@ -0,0 +1,147 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: invite_codes.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createInviteCode = `-- name: CreateInviteCode :one
+INSERT INTO invite_codes (id, created_at, created_by, code_hash, code_prefix, max_uses, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING id, created_at, created_by, code_hash, code_prefix, max_uses, uses, expires_at, revoked_at
+`
+
+type CreateInviteCodeParams struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	CreatedBy  uuid.NullUUID
+	CodeHash   string
+	CodePrefix string
+	MaxUses    int32
+	ExpiresAt  sql.NullTime
+}
+
+func (q *Queries) CreateInviteCode(ctx context.Context, arg CreateInviteCodeParams) (InviteCode, error) {
+	row := q.db.QueryRowContext(ctx, createInviteCode,
+		arg.ID,
+		arg.CreatedAt,
+		arg.CreatedBy,
+		arg.CodeHash,
+		arg.CodePrefix,
+		arg.MaxUses,
+		arg.ExpiresAt,
+	)
+	var i InviteCode
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.CreatedBy,
+		&i.CodeHash,
+		&i.CodePrefix,
+		&i.MaxUses,
+		&i.Uses,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
+
+const listInviteCodes = `-- name: ListInviteCodes :many
+SELECT id, created_at, created_by, code_hash, code_prefix, max_uses, uses, expires_at, revoked_at FROM invite_codes
+ORDER BY created_at DESC
+`
+
+func (q *Queries) ListInviteCodes(ctx context.Context) ([]InviteCode, error) {
+	rows, err := q.db.QueryContext(ctx, listInviteCodes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []InviteCode
+	for rows.Next() {
+		var i InviteCode
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.CreatedBy,
+			&i.CodeHash,
+			&i.CodePrefix,
+			&i.MaxUses,
+			&i.Uses,
+			&i.ExpiresAt,
+			&i.RevokedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const releaseInviteCode = `-- name: ReleaseInviteCode :exec
+UPDATE invite_codes SET uses = uses - 1
+WHERE id = $1 AND uses > 0
+`
+
+func (q *Queries) ReleaseInviteCode(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, releaseInviteCode, id)
+	return err
+}
+
+const revokeInviteCode = `-- name: RevokeInviteCode :execrows
+UPDATE invite_codes SET revoked_at = $2
+WHERE id = $1 AND revoked_at IS NULL
+`
+
+type RevokeInviteCodeParams struct {
+	ID        uuid.UUID
+	RevokedAt sql.NullTime
+}
+
+func (q *Queries) RevokeInviteCode(ctx context.Context, arg RevokeInviteCodeParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeInviteCode, arg.ID, arg.RevokedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const useInviteCode = `-- name: UseInviteCode :one
+UPDATE invite_codes SET uses = uses + 1
+WHERE code_hash = $1
+  AND revoked_at IS NULL
+  AND (expires_at IS NULL OR expires_at > now())
+  AND uses < max_uses
+RETURNING id, created_at, created_by, code_hash, code_prefix, max_uses, uses, expires_at, revoked_at
+`
+
+func (q *Queries) UseInviteCode(ctx context.Context, codeHash string) (InviteCode, error) {
+	row := q.db.QueryRowContext(ctx, useInviteCode, codeHash)
+	var i InviteCode
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.CreatedBy,
+		&i.CodeHash,
+		&i.CodePrefix,
+		&i.MaxUses,
+		&i.Uses,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
@@ -6,19 +6,99 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type ApiKey struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UserID     uuid.UUID
+	Label      string
+	Scopes     []string
+	ExpiresAt  sql.NullTime
+	LastUsedAt sql.NullTime
+	RevokedAt  sql.NullTime
+	KeyHash    string
+	KeyPrefix  string
+}
+
+type AuditEvent struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UserID     uuid.UUID
+	Action     string
+	RemoteAddr sql.NullString
+	Details    json.RawMessage
+}
+
+type DeletedPostUrl struct {
+	Url       string
+	DeletedAt time.Time
+}
+
+type DigestRun struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UserID       uuid.UUID
+	PeriodStart  time.Time
+	ScheduledFor time.Time
+	Status       string
+	Posts        int32
+	FinishedAt   sql.NullTime
+	Error        sql.NullString
+}
+
+type DigestSubscription struct {
+	UserID             uuid.UUID
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+	Email              string
+	Frequency          string
+	SendHour           int32
+	Weekday            int32
+	Timezone           string
+	UnsubscribeToken   string
+	ConfirmationToken  string
+	ConfirmationSentAt sql.NullTime
+	ConfirmedAt        sql.NullTime
+}
+
 type Feed struct {
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Name                string
+	Url                 string
+	LastFetchedAt       sql.NullTime
+	UserID              uuid.NullUUID
+	FetchFullContent    bool
+	ProxyUrl            sql.NullString
+	DeadAt              sql.NullTime
+	ConsecutiveFailures int32
+	LastError           sql.NullString
+	LastErrorKind       sql.NullString
+	LastErrorStatus     sql.NullInt32
+	LastErrorAt         sql.NullTime
+	NextFetchAt         sql.NullTime
+	DisabledAt          sql.NullTime
+	RefreshRequestedAt  sql.NullTime
+}
+
+type FeedFetch struct {
 	ID            uuid.UUID
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	Name          string
-	Url           string
-	LastFetchedAt sql.NullTime
-	UserID        uuid.UUID
+	FeedID        uuid.UUID
+	StartedAt     time.Time
+	FinishedAt    time.Time
+	DurationMs    int32
+	StatusCode    sql.NullInt32
+	Bytes         int64
+	ItemsSeen     int32
+	PostsInserted int32
+	PostsUpdated  int32
+	Error         sql.NullString
 }
 
 type FeedFollow struct {
@@ -28,21 +108,172 @@ type FeedFollow struct {
 	FeedID    uuid.UUID
 }
 
+type FeedUrlHistory struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	FeedID     uuid.UUID
+	OldUrl     string
+	NewUrl     sql.NullString
+	StatusCode int32
+}
+
+type FilterRule struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	FeedID    uuid.NullUUID
+	Field     string
+	Match     string
+	Pattern   string
+	Action    string
+	Tag       sql.NullString
+}
+
+type InviteCode struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	CreatedBy  uuid.NullUUID
+	CodeHash   string
+	CodePrefix string
+	MaxUses    int32
+	Uses       int32
+	ExpiresAt  sql.NullTime
+	RevokedAt  sql.NullTime
+}
+
 type Post struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Url         string
-	Title       sql.NullString
-	Description sql.NullString
-	PublishedAt time.Time
-	FeedID      uuid.UUID
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Url             string
+	Title           sql.NullString
+	Description     sql.NullString
+	PublishedAt     time.Time
+	FeedID          uuid.UUID
+	DescriptionText sql.NullString
+	Content         sql.NullString
+	Seq             int64
+	Author          sql.NullString
+	Categories      []string
 }
 
-type User struct {
+type PostEpisode struct {
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	PostID          uuid.UUID
+	EnclosureUrl    string
+	EnclosureType   string
+	EnclosureLength sql.NullInt64
+	DurationSeconds sql.NullInt32
+	ImageUrl        sql.NullString
+	Episode         sql.NullInt32
+	Season          sql.NullInt32
+	Explicit        sql.NullBool
+}
+
+type PostState struct {
+	UserID    uuid.UUID
+	PostID    uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Hidden    bool
+	Read      bool
+	Starred   bool
+	Tags      []string
+}
+
+type PublishedFeed struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	UserID        uuid.UUID
+	Kind          string
+	Token         string
+	SavedSearchID uuid.NullUUID
+}
+
+type RateLimitBucket struct {
+	Key       string
+	Tokens    float64
+	Allowed   bool
+	UpdatedAt time.Time
+}
+
+type SavedSearch struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	UserID    uuid.UUID
 	Name      string
-	ApiKey    string
+	Keywords  []string
+	FeedIds   []uuid.UUID
+	Since     sql.NullTime
+	Until     sql.NullTime
+}
+
+type Session struct {
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UserID           uuid.UUID
+	AccessTokenHash  string
+	AccessExpiresAt  time.Time
+	RefreshTokenHash string
+	RefreshExpiresAt time.Time
+	RefreshedAt      sql.NullTime
+	RevokedAt        sql.NullTime
+}
+
+type User struct {
+	ID                      uuid.UUID
+	CreatedAt               time.Time
+	UpdatedAt               time.Time
+	Name                    string
+	ApiKeyHash              string
+	ApiKeyPrefix            string
+	PreviousApiKeyHash      sql.NullString
+	PreviousApiKeyExpiresAt sql.NullTime
+	Email                   sql.NullString
+	PasswordHash            sql.NullString
+	IsAdmin                 bool
+	DisabledAt              sql.NullTime
+	InviteCodeID            uuid.NullUUID
+	Preferences             json.RawMessage
+}
+
+type Webhook struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Url       string
+	Secret    string
+	FeedIds   []uuid.UUID
+	Keywords  []string
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	WebhookID      uuid.UUID
+	PostID         uuid.NullUUID
+	Event          string
+	Status         string
+	Attempts       int32
+	NextAttemptAt  sql.NullTime
+	LastAttemptAt  sql.NullTime
+	ResponseStatus sql.NullInt32
+	Error          sql.NullString
+}
+
+type WebsubSubscription struct {
+	FeedID         uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	HubUrl         string
+	Topic          string
+	Secret         string
+	RequestedAt    time.Time
+	VerifiedAt     sql.NullTime
+	LeaseExpiresAt sql.NullTime
+	CallbackToken  string
 }
@@ -0,0 +1,140 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: post_episodes.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const createPostEpisode = `-- name: CreatePostEpisode :one
+INSERT INTO post_episodes (id, created_at, post_id, enclosure_url, enclosure_type, enclosure_length, duration_seconds, image_url, episode, season, explicit)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+RETURNING id, created_at, post_id, enclosure_url, enclosure_type, enclosure_length, duration_seconds, image_url, episode, season, explicit
+`
+
+type CreatePostEpisodeParams struct {
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	PostID          uuid.UUID
+	EnclosureUrl    string
+	EnclosureType   string
+	EnclosureLength sql.NullInt64
+	DurationSeconds sql.NullInt32
+	ImageUrl        sql.NullString
+	Episode         sql.NullInt32
+	Season          sql.NullInt32
+	Explicit        sql.NullBool
+}
+
+func (q *Queries) CreatePostEpisode(ctx context.Context, arg CreatePostEpisodeParams) (PostEpisode, error) {
+	row := q.db.QueryRowContext(ctx, createPostEpisode,
+		arg.ID,
+		arg.CreatedAt,
+		arg.PostID,
+		arg.EnclosureUrl,
+		arg.EnclosureType,
+		arg.EnclosureLength,
+		arg.DurationSeconds,
+		arg.ImageUrl,
+		arg.Episode,
+		arg.Season,
+		arg.Explicit,
+	)
+	var i PostEpisode
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.PostID,
+		&i.EnclosureUrl,
+		&i.EnclosureType,
+		&i.EnclosureLength,
+		&i.DurationSeconds,
+		&i.ImageUrl,
+		&i.Episode,
+		&i.Season,
+		&i.Explicit,
+	)
+	return i, err
+}
+
+const getUserPostsWithEnclosure = `-- name: GetUserPostsWithEnclosure :many
+SELECT p.id, p.created_at, p.updated_at, p.url, p.title, p.description, p.published_at, p.feed_id, p.description_text, p.content, p.seq, p.author, p.categories, e.id, e.created_at, e.post_id, e.enclosure_url, e.enclosure_type, e.enclosure_length, e.duration_seconds, e.image_url, e.episode, e.season, e.explicit
+FROM posts p
+  INNER JOIN feeds f ON p.feed_id = f.id
+  INNER JOIN post_episodes e ON e.post_id = p.id
+WHERE NOT EXISTS (
+    SELECT 1 FROM post_states ps
+    WHERE ps.post_id = p.id AND ps.user_id = $1 AND ps.hidden
+  )
+  AND f.user_id = $1
+  AND e.enclosure_type LIKE $3::text || '/%'
+ORDER BY p.published_at DESC
+LIMIT $2
+`
+
+type GetUserPostsWithEnclosureParams struct {
+	UserID    uuid.UUID
+	Limit     int32
+	MediaType string
+}
+
+type GetUserPostsWithEnclosureRow struct {
+	Post        Post
+	PostEpisode PostEpisode
+}
+
+func (q *Queries) GetUserPostsWithEnclosure(ctx context.Context, arg GetUserPostsWithEnclosureParams) ([]GetUserPostsWithEnclosureRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUserPostsWithEnclosure, arg.UserID, arg.Limit, arg.MediaType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUserPostsWithEnclosureRow
+	for rows.Next() {
+		var i GetUserPostsWithEnclosureRow
+		if err := rows.Scan(
+			&i.Post.ID,
+			&i.Post.CreatedAt,
+			&i.Post.UpdatedAt,
+			&i.Post.Url,
+			&i.Post.Title,
+			&i.Post.Description,
+			&i.Post.PublishedAt,
+			&i.Post.FeedID,
+			&i.Post.DescriptionText,
+			&i.Post.Content,
+			&i.Post.Seq,
+			&i.Post.Author,
+			pq.Array(&i.Post.Categories),
+			&i.PostEpisode.ID,
+			&i.PostEpisode.CreatedAt,
+			&i.PostEpisode.PostID,
+			&i.PostEpisode.EnclosureUrl,
+			&i.PostEpisode.EnclosureType,
+			&i.PostEpisode.EnclosureLength,
+			&i.PostEpisode.DurationSeconds,
+			&i.PostEpisode.ImageUrl,
+			&i.PostEpisode.Episode,
+			&i.PostEpisode.Season,
+			&i.PostEpisode.Explicit,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -11,23 +11,28 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createPost = `-- name: CreatePost :one
-INSERT INTO posts (id, created_at, updated_at, url, title, description, published_at, feed_id)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-RETURNING id, created_at, updated_at, url, title, description, published_at, feed_id
+INSERT INTO posts (id, created_at, updated_at, url, title, description, published_at, feed_id, description_text, author, categories)
+SELECT $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
+WHERE NOT EXISTS (SELECT 1 FROM deleted_post_urls d WHERE d.url = $4)
+RETURNING id, created_at, updated_at, url, title, description, published_at, feed_id, description_text, content, seq, author, categories
 `
 
 type CreatePostParams struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Url         string
-	Title       sql.NullString
-	Description sql.NullString
-	PublishedAt time.Time
-	FeedID      uuid.UUID
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Url             string
+	Title           sql.NullString
+	Description     sql.NullString
+	PublishedAt     time.Time
+	FeedID          uuid.UUID
+	DescriptionText sql.NullString
+	Author          sql.NullString
+	Categories      []string
 }
 
 func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
@@ -40,6 +45,9 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		arg.Description,
 		arg.PublishedAt,
 		arg.FeedID,
+		arg.DescriptionText,
+		arg.Author,
+		pq.Array(arg.Categories),
 	)
 	var i Post
 	err := row.Scan(
@@ -51,15 +59,251 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		&i.Description,
 		&i.PublishedAt,
 		&i.FeedID,
+		&i.DescriptionText,
+		&i.Content,
+		&i.Seq,
+		&i.Author,
+		pq.Array(&i.Categories),
 	)
 	return i, err
 }
 
+const getFollowedPosts = `-- name: GetFollowedPosts :many
+SELECT p.id, p.created_at, p.updated_at, p.url, p.title, p.description, p.published_at, p.feed_id, p.description_text, p.content, p.seq, p.author, p.categories
+FROM posts p
+  INNER JOIN feed_follows ff ON p.feed_id = ff.feed_id
+WHERE ff.user_id = $1
+  AND NOT EXISTS (
+    SELECT 1 FROM post_states ps
+    WHERE ps.post_id = p.id AND ps.user_id = $1 AND ps.hidden
+  )
+ORDER BY p.published_at DESC
+LIMIT $2
+`
+
+type GetFollowedPostsParams struct {
+	UserID uuid.UUID
+	Limit  int32
+}
+
+func (q *Queries) GetFollowedPosts(ctx context.Context, arg GetFollowedPostsParams) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, getFollowedPosts, arg.UserID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Post
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Url,
+			&i.Title,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.DescriptionText,
+			&i.Content,
+			&i.Seq,
+			&i.Author,
+			pq.Array(&i.Categories),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFollowedPostsAfter = `-- name: GetFollowedPostsAfter :many
+SELECT p.id, p.created_at, p.updated_at, p.url, p.title, p.description, p.published_at, p.feed_id, p.description_text, p.content, p.seq, p.author, p.categories
+FROM posts p
+  INNER JOIN feed_follows ff ON p.feed_id = ff.feed_id
+WHERE ff.user_id = $1 AND p.seq > $2
+  AND NOT EXISTS (
+    SELECT 1 FROM post_states ps
+    WHERE ps.post_id = p.id AND ps.user_id = $1 AND ps.hidden
+  )
+ORDER BY p.seq ASC
+LIMIT $3
+`
+
+type GetFollowedPostsAfterParams struct {
+	UserID uuid.UUID
+	Seq    int64
+	Limit  int32
+}
+
+func (q *Queries) GetFollowedPostsAfter(ctx context.Context, arg GetFollowedPostsAfterParams) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, getFollowedPostsAfter, arg.UserID, arg.Seq, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Post
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Url,
+			&i.Title,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.DescriptionText,
+			&i.Content,
+			&i.Seq,
+			&i.Author,
+			pq.Array(&i.Categories),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFollowedPostsWithFeedAfter = `-- name: GetFollowedPostsWithFeedAfter :many
+SELECT p.id, p.created_at, p.updated_at, p.url, p.title, p.description, p.published_at, p.feed_id, p.description_text, p.content, p.seq, p.author, p.categories, f.name AS feed_name
+FROM posts p
+  INNER JOIN feed_follows ff ON p.feed_id = ff.feed_id
+  INNER JOIN feeds f ON p.feed_id = f.id
+WHERE ff.user_id = $1 AND p.seq > $2
+ORDER BY p.seq ASC
+LIMIT $3
+`
+
+type GetFollowedPostsWithFeedAfterParams struct {
+	UserID uuid.UUID
+	Seq    int64
+	Limit  int32
+}
+
+type GetFollowedPostsWithFeedAfterRow struct {
+	Post     Post
+	FeedName string
+}
+
+func (q *Queries) GetFollowedPostsWithFeedAfter(ctx context.Context, arg GetFollowedPostsWithFeedAfterParams) ([]GetFollowedPostsWithFeedAfterRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFollowedPostsWithFeedAfter, arg.UserID, arg.Seq, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetFollowedPostsWithFeedAfterRow
+	for rows.Next() {
+		var i GetFollowedPostsWithFeedAfterRow
+		if err := rows.Scan(
+			&i.Post.ID,
+			&i.Post.CreatedAt,
+			&i.Post.UpdatedAt,
+			&i.Post.Url,
+			&i.Post.Title,
+			&i.Post.Description,
+			&i.Post.PublishedAt,
+			&i.Post.FeedID,
+			&i.Post.DescriptionText,
+			&i.Post.Content,
+			&i.Post.Seq,
+			&i.Post.Author,
+			pq.Array(&i.Post.Categories),
+			&i.FeedName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPost = `-- name: GetPost :one
+SELECT id, created_at, updated_at, url, title, description, published_at, feed_id, description_text, content, seq, author, categories FROM posts
+WHERE id = $1
+`
+
+func (q *Queries) GetPost(ctx context.Context, id uuid.UUID) (Post, error) {
+	row := q.db.QueryRowContext(ctx, getPost, id)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Url,
+		&i.Title,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+		&i.DescriptionText,
+		&i.Content,
+		&i.Seq,
+		&i.Author,
+		pq.Array(&i.Categories),
+	)
+	return i, err
+}
+
+const getStreamResumeSeq = `-- name: GetStreamResumeSeq :one
+SELECT COALESCE((
+  SELECT p.seq FROM posts p
+  WHERE p.seq <= $1::bigint
+    AND p.created_at < (
+      SELECT l.created_at FROM posts l
+      WHERE l.seq <= $1::bigint
+      ORDER BY l.seq DESC
+      LIMIT 1
+    ) - make_interval(secs => $2::float8)
+  ORDER BY p.seq DESC
+  LIMIT 1
+), 0)::bigint AS seq
+`
+
+type GetStreamResumeSeqParams struct {
+	Seq            int64
+	OverlapSeconds float64
+}
+
+// seq is taken when a post is inserted, but the worker transactions commit
+// out of order: a post may become visible after one with a higher seq was
+// streamed. Streams resume after the last post created overlap_seconds
+// before the last streamed one, replaying the posts in between.
+func (q *Queries) GetStreamResumeSeq(ctx context.Context, arg GetStreamResumeSeqParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getStreamResumeSeq, arg.Seq, arg.OverlapSeconds)
+	var seq int64
+	err := row.Scan(&seq)
+	return seq, err
+}
+
 const getUserPosts = `-- name: GetUserPosts :many
-SELECT p.id, p.created_at, p.updated_at, p.url, p.title, p.description, p.published_at, p.feed_id
+SELECT p.id, p.created_at, p.updated_at, p.url, p.title, p.description, p.published_at, p.feed_id, p.description_text, p.content, p.seq, p.author, p.categories
 FROM posts p
   INNER JOIN feeds f ON p.feed_id = f.id
-WHERE f.user_id = $1
+WHERE NOT EXISTS (
+    SELECT 1 FROM post_states ps
+    WHERE ps.post_id = p.id AND ps.user_id = $1 AND ps.hidden
+  )
+  AND f.user_id = $1
 ORDER BY p.published_at DESC
 LIMIT $2
 `
@@ -87,6 +331,11 @@ func (q *Queries) GetUserPosts(ctx context.Context, arg GetUserPostsParams) ([]P
 			&i.Description,
 			&i.PublishedAt,
 			&i.FeedID,
+			&i.DescriptionText,
+			&i.Content,
+			&i.Seq,
+			&i.Author,
+			pq.Array(&i.Categories),
 		); err != nil {
 			return nil, err
 		}
@@ -100,3 +349,69 @@ func (q *Queries) GetUserPosts(ctx context.Context, arg GetUserPostsParams) ([]P
 	}
 	return items, nil
 }
+
+const getVisiblePost = `-- name: GetVisiblePost :one
+SELECT p.id, p.created_at, p.updated_at, p.url, p.title, p.description, p.published_at, p.feed_id, p.description_text, p.content, p.seq, p.author, p.categories FROM posts p
+WHERE p.id = $1
+  AND NOT EXISTS (
+    SELECT 1 FROM post_states ps
+    WHERE ps.post_id = p.id AND ps.user_id = $2 AND ps.hidden
+  )
+`
+
+type GetVisiblePostParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) GetVisiblePost(ctx context.Context, arg GetVisiblePostParams) (Post, error) {
+	row := q.db.QueryRowContext(ctx, getVisiblePost, arg.ID, arg.UserID)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Url,
+		&i.Title,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+		&i.DescriptionText,
+		&i.Content,
+		&i.Seq,
+		&i.Author,
+		pq.Array(&i.Categories),
+	)
+	return i, err
+}
+
+const movePosts = `-- name: MovePosts :exec
+UPDATE posts SET feed_id = $1
+WHERE feed_id = $2
+`
+
+type MovePostsParams struct {
+	ToFeedID   uuid.UUID
+	FromFeedID uuid.UUID
+}
+
+func (q *Queries) MovePosts(ctx context.Context, arg MovePostsParams) error {
+	_, err := q.db.ExecContext(ctx, movePosts, arg.ToFeedID, arg.FromFeedID)
+	return err
+}
+
+const updatePostContent = `-- name: UpdatePostContent :exec
+UPDATE posts SET content = $2, updated_at = $3
+WHERE id = $1
+`
+
+type UpdatePostContentParams struct {
+	ID        uuid.UUID
+	Content   sql.NullString
+	UpdatedAt time.Time
+}
+
+func (q *Queries) UpdatePostContent(ctx context.Context, arg UpdatePostContentParams) error {
+	_, err := q.db.ExecContext(ctx, updatePostContent, arg.ID, arg.Content, arg.UpdatedAt)
+	return err
+}
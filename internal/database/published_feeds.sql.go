@@ -0,0 +1,133 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: published_feeds.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createPublishedFeed = `-- name: CreatePublishedFeed :one
+INSERT INTO published_feeds (id, created_at, user_id, kind, token, saved_search_id)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, created_at, user_id, kind, token, saved_search_id
+`
+
+type CreatePublishedFeedParams struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	UserID        uuid.UUID
+	Kind          string
+	Token         string
+	SavedSearchID uuid.NullUUID
+}
+
+func (q *Queries) CreatePublishedFeed(ctx context.Context, arg CreatePublishedFeedParams) (PublishedFeed, error) {
+	row := q.db.QueryRowContext(ctx, createPublishedFeed,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UserID,
+		arg.Kind,
+		arg.Token,
+		arg.SavedSearchID,
+	)
+	var i PublishedFeed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.Kind,
+		&i.Token,
+		&i.SavedSearchID,
+	)
+	return i, err
+}
+
+const deletePublishedFeed = `-- name: DeletePublishedFeed :exec
+DELETE FROM published_feeds
+WHERE id = $1
+`
+
+func (q *Queries) DeletePublishedFeed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deletePublishedFeed, id)
+	return err
+}
+
+const getPublishedFeed = `-- name: GetPublishedFeed :one
+SELECT id, created_at, user_id, kind, token, saved_search_id FROM published_feeds
+WHERE id = $1
+`
+
+func (q *Queries) GetPublishedFeed(ctx context.Context, id uuid.UUID) (PublishedFeed, error) {
+	row := q.db.QueryRowContext(ctx, getPublishedFeed, id)
+	var i PublishedFeed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.Kind,
+		&i.Token,
+		&i.SavedSearchID,
+	)
+	return i, err
+}
+
+const getPublishedFeedByToken = `-- name: GetPublishedFeedByToken :one
+SELECT id, created_at, user_id, kind, token, saved_search_id FROM published_feeds
+WHERE token = $1
+`
+
+func (q *Queries) GetPublishedFeedByToken(ctx context.Context, token string) (PublishedFeed, error) {
+	row := q.db.QueryRowContext(ctx, getPublishedFeedByToken, token)
+	var i PublishedFeed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.Kind,
+		&i.Token,
+		&i.SavedSearchID,
+	)
+	return i, err
+}
+
+const listUserPublishedFeeds = `-- name: ListUserPublishedFeeds :many
+SELECT id, created_at, user_id, kind, token, saved_search_id FROM published_feeds
+WHERE user_id = $1
+ORDER BY created_at ASC
+`
+
+func (q *Queries) ListUserPublishedFeeds(ctx context.Context, userID uuid.UUID) ([]PublishedFeed, error) {
+	rows, err := q.db.QueryContext(ctx, listUserPublishedFeeds, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PublishedFeed
+	for rows.Next() {
+		var i PublishedFeed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UserID,
+			&i.Kind,
+			&i.Token,
+			&i.SavedSearchID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
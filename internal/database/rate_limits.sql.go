@@ -0,0 +1,58 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: rate_limits.sql
+
+package database
+
+import (
+	"context"
+)
+
+const deleteIdleRateLimitBuckets = `-- name: DeleteIdleRateLimitBuckets :execrows
+DELETE FROM rate_limit_buckets
+WHERE updated_at < now() - make_interval(secs => $1::float8)
+`
+
+func (q *Queries) DeleteIdleRateLimitBuckets(ctx context.Context, idleSeconds float64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteIdleRateLimitBuckets, idleSeconds)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const takeRateLimitToken = `-- name: TakeRateLimitToken :one
+INSERT INTO rate_limit_buckets AS b (key, tokens, allowed, updated_at)
+VALUES ($1, $2::float8 - 1, true, now())
+ON CONFLICT (key) DO UPDATE SET
+  tokens = CASE
+    WHEN LEAST($2::float8, b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at)::float8 * $3::float8) >= 1
+    THEN LEAST($2::float8, b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at)::float8 * $3::float8) - 1
+    ELSE LEAST($2::float8, b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at)::float8 * $3::float8)
+  END,
+  allowed = LEAST($2::float8, b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at)::float8 * $3::float8) >= 1,
+  updated_at = now()
+RETURNING tokens, allowed
+`
+
+type TakeRateLimitTokenParams struct {
+	Key      string
+	Capacity float64
+	Rate     float64
+}
+
+type TakeRateLimitTokenRow struct {
+	Tokens  float64
+	Allowed bool
+}
+
+// Refills the bucket of key since its last update, at rate tokens per
+// second up to capacity, then takes a token from it when there is one. The
+// database clock is used so that the replicas agree on the elapsed time.
+func (q *Queries) TakeRateLimitToken(ctx context.Context, arg TakeRateLimitTokenParams) (TakeRateLimitTokenRow, error) {
+	row := q.db.QueryRowContext(ctx, takeRateLimitToken, arg.Key, arg.Capacity, arg.Rate)
+	var i TakeRateLimitTokenRow
+	err := row.Scan(&i.Tokens, &i.Allowed)
+	return i, err
+}
@@ -0,0 +1,236 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: saved_searches.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const createSavedSearch = `-- name: CreateSavedSearch :one
+INSERT INTO saved_searches (id, created_at, updated_at, user_id, name, keywords, feed_ids, since, until)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING id, created_at, updated_at, user_id, name, keywords, feed_ids, since, until
+`
+
+type CreateSavedSearchParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Name      string
+	Keywords  []string
+	FeedIds   []uuid.UUID
+	Since     sql.NullTime
+	Until     sql.NullTime
+}
+
+func (q *Queries) CreateSavedSearch(ctx context.Context, arg CreateSavedSearchParams) (SavedSearch, error) {
+	row := q.db.QueryRowContext(ctx, createSavedSearch,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.UserID,
+		arg.Name,
+		pq.Array(arg.Keywords),
+		pq.Array(arg.FeedIds),
+		arg.Since,
+		arg.Until,
+	)
+	var i SavedSearch
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Name,
+		pq.Array(&i.Keywords),
+		pq.Array(&i.FeedIds),
+		&i.Since,
+		&i.Until,
+	)
+	return i, err
+}
+
+const deleteSavedSearch = `-- name: DeleteSavedSearch :exec
+DELETE FROM saved_searches
+WHERE id = $1
+`
+
+func (q *Queries) DeleteSavedSearch(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteSavedSearch, id)
+	return err
+}
+
+const getSavedSearch = `-- name: GetSavedSearch :one
+SELECT id, created_at, updated_at, user_id, name, keywords, feed_ids, since, until FROM saved_searches
+WHERE id = $1
+`
+
+func (q *Queries) GetSavedSearch(ctx context.Context, id uuid.UUID) (SavedSearch, error) {
+	row := q.db.QueryRowContext(ctx, getSavedSearch, id)
+	var i SavedSearch
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Name,
+		pq.Array(&i.Keywords),
+		pq.Array(&i.FeedIds),
+		&i.Since,
+		&i.Until,
+	)
+	return i, err
+}
+
+const getSavedSearchPosts = `-- name: GetSavedSearchPosts :many
+SELECT p.id, p.created_at, p.updated_at, p.url, p.title, p.description, p.published_at, p.feed_id, p.description_text, p.content, p.seq, p.author, p.categories
+FROM saved_searches s
+  INNER JOIN feed_follows ff ON ff.user_id = s.user_id
+  INNER JOIN posts p ON p.feed_id = ff.feed_id
+WHERE s.id = $1
+  AND (cardinality(s.feed_ids) = 0 OR p.feed_id = ANY(s.feed_ids))
+  AND (s.since IS NULL OR p.published_at >= s.since)
+  AND (s.until IS NULL OR p.published_at < s.until)
+  AND NOT EXISTS (
+    SELECT 1 FROM unnest(s.keywords) k
+    WHERE position(lower(k) in lower(concat_ws(' ', p.title, p.description_text))) = 0
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM post_states ps
+    WHERE ps.post_id = p.id AND ps.user_id = s.user_id AND ps.hidden
+  )
+ORDER BY p.published_at DESC
+LIMIT $2
+`
+
+type GetSavedSearchPostsParams struct {
+	ID    uuid.UUID
+	Limit int32
+}
+
+func (q *Queries) GetSavedSearchPosts(ctx context.Context, arg GetSavedSearchPostsParams) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, getSavedSearchPosts, arg.ID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Post
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Url,
+			&i.Title,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.DescriptionText,
+			&i.Content,
+			&i.Seq,
+			&i.Author,
+			pq.Array(&i.Categories),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserSavedSearches = `-- name: ListUserSavedSearches :many
+SELECT s.id, s.created_at, s.updated_at, s.user_id, s.name, s.keywords, s.feed_ids, s.since, s.until, (
+  SELECT count(*)
+  FROM posts p
+    INNER JOIN feed_follows ff ON p.feed_id = ff.feed_id
+  WHERE ff.user_id = s.user_id
+    AND (cardinality(s.feed_ids) = 0 OR p.feed_id = ANY(s.feed_ids))
+    AND (s.since IS NULL OR p.published_at >= s.since)
+    AND (s.until IS NULL OR p.published_at < s.until)
+    AND NOT EXISTS (
+      SELECT 1 FROM unnest(s.keywords) k
+      -- position, unlike ILIKE, gives no meaning to % and _ in keywords
+      WHERE position(lower(k) in lower(concat_ws(' ', p.title, p.description_text))) = 0
+    )
+    AND NOT EXISTS (
+      SELECT 1 FROM post_states ps
+      WHERE ps.post_id = p.id AND ps.user_id = s.user_id AND (ps.hidden OR ps.read)
+    )
+) AS unread
+FROM saved_searches s
+WHERE s.user_id = $1
+ORDER BY s.name ASC
+`
+
+type ListUserSavedSearchesRow struct {
+	SavedSearch SavedSearch
+	Unread      int64
+}
+
+func (q *Queries) ListUserSavedSearches(ctx context.Context, userID uuid.UUID) ([]ListUserSavedSearchesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listUserSavedSearches, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUserSavedSearchesRow
+	for rows.Next() {
+		var i ListUserSavedSearchesRow
+		if err := rows.Scan(
+			&i.SavedSearch.ID,
+			&i.SavedSearch.CreatedAt,
+			&i.SavedSearch.UpdatedAt,
+			&i.SavedSearch.UserID,
+			&i.SavedSearch.Name,
+			pq.Array(&i.SavedSearch.Keywords),
+			pq.Array(&i.SavedSearch.FeedIds),
+			&i.SavedSearch.Since,
+			&i.SavedSearch.Until,
+			&i.Unread,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const moveSavedSearchFeeds = `-- name: MoveSavedSearchFeeds :exec
+UPDATE saved_searches SET feed_ids = CASE
+    WHEN $1::uuid = ANY(feed_ids) THEN array_remove(feed_ids, $2::uuid)
+    ELSE array_replace(feed_ids, $2::uuid, $1::uuid)
+  END
+WHERE $2::uuid = ANY(feed_ids)
+`
+
+type MoveSavedSearchFeedsParams struct {
+	ToFeedID   uuid.UUID
+	FromFeedID uuid.UUID
+}
+
+func (q *Queries) MoveSavedSearchFeeds(ctx context.Context, arg MoveSavedSearchFeedsParams) error {
+	_, err := q.db.ExecContext(ctx, moveSavedSearchFeeds, arg.ToFeedID, arg.FromFeedID)
+	return err
+}
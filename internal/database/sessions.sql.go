@@ -0,0 +1,249 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: sessions.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createSession = `-- name: CreateSession :one
+INSERT INTO sessions (id, created_at, user_id, access_token_hash, access_expires_at, refresh_token_hash, refresh_expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING id, created_at, user_id, access_token_hash, access_expires_at, refresh_token_hash, refresh_expires_at, refreshed_at, revoked_at
+`
+
+type CreateSessionParams struct {
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UserID           uuid.UUID
+	AccessTokenHash  string
+	AccessExpiresAt  time.Time
+	RefreshTokenHash string
+	RefreshExpiresAt time.Time
+}
+
+func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error) {
+	row := q.db.QueryRowContext(ctx, createSession,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UserID,
+		arg.AccessTokenHash,
+		arg.AccessExpiresAt,
+		arg.RefreshTokenHash,
+		arg.RefreshExpiresAt,
+	)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.AccessTokenHash,
+		&i.AccessExpiresAt,
+		&i.RefreshTokenHash,
+		&i.RefreshExpiresAt,
+		&i.RefreshedAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
+
+const getSession = `-- name: GetSession :one
+SELECT id, created_at, user_id, access_token_hash, access_expires_at, refresh_token_hash, refresh_expires_at, refreshed_at, revoked_at FROM sessions
+WHERE id = $1
+`
+
+func (q *Queries) GetSession(ctx context.Context, id uuid.UUID) (Session, error) {
+	row := q.db.QueryRowContext(ctx, getSession, id)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.AccessTokenHash,
+		&i.AccessExpiresAt,
+		&i.RefreshTokenHash,
+		&i.RefreshExpiresAt,
+		&i.RefreshedAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
+
+const getUserBySessionAccessHash = `-- name: GetUserBySessionAccessHash :one
+SELECT u.id, u.created_at, u.updated_at, u.name, u.api_key_hash, u.api_key_prefix, u.previous_api_key_hash, u.previous_api_key_expires_at, u.email, u.password_hash, u.is_admin, u.disabled_at, u.invite_code_id, u.preferences, s.id, s.created_at, s.user_id, s.access_token_hash, s.access_expires_at, s.refresh_token_hash, s.refresh_expires_at, s.refreshed_at, s.revoked_at
+FROM sessions s
+  INNER JOIN users u ON u.id = s.user_id
+WHERE s.access_token_hash = $1
+  AND s.revoked_at IS NULL
+  AND s.access_expires_at > now()
+  AND u.disabled_at IS NULL
+`
+
+type GetUserBySessionAccessHashRow struct {
+	User    User
+	Session Session
+}
+
+func (q *Queries) GetUserBySessionAccessHash(ctx context.Context, accessTokenHash string) (GetUserBySessionAccessHashRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserBySessionAccessHash, accessTokenHash)
+	var i GetUserBySessionAccessHashRow
+	err := row.Scan(
+		&i.User.ID,
+		&i.User.CreatedAt,
+		&i.User.UpdatedAt,
+		&i.User.Name,
+		&i.User.ApiKeyHash,
+		&i.User.ApiKeyPrefix,
+		&i.User.PreviousApiKeyHash,
+		&i.User.PreviousApiKeyExpiresAt,
+		&i.User.Email,
+		&i.User.PasswordHash,
+		&i.User.IsAdmin,
+		&i.User.DisabledAt,
+		&i.User.InviteCodeID,
+		&i.User.Preferences,
+		&i.Session.ID,
+		&i.Session.CreatedAt,
+		&i.Session.UserID,
+		&i.Session.AccessTokenHash,
+		&i.Session.AccessExpiresAt,
+		&i.Session.RefreshTokenHash,
+		&i.Session.RefreshExpiresAt,
+		&i.Session.RefreshedAt,
+		&i.Session.RevokedAt,
+	)
+	return i, err
+}
+
+const listUserSessions = `-- name: ListUserSessions :many
+SELECT id, created_at, user_id, access_token_hash, access_expires_at, refresh_token_hash, refresh_expires_at, refreshed_at, revoked_at FROM sessions
+WHERE user_id = $1
+  AND revoked_at IS NULL
+  AND refresh_expires_at > now()
+ORDER BY created_at ASC
+`
+
+func (q *Queries) ListUserSessions(ctx context.Context, userID uuid.UUID) ([]Session, error) {
+	rows, err := q.db.QueryContext(ctx, listUserSessions, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Session
+	for rows.Next() {
+		var i Session
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UserID,
+			&i.AccessTokenHash,
+			&i.AccessExpiresAt,
+			&i.RefreshTokenHash,
+			&i.RefreshExpiresAt,
+			&i.RefreshedAt,
+			&i.RevokedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const refreshSession = `-- name: RefreshSession :one
+UPDATE sessions SET access_token_hash = $1, access_expires_at = $2,
+  refresh_token_hash = $3, refresh_expires_at = $4, refreshed_at = $5
+WHERE refresh_token_hash = $6
+  AND revoked_at IS NULL
+  AND refresh_expires_at > now()
+RETURNING id, created_at, user_id, access_token_hash, access_expires_at, refresh_token_hash, refresh_expires_at, refreshed_at, revoked_at
+`
+
+type RefreshSessionParams struct {
+	AccessTokenHash          string
+	AccessExpiresAt          time.Time
+	RefreshTokenHash         string
+	RefreshExpiresAt         time.Time
+	RefreshedAt              sql.NullTime
+	PreviousRefreshTokenHash string
+}
+
+func (q *Queries) RefreshSession(ctx context.Context, arg RefreshSessionParams) (Session, error) {
+	row := q.db.QueryRowContext(ctx, refreshSession,
+		arg.AccessTokenHash,
+		arg.AccessExpiresAt,
+		arg.RefreshTokenHash,
+		arg.RefreshExpiresAt,
+		arg.RefreshedAt,
+		arg.PreviousRefreshTokenHash,
+	)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.AccessTokenHash,
+		&i.AccessExpiresAt,
+		&i.RefreshTokenHash,
+		&i.RefreshExpiresAt,
+		&i.RefreshedAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
+
+const revokeSession = `-- name: RevokeSession :exec
+UPDATE sessions SET revoked_at = $2
+WHERE id = $1 AND revoked_at IS NULL
+`
+
+type RevokeSessionParams struct {
+	ID        uuid.UUID
+	RevokedAt sql.NullTime
+}
+
+func (q *Queries) RevokeSession(ctx context.Context, arg RevokeSessionParams) error {
+	_, err := q.db.ExecContext(ctx, revokeSession, arg.ID, arg.RevokedAt)
+	return err
+}
+
+const revokeSessionByRefreshHash = `-- name: RevokeSessionByRefreshHash :one
+UPDATE sessions SET revoked_at = $2
+WHERE refresh_token_hash = $1 AND revoked_at IS NULL
+RETURNING id, created_at, user_id, access_token_hash, access_expires_at, refresh_token_hash, refresh_expires_at, refreshed_at, revoked_at
+`
+
+type RevokeSessionByRefreshHashParams struct {
+	RefreshTokenHash string
+	RevokedAt        sql.NullTime
+}
+
+func (q *Queries) RevokeSessionByRefreshHash(ctx context.Context, arg RevokeSessionByRefreshHashParams) (Session, error) {
+	row := q.db.QueryRowContext(ctx, revokeSessionByRefreshHash, arg.RefreshTokenHash, arg.RevokedAt)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.AccessTokenHash,
+		&i.AccessExpiresAt,
+		&i.RefreshTokenHash,
+		&i.RefreshExpiresAt,
+		&i.RefreshedAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
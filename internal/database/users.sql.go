@@ -7,22 +7,30 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, name, api_key)
-VALUES ($1, $2, $3, $4, encode(sha256(random()::text::bytea), 'hex'))
-RETURNING id, created_at, updated_at, name, api_key
+INSERT INTO users (id, created_at, updated_at, name, api_key_hash, api_key_prefix, email, password_hash, invite_code_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING id, created_at, updated_at, name, api_key_hash, api_key_prefix, previous_api_key_hash, previous_api_key_expires_at, email, password_hash, is_admin, disabled_at, invite_code_id, preferences
 `
 
 type CreateUserParams struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	Name      string
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Name         string
+	ApiKeyHash   string
+	ApiKeyPrefix string
+	Email        sql.NullString
+	PasswordHash sql.NullString
+	InviteCodeID uuid.NullUUID
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
@@ -31,6 +39,11 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		arg.CreatedAt,
 		arg.UpdatedAt,
 		arg.Name,
+		arg.ApiKeyHash,
+		arg.ApiKeyPrefix,
+		arg.Email,
+		arg.PasswordHash,
+		arg.InviteCodeID,
 	)
 	var i User
 	err := row.Scan(
@@ -38,25 +51,314 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Name,
-		&i.ApiKey,
+		&i.ApiKeyHash,
+		&i.ApiKeyPrefix,
+		&i.PreviousApiKeyHash,
+		&i.PreviousApiKeyExpiresAt,
+		&i.Email,
+		&i.PasswordHash,
+		&i.IsAdmin,
+		&i.DisabledAt,
+		&i.InviteCodeID,
+		&i.Preferences,
 	)
 	return i, err
 }
 
-const getUserByApiKey = `-- name: GetUserByApiKey :one
-SELECT id, created_at, updated_at, name, api_key FROM users
-WHERE api_key = $1 LIMIT 1
+const deleteUser = `-- name: DeleteUser :execrows
+WITH orphan_feeds AS (
+  DELETE FROM feeds f
+  WHERE (f.user_id IS NULL OR f.user_id = $1::uuid)
+    AND NOT EXISTS (SELECT 1 FROM feed_follows ff WHERE ff.feed_id = f.id AND ff.user_id <> $1::uuid)
+)
+DELETE FROM users u
+WHERE u.id = $1::uuid
+`
+
+// The feeds left without an owner or other followers go with the user, in
+// the same statement. Both deletes see the rows as they were before it.
+func (q *Queries) DeleteUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getUser = `-- name: GetUser :one
+SELECT id, created_at, updated_at, name, api_key_hash, api_key_prefix, previous_api_key_hash, previous_api_key_expires_at, email, password_hash, is_admin, disabled_at, invite_code_id, preferences FROM users
+WHERE id = $1
+`
+
+func (q *Queries) GetUser(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.ApiKeyHash,
+		&i.ApiKeyPrefix,
+		&i.PreviousApiKeyHash,
+		&i.PreviousApiKeyExpiresAt,
+		&i.Email,
+		&i.PasswordHash,
+		&i.IsAdmin,
+		&i.DisabledAt,
+		&i.InviteCodeID,
+		&i.Preferences,
+	)
+	return i, err
+}
+
+const getUserByApiKeyHash = `-- name: GetUserByApiKeyHash :one
+SELECT id, created_at, updated_at, name, api_key_hash, api_key_prefix, previous_api_key_hash, previous_api_key_expires_at, email, password_hash, is_admin, disabled_at, invite_code_id, preferences FROM users
+WHERE (api_key_hash = $1
+    OR (previous_api_key_hash = $1 AND previous_api_key_expires_at > now()))
+  AND disabled_at IS NULL
+LIMIT 1
+`
+
+func (q *Queries) GetUserByApiKeyHash(ctx context.Context, apiKeyHash string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByApiKeyHash, apiKeyHash)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.ApiKeyHash,
+		&i.ApiKeyPrefix,
+		&i.PreviousApiKeyHash,
+		&i.PreviousApiKeyExpiresAt,
+		&i.Email,
+		&i.PasswordHash,
+		&i.IsAdmin,
+		&i.DisabledAt,
+		&i.InviteCodeID,
+		&i.Preferences,
+	)
+	return i, err
+}
+
+const getUserByEmail = `-- name: GetUserByEmail :one
+SELECT id, created_at, updated_at, name, api_key_hash, api_key_prefix, previous_api_key_hash, previous_api_key_expires_at, email, password_hash, is_admin, disabled_at, invite_code_id, preferences FROM users
+WHERE email = $1
+`
+
+func (q *Queries) GetUserByEmail(ctx context.Context, email sql.NullString) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByEmail, email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.ApiKeyHash,
+		&i.ApiKeyPrefix,
+		&i.PreviousApiKeyHash,
+		&i.PreviousApiKeyExpiresAt,
+		&i.Email,
+		&i.PasswordHash,
+		&i.IsAdmin,
+		&i.DisabledAt,
+		&i.InviteCodeID,
+		&i.Preferences,
+	)
+	return i, err
+}
+
+const listStarredPosts = `-- name: ListStarredPosts :many
+SELECT p.id, p.created_at, p.updated_at, p.url, p.title, p.description, p.published_at, p.feed_id, p.description_text, p.content, p.seq, p.author, p.categories, ps.user_id, ps.post_id, ps.created_at, ps.updated_at, ps.hidden, ps.read, ps.starred, ps.tags
+FROM post_states ps
+  INNER JOIN posts p ON p.id = ps.post_id
+WHERE ps.user_id = $1 AND ps.starred
+ORDER BY p.published_at DESC
+`
+
+type ListStarredPostsRow struct {
+	Post      Post
+	PostState PostState
+}
+
+func (q *Queries) ListStarredPosts(ctx context.Context, userID uuid.UUID) ([]ListStarredPostsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listStarredPosts, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListStarredPostsRow
+	for rows.Next() {
+		var i ListStarredPostsRow
+		if err := rows.Scan(
+			&i.Post.ID,
+			&i.Post.CreatedAt,
+			&i.Post.UpdatedAt,
+			&i.Post.Url,
+			&i.Post.Title,
+			&i.Post.Description,
+			&i.Post.PublishedAt,
+			&i.Post.FeedID,
+			&i.Post.DescriptionText,
+			&i.Post.Content,
+			&i.Post.Seq,
+			&i.Post.Author,
+			pq.Array(&i.Post.Categories),
+			&i.PostState.UserID,
+			&i.PostState.PostID,
+			&i.PostState.CreatedAt,
+			&i.PostState.UpdatedAt,
+			&i.PostState.Hidden,
+			&i.PostState.Read,
+			&i.PostState.Starred,
+			pq.Array(&i.PostState.Tags),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserFollowedFeeds = `-- name: ListUserFollowedFeeds :many
+SELECT f.id, f.created_at, f.updated_at, f.name, f.url, f.last_fetched_at, f.user_id, f.fetch_full_content, f.proxy_url, f.dead_at, f.consecutive_failures, f.last_error, f.last_error_kind, f.last_error_status, f.last_error_at, f.next_fetch_at, f.disabled_at, f.refresh_requested_at FROM feeds f
+  INNER JOIN feed_follows ff ON ff.feed_id = f.id
+WHERE ff.user_id = $1
+ORDER BY ff.created_at ASC
 `
 
-func (q *Queries) GetUserByApiKey(ctx context.Context, apiKey string) (User, error) {
-	row := q.db.QueryRowContext(ctx, getUserByApiKey, apiKey)
+func (q *Queries) ListUserFollowedFeeds(ctx context.Context, userID uuid.UUID) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, listUserFollowedFeeds, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.LastFetchedAt,
+			&i.UserID,
+			&i.FetchFullContent,
+			&i.ProxyUrl,
+			&i.DeadAt,
+			&i.ConsecutiveFailures,
+			&i.LastError,
+			&i.LastErrorKind,
+			&i.LastErrorStatus,
+			&i.LastErrorAt,
+			&i.NextFetchAt,
+			&i.DisabledAt,
+			&i.RefreshRequestedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const rotateUserApiKey = `-- name: RotateUserApiKey :one
+UPDATE users SET api_key_hash = $2, api_key_prefix = $3, updated_at = $4,
+  previous_api_key_hash = $5, previous_api_key_expires_at = $6
+WHERE id = $1
+RETURNING id, created_at, updated_at, name, api_key_hash, api_key_prefix, previous_api_key_hash, previous_api_key_expires_at, email, password_hash, is_admin, disabled_at, invite_code_id, preferences
+`
+
+type RotateUserApiKeyParams struct {
+	ID                      uuid.UUID
+	ApiKeyHash              string
+	ApiKeyPrefix            string
+	UpdatedAt               time.Time
+	PreviousApiKeyHash      sql.NullString
+	PreviousApiKeyExpiresAt sql.NullTime
+}
+
+func (q *Queries) RotateUserApiKey(ctx context.Context, arg RotateUserApiKeyParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, rotateUserApiKey,
+		arg.ID,
+		arg.ApiKeyHash,
+		arg.ApiKeyPrefix,
+		arg.UpdatedAt,
+		arg.PreviousApiKeyHash,
+		arg.PreviousApiKeyExpiresAt,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.ApiKeyHash,
+		&i.ApiKeyPrefix,
+		&i.PreviousApiKeyHash,
+		&i.PreviousApiKeyExpiresAt,
+		&i.Email,
+		&i.PasswordHash,
+		&i.IsAdmin,
+		&i.DisabledAt,
+		&i.InviteCodeID,
+		&i.Preferences,
+	)
+	return i, err
+}
+
+const updateUser = `-- name: UpdateUser :one
+UPDATE users SET name = $2, email = $3, preferences = $4, updated_at = $5
+WHERE id = $1
+RETURNING id, created_at, updated_at, name, api_key_hash, api_key_prefix, previous_api_key_hash, previous_api_key_expires_at, email, password_hash, is_admin, disabled_at, invite_code_id, preferences
+`
+
+type UpdateUserParams struct {
+	ID          uuid.UUID
+	Name        string
+	Email       sql.NullString
+	Preferences json.RawMessage
+	UpdatedAt   time.Time
+}
+
+func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUser,
+		arg.ID,
+		arg.Name,
+		arg.Email,
+		arg.Preferences,
+		arg.UpdatedAt,
+	)
 	var i User
 	err := row.Scan(
 		&i.ID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Name,
-		&i.ApiKey,
+		&i.ApiKeyHash,
+		&i.ApiKeyPrefix,
+		&i.PreviousApiKeyHash,
+		&i.PreviousApiKeyExpiresAt,
+		&i.Email,
+		&i.PasswordHash,
+		&i.IsAdmin,
+		&i.DisabledAt,
+		&i.InviteCodeID,
+		&i.Preferences,
 	)
 	return i, err
 }
@@ -0,0 +1,356 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: webhooks.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const claimWebhookDeliveries = `-- name: ClaimWebhookDeliveries :many
+UPDATE webhook_deliveries SET next_attempt_at = $1
+WHERE id IN (
+  SELECT d.id FROM webhook_deliveries d
+  WHERE d.status = 'pending' AND d.next_attempt_at <= now()
+  ORDER BY d.next_attempt_at ASC
+  LIMIT $2
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING id, created_at, webhook_id, post_id, event, status, attempts, next_attempt_at, last_attempt_at, response_status, error
+`
+
+type ClaimWebhookDeliveriesParams struct {
+	LockedUntil sql.NullTime
+	Amount      int32
+}
+
+func (q *Queries) ClaimWebhookDeliveries(ctx context.Context, arg ClaimWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.QueryContext(ctx, claimWebhookDeliveries, arg.LockedUntil, arg.Amount)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.WebhookID,
+			&i.PostID,
+			&i.Event,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.LastAttemptAt,
+			&i.ResponseStatus,
+			&i.Error,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createWebhook = `-- name: CreateWebhook :one
+INSERT INTO webhooks (id, created_at, updated_at, user_id, url, secret, feed_ids, keywords)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING id, created_at, updated_at, user_id, url, secret, feed_ids, keywords
+`
+
+type CreateWebhookParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Url       string
+	Secret    string
+	FeedIds   []uuid.UUID
+	Keywords  []string
+}
+
+func (q *Queries) CreateWebhook(ctx context.Context, arg CreateWebhookParams) (Webhook, error) {
+	row := q.db.QueryRowContext(ctx, createWebhook,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.UserID,
+		arg.Url,
+		arg.Secret,
+		pq.Array(arg.FeedIds),
+		pq.Array(arg.Keywords),
+	)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Url,
+		&i.Secret,
+		pq.Array(&i.FeedIds),
+		pq.Array(&i.Keywords),
+	)
+	return i, err
+}
+
+const createWebhookDelivery = `-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries (id, created_at, webhook_id, post_id, event, next_attempt_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, created_at, webhook_id, post_id, event, status, attempts, next_attempt_at, last_attempt_at, response_status, error
+`
+
+type CreateWebhookDeliveryParams struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	WebhookID     uuid.UUID
+	PostID        uuid.NullUUID
+	Event         string
+	NextAttemptAt sql.NullTime
+}
+
+func (q *Queries) CreateWebhookDelivery(ctx context.Context, arg CreateWebhookDeliveryParams) (WebhookDelivery, error) {
+	row := q.db.QueryRowContext(ctx, createWebhookDelivery,
+		arg.ID,
+		arg.CreatedAt,
+		arg.WebhookID,
+		arg.PostID,
+		arg.Event,
+		arg.NextAttemptAt,
+	)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.WebhookID,
+		&i.PostID,
+		&i.Event,
+		&i.Status,
+		&i.Attempts,
+		&i.NextAttemptAt,
+		&i.LastAttemptAt,
+		&i.ResponseStatus,
+		&i.Error,
+	)
+	return i, err
+}
+
+const deleteWebhook = `-- name: DeleteWebhook :exec
+DELETE FROM webhooks
+WHERE id = $1
+`
+
+func (q *Queries) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteWebhook, id)
+	return err
+}
+
+const enqueuePostWebhookDeliveries = `-- name: EnqueuePostWebhookDeliveries :exec
+INSERT INTO webhook_deliveries (id, created_at, webhook_id, post_id, event, next_attempt_at)
+SELECT gen_random_uuid(), now(), w.id, p.id, $1, now()
+FROM posts p
+  INNER JOIN feed_follows ff ON ff.feed_id = p.feed_id
+  INNER JOIN webhooks w ON w.user_id = ff.user_id
+WHERE p.id = $2
+  AND (cardinality(w.feed_ids) = 0 OR p.feed_id = ANY(w.feed_ids))
+  AND (cardinality(w.keywords) = 0 OR EXISTS (
+    SELECT 1 FROM unnest(w.keywords) k
+    -- position, unlike ILIKE, gives no meaning to % and _ in keywords
+    WHERE position(lower(k) in lower(concat_ws(' ', p.title, p.description_text))) > 0
+  ))
+  -- the filter rules of the owner run first, in the same transaction
+  AND NOT EXISTS (
+    SELECT 1 FROM post_states ps
+    WHERE ps.post_id = p.id AND ps.user_id = w.user_id AND ps.hidden
+  )
+`
+
+type EnqueuePostWebhookDeliveriesParams struct {
+	Event  string
+	PostID uuid.UUID
+}
+
+func (q *Queries) EnqueuePostWebhookDeliveries(ctx context.Context, arg EnqueuePostWebhookDeliveriesParams) error {
+	_, err := q.db.ExecContext(ctx, enqueuePostWebhookDeliveries, arg.Event, arg.PostID)
+	return err
+}
+
+const getWebhook = `-- name: GetWebhook :one
+SELECT id, created_at, updated_at, user_id, url, secret, feed_ids, keywords FROM webhooks
+WHERE id = $1
+`
+
+func (q *Queries) GetWebhook(ctx context.Context, id uuid.UUID) (Webhook, error) {
+	row := q.db.QueryRowContext(ctx, getWebhook, id)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Url,
+		&i.Secret,
+		pq.Array(&i.FeedIds),
+		pq.Array(&i.Keywords),
+	)
+	return i, err
+}
+
+const listUserWebhooks = `-- name: ListUserWebhooks :many
+SELECT id, created_at, updated_at, user_id, url, secret, feed_ids, keywords FROM webhooks
+WHERE user_id = $1
+ORDER BY created_at ASC
+`
+
+func (q *Queries) ListUserWebhooks(ctx context.Context, userID uuid.UUID) ([]Webhook, error) {
+	rows, err := q.db.QueryContext(ctx, listUserWebhooks, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Webhook
+	for rows.Next() {
+		var i Webhook
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.Url,
+			&i.Secret,
+			pq.Array(&i.FeedIds),
+			pq.Array(&i.Keywords),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhookDeliveries = `-- name: ListWebhookDeliveries :many
+SELECT id, created_at, webhook_id, post_id, event, status, attempts, next_attempt_at, last_attempt_at, response_status, error FROM webhook_deliveries
+WHERE webhook_id = $1
+ORDER BY created_at DESC
+LIMIT $2
+`
+
+type ListWebhookDeliveriesParams struct {
+	WebhookID uuid.UUID
+	Limit     int32
+}
+
+func (q *Queries) ListWebhookDeliveries(ctx context.Context, arg ListWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.QueryContext(ctx, listWebhookDeliveries, arg.WebhookID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.WebhookID,
+			&i.PostID,
+			&i.Event,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.LastAttemptAt,
+			&i.ResponseStatus,
+			&i.Error,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const moveWebhookFeeds = `-- name: MoveWebhookFeeds :exec
+UPDATE webhooks SET feed_ids = CASE
+    WHEN $1::uuid = ANY(feed_ids) THEN array_remove(feed_ids, $2::uuid)
+    ELSE array_replace(feed_ids, $2::uuid, $1::uuid)
+  END
+WHERE $2::uuid = ANY(feed_ids)
+`
+
+type MoveWebhookFeedsParams struct {
+	ToFeedID   uuid.UUID
+	FromFeedID uuid.UUID
+}
+
+func (q *Queries) MoveWebhookFeeds(ctx context.Context, arg MoveWebhookFeedsParams) error {
+	_, err := q.db.ExecContext(ctx, moveWebhookFeeds, arg.ToFeedID, arg.FromFeedID)
+	return err
+}
+
+const recordWebhookDeliveryAttempt = `-- name: RecordWebhookDeliveryAttempt :one
+UPDATE webhook_deliveries SET status = $2, attempts = attempts + 1, last_attempt_at = $3,
+  next_attempt_at = $4, response_status = $5, error = $6
+WHERE id = $1
+RETURNING id, created_at, webhook_id, post_id, event, status, attempts, next_attempt_at, last_attempt_at, response_status, error
+`
+
+type RecordWebhookDeliveryAttemptParams struct {
+	ID             uuid.UUID
+	Status         string
+	LastAttemptAt  sql.NullTime
+	NextAttemptAt  sql.NullTime
+	ResponseStatus sql.NullInt32
+	Error          sql.NullString
+}
+
+func (q *Queries) RecordWebhookDeliveryAttempt(ctx context.Context, arg RecordWebhookDeliveryAttemptParams) (WebhookDelivery, error) {
+	row := q.db.QueryRowContext(ctx, recordWebhookDeliveryAttempt,
+		arg.ID,
+		arg.Status,
+		arg.LastAttemptAt,
+		arg.NextAttemptAt,
+		arg.ResponseStatus,
+		arg.Error,
+	)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.WebhookID,
+		&i.PostID,
+		&i.Event,
+		&i.Status,
+		&i.Attempts,
+		&i.NextAttemptAt,
+		&i.LastAttemptAt,
+		&i.ResponseStatus,
+		&i.Error,
+	)
+	return i, err
+}
@@ -0,0 +1,35 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnqueuePostWebhookDeliveries(t *testing.T) {
+	q := setupTestQueries(t)
+	ctx := context.Background()
+	now := time.Now()
+	user, feed := setupTestFollow(t, q)
+	hook, err := q.CreateWebhook(ctx, CreateWebhookParams{
+		ID: uuid.New(), CreatedAt: now, UpdatedAt: now, UserID: user.ID,
+		Url: "http://example.com/hook", Secret: "s3cret", FeedIds: []uuid.UUID{}, Keywords: []string{"50%"},
+	})
+	require.NoError(t, err)
+	matching := setupTestPost(t, q, feed.ID, "Up to 50% off")
+	wildcard := setupTestPost(t, q, feed.ID, "500 reasons")
+	hidden := setupTestPost(t, q, feed.ID, "Hidden 50% off")
+	require.NoError(t, q.UpsertPostState(ctx, UpsertPostStateParams{UserID: user.ID, PostID: hidden.ID, CreatedAt: now, Hidden: true}))
+
+	for _, post := range []Post{matching, wildcard, hidden} {
+		require.NoError(t, q.EnqueuePostWebhookDeliveries(ctx, EnqueuePostWebhookDeliveriesParams{Event: "post.created", PostID: post.ID}))
+	}
+
+	deliveries, err := q.ListWebhookDeliveries(ctx, ListWebhookDeliveriesParams{WebhookID: hook.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	require.Equal(t, matching.ID, deliveries[0].PostID)
+}
@@ -0,0 +1,178 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: websub_subscriptions.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const deleteWebsubSubscription = `-- name: DeleteWebsubSubscription :exec
+DELETE FROM websub_subscriptions
+WHERE feed_id = $1
+`
+
+func (q *Queries) DeleteWebsubSubscription(ctx context.Context, feedID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteWebsubSubscription, feedID)
+	return err
+}
+
+const getExpiringWebsubSubscriptions = `-- name: GetExpiringWebsubSubscriptions :many
+SELECT feed_id, created_at, updated_at, hub_url, topic, secret, requested_at, verified_at, lease_expires_at, callback_token FROM websub_subscriptions
+WHERE lease_expires_at <= $1
+  AND requested_at <= $2
+ORDER BY lease_expires_at ASC
+`
+
+type GetExpiringWebsubSubscriptionsParams struct {
+	ExpiresBefore   sql.NullTime
+	RequestedBefore time.Time
+}
+
+func (q *Queries) GetExpiringWebsubSubscriptions(ctx context.Context, arg GetExpiringWebsubSubscriptionsParams) ([]WebsubSubscription, error) {
+	rows, err := q.db.QueryContext(ctx, getExpiringWebsubSubscriptions, arg.ExpiresBefore, arg.RequestedBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebsubSubscription
+	for rows.Next() {
+		var i WebsubSubscription
+		if err := rows.Scan(
+			&i.FeedID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.HubUrl,
+			&i.Topic,
+			&i.Secret,
+			&i.RequestedAt,
+			&i.VerifiedAt,
+			&i.LeaseExpiresAt,
+			&i.CallbackToken,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWebsubSubscription = `-- name: GetWebsubSubscription :one
+SELECT feed_id, created_at, updated_at, hub_url, topic, secret, requested_at, verified_at, lease_expires_at, callback_token FROM websub_subscriptions
+WHERE feed_id = $1
+`
+
+func (q *Queries) GetWebsubSubscription(ctx context.Context, feedID uuid.UUID) (WebsubSubscription, error) {
+	row := q.db.QueryRowContext(ctx, getWebsubSubscription, feedID)
+	var i WebsubSubscription
+	err := row.Scan(
+		&i.FeedID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.HubUrl,
+		&i.Topic,
+		&i.Secret,
+		&i.RequestedAt,
+		&i.VerifiedAt,
+		&i.LeaseExpiresAt,
+		&i.CallbackToken,
+	)
+	return i, err
+}
+
+const upsertWebsubSubscription = `-- name: UpsertWebsubSubscription :one
+INSERT INTO websub_subscriptions (feed_id, created_at, updated_at, hub_url, topic, secret, requested_at, callback_token)
+VALUES ($1, $2, $2, $3, $4, $5, $2, $6)
+ON CONFLICT (feed_id) DO UPDATE SET
+  updated_at = EXCLUDED.updated_at, hub_url = EXCLUDED.hub_url, topic = EXCLUDED.topic,
+  secret = EXCLUDED.secret, requested_at = EXCLUDED.requested_at, callback_token = EXCLUDED.callback_token
+RETURNING feed_id, created_at, updated_at, hub_url, topic, secret, requested_at, verified_at, lease_expires_at, callback_token
+`
+
+type UpsertWebsubSubscriptionParams struct {
+	FeedID        uuid.UUID
+	RequestedAt   time.Time
+	HubUrl        string
+	Topic         string
+	Secret        string
+	CallbackToken string
+}
+
+func (q *Queries) UpsertWebsubSubscription(ctx context.Context, arg UpsertWebsubSubscriptionParams) (WebsubSubscription, error) {
+	row := q.db.QueryRowContext(ctx, upsertWebsubSubscription,
+		arg.FeedID,
+		arg.RequestedAt,
+		arg.HubUrl,
+		arg.Topic,
+		arg.Secret,
+		arg.CallbackToken,
+	)
+	var i WebsubSubscription
+	err := row.Scan(
+		&i.FeedID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.HubUrl,
+		&i.Topic,
+		&i.Secret,
+		&i.RequestedAt,
+		&i.VerifiedAt,
+		&i.LeaseExpiresAt,
+		&i.CallbackToken,
+	)
+	return i, err
+}
+
+const verifyWebsubSubscription = `-- name: VerifyWebsubSubscription :one
+UPDATE websub_subscriptions
+SET verified_at = $1, updated_at = $1, lease_expires_at = $2
+WHERE feed_id = $3
+  AND callback_token = $4
+  AND (verified_at IS NULL OR verified_at < requested_at)
+  AND requested_at >= $5
+RETURNING feed_id, created_at, updated_at, hub_url, topic, secret, requested_at, verified_at, lease_expires_at, callback_token
+`
+
+type VerifyWebsubSubscriptionParams struct {
+	VerifiedAt     sql.NullTime
+	LeaseExpiresAt sql.NullTime
+	FeedID         uuid.UUID
+	CallbackToken  string
+	RequestedAfter time.Time
+}
+
+func (q *Queries) VerifyWebsubSubscription(ctx context.Context, arg VerifyWebsubSubscriptionParams) (WebsubSubscription, error) {
+	row := q.db.QueryRowContext(ctx, verifyWebsubSubscription,
+		arg.VerifiedAt,
+		arg.LeaseExpiresAt,
+		arg.FeedID,
+		arg.CallbackToken,
+		arg.RequestedAfter,
+	)
+	var i WebsubSubscription
+	err := row.Scan(
+		&i.FeedID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.HubUrl,
+		&i.Topic,
+		&i.Secret,
+		&i.RequestedAt,
+		&i.VerifiedAt,
+		&i.LeaseExpiresAt,
+		&i.CallbackToken,
+	)
+	return i, err
+}
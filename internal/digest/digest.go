@@ -0,0 +1,146 @@
+package digest
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/sp3dr4/bloggogrator/internal/database"
+)
+
+// Frequencies of a digest subscription.
+const (
+	FrequencyDaily  = "daily"
+	FrequencyWeekly = "weekly"
+)
+
+// Statuses of a digest run.
+const (
+	StatusSending = "sending"
+	StatusSent    = "sent"
+	StatusSkipped = "skipped"
+	StatusFailed  = "failed"
+)
+
+// MaxPosts is the number of posts listed in a digest.
+const MaxPosts = 100
+
+//go:embed templates
+var templates embed.FS
+
+var (
+	htmlTemplate = htmltemplate.Must(htmltemplate.ParseFS(templates, "templates/digest.html", "templates/confirm.html"))
+	textTemplate = texttemplate.Must(texttemplate.ParseFS(templates, "templates/digest.txt", "templates/confirm.txt"))
+)
+
+type Post struct {
+	Title       string
+	Url         string
+	PublishedAt time.Time
+}
+
+// Data is what the digest templates are rendered with.
+type Data struct {
+	Frequency      string
+	PeriodStart    time.Time
+	Posts          []Post
+	UnsubscribeUrl string
+}
+
+// NewData returns the data of a digest listing posts since periodStart, in
+// the timezone of the subscription.
+func NewData(sub database.DigestSubscription, periodStart time.Time, posts []database.Post, unsubscribeUrl string) Data {
+	loc, err := time.LoadLocation(sub.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	data := Data{
+		Frequency:      sub.Frequency,
+		PeriodStart:    periodStart.In(loc),
+		UnsubscribeUrl: unsubscribeUrl,
+	}
+	for _, o := range posts {
+		title := o.Title.String
+		if title == "" {
+			title = o.Url
+		}
+		data.Posts = append(data.Posts, Post{Title: title, Url: o.Url, PublishedAt: o.PublishedAt.In(loc)})
+	}
+	return data
+}
+
+// Render returns the message of a digest, with a plain text and an HTML body.
+func Render(data Data) (Message, error) {
+	var text, html bytes.Buffer
+	if err := textTemplate.ExecuteTemplate(&text, "digest.txt", data); err != nil {
+		return Message{}, err
+	}
+	if err := htmlTemplate.ExecuteTemplate(&html, "digest.html", data); err != nil {
+		return Message{}, err
+	}
+	plural := "s"
+	if len(data.Posts) == 1 {
+		plural = ""
+	}
+	return Message{
+		Subject:        fmt.Sprintf("Your %s bloggogrator digest: %d new post%s", data.Frequency, len(data.Posts), plural),
+		Text:           text.String(),
+		HTML:           html.String(),
+		UnsubscribeUrl: data.UnsubscribeUrl,
+	}, nil
+}
+
+// ConfirmationData is what the confirmation templates are rendered with.
+type ConfirmationData struct {
+	Frequency  string
+	ConfirmUrl string
+}
+
+// RenderConfirmation returns the message asking the recipient of a new
+// subscription to confirm it. No digest is sent to an unconfirmed address.
+func RenderConfirmation(data ConfirmationData) (Message, error) {
+	var text, html bytes.Buffer
+	if err := textTemplate.ExecuteTemplate(&text, "confirm.txt", data); err != nil {
+		return Message{}, err
+	}
+	if err := htmlTemplate.ExecuteTemplate(&html, "confirm.html", data); err != nil {
+		return Message{}, err
+	}
+	return Message{
+		Subject: fmt.Sprintf("Confirm your %s bloggogrator digest", data.Frequency),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
+
+// Schedule returns the last time at or before now the digest of sub was
+// due, and the start of the period that digest covers. Send times are
+// computed in the timezone of the subscription.
+func Schedule(sub database.DigestSubscription, now time.Time) (scheduled, periodStart time.Time, err error) {
+	loc, err := time.LoadLocation(sub.Timezone)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	local := now.In(loc)
+	scheduled = time.Date(local.Year(), local.Month(), local.Day(), int(sub.SendHour), 0, 0, 0, loc)
+
+	switch sub.Frequency {
+	case FrequencyDaily:
+		if scheduled.After(now) {
+			scheduled = scheduled.AddDate(0, 0, -1)
+		}
+		return scheduled, scheduled.AddDate(0, 0, -1), nil
+	case FrequencyWeekly:
+		daysSince := (int(local.Weekday()) - int(sub.Weekday) + 7) % 7
+		scheduled = scheduled.AddDate(0, 0, -daysSince)
+		if scheduled.After(now) {
+			scheduled = scheduled.AddDate(0, 0, -7)
+		}
+		return scheduled, scheduled.AddDate(0, 0, -7), nil
+	default:
+		return time.Time{}, time.Time{}, fmt.Errorf("unknown digest frequency %q", sub.Frequency)
+	}
+}
@@ -0,0 +1,290 @@
+package digest
+
+import (
+	"database/sql"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sp3dr4/bloggogrator/internal/database"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedule(t *testing.T) {
+	rome, err := time.LoadLocation("Europe/Rome")
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		sub         database.DigestSubscription
+		now         time.Time
+		scheduled   time.Time
+		periodStart time.Time
+		err         bool
+	}{
+		"daily after send hour": {
+			sub:         database.DigestSubscription{Frequency: FrequencyDaily, SendHour: 8, Timezone: "UTC"},
+			now:         time.Date(2024, 6, 12, 9, 30, 0, 0, time.UTC),
+			scheduled:   time.Date(2024, 6, 12, 8, 0, 0, 0, time.UTC),
+			periodStart: time.Date(2024, 6, 11, 8, 0, 0, 0, time.UTC),
+		},
+		"daily before send hour": {
+			sub:         database.DigestSubscription{Frequency: FrequencyDaily, SendHour: 8, Timezone: "UTC"},
+			now:         time.Date(2024, 6, 12, 7, 59, 0, 0, time.UTC),
+			scheduled:   time.Date(2024, 6, 11, 8, 0, 0, 0, time.UTC),
+			periodStart: time.Date(2024, 6, 10, 8, 0, 0, 0, time.UTC),
+		},
+		"daily in timezone": {
+			sub:         database.DigestSubscription{Frequency: FrequencyDaily, SendHour: 8, Timezone: "Europe/Rome"},
+			now:         time.Date(2024, 6, 12, 6, 30, 0, 0, time.UTC),
+			scheduled:   time.Date(2024, 6, 12, 8, 0, 0, 0, rome),
+			periodStart: time.Date(2024, 6, 11, 8, 0, 0, 0, rome),
+		},
+		"weekly": {
+			// 2024-06-12 is a Wednesday
+			sub:         database.DigestSubscription{Frequency: FrequencyWeekly, SendHour: 18, Weekday: int32(time.Monday), Timezone: "UTC"},
+			now:         time.Date(2024, 6, 12, 9, 0, 0, 0, time.UTC),
+			scheduled:   time.Date(2024, 6, 10, 18, 0, 0, 0, time.UTC),
+			periodStart: time.Date(2024, 6, 3, 18, 0, 0, 0, time.UTC),
+		},
+		"weekly on send day before send hour": {
+			sub:         database.DigestSubscription{Frequency: FrequencyWeekly, SendHour: 18, Weekday: int32(time.Wednesday), Timezone: "UTC"},
+			now:         time.Date(2024, 6, 12, 9, 0, 0, 0, time.UTC),
+			scheduled:   time.Date(2024, 6, 5, 18, 0, 0, 0, time.UTC),
+			periodStart: time.Date(2024, 5, 29, 18, 0, 0, 0, time.UTC),
+		},
+		"unknown timezone": {
+			sub: database.DigestSubscription{Frequency: FrequencyDaily, Timezone: "Nowhere/Town"},
+			now: time.Now(),
+			err: true,
+		},
+		"unknown frequency": {
+			sub: database.DigestSubscription{Frequency: "hourly", Timezone: "UTC"},
+			now: time.Now(),
+			err: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			scheduled, periodStart, err := Schedule(tc.sub, tc.now)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.True(t, tc.scheduled.Equal(scheduled), "scheduled %v, expected %v", scheduled, tc.scheduled)
+			require.True(t, tc.periodStart.Equal(periodStart), "period start %v, expected %v", periodStart, tc.periodStart)
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	sub := database.DigestSubscription{Frequency: FrequencyDaily, Timezone: "UTC"}
+	posts := []database.Post{
+		{Url: "http://example.com/a", Title: sql.NullString{String: "<b>First</b>", Valid: true}, PublishedAt: time.Now()},
+		{Url: "http://example.com/b", PublishedAt: time.Now()},
+	}
+
+	msg, err := Render(NewData(sub, time.Now().Add(-24*time.Hour), posts, "http://api.example.com/v1/digest/unsubscribe?token=abc"))
+
+	require.NoError(t, err)
+	require.Equal(t, "Your daily bloggogrator digest: 2 new posts", msg.Subject)
+	require.Contains(t, msg.Text, "<b>First</b>")
+	require.Contains(t, msg.Text, "http://example.com/b")
+	require.Contains(t, msg.HTML, "&lt;b&gt;First&lt;/b&gt;")
+	require.Contains(t, msg.HTML, `href="http://example.com/a"`)
+	require.Contains(t, msg.HTML, "token=abc")
+	require.Equal(t, "http://api.example.com/v1/digest/unsubscribe?token=abc", msg.UnsubscribeUrl)
+}
+
+// smtpSink is a minimal SMTP server accepting a single message.
+func smtpSink(t *testing.T) (*Mailer, <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	messages := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tp := textproto.NewConn(conn)
+		tp.PrintfLine("220 localhost ESMTP")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); cmd {
+			case "EHLO", "HELO", "MAIL", "RCPT", "RSET", "NOOP":
+				tp.PrintfLine("250 OK")
+			case "DATA":
+				tp.PrintfLine("354 go ahead")
+				data, err := tp.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				messages <- string(data)
+				tp.PrintfLine("250 OK")
+			case "QUIT":
+				tp.PrintfLine("221 bye")
+				return
+			default:
+				tp.PrintfLine("502 unsupported")
+			}
+		}
+	}()
+
+	host, portStr, err := net.SplitHostPort(ln.Addr().String())
+	require.NoError(t, err)
+	port, err := strconv.Atoi(portStr)
+	require.NoError(t, err)
+	return NewMailer(SMTPConfig{Host: host, Port: port, From: "Bloggogrator <digest@example.com>"}), messages
+}
+
+func TestMailerSend(t *testing.T) {
+	mailer, messages := smtpSink(t)
+	msg := Message{Subject: "Hello", Text: "plain body", HTML: "<p>html body</p>", UnsubscribeUrl: "http://api.example.com/unsub"}
+
+	err := mailer.Send("user@example.com", msg)
+
+	require.NoError(t, err)
+	data := <-messages
+	require.Contains(t, data, "Subject: Hello")
+	require.Contains(t, data, "To: <user@example.com>")
+	require.Contains(t, data, "List-Unsubscribe: <http://api.example.com/unsub>")
+	require.Contains(t, data, "List-Unsubscribe-Post: List-Unsubscribe=One-Click")
+	require.Contains(t, data, "Content-Type: multipart/alternative")
+	require.Contains(t, data, "plain body")
+	require.Contains(t, data, "<p>html body</p>")
+}
+
+func TestProcess(t *testing.T) {
+	now := time.Date(2024, 6, 12, 9, 0, 0, 0, time.UTC)
+	sub := database.DigestSubscription{
+		UserID:           uuid.New(),
+		CreatedAt:        now.Add(-30 * 24 * time.Hour),
+		Email:            "user@example.com",
+		Frequency:        FrequencyDaily,
+		SendHour:         8,
+		Timezone:         "UTC",
+		UnsubscribeToken: "tok",
+		ConfirmedAt:      sql.NullTime{Time: now.Add(-30 * 24 * time.Hour), Valid: true},
+	}
+	posts := []database.Post{{Url: "http://example.com/a", PublishedAt: now}}
+
+	t.Run("sends and records the run", func(t *testing.T) {
+		mailer, messages := smtpSink(t)
+		var finished database.FinishDigestRunParams
+		runId := uuid.New()
+		hooks := Hooks{
+			Claim: func(userId uuid.UUID, periodStart, scheduled time.Time) (database.DigestRun, error) {
+				require.Equal(t, sub.UserID, userId)
+				require.Equal(t, time.Date(2024, 6, 12, 8, 0, 0, 0, time.UTC), scheduled.UTC())
+				return database.DigestRun{ID: runId}, nil
+			},
+			GetPosts: func(userId uuid.UUID, after, until time.Time) ([]database.Post, error) { return posts, nil },
+			Finish:   func(params database.FinishDigestRunParams) error { finished = params; return nil },
+		}
+
+		process(mailer, "http://api.example.com", hooks, sub, now)
+
+		require.Contains(t, <-messages, "token=tok")
+		require.Equal(t, runId, finished.ID)
+		require.Equal(t, StatusSent, finished.Status)
+		require.Equal(t, int32(1), finished.Posts)
+		require.True(t, finished.FinishedAt.Valid)
+	})
+
+	t.Run("skips without posts", func(t *testing.T) {
+		var finished database.FinishDigestRunParams
+		hooks := Hooks{
+			Claim: func(userId uuid.UUID, periodStart, scheduled time.Time) (database.DigestRun, error) {
+				return database.DigestRun{}, nil
+			},
+			GetPosts: func(userId uuid.UUID, after, until time.Time) ([]database.Post, error) { return nil, nil },
+			Finish:   func(params database.FinishDigestRunParams) error { finished = params; return nil },
+		}
+
+		process(nil, "http://api.example.com", hooks, sub, now)
+
+		require.Equal(t, StatusSkipped, finished.Status)
+	})
+
+	t.Run("already claimed", func(t *testing.T) {
+		hooks := Hooks{
+			Claim: func(userId uuid.UUID, periodStart, scheduled time.Time) (database.DigestRun, error) {
+				return database.DigestRun{}, ErrAlreadyClaimed
+			},
+			GetPosts: func(userId uuid.UUID, after, until time.Time) ([]database.Post, error) {
+				t.Fatal("posts retrieved for a claimed run")
+				return nil, nil
+			},
+			Finish: func(params database.FinishDigestRunParams) error {
+				t.Fatal("claimed run finished")
+				return nil
+			},
+		}
+
+		process(nil, "http://api.example.com", hooks, sub, now)
+	})
+
+	t.Run("confirmed after the due digest", func(t *testing.T) {
+		recent := sub
+		recent.ConfirmedAt.Time = now.Add(-time.Minute)
+		hooks := Hooks{
+			Claim: func(userId uuid.UUID, periodStart, scheduled time.Time) (database.DigestRun, error) {
+				t.Fatal("run claimed before the subscription")
+				return database.DigestRun{}, nil
+			},
+		}
+
+		process(nil, "http://api.example.com", hooks, recent, now)
+	})
+
+	t.Run("sends the confirmation instead of the digest", func(t *testing.T) {
+		mailer, messages := smtpSink(t)
+		unconfirmed := sub
+		unconfirmed.ConfirmedAt = sql.NullTime{}
+		unconfirmed.ConfirmationToken = "c0nfirm"
+		claimed := false
+		hooks := Hooks{
+			ClaimConfirmation: func(s database.DigestSubscription, sentAt time.Time) error {
+				require.Equal(t, unconfirmed, s)
+				claimed = true
+				return nil
+			},
+			Claim: func(userId uuid.UUID, periodStart, scheduled time.Time) (database.DigestRun, error) {
+				t.Fatal("digest run claimed before the confirmation")
+				return database.DigestRun{}, nil
+			},
+		}
+
+		process(mailer, "http://api.example.com", hooks, unconfirmed, now)
+
+		msg := <-messages
+		require.True(t, claimed)
+		require.Contains(t, msg, "Confirm your daily bloggogrator digest")
+		require.Contains(t, msg, "http://api.example.com/v1/digest/confirm?token=3Dc0nfirm")
+	})
+
+	t.Run("sends the confirmation once", func(t *testing.T) {
+		unconfirmed := sub
+		unconfirmed.ConfirmedAt = sql.NullTime{}
+		unconfirmed.ConfirmationSentAt = sql.NullTime{Time: now.Add(-time.Hour), Valid: true}
+		hooks := Hooks{
+			ClaimConfirmation: func(s database.DigestSubscription, sentAt time.Time) error {
+				t.Fatal("confirmation claimed twice")
+				return nil
+			},
+		}
+
+		process(nil, "http://api.example.com", hooks, unconfirmed, now)
+	})
+}
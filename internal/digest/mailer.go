@@ -0,0 +1,111 @@
+package digest
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"time"
+)
+
+type SMTPConfig struct {
+	Host string
+	Port int
+	// Username and Password authenticate with PLAIN when Username is set,
+	// which net/smtp only allows over TLS or to localhost.
+	Username string
+	Password string
+	From     string
+}
+
+// Message is an email with a plain text and an HTML alternative.
+type Message struct {
+	Subject        string
+	Text           string
+	HTML           string
+	UnsubscribeUrl string
+}
+
+// Mailer sends emails through an SMTP server, using STARTTLS when the
+// server offers it.
+type Mailer struct {
+	cfg SMTPConfig
+}
+
+func NewMailer(cfg SMTPConfig) *Mailer {
+	return &Mailer{cfg: cfg}
+}
+
+func (m *Mailer) Send(to string, msg Message) error {
+	from, err := mail.ParseAddress(m.cfg.From)
+	if err != nil {
+		return fmt.Errorf("invalid sender address: %w", err)
+	}
+	rcpt, err := mail.ParseAddress(to)
+	if err != nil {
+		return fmt.Errorf("invalid recipient address: %w", err)
+	}
+
+	data, err := buildMessage(from, rcpt, msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.cfg.Username != "" {
+		auth = smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)
+	}
+	addr := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+	return smtp.SendMail(addr, auth, from.Address, []string{rcpt.Address}, data)
+}
+
+func buildMessage(from, to *mail.Address, msg Message, date time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	body := multipart.NewWriter(&buf)
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "From: %s\r\n", from)
+	fmt.Fprintf(&out, "To: %s\r\n", to)
+	fmt.Fprintf(&out, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&out, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	fmt.Fprintf(&out, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&out, "Content-Type: multipart/alternative; boundary=%s\r\n", body.Boundary())
+	if msg.UnsubscribeUrl != "" {
+		// RFC 8058 one-click unsubscribe
+		fmt.Fprintf(&out, "List-Unsubscribe: <%s>\r\n", msg.UnsubscribeUrl)
+		fmt.Fprintf(&out, "List-Unsubscribe-Post: List-Unsubscribe=One-Click\r\n")
+	}
+	out.WriteString("\r\n")
+
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		w, err := body.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := body.Close(); err != nil {
+		return nil, err
+	}
+
+	out.Write(buf.Bytes())
+	return out.Bytes(), nil
+}
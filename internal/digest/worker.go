@@ -0,0 +1,134 @@
+package digest
+
+import (
+	"errors"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sp3dr4/bloggogrator/internal/database"
+)
+
+type ListSubscriptions func() ([]database.DigestSubscription, error)
+type ClaimConfirmation func(sub database.DigestSubscription, sentAt time.Time) error
+type ClaimRun func(userId uuid.UUID, periodStart, scheduled time.Time) (database.DigestRun, error)
+type GetPosts func(userId uuid.UUID, after, until time.Time) ([]database.Post, error)
+type FinishRun func(params database.FinishDigestRunParams) error
+
+// ErrAlreadyClaimed is returned by ClaimRun when the run of a period exists,
+// and by ClaimConfirmation when the confirmation was sent, whichever instance
+// created them.
+var ErrAlreadyClaimed = errors.New("digest run already claimed")
+
+// Hooks are the persistence callbacks used by the digest worker.
+type Hooks struct {
+	List ListSubscriptions
+	// ClaimConfirmation records the confirmation email of a subscription
+	// before sending it, so that it is sent once per address.
+	ClaimConfirmation ClaimConfirmation
+	// Claim records the run of a period before sending it, so that a digest
+	// is never sent twice, even across restarts.
+	Claim    ClaimRun
+	GetPosts GetPosts
+	Finish   FinishRun
+}
+
+// Run sends the due digests every frequency. publicUrl is the base url of
+// the API, used for the unsubscribe links.
+func Run(frequency time.Duration, mailer *Mailer, publicUrl string, hooks Hooks) {
+	ticker := time.NewTicker(frequency)
+	for range ticker.C {
+		subs, err := hooks.List()
+		if err != nil {
+			log.Printf("could not retrieve digest subscriptions: %v\n", err)
+			continue
+		}
+		for _, sub := range subs {
+			process(mailer, publicUrl, hooks, sub, time.Now())
+		}
+	}
+}
+
+func process(mailer *Mailer, publicUrl string, hooks Hooks, sub database.DigestSubscription, now time.Time) {
+	if !sub.ConfirmedAt.Valid {
+		confirm(mailer, publicUrl, hooks, sub, now)
+		return
+	}
+
+	scheduled, periodStart, err := Schedule(sub, now)
+	if err != nil {
+		log.Printf("invalid digest schedule of user %v: %v\n", sub.UserID, err)
+		return
+	}
+	// the digest due before confirming isn't owed
+	if !scheduled.After(sub.ConfirmedAt.Time) {
+		return
+	}
+
+	run, err := hooks.Claim(sub.UserID, periodStart, scheduled)
+	if errors.Is(err, ErrAlreadyClaimed) {
+		return
+	}
+	if err != nil {
+		log.Printf("err claiming digest of user %v: %v\n", sub.UserID, err)
+		return
+	}
+
+	finish := database.FinishDigestRunParams{ID: run.ID, Status: StatusSent}
+	defer func() {
+		finish.FinishedAt.Time, finish.FinishedAt.Valid = time.Now(), true
+		if err := hooks.Finish(finish); err != nil {
+			log.Printf("err finishing digest run %v: %v\n", run.ID, err)
+		}
+	}()
+
+	posts, err := hooks.GetPosts(sub.UserID, periodStart, scheduled)
+	if err != nil {
+		finish.Status, finish.Error.String, finish.Error.Valid = StatusFailed, err.Error(), true
+		return
+	}
+	finish.Posts = int32(len(posts))
+	if len(posts) == 0 {
+		finish.Status = StatusSkipped
+		return
+	}
+
+	unsubscribeUrl := publicUrl + "/v1/digest/unsubscribe?token=" + url.QueryEscape(sub.UnsubscribeToken)
+	msg, err := Render(NewData(sub, periodStart, posts, unsubscribeUrl))
+	if err == nil {
+		err = mailer.Send(sub.Email, msg)
+	}
+	if err != nil {
+		log.Printf("err sending digest of user %v: %v\n", sub.UserID, err)
+		finish.Status, finish.Error.String, finish.Error.Valid = StatusFailed, err.Error(), true
+		return
+	}
+	log.Printf("sent %s digest of %d posts to user %v\n", sub.Frequency, len(posts), sub.UserID)
+}
+
+// confirm sends the confirmation email of a subscription, once.
+func confirm(mailer *Mailer, publicUrl string, hooks Hooks, sub database.DigestSubscription, now time.Time) {
+	if sub.ConfirmationSentAt.Valid {
+		return
+	}
+	err := hooks.ClaimConfirmation(sub, now)
+	if errors.Is(err, ErrAlreadyClaimed) {
+		return
+	}
+	if err != nil {
+		log.Printf("err claiming digest confirmation of user %v: %v\n", sub.UserID, err)
+		return
+	}
+
+	confirmUrl := publicUrl + "/v1/digest/confirm?token=" + url.QueryEscape(sub.ConfirmationToken)
+	msg, err := RenderConfirmation(ConfirmationData{Frequency: sub.Frequency, ConfirmUrl: confirmUrl})
+	if err == nil {
+		err = mailer.Send(sub.Email, msg)
+	}
+	if err != nil {
+		log.Printf("err sending digest confirmation of user %v: %v\n", sub.UserID, err)
+		return
+	}
+	log.Printf("sent digest confirmation to user %v\n", sub.UserID)
+}
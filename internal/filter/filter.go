@@ -0,0 +1,215 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sp3dr4/bloggogrator/internal/database"
+)
+
+// Fields a rule matches on.
+const (
+	FieldTitle       = "title"
+	FieldDescription = "description"
+	FieldAuthor      = "author"
+	FieldCategory    = "category"
+	FieldFeed        = "feed"
+)
+
+// Ways a rule matches its pattern.
+const (
+	MatchContains = "contains"
+	MatchRegex    = "regex"
+)
+
+// Actions applied to the posts matching a rule.
+const (
+	ActionHide = "hide"
+	ActionRead = "read"
+	ActionStar = "star"
+	ActionTag  = "tag"
+)
+
+// maxPatternLength bounds the patterns, regular expressions included.
+const maxPatternLength = 512
+
+// maxTagLength matches the filter_rules.tag column size.
+const maxTagLength = 64
+
+var (
+	fields  = map[string]bool{FieldTitle: true, FieldDescription: true, FieldAuthor: true, FieldCategory: true, FieldFeed: true}
+	matches = map[string]bool{MatchContains: true, MatchRegex: true}
+	actions = map[string]bool{ActionHide: true, ActionRead: true, ActionStar: true, ActionTag: true}
+)
+
+// Validate checks the definition of a rule, returning an error suitable for
+// the API clients.
+func Validate(field, match, pattern, action, tag string) error {
+	if !fields[field] {
+		return fmt.Errorf("invalid field %q", field)
+	}
+	if !matches[match] {
+		return fmt.Errorf("invalid match %q", match)
+	}
+	if pattern == "" || len(pattern) > maxPatternLength {
+		return errors.New("invalid pattern")
+	}
+	if match == MatchRegex {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return errors.New("invalid regex pattern")
+		}
+	}
+	if !actions[action] {
+		return fmt.Errorf("invalid action %q", action)
+	}
+	if action == ActionTag && (tag == "" || len(tag) > maxTagLength) {
+		return errors.New("invalid tag")
+	}
+	return nil
+}
+
+// Target is what the rules are matched against.
+type Target struct {
+	FeedID      uuid.UUID
+	FeedName    string
+	Title       string
+	Description string
+	Author      string
+	Categories  []string
+}
+
+// NewTarget returns the target of a post of the feed named feedName.
+func NewTarget(post database.Post, feedName string) Target {
+	return Target{
+		FeedID:      post.FeedID,
+		FeedName:    feedName,
+		Title:       post.Title.String,
+		Description: post.DescriptionText.String,
+		Author:      post.Author.String,
+		Categories:  post.Categories,
+	}
+}
+
+// Rule is a compiled filter rule.
+type Rule struct {
+	database.FilterRule
+	re *regexp.Regexp
+}
+
+// Compile compiles rules, which must be valid.
+func Compile(rules []database.FilterRule) ([]Rule, error) {
+	compiled := make([]Rule, 0, len(rules))
+	for _, o := range rules {
+		rule := Rule{FilterRule: o}
+		if o.Match == MatchRegex {
+			re, err := regexp.Compile(o.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("rule %v: %w", o.ID, err)
+			}
+			rule.re = re
+		}
+		compiled = append(compiled, rule)
+	}
+	return compiled, nil
+}
+
+// Matches reports whether the rule applies to t. Substrings are matched
+// case-insensitively, regular expressions as written.
+func (r Rule) Matches(t Target) bool {
+	if r.FeedID.Valid && r.FeedID.UUID != t.FeedID {
+		return false
+	}
+	var values []string
+	switch r.Field {
+	case FieldTitle:
+		values = []string{t.Title}
+	case FieldDescription:
+		values = []string{t.Description}
+	case FieldAuthor:
+		values = []string{t.Author}
+	case FieldCategory:
+		values = t.Categories
+	case FieldFeed:
+		values = []string{t.FeedName}
+	}
+	for _, v := range values {
+		if r.matchValue(v) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Rule) matchValue(v string) bool {
+	if r.re != nil {
+		return r.re.MatchString(v)
+	}
+	return strings.Contains(strings.ToLower(v), strings.ToLower(r.Pattern))
+}
+
+// State is the outcome of the rules of a user on a post.
+type State struct {
+	Hidden  bool
+	Read    bool
+	Starred bool
+	Tags    []string
+}
+
+// Evaluate applies rules to t, returning the state of the post for each
+// user with a matching rule.
+func Evaluate(rules []Rule, t Target) map[uuid.UUID]State {
+	states := map[uuid.UUID]State{}
+	for _, r := range rules {
+		if !r.Matches(t) {
+			continue
+		}
+		state := states[r.UserID]
+		switch r.Action {
+		case ActionHide:
+			state.Hidden = true
+		case ActionRead:
+			state.Read = true
+		case ActionStar:
+			state.Starred = true
+		case ActionTag:
+			if !contains(state.Tags, r.Tag.String) {
+				state.Tags = append(state.Tags, r.Tag.String)
+				sort.Strings(state.Tags)
+			}
+		}
+		states[r.UserID] = state
+	}
+	return states
+}
+
+// Params returns the parameters saving the state of the post for the user,
+// merged with the state it may already have.
+func (s State) Params(userId, postId uuid.UUID, now time.Time) database.UpsertPostStateParams {
+	tags := s.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return database.UpsertPostStateParams{
+		UserID:    userId,
+		PostID:    postId,
+		CreatedAt: now,
+		Hidden:    s.Hidden,
+		Read:      s.Read,
+		Starred:   s.Starred,
+		Tags:      tags,
+	}
+}
+
+func contains(values []string, v string) bool {
+	for _, o := range values {
+		if o == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package filter
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sp3dr4/bloggogrator/internal/database"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	tests := map[string]struct {
+		field, match, pattern, action, tag string
+		err                                bool
+	}{
+		"valid contains": {FieldTitle, MatchContains, "sponsored", ActionHide, "", false},
+		"valid regex":    {FieldCategory, MatchRegex, `(?i)^hiring$`, ActionTag, "jobs", false},
+		"unknown field":  {"body", MatchContains, "x", ActionHide, "", true},
+		"unknown match":  {FieldTitle, "glob", "x", ActionHide, "", true},
+		"empty pattern":  {FieldTitle, MatchContains, "", ActionHide, "", true},
+		"invalid regex":  {FieldTitle, MatchRegex, "(", ActionHide, "", true},
+		"unknown action": {FieldTitle, MatchContains, "x", "delete", "", true},
+		"missing tag":    {FieldTitle, MatchContains, "x", ActionTag, "", true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := Validate(tc.field, tc.match, tc.pattern, tc.action, tc.tag)
+			if tc.err {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	alice, bob := uuid.New(), uuid.New()
+	feedId, otherFeedId := uuid.New(), uuid.New()
+	rule := func(userId uuid.UUID, field, match, pattern, action, tag string) database.FilterRule {
+		return database.FilterRule{
+			ID: uuid.New(), UserID: userId, Field: field, Match: match, Pattern: pattern,
+			Action: action, Tag: sql.NullString{String: tag, Valid: tag != ""},
+		}
+	}
+	scoped := rule(bob, FieldTitle, MatchContains, "hiring", ActionHide, "")
+	scoped.FeedID = uuid.NullUUID{UUID: otherFeedId, Valid: true}
+
+	rules, err := Compile([]database.FilterRule{
+		rule(alice, FieldTitle, MatchContains, "SPONSORED", ActionHide, ""),
+		rule(alice, FieldCategory, MatchRegex, `^Jobs$`, ActionTag, "jobs"),
+		rule(alice, FieldAuthor, MatchContains, "jane", ActionStar, ""),
+		rule(alice, FieldFeed, MatchContains, "changelog", ActionRead, ""),
+		rule(alice, FieldDescription, MatchContains, "nothing matches this", ActionHide, ""),
+		rule(bob, FieldCategory, MatchContains, "job", ActionTag, "work"),
+		rule(bob, FieldCategory, MatchContains, "job", ActionTag, "work"),
+		scoped,
+	})
+	require.NoError(t, err)
+
+	post := database.Post{
+		FeedID:          feedId,
+		Title:           sql.NullString{String: "Sponsored: we are hiring", Valid: true},
+		DescriptionText: sql.NullString{String: "Apply now", Valid: true},
+		Author:          sql.NullString{String: "Jane Doe", Valid: true},
+		Categories:      []string{"Jobs", "News"},
+	}
+
+	states := Evaluate(rules, NewTarget(post, "Product changelog"))
+
+	require.Equal(t, map[uuid.UUID]State{
+		alice: {Hidden: true, Read: true, Starred: true, Tags: []string{"jobs"}},
+		bob:   {Tags: []string{"work"}},
+	}, states)
+}
+
+func TestCompileInvalidRegex(t *testing.T) {
+	_, err := Compile([]database.FilterRule{{Match: MatchRegex, Pattern: "("}})
+	require.Error(t, err)
+}
+
+func TestStateParams(t *testing.T) {
+	userId, postId, now := uuid.New(), uuid.New(), time.Now()
+
+	params := State{Hidden: true}.Params(userId, postId, now)
+
+	require.Equal(t, database.UpsertPostStateParams{
+		UserID: userId, PostID: postId, CreatedAt: now, Hidden: true, Tags: []string{},
+	}, params)
+}
@@ -0,0 +1,47 @@
+package rss
+
+import (
+	"bytes"
+	"io"
+	"mime"
+
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding/unicode"
+)
+
+var (
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+	utf16LEBOM = []byte{0xFF, 0xFE}
+)
+
+// newXmlReader returns body transcoded to UTF-8 when its encoding is known
+// from a byte order mark or from the charset of contentType. In that case the
+// returned bool is true and the encoding in the XML declaration must be
+// ignored, since the reader already yields UTF-8.
+func newXmlReader(body []byte, contentType string) (io.Reader, bool) {
+	switch {
+	case bytes.HasPrefix(body, utf8BOM):
+		return bytes.NewReader(body[len(utf8BOM):]), true
+	case bytes.HasPrefix(body, utf16BEBOM), bytes.HasPrefix(body, utf16LEBOM):
+		dec := unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM).NewDecoder()
+		return dec.Reader(bytes.NewReader(body)), true
+	}
+
+	if _, params, err := mime.ParseMediaType(contentType); err == nil && params["charset"] != "" {
+		if r, err := charset.NewReaderLabel(params["charset"], bytes.NewReader(body)); err == nil {
+			return r, true
+		}
+	}
+	return bytes.NewReader(body), false
+}
+
+// charsetReader transcodes the input according to the encoding declared in
+// the XML declaration.
+func charsetReader(label string, input io.Reader) (io.Reader, error) {
+	return charset.NewReaderLabel(label, input)
+}
+
+func passthroughCharsetReader(label string, input io.Reader) (io.Reader, error) {
+	return input, nil
+}
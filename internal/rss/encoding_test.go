@@ -0,0 +1,73 @@
+package rss
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func encodedFeed(t *testing.T, enc encoding.Encoding, declared, title string) []byte {
+	t.Helper()
+	doc := `<?xml version="1.0" encoding="` + declared + `"?><rss><channel><title>` + title + `</title></channel></rss>`
+	out, err := enc.NewEncoder().Bytes([]byte(doc))
+	require.NoError(t, err)
+	return out
+}
+
+func TestParseRssEncodings(t *testing.T) {
+	cases := []struct {
+		name        string
+		body        []byte
+		contentType string
+		want        string
+	}{
+		{
+			name: "iso-8859-1 declaration",
+			body: encodedFeed(t, charmap.ISO8859_1, "ISO-8859-1", "Café à Paris"),
+			want: "Café à Paris",
+		},
+		{
+			name: "windows-1252 declaration",
+			body: encodedFeed(t, charmap.Windows1252, "windows-1252", "“Quoted” – 5€"),
+			want: "“Quoted” – 5€",
+		},
+		{
+			name: "shift_jis declaration",
+			body: encodedFeed(t, japanese.ShiftJIS, "Shift_JIS", "日本語のフィード"),
+			want: "日本語のフィード",
+		},
+		{
+			name:        "content-type charset overrides declaration",
+			body:        encodedFeed(t, japanese.EUCJP, "UTF-8", "日本語"),
+			contentType: "application/rss+xml; charset=EUC-JP",
+			want:        "日本語",
+		},
+		{
+			name:        "utf-8 content-type with a stale declaration",
+			body:        []byte(`<?xml version="1.0" encoding="ISO-8859-1"?><rss><channel><title>Café</title></channel></rss>`),
+			contentType: "text/xml; charset=utf-8",
+			want:        "Café",
+		},
+		{
+			name: "utf-8 bom",
+			body: append([]byte{0xEF, 0xBB, 0xBF}, []byte(`<?xml version="1.0" encoding="UTF-8"?><rss><channel><title>Café</title></channel></rss>`)...),
+			want: "Café",
+		},
+		{
+			name: "utf-16 bom",
+			body: encodedFeed(t, unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), "UTF-16", "Café"),
+			want: "Café",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rss, err := parseRss(c.body, c.contentType)
+			require.NoError(t, err)
+			require.Equal(t, c.want, rss.Channel.Title)
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package rss
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrBodyTooLarge = errors.New("feed body too large")
+	ErrFeedGone     = errors.New("feed is gone")
+)
+
+// Kinds of fetch errors, as returned by ErrorKind.
+const (
+	KindHTTPStatus = "http_status"
+	KindParse      = "parse"
+	KindTimeout    = "timeout"
+	KindTooLarge   = "too_large"
+	KindNetwork    = "network"
+	KindRobots     = "robots"
+	KindBackoff    = "backoff"
+)
+
+// HTTPStatusError is returned when a fetch gets a non-2xx response.
+type HTTPStatusError struct {
+	Url        string
+	StatusCode int
+	// RetryAfter is the delay asked by a 429 or 503 response, if any.
+	RetryAfter time.Duration
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d %s fetching %s", e.StatusCode, http.StatusText(e.StatusCode), e.Url)
+}
+
+func (e *HTTPStatusError) Is(target error) bool {
+	return target == ErrFeedGone && e.StatusCode == http.StatusGone
+}
+
+// Temporary reports whether the request may succeed if retried later.
+func (e *HTTPStatusError) Temporary() bool {
+	return e.StatusCode == http.StatusRequestTimeout ||
+		e.StatusCode == http.StatusTooManyRequests ||
+		e.StatusCode >= 500
+}
+
+func newHTTPStatusError(url string, resp *http.Response) *HTTPStatusError {
+	e := &HTTPStatusError{Url: url, StatusCode: resp.StatusCode}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		e.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+	return e
+}
+
+// parseRetryAfter reads a Retry-After header, given either in seconds or as
+// an HTTP date, and returns 0 when it's missing or invalid.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if sec, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if sec <= 0 {
+			return 0
+		}
+		return time.Duration(sec) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// ParseError is returned when a fetched body is not a valid feed.
+type ParseError struct {
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("invalid feed: %v", e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// TimeoutError is returned when connecting or reading timed out.
+type TimeoutError struct {
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("timeout: %v", e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// RobotsError is returned when robots.txt disallows fetching a url.
+type RobotsError struct {
+	Url string
+}
+
+func (e *RobotsError) Error() string {
+	return fmt.Sprintf("fetching %s is disallowed by robots.txt", e.Url)
+}
+
+// BackoffError is returned when a host asked, through Retry-After, not to be
+// fetched before a given time.
+type BackoffError struct {
+	Host  string
+	Until time.Time
+}
+
+func (e *BackoffError) Error() string {
+	return fmt.Sprintf("host %s asked to retry after %s", e.Host, e.Until.Format(time.RFC3339))
+}
+
+// TooLargeError is returned when a body exceeds the configured limit.
+type TooLargeError struct {
+	Limit int64
+	// Size is the announced Content-Length, or 0 if the limit was hit while reading.
+	Size int64
+}
+
+func (e *TooLargeError) Error() string {
+	if e.Size > 0 {
+		return fmt.Sprintf("%v: %d bytes exceeds the limit of %d bytes", ErrBodyTooLarge, e.Size, e.Limit)
+	}
+	return fmt.Sprintf("%v: exceeds the limit of %d bytes", ErrBodyTooLarge, e.Limit)
+}
+
+func (e *TooLargeError) Is(target error) bool {
+	return target == ErrBodyTooLarge
+}
+
+// wrapNetError turns network timeouts into a TimeoutError.
+func wrapNetError(err error) error {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return &TimeoutError{Err: err}
+	}
+	return err
+}
+
+// ErrorKind classifies a fetch error into one of the Kind constants.
+func ErrorKind(err error) string {
+	var statusErr *HTTPStatusError
+	var parseErr *ParseError
+	var timeoutErr *TimeoutError
+	var tooLargeErr *TooLargeError
+	var robotsErr *RobotsError
+	var backoffErr *BackoffError
+	switch {
+	case errors.As(err, &statusErr):
+		return KindHTTPStatus
+	case errors.As(err, &parseErr):
+		return KindParse
+	case errors.As(err, &timeoutErr):
+		return KindTimeout
+	case errors.As(err, &tooLargeErr):
+		return KindTooLarge
+	case errors.As(err, &robotsErr):
+		return KindRobots
+	case errors.As(err, &backoffErr):
+		return KindBackoff
+	default:
+		return KindNetwork
+	}
+}
+
+// StatusCode returns the HTTP status of err, or 0 if it isn't an HTTPStatusError.
+func StatusCode(err error) int {
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode
+	}
+	return 0
+}
+
+const (
+	maxTemporaryBackoff = 6 * time.Hour
+	minPermanentBackoff = time.Hour
+	maxPermanentBackoff = 24 * time.Hour
+)
+
+// RetryDelay returns how long to wait before fetching again a feed that has
+// failed failures consecutive times, the last one with err. A delay asked by
+// the server through Retry-After is honored; otherwise errors that may be
+// transient back off exponentially from frequency, while errors that need
+// the publisher to fix the feed back off from an hour.
+func RetryDelay(err error, failures int32, frequency time.Duration) time.Duration {
+	base, limit := frequency, maxTemporaryBackoff
+	var statusErr *HTTPStatusError
+	var backoffErr *BackoffError
+	switch ErrorKind(err) {
+	case KindHTTPStatus:
+		errors.As(err, &statusErr)
+		if statusErr.RetryAfter > 0 {
+			return min(statusErr.RetryAfter, maxPermanentBackoff)
+		}
+		if !statusErr.Temporary() {
+			base, limit = minPermanentBackoff, maxPermanentBackoff
+		}
+	case KindBackoff:
+		errors.As(err, &backoffErr)
+		return min(max(time.Until(backoffErr.Until), frequency), maxPermanentBackoff)
+	case KindParse, KindRobots:
+		base, limit = minPermanentBackoff, maxPermanentBackoff
+	case KindTooLarge:
+		return maxPermanentBackoff
+	}
+
+	if failures < 1 {
+		failures = 1
+	}
+	delay := float64(base) * math.Pow(2, float64(failures-1))
+	if delay > float64(limit) {
+		return limit
+	}
+	return time.Duration(delay)
+}
@@ -0,0 +1,89 @@
+package rss
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadRssTypedErrors(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("<html><body>Not found</body></html>"))
+	})
+	mux.HandleFunc("/html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><body>not a feed</body></html>"))
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	cfg := testFetcherConfig()
+	cfg.ReadTimeout = 50 * time.Millisecond
+	fetcher := NewFetcher(cfg)
+
+	t.Run("http status", func(t *testing.T) {
+		_, err := fetcher.ReadRss(srv.URL+"/missing", "")
+		var statusErr *HTTPStatusError
+		require.ErrorAs(t, err, &statusErr)
+		require.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+		require.Equal(t, KindHTTPStatus, ErrorKind(err))
+		require.Equal(t, http.StatusNotFound, StatusCode(err))
+	})
+
+	t.Run("parse", func(t *testing.T) {
+		_, err := fetcher.ReadRss(srv.URL+"/html", "")
+		var parseErr *ParseError
+		require.ErrorAs(t, err, &parseErr)
+		require.Equal(t, KindParse, ErrorKind(err))
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		_, err := fetcher.ReadRss(srv.URL+"/slow", "")
+		var timeoutErr *TimeoutError
+		require.ErrorAs(t, err, &timeoutErr)
+		require.Equal(t, KindTimeout, ErrorKind(err))
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		cfg := testFetcherConfig()
+		cfg.MaxBodySize = 8
+		_, err := NewFetcher(cfg).ReadRss(srv.URL+"/html", "")
+		var tooLargeErr *TooLargeError
+		require.ErrorAs(t, err, &tooLargeErr)
+		require.ErrorIs(t, err, ErrBodyTooLarge)
+		require.Equal(t, KindTooLarge, ErrorKind(err))
+	})
+}
+
+func TestRetryDelay(t *testing.T) {
+	frequency := time.Minute
+	unavailable := &HTTPStatusError{StatusCode: http.StatusServiceUnavailable}
+	notFound := &HTTPStatusError{StatusCode: http.StatusNotFound}
+	timeout := &TimeoutError{Err: context.DeadlineExceeded}
+
+	require.Equal(t, time.Minute, RetryDelay(unavailable, 1, frequency))
+	require.Equal(t, 4*time.Minute, RetryDelay(timeout, 3, frequency))
+	require.Equal(t, maxTemporaryBackoff, RetryDelay(unavailable, 20, frequency))
+	require.Equal(t, time.Hour, RetryDelay(notFound, 1, frequency))
+	require.Equal(t, 2*time.Hour, RetryDelay(&ParseError{Err: errors.New("eof")}, 2, frequency))
+	require.Equal(t, maxPermanentBackoff, RetryDelay(notFound, 10, frequency))
+	require.Equal(t, maxPermanentBackoff, RetryDelay(fmt.Errorf("wrapped: %w", &TooLargeError{Limit: 1}), 1, frequency))
+	require.Equal(t, 2*time.Hour, RetryDelay(&RobotsError{Url: "http://example.com/feed"}, 2, frequency))
+	require.Equal(t, frequency, RetryDelay(&BackoffError{Until: time.Now()}, 1, frequency))
+	require.Equal(t, maxPermanentBackoff, RetryDelay(&BackoffError{Until: time.Now().Add(72 * time.Hour)}, 1, frequency))
+	require.Equal(t, 90*time.Second, RetryDelay(&HTTPStatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: 90 * time.Second}, 5, frequency))
+}
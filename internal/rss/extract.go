@@ -0,0 +1,220 @@
+package rss
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"mime"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const maxArticleSize = 5 << 20
+
+var ErrNotHTML = errors.New("article is not an html document")
+
+var (
+	unlikelyCandidates = regexp.MustCompile(`(?i)banner|breadcrumb|combx|comment|community|cookie|disqus|extra|footer|header|menu|modal|nav|popup|promo|related|remark|replies|rss|share|shoutbox|sidebar|skyscraper|social|sponsor|subscribe|ad-break|agegate|pagination|pager`)
+	maybeCandidate     = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow`)
+	positiveHints      = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|main|page|post|text|blog|story`)
+	negativeHints      = regexp.MustCompile(`(?i)hidden|banner|combx|comment|com-|contact|foot|footer|footnote|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|tool|widget`)
+)
+
+// FetchArticle downloads the page at url and returns its main content as
+// sanitized HTML. Responses that are not HTML are rejected with ErrNotHTML
+// before their body is read.
+func (f *Fetcher) FetchArticle(url, proxyUrl string) (string, error) {
+	resp, err := f.open(url, proxyUrl)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", newHTTPStatusError(url, resp)
+	}
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || (mediaType != "text/html" && mediaType != "application/xhtml+xml") {
+		return "", ErrNotHTML
+	}
+
+	body, err := readBody(resp, maxArticleSize)
+	if err != nil {
+		return "", err
+	}
+	return ExtractContent(body, resp.Request.URL.String())
+}
+
+// ExtractContent finds the main content of an HTML page with a
+// readability-style scoring of its paragraphs, and returns it sanitized
+// with links resolved against base.
+func ExtractContent(page []byte, base string) (string, error) {
+	doc, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return "", err
+	}
+
+	body := findElement(doc, atom.Body)
+	if body == nil {
+		return "", errors.New("article has no body")
+	}
+	removeUnlikely(body)
+
+	scores := map[*html.Node]float64{}
+	var candidates []*html.Node
+	addScore := func(n *html.Node, score float64) {
+		if n == nil || n.Type != html.ElementNode {
+			return
+		}
+		if _, ok := scores[n]; !ok {
+			scores[n] = initialScore(n)
+			candidates = append(candidates, n)
+		}
+		scores[n] += score
+	}
+
+	walk(body, func(n *html.Node) {
+		switch n.DataAtom {
+		case atom.P, atom.Pre, atom.Td, atom.Blockquote:
+		default:
+			return
+		}
+		text := strings.TrimSpace(textContent(n))
+		if len(text) < 25 {
+			return
+		}
+		score := 1 + float64(strings.Count(text, ",")) + math.Min(float64(len(text))/100, 3)
+		addScore(n.Parent, score)
+		if n.Parent != nil {
+			addScore(n.Parent.Parent, score/2)
+		}
+	})
+
+	var top *html.Node
+	var topScore float64
+	for _, c := range candidates {
+		score := scores[c] * (1 - linkDensity(c))
+		if top == nil || score > topScore {
+			top, topScore = c, score
+		}
+	}
+	if top == nil {
+		top = body
+	}
+
+	var buf bytes.Buffer
+	for c := top.FirstChild; c != nil; c = c.NextSibling {
+		if err := html.Render(&buf, c); err != nil {
+			return "", err
+		}
+	}
+	content := SanitizeHTML(buf.String(), base)
+	if content == "" {
+		return "", errors.New("no content found in article")
+	}
+	return content, nil
+}
+
+func initialScore(n *html.Node) float64 {
+	var score float64
+	switch n.DataAtom {
+	case atom.Article:
+		score = 10
+	case atom.Div:
+		score = 5
+	case atom.Pre, atom.Td, atom.Blockquote:
+		score = 3
+	case atom.Address, atom.Ol, atom.Ul, atom.Dl, atom.Dd, atom.Dt, atom.Li, atom.Form:
+		score = -3
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Th:
+		score = -5
+	}
+	hints := getAttribute(n, "class") + " " + getAttribute(n, "id")
+	if negativeHints.MatchString(hints) {
+		score -= 25
+	}
+	if positiveHints.MatchString(hints) {
+		score += 25
+	}
+	return score
+}
+
+// removeUnlikely detaches the elements that can't be part of the main content.
+func removeUnlikely(root *html.Node) {
+	var remove []*html.Node
+	walk(root, func(n *html.Node) {
+		switch n.DataAtom {
+		case atom.Nav, atom.Header, atom.Footer, atom.Aside, atom.Form:
+			remove = append(remove, n)
+			return
+		case atom.Body, atom.Article, atom.Main:
+			return
+		}
+		if droppedElements[n.DataAtom] {
+			remove = append(remove, n)
+			return
+		}
+		hints := getAttribute(n, "class") + " " + getAttribute(n, "id")
+		if unlikelyCandidates.MatchString(hints) && !maybeCandidate.MatchString(hints) {
+			remove = append(remove, n)
+		}
+	})
+	for _, n := range remove {
+		if n.Parent != nil {
+			n.Parent.RemoveChild(n)
+		}
+	}
+}
+
+func linkDensity(n *html.Node) float64 {
+	total := len(strings.TrimSpace(textContent(n)))
+	if total == 0 {
+		return 0
+	}
+	var links int
+	walk(n, func(c *html.Node) {
+		if c.DataAtom == atom.A {
+			links += len(strings.TrimSpace(textContent(c)))
+		}
+	})
+	return float64(links) / float64(total)
+}
+
+func walk(n *html.Node, fn func(*html.Node)) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode {
+			fn(c)
+		}
+		walk(c, fn)
+	}
+}
+
+func findElement(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func textContent(n *html.Node) string {
+	var sb strings.Builder
+	collectText(&sb, n)
+	return sb.String()
+}
+
+func getAttribute(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
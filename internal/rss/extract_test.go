@@ -0,0 +1,54 @@
+package rss
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractContent(t *testing.T) {
+	page, err := os.ReadFile("testdata/article.html")
+	require.NoError(t, err)
+
+	content, err := ExtractContent(page, "http://example.com/posts/indexes")
+
+	require.NoError(t, err)
+	require.Contains(t, content, "A B-tree index supports equality")
+	require.Contains(t, content, `<a href="http://example.com/docs/indexes"`)
+	require.Contains(t, content, `<img src="http://example.com/posts/images/btree.png"`)
+	require.NotContains(t, content, "newsletter")
+	require.NotContains(t, content, "Great article")
+	require.NotContains(t, content, "Copyright")
+	require.NotContains(t, content, "tracker")
+}
+
+func TestFetchArticle(t *testing.T) {
+	page, err := os.ReadFile("testdata/article.html")
+	require.NoError(t, err)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/article":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write(page)
+		case "/episode.mp3":
+			w.Header().Set("Content-Type", "audio/mpeg")
+			w.Write([]byte("ID3"))
+		}
+	}))
+	defer srv.Close()
+	fetcher := NewFetcher(testFetcherConfig())
+
+	t.Run("extracts html", func(t *testing.T) {
+		content, err := fetcher.FetchArticle(srv.URL+"/article", "")
+		require.NoError(t, err)
+		require.Contains(t, content, "Indexes are the first tool")
+	})
+
+	t.Run("skips non html", func(t *testing.T) {
+		_, err := fetcher.FetchArticle(srv.URL+"/episode.mp3", "")
+		require.ErrorIs(t, err, ErrNotHTML)
+	})
+}
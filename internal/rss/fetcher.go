@@ -0,0 +1,298 @@
+package rss
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+const DefaultUserAgent = "bloggogrator/1.0 (+https://github.com/sp3dr4/bloggogrator)"
+
+type FetcherConfig struct {
+	// UserAgent identifies the fetcher to the remote hosts.
+	UserAgent string
+	// ConnectTimeout bounds dialing and the TLS handshake.
+	ConnectTimeout time.Duration
+	// ReadTimeout bounds waiting for and reading the response.
+	ReadTimeout time.Duration
+	// MaxRedirects is the number of redirects followed before giving up.
+	MaxRedirects int
+	// MaxBodySize is the largest decoded feed body accepted.
+	MaxBodySize int64
+	// HostInterval is the minimum delay between two requests to the same host.
+	HostInterval time.Duration
+	// RespectRobots enables the robots.txt checks.
+	RespectRobots bool
+}
+
+func DefaultFetcherConfig() FetcherConfig {
+	return FetcherConfig{
+		UserAgent:      DefaultUserAgent,
+		ConnectTimeout: 10 * time.Second,
+		ReadTimeout:    30 * time.Second,
+		MaxRedirects:   5,
+		MaxBodySize:    DefaultMaxBodySize,
+		HostInterval:   time.Second,
+		RespectRobots:  true,
+	}
+}
+
+// Fetcher is the HTTP client shared by every fetch path of the worker. It
+// identifies itself with a User-Agent, enforces timeouts, a redirect limit
+// and a body size limit, decodes compressed responses, spaces out requests
+// to the same host, honors robots.txt and the Retry-After of 429 and 503
+// responses.
+type Fetcher struct {
+	cfg     FetcherConfig
+	limiter *hostLimiter
+	robots  *robotsCache
+
+	mu      sync.Mutex
+	clients map[string]*http.Client
+	// backoff holds the hosts that asked not to be fetched until a given time.
+	backoff map[string]time.Time
+}
+
+func NewFetcher(cfg FetcherConfig) *Fetcher {
+	return &Fetcher{
+		cfg:     cfg,
+		limiter: newHostLimiter(cfg.HostInterval),
+		robots:  newRobotsCache(),
+		clients: map[string]*http.Client{},
+		backoff: map[string]time.Time{},
+	}
+}
+
+func (f *Fetcher) hostBackoff(host string) (time.Time, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	until, ok := f.backoff[host]
+	if ok && time.Now().After(until) {
+		delete(f.backoff, host)
+		return time.Time{}, false
+	}
+	return until, ok
+}
+
+func (f *Fetcher) setHostBackoff(host string, until time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if until.After(f.backoff[host]) {
+		f.backoff[host] = until
+	}
+}
+
+// client returns the http client going through proxyUrl, or through the
+// HTTP_PROXY/HTTPS_PROXY environment when proxyUrl is empty.
+func (f *Fetcher) client(proxyUrl string) (*http.Client, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if c, ok := f.clients[proxyUrl]; ok {
+		return c, nil
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if proxyUrl != "" {
+		u, err := ParseProxyUrl(proxyUrl)
+		if err != nil {
+			return nil, err
+		}
+		proxy = http.ProxyURL(u)
+	}
+
+	dialer := &net.Dialer{Timeout: f.cfg.ConnectTimeout, KeepAlive: 30 * time.Second}
+	c := &http.Client{
+		Timeout: f.cfg.ConnectTimeout + f.cfg.ReadTimeout,
+		Transport: &http.Transport{
+			Proxy:                 proxy,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   f.cfg.ConnectTimeout,
+			ResponseHeaderTimeout: f.cfg.ReadTimeout,
+			IdleConnTimeout:       90 * time.Second,
+			MaxIdleConns:          100,
+			DisableCompression:    true,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > f.cfg.MaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", f.cfg.MaxRedirects)
+			}
+			return nil
+		},
+	}
+	f.clients[proxyUrl] = c
+	return c, nil
+}
+
+// ParseProxyUrl validates a per-feed proxy url.
+func ParseProxyUrl(proxyUrl string) (*url.URL, error) {
+	u, err := url.Parse(proxyUrl)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, errors.New("proxy url has no host")
+	}
+	return u, nil
+}
+
+// open sends a GET request for url and returns the response with its body
+// already decoded according to the Content-Encoding. It fails with a
+// BackoffError while the host is backing off and with a RobotsError when
+// robots.txt disallows url.
+func (f *Fetcher) open(url, proxyUrl string) (*http.Response, error) {
+	client, err := f.client(proxyUrl)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.cfg.UserAgent)
+	req.Header.Set("Accept-Encoding", "gzip, deflate, br")
+
+	if until, ok := f.hostBackoff(req.URL.Host); ok {
+		return nil, &BackoffError{Host: req.URL.Host, Until: until}
+	}
+	if f.cfg.RespectRobots && !f.robotsAllowed(client, req.URL) {
+		return nil, &RobotsError{Url: url}
+	}
+
+	f.limiter.Wait(req.URL.Host)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, wrapNetError(err)
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		if delay := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); delay > 0 {
+			f.setHostBackoff(resp.Request.URL.Host, time.Now().Add(delay))
+		}
+	}
+
+	if err := decodeBody(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+// permanentRedirectTarget returns the url reached through the leading chain
+// of permanent (301/308) redirects that produced resp and the status of the
+// last of them, or "" if the first hop wasn't permanent.
+func permanentRedirectTarget(resp *http.Response) (string, int) {
+	var chain []*http.Request
+	for req := resp.Request; req != nil; {
+		chain = append(chain, req)
+		if req.Response == nil {
+			break
+		}
+		req = req.Response.Request
+	}
+
+	var target string
+	var status int
+	for i := len(chain) - 1; i > 0; i-- {
+		code := chain[i-1].Response.StatusCode
+		if code != http.StatusMovedPermanently && code != http.StatusPermanentRedirect {
+			break
+		}
+		target, status = chain[i-1].URL.String(), code
+	}
+	return target, status
+}
+
+type decodedBody struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (b *decodedBody) Close() error {
+	var errs []error
+	for _, c := range b.closers {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func decodeBody(resp *http.Response) error {
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	var r io.Reader
+	closers := []io.Closer{resp.Body}
+	switch encoding {
+	case "", "identity":
+		return nil
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return err
+		}
+		r = gz
+		closers = append(closers, gz)
+	case "deflate":
+		// deflate is meant to be zlib-wrapped, but some servers send raw deflate.
+		buffered := bufio.NewReader(resp.Body)
+		if header, err := buffered.Peek(2); err == nil && isZlibHeader(header) {
+			zr, err := zlib.NewReader(buffered)
+			if err != nil {
+				return err
+			}
+			r = zr
+			closers = append(closers, zr)
+		} else {
+			fr := flate.NewReader(buffered)
+			r = fr
+			closers = append(closers, fr)
+		}
+	case "br":
+		r = brotli.NewReader(resp.Body)
+	default:
+		return fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+
+	resp.Body = &decodedBody{Reader: r, closers: closers}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+// isZlibHeader reports whether the first two bytes of a body are a zlib
+// header (RFC 1950): deflate compression and a valid check value.
+func isZlibHeader(b []byte) bool {
+	return b[0]&0x0f == 8 && (uint16(b[0])<<8|uint16(b[1]))%31 == 0
+}
+
+func readBody(resp *http.Response, maxBodySize int64) ([]byte, error) {
+	if resp.ContentLength > maxBodySize {
+		return nil, &TooLargeError{Limit: maxBodySize, Size: resp.ContentLength}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize+1))
+	if err != nil {
+		return nil, wrapNetError(err)
+	}
+	if int64(len(body)) > maxBodySize {
+		return nil, &TooLargeError{Limit: maxBodySize}
+	}
+	return body, nil
+}
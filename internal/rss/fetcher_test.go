@@ -0,0 +1,163 @@
+package rss
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/require"
+)
+
+func compress(t *testing.T, encoding string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	switch encoding {
+	case "gzip":
+		w := gzip.NewWriter(&buf)
+		w.Write(data)
+		require.NoError(t, w.Close())
+	case "deflate":
+		w := zlib.NewWriter(&buf)
+		w.Write(data)
+		require.NoError(t, w.Close())
+	case "br":
+		w := brotli.NewWriter(&buf)
+		w.Write(data)
+		require.NoError(t, w.Close())
+	}
+	return buf.Bytes()
+}
+
+func TestFetcherDecodesCompressedBodies(t *testing.T) {
+	for _, encoding := range []string{"gzip", "deflate", "br"} {
+		t.Run(encoding, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Contains(t, r.Header.Get("Accept-Encoding"), encoding)
+				w.Header().Set("Content-Encoding", encoding)
+				w.Write(compress(t, encoding, []byte(podcastFeed)))
+			}))
+			defer srv.Close()
+
+			result, err := NewFetcher(testFetcherConfig()).ReadRss(srv.URL, "")
+
+			require.NoError(t, err)
+			require.Equal(t, "Podcast", result.Feed.Channel.Title)
+		})
+	}
+}
+
+func TestFetcherDecodesRawDeflate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		fw, err := flate.NewWriter(&buf, flate.DefaultCompression)
+		require.NoError(t, err)
+		fw.Write([]byte(podcastFeed))
+		require.NoError(t, fw.Close())
+		w.Header().Set("Content-Encoding", "deflate")
+		w.Write(buf.Bytes())
+	}))
+	defer srv.Close()
+
+	result, err := NewFetcher(testFetcherConfig()).ReadRss(srv.URL, "")
+
+	require.NoError(t, err)
+	require.Equal(t, "Podcast", result.Feed.Channel.Title)
+}
+
+func TestFetcherSendsUserAgent(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("User-Agent")
+		w.Write([]byte(podcastFeed))
+	}))
+	defer srv.Close()
+	cfg := testFetcherConfig()
+	cfg.UserAgent = "test-agent/1.0"
+
+	_, err := NewFetcher(cfg).ReadRss(srv.URL, "")
+
+	require.NoError(t, err)
+	require.Equal(t, "test-agent/1.0", got)
+}
+
+func TestFetcherMaxRedirects(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	}))
+	defer srv.Close()
+	cfg := testFetcherConfig()
+	cfg.MaxRedirects = 2
+
+	_, err := NewFetcher(cfg).ReadRss(srv.URL, "")
+
+	require.ErrorContains(t, err, "stopped after 2 redirects")
+}
+
+func TestFetcherPerFeedProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		w.Write([]byte(podcastFeed))
+	}))
+	defer proxy.Close()
+
+	result, err := NewFetcher(testFetcherConfig()).ReadRss("http://feeds.example.com/podcast.xml", proxy.URL)
+
+	require.NoError(t, err)
+	require.Equal(t, "Podcast", result.Feed.Channel.Title)
+	require.Equal(t, "http://feeds.example.com/podcast.xml", proxied)
+}
+
+func TestReadRssRedirects(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/newest", http.StatusPermanentRedirect)
+	})
+	mux.HandleFunc("/newest", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(podcastFeed))
+	})
+	mux.HandleFunc("/temporary", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/old", http.StatusFound)
+	})
+	mux.HandleFunc("/gone", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	fetcher := NewFetcher(testFetcherConfig())
+
+	t.Run("follows permanent redirects", func(t *testing.T) {
+		result, err := fetcher.ReadRss(srv.URL+"/old", "")
+		require.NoError(t, err)
+		require.Equal(t, srv.URL+"/newest", result.MovedTo)
+		require.Equal(t, http.StatusPermanentRedirect, result.MovedStatus)
+	})
+
+	t.Run("ignores chains starting with a temporary redirect", func(t *testing.T) {
+		result, err := fetcher.ReadRss(srv.URL+"/temporary", "")
+		require.NoError(t, err)
+		require.Empty(t, result.MovedTo)
+	})
+
+	t.Run("reports gone feeds", func(t *testing.T) {
+		_, err := fetcher.ReadRss(srv.URL+"/gone", "")
+		require.ErrorIs(t, err, ErrFeedGone)
+	})
+}
+
+// testFetcherConfig doesn't space requests nor check robots.txt, so test
+// servers don't need to serve one.
+func testFetcherConfig() FetcherConfig {
+	cfg := DefaultFetcherConfig()
+	cfg.HostInterval = 0
+	cfg.RespectRobots = false
+	return cfg
+}
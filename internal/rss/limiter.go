@@ -0,0 +1,31 @@
+package rss
+
+import (
+	"sync"
+	"time"
+)
+
+// hostLimiter spaces out requests to the same host by a minimum interval.
+type hostLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     map[string]time.Time
+}
+
+func newHostLimiter(interval time.Duration) *hostLimiter {
+	return &hostLimiter{interval: interval, next: map[string]time.Time{}}
+}
+
+// Wait blocks until a request to host is allowed.
+func (l *hostLimiter) Wait(host string) {
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next[host]
+	if at.Before(now) {
+		at = now
+	}
+	l.next[host] = at.Add(l.interval)
+	l.mu.Unlock()
+
+	time.Sleep(time.Until(at))
+}
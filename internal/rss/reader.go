@@ -2,8 +2,26 @@ package rss
 
 import (
 	"encoding/xml"
-	"io"
-	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultMaxBodySize is the feed body size limit used when none is configured.
+	DefaultMaxBodySize int64 = 10 << 20
+
+	// maxTitleLength matches the posts.title column size.
+	maxTitleLength = 255
+	// maxDescriptionLength caps the raw description before sanitization.
+	maxDescriptionLength = 64 << 10
+	// maxAuthorLength matches the posts.author column size.
+	maxAuthorLength = 255
+	// maxCategories and maxCategoryLength cap the categories stored per post.
+	maxCategories     = 32
+	maxCategoryLength = 255
+	// maxEnclosureTypeLength matches the post_episodes.enclosure_type column size.
+	maxEnclosureTypeLength = 255
 )
 
 type RSS struct {
@@ -14,38 +32,182 @@ type RSS struct {
 type Channel struct {
 	Title       string `xml:"title"`
 	Description string `xml:"description"`
-	Link        string `xml:"link"`
-	Items       []Item `xml:"item"`
+	// AtomLinks precedes Link so that atom:link elements don't match it.
+	AtomLinks []AtomLink `xml:"http://www.w3.org/2005/Atom link"`
+	Link      string     `xml:"link"`
+	Items     []Item     `xml:"item"`
 }
 
 type Item struct {
-	Title       string `xml:"title"`
-	Description string `xml:"description"`
-	Link        string `xml:"link"`
-	PubDate     string `xml:"pubDate"`
+	Title          string      `xml:"title"`
+	Description    string      `xml:"description"`
+	Link           string      `xml:"link"`
+	PubDate        string      `xml:"pubDate"`
+	Author         string      `xml:"author"`
+	Creator        string      `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Categories     []string    `xml:"category"`
+	Enclosure      *Enclosure  `xml:"enclosure"`
+	ItunesDuration string      `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+	ItunesImage    ItunesImage `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd image"`
+	ItunesEpisode  string      `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd episode"`
+	ItunesSeason   string      `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd season"`
+	ItunesExplicit string      `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd explicit"`
+}
+
+type Enclosure struct {
+	Url    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+type ItunesImage struct {
+	Href string `xml:"href,attr"`
+}
+
+// Episode is the podcast metadata of an item, normalized from the enclosure
+// and the iTunes namespace. Fields that are missing or unparsable are nil.
+type Episode struct {
+	EnclosureUrl    string
+	EnclosureType   string
+	EnclosureLength *int64
+	DurationSeconds *int32
+	ImageUrl        *string
+	Episode         *int32
+	Season          *int32
+	Explicit        *bool
+}
+
+// Episode returns the podcast metadata of the item, or nil if it has no enclosure.
+func (i Item) Episode() *Episode {
+	if i.Enclosure == nil || strings.TrimSpace(i.Enclosure.Url) == "" {
+		return nil
+	}
+	ep := &Episode{
+		EnclosureUrl:    strings.TrimSpace(i.Enclosure.Url),
+		EnclosureType:   truncate(strings.ToLower(strings.TrimSpace(i.Enclosure.Type)), maxEnclosureTypeLength),
+		DurationSeconds: parseDuration(i.ItunesDuration),
+		Episode:         parseInt32(i.ItunesEpisode),
+		Season:          parseInt32(i.ItunesSeason),
+		Explicit:        parseExplicit(i.ItunesExplicit),
+	}
+	if n, err := strconv.ParseInt(strings.TrimSpace(i.Enclosure.Length), 10, 64); err == nil && n > 0 {
+		ep.EnclosureLength = &n
+	}
+	if href := strings.TrimSpace(i.ItunesImage.Href); href != "" {
+		ep.ImageUrl = &href
+	}
+	return ep
+}
+
+// parseDuration accepts the formats allowed by itunes:duration: plain seconds,
+// MM:SS or HH:MM:SS.
+func parseDuration(s string) *int32 {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return nil
+	}
+	var total int64
+	for _, p := range parts {
+		n, err := strconv.ParseInt(p, 10, 32)
+		if err != nil || n < 0 {
+			return nil
+		}
+		total = total*60 + n
+	}
+	if total > 1<<31-1 {
+		return nil
+	}
+	d := int32(total)
+	return &d
 }
 
-func getRssBody(url string) ([]byte, error) {
-	resp, err := http.Get(url)
+func parseInt32(s string) *int32 {
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 32)
 	if err != nil {
+		return nil
+	}
+	v := int32(n)
+	return &v
+}
+
+func parseExplicit(s string) *bool {
+	var v bool
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "yes", "true", "explicit":
+		v = true
+	case "no", "false", "clean":
+		v = false
+	default:
+		return nil
+	}
+	return &v
+}
+
+// truncate shortens s to at most max characters, cutting on a rune boundary
+// and marking the cut with an ellipsis.
+func truncate(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	runes := []rune(s)
+	return strings.TrimSpace(string(runes[:max-1])) + "…"
+}
+
+// parseRss decodes an RSS document, honoring a byte order mark, the charset
+// of contentType and the encoding of the XML declaration, in that order.
+func parseRss(body []byte, contentType string) (*RSS, error) {
+	r, transcoded := newXmlReader(body, contentType)
+	decoder := xml.NewDecoder(r)
+	decoder.CharsetReader = charsetReader
+	if transcoded {
+		decoder.CharsetReader = passthroughCharsetReader
+	}
+
+	var rss RSS
+	if err := decoder.Decode(&rss); err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	return &rss, nil
+}
 
-	return io.ReadAll(resp.Body)
+type FetchResult struct {
+	Feed       *RSS
+	StatusCode int
+	Bytes      int64
+	// MovedTo is the url the feed permanently redirected to, if any, and
+	// MovedStatus the status code of that redirect.
+	MovedTo     string
+	MovedStatus int
 }
 
-func ReadRss(url string) (*RSS, error) {
-	body, err := getRssBody(url)
+// ReadRss fetches and parses the feed at url, through proxyUrl if not empty.
+// Failures are reported as HTTPStatusError, ParseError, TimeoutError or
+// TooLargeError; a 410 response also matches ErrFeedGone.
+func (f *Fetcher) ReadRss(url, proxyUrl string) (*FetchResult, error) {
+	resp, err := f.open(url, proxyUrl)
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
 
-	var rss RSS
-	err = xml.Unmarshal(body, &rss)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, newHTTPStatusError(url, resp)
+	}
+
+	body, err := readBody(resp, f.cfg.MaxBodySize)
 	if err != nil {
 		return nil, err
 	}
 
-	return &rss, nil
+	feed, err := parseRss(body, resp.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, &ParseError{Err: err}
+	}
+	result := &FetchResult{Feed: feed, StatusCode: resp.StatusCode, Bytes: int64(len(body))}
+	result.MovedTo, result.MovedStatus = permanentRedirectTarget(resp)
+	return result, nil
 }
@@ -0,0 +1,133 @@
+package rss
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/require"
+)
+
+const podcastFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd">
+<channel>
+  <title>Podcast</title>
+  <item>
+    <title>Episode 1</title>
+    <link>http://example.com/ep1</link>
+    <enclosure url="http://example.com/ep1.mp3" type="audio/mpeg" length="1234"/>
+    <itunes:duration>1:02:03</itunes:duration>
+    <itunes:image href="http://example.com/ep1.jpg"/>
+    <itunes:episode>1</itunes:episode>
+    <itunes:season>2</itunes:season>
+    <itunes:explicit>no</itunes:explicit>
+  </item>
+  <item>
+    <title>Blog post</title>
+    <link>http://example.com/post</link>
+  </item>
+</channel>
+</rss>`
+
+func TestItemEpisode(t *testing.T) {
+	var rss RSS
+	require.NoError(t, xml.Unmarshal([]byte(podcastFeed), &rss))
+	require.Len(t, rss.Channel.Items, 2)
+
+	ep := rss.Channel.Items[0].Episode()
+	require.NotNil(t, ep)
+	require.Equal(t, "http://example.com/ep1.mp3", ep.EnclosureUrl)
+	require.Equal(t, "audio/mpeg", ep.EnclosureType)
+	require.Equal(t, int64(1234), *ep.EnclosureLength)
+	require.Equal(t, int32(3723), *ep.DurationSeconds)
+	require.Equal(t, "http://example.com/ep1.jpg", *ep.ImageUrl)
+	require.Equal(t, int32(1), *ep.Episode)
+	require.Equal(t, int32(2), *ep.Season)
+	require.False(t, *ep.Explicit)
+
+	require.Nil(t, rss.Channel.Items[1].Episode())
+}
+
+func TestParseDuration(t *testing.T) {
+	cases := map[string]*int32{
+		"90":      ptr(int32(90)),
+		"01:30":   ptr(int32(90)),
+		"1:01:30": ptr(int32(3690)),
+		"":        nil,
+		"abc":     nil,
+		"1:2:3:4": nil,
+	}
+	for in, want := range cases {
+		require.Equal(t, want, parseDuration(in), in)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestReadRssBodyLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(podcastFeed))
+	}))
+	defer srv.Close()
+
+	cfg := testFetcherConfig()
+	cfg.MaxBodySize = 64
+	_, err := NewFetcher(cfg).ReadRss(srv.URL, "")
+	require.ErrorIs(t, err, ErrBodyTooLarge)
+
+	result, err := NewFetcher(testFetcherConfig()).ReadRss(srv.URL, "")
+	require.NoError(t, err)
+	require.Equal(t, "Podcast", result.Feed.Channel.Title)
+}
+
+func TestSanitizeTruncatesOversizedItems(t *testing.T) {
+	item := Item{
+		Title:       strings.Repeat("é", 300),
+		Description: "<p>" + strings.Repeat("a", maxDescriptionLength+10) + "</p>",
+		Link:        "http://example.com/post",
+	}
+
+	clean := item.Sanitize()
+
+	require.Equal(t, maxTitleLength, utf8.RuneCountInString(clean.Title))
+	require.True(t, strings.HasSuffix(clean.Title, "…"))
+	require.Less(t, len(clean.Description), maxDescriptionLength+10)
+	require.True(t, strings.HasSuffix(clean.Description, "</p>"))
+}
+
+func TestItemAuthorAndCategories(t *testing.T) {
+	const feed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel>
+  <item>
+    <title>Hiring</title>
+    <dc:creator><![CDATA[Jane <b>Doe</b>]]></dc:creator>
+    <category>Jobs</category>
+    <category> Sponsored </category>
+    <category>Jobs</category>
+    <category></category>
+  </item>
+  <item>
+    <title>Post</title>
+    <author>john@example.com (John)</author>
+    <dc:creator>Ignored</dc:creator>
+  </item>
+</channel>
+</rss>`
+	var rss RSS
+	require.NoError(t, xml.Unmarshal([]byte(feed), &rss))
+	require.Len(t, rss.Channel.Items, 2)
+
+	first := rss.Channel.Items[0].Sanitize()
+	require.Equal(t, "Jane Doe", first.Author)
+	require.Equal(t, []string{"Jobs", "Sponsored"}, first.Categories)
+
+	second := rss.Channel.Items[1].Sanitize()
+	require.Equal(t, "john@example.com (John)", second.Author)
+	require.Nil(t, second.Categories)
+}
@@ -0,0 +1,204 @@
+package rss
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxRobotsSize = 500 << 10
+	robotsTTL     = 24 * time.Hour
+	// robotsErrorTTL is how long an unreachable robots.txt keeps its host
+	// disallowed before being fetched again.
+	robotsErrorTTL = time.Hour
+)
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+	re      *regexp.Regexp
+}
+
+// robotsRules are the rules of a robots.txt that apply to one user agent.
+type robotsRules struct {
+	rules      []robotsRule
+	disallowed bool
+}
+
+var allowAll = &robotsRules{}
+var disallowAll = &robotsRules{disallowed: true}
+
+// Allowed reports whether path, including its query, may be fetched. The
+// longest matching rule wins and Allow wins ties.
+func (r *robotsRules) Allowed(path string) bool {
+	if r.disallowed {
+		return false
+	}
+	if path == "" {
+		path = "/"
+	}
+	allowed, longest := true, -1
+	for _, rule := range r.rules {
+		if !rule.re.MatchString(path) {
+			continue
+		}
+		if len(rule.pattern) > longest || (len(rule.pattern) == longest && rule.allow) {
+			allowed, longest = rule.allow, len(rule.pattern)
+		}
+	}
+	return allowed
+}
+
+// parseRobots extracts from a robots.txt the rules of the groups whose
+// user agent is agent, falling back to the "*" groups when none is.
+func parseRobots(body []byte, agent string) *robotsRules {
+	agent = strings.ToLower(agent)
+
+	var specific, wildcard []robotsRule
+	var groupAgents []string
+	inRules := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if inRules {
+				groupAgents = nil
+				inRules = false
+			}
+			groupAgents = append(groupAgents, strings.ToLower(value))
+		case "allow", "disallow":
+			inRules = true
+			if value == "" {
+				continue
+			}
+			rule := robotsRule{allow: key == "allow", pattern: value, re: robotsPattern(value)}
+			for _, a := range groupAgents {
+				switch {
+				case a == "*":
+					wildcard = append(wildcard, rule)
+				case a == agent:
+					specific = append(specific, rule)
+				}
+			}
+		}
+	}
+
+	if specific != nil {
+		return &robotsRules{rules: specific}
+	}
+	return &robotsRules{rules: wildcard}
+}
+
+// robotsPattern compiles a robots.txt path pattern, where * matches any
+// sequence of characters and a trailing $ anchors the end of the path.
+func robotsPattern(pattern string) *regexp.Regexp {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
+
+type robotsEntry struct {
+	rules   *robotsRules
+	expires time.Time
+}
+
+// robotsCache keeps the parsed robots.txt of each host.
+type robotsCache struct {
+	mu      sync.Mutex
+	entries map[string]robotsEntry
+}
+
+func newRobotsCache() *robotsCache {
+	return &robotsCache{entries: map[string]robotsEntry{}}
+}
+
+func (c *robotsCache) get(key string) (*robotsRules, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		return nil, false
+	}
+	return e.rules, true
+}
+
+func (c *robotsCache) set(key string, rules *robotsRules, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = robotsEntry{rules: rules, expires: time.Now().Add(ttl)}
+}
+
+// robotsAllowed reports whether u may be fetched according to the
+// robots.txt of its host, fetching and caching it if needed. As in RFC 9309,
+// a missing robots.txt allows everything while an unreachable one
+// disallows everything.
+func (f *Fetcher) robotsAllowed(client *http.Client, u *url.URL) bool {
+	key := u.Scheme + "://" + u.Host
+	if rules, ok := f.robots.get(key); ok {
+		return rules.Allowed(u.RequestURI())
+	}
+
+	rules, ttl := f.fetchRobots(client, key+"/robots.txt")
+	f.robots.set(key, rules, ttl)
+	return rules.Allowed(u.RequestURI())
+}
+
+func (f *Fetcher) fetchRobots(client *http.Client, robotsUrl string) (*robotsRules, time.Duration) {
+	req, err := http.NewRequest(http.MethodGet, robotsUrl, nil)
+	if err != nil {
+		return disallowAll, robotsErrorTTL
+	}
+	req.Header.Set("User-Agent", f.cfg.UserAgent)
+
+	f.limiter.Wait(req.URL.Host)
+	resp, err := client.Do(req)
+	if err != nil {
+		return disallowAll, robotsErrorTTL
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 500:
+		return disallowAll, robotsErrorTTL
+	case resp.StatusCode >= 300:
+		return allowAll, robotsTTL
+	}
+
+	// only the first maxRobotsSize bytes are parsed, as allowed by RFC 9309
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRobotsSize))
+	if err != nil {
+		return disallowAll, robotsErrorTTL
+	}
+	return parseRobots(body, f.agentToken()), robotsTTL
+}
+
+// agentToken is the product token of the User-Agent, matched against the
+// User-agent lines of robots.txt files.
+func (f *Fetcher) agentToken() string {
+	token, _, _ := strings.Cut(f.cfg.UserAgent, "/")
+	token, _, _ = strings.Cut(token, " ")
+	return token
+}
@@ -0,0 +1,106 @@
+package rss
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const robotsTxt = `
+# comments are ignored
+User-agent: *
+Disallow: /private/
+Allow: /private/feed.xml
+
+User-agent: otherbot
+User-agent: bloggogrator
+Disallow: /
+Allow: /feeds/
+Disallow: /feeds/*.json$
+`
+
+func TestParseRobots(t *testing.T) {
+	ours := parseRobots([]byte(robotsTxt), "bloggogrator")
+	require.True(t, ours.Allowed("/feeds/rss.xml"))
+	require.False(t, ours.Allowed("/feeds/feed.json"))
+	require.True(t, ours.Allowed("/feeds/feed.json?x=1"))
+	require.False(t, ours.Allowed("/blog/rss.xml"))
+
+	others := parseRobots([]byte(robotsTxt), "somebot")
+	require.True(t, others.Allowed("/blog/rss.xml"))
+	require.False(t, others.Allowed("/private/secret.xml"))
+	require.True(t, others.Allowed("/private/feed.xml"))
+
+	require.True(t, parseRobots(nil, "bloggogrator").Allowed("/anything"))
+}
+
+func TestFetcherRobots(t *testing.T) {
+	var robotsFetches atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		robotsFetches.Add(1)
+		w.Write([]byte("User-agent: bloggogrator\nDisallow: /blocked\n"))
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(podcastFeed))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	cfg := testFetcherConfig()
+	cfg.RespectRobots = true
+	fetcher := NewFetcher(cfg)
+
+	_, err := fetcher.ReadRss(srv.URL+"/feed.xml", "")
+	require.NoError(t, err)
+
+	_, err = fetcher.ReadRss(srv.URL+"/blocked/feed.xml", "")
+	var robotsErr *RobotsError
+	require.ErrorAs(t, err, &robotsErr)
+	require.Equal(t, KindRobots, ErrorKind(err))
+
+	require.Equal(t, int32(1), robotsFetches.Load())
+}
+
+func TestFetcherRetryAfter(t *testing.T) {
+	var hits atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/limited", func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	mux.HandleFunc("/other", func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write([]byte(podcastFeed))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	fetcher := NewFetcher(testFetcherConfig())
+
+	_, err := fetcher.ReadRss(srv.URL+"/limited", "")
+	var statusErr *HTTPStatusError
+	require.ErrorAs(t, err, &statusErr)
+	require.Equal(t, 2*time.Minute, statusErr.RetryAfter)
+	require.Equal(t, 2*time.Minute, RetryDelay(err, 1, time.Minute))
+
+	_, err = fetcher.ReadRss(srv.URL+"/other", "")
+	var backoffErr *BackoffError
+	require.ErrorAs(t, err, &backoffErr)
+	require.WithinDuration(t, time.Now().Add(2*time.Minute), backoffErr.Until, 5*time.Second)
+	require.InDelta(t, float64(2*time.Minute), float64(RetryDelay(err, 1, time.Minute)), float64(5*time.Second))
+
+	require.Equal(t, int32(1), hits.Load())
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	require.Equal(t, 30*time.Second, parseRetryAfter("30", now))
+	require.Equal(t, time.Hour, parseRetryAfter("Mon, 01 Jan 2024 13:00:00 GMT", now))
+	require.Equal(t, time.Duration(0), parseRetryAfter("Mon, 01 Jan 2024 11:00:00 GMT", now))
+	require.Equal(t, time.Duration(0), parseRetryAfter("soon", now))
+	require.Equal(t, time.Duration(0), parseRetryAfter("", now))
+}
@@ -0,0 +1,347 @@
+package rss
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// allowedElements maps the elements kept by SanitizeHTML to the attributes
+// they are allowed to carry. Elements not listed here are unwrapped, keeping
+// their children, unless they are in droppedElements.
+var allowedElements = map[atom.Atom][]string{
+	atom.A:          {"href", "title"},
+	atom.Abbr:       {"title"},
+	atom.B:          nil,
+	atom.Blockquote: {"cite"},
+	atom.Br:         nil,
+	atom.Code:       nil,
+	atom.Dd:         nil,
+	atom.Del:        nil,
+	atom.Div:        nil,
+	atom.Dl:         nil,
+	atom.Dt:         nil,
+	atom.Em:         nil,
+	atom.Figcaption: nil,
+	atom.Figure:     nil,
+	atom.H1:         nil,
+	atom.H2:         nil,
+	atom.H3:         nil,
+	atom.H4:         nil,
+	atom.H5:         nil,
+	atom.H6:         nil,
+	atom.Hr:         nil,
+	atom.I:          nil,
+	atom.Img:        {"src", "alt", "title", "width", "height"},
+	atom.Ins:        nil,
+	atom.Li:         nil,
+	atom.Ol:         nil,
+	atom.P:          nil,
+	atom.Pre:        nil,
+	atom.Q:          {"cite"},
+	atom.S:          nil,
+	atom.Small:      nil,
+	atom.Span:       nil,
+	atom.Strong:     nil,
+	atom.Sub:        nil,
+	atom.Sup:        nil,
+	atom.Table:      nil,
+	atom.Tbody:      nil,
+	atom.Td:         {"colspan", "rowspan"},
+	atom.Tfoot:      nil,
+	atom.Th:         {"colspan", "rowspan"},
+	atom.Thead:      nil,
+	atom.Tr:         nil,
+	atom.U:          nil,
+	atom.Ul:         nil,
+}
+
+// droppedElements are removed together with their content.
+var droppedElements = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Iframe:   true,
+	atom.Frame:    true,
+	atom.Frameset: true,
+	atom.Object:   true,
+	atom.Embed:    true,
+	atom.Applet:   true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Form:     true,
+	atom.Input:    true,
+	atom.Button:   true,
+	atom.Select:   true,
+	atom.Textarea: true,
+	atom.Svg:      true,
+	atom.Math:     true,
+	atom.Link:     true,
+	atom.Meta:     true,
+	atom.Base:     true,
+	atom.Head:     true,
+	atom.Title:    true,
+}
+
+var urlAttributes = map[string]bool{"href": true, "src": true, "cite": true}
+
+var blockElements = map[atom.Atom]bool{
+	atom.Blockquote: true,
+	atom.Br:         true,
+	atom.Dd:         true,
+	atom.Div:        true,
+	atom.Dl:         true,
+	atom.Dt:         true,
+	atom.Figcaption: true,
+	atom.Figure:     true,
+	atom.H1:         true,
+	atom.H2:         true,
+	atom.H3:         true,
+	atom.H4:         true,
+	atom.H5:         true,
+	atom.H6:         true,
+	atom.Hr:         true,
+	atom.Li:         true,
+	atom.Ol:         true,
+	atom.P:          true,
+	atom.Pre:        true,
+	atom.Table:      true,
+	atom.Tr:         true,
+	atom.Ul:         true,
+}
+
+// Sanitize returns a copy of the item with a plain-text title and an
+// allowlist-sanitized description whose links are resolved against the item link.
+// Oversized titles and descriptions are truncated so the item can still be stored.
+// The author falls back to dc:creator and categories are deduplicated.
+//
+// The title, author and categories are unescaped text: "&lt;b&gt;" becomes
+// "<b>". They are not safe to embed as markup, so every output must escape
+// them, as the JSON, XML and webhook encoders do.
+func (i Item) Sanitize() Item {
+	i.Title = truncate(PlainText(i.Title), maxTitleLength)
+	i.Description = SanitizeHTML(truncate(i.Description, maxDescriptionLength), i.Link)
+	if strings.TrimSpace(i.Author) == "" {
+		i.Author = i.Creator
+	}
+	i.Author = truncate(PlainText(i.Author), maxAuthorLength)
+	i.Creator = ""
+
+	var categories []string
+	seen := map[string]bool{}
+	for _, c := range i.Categories {
+		c = truncate(PlainText(c), maxCategoryLength)
+		if c == "" || seen[c] || len(categories) == maxCategories {
+			continue
+		}
+		seen[c] = true
+		categories = append(categories, c)
+	}
+	i.Categories = categories
+	return i
+}
+
+func parseFragment(s string) ([]*html.Node, error) {
+	return html.ParseFragment(strings.NewReader(s), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+}
+
+// SanitizeHTML strips everything from s that is not in the allowlist:
+// scripts, event handlers, iframes and tracking pixels are removed and
+// relative links are rewritten against base.
+func SanitizeHTML(s, base string) string {
+	if strings.TrimSpace(s) == "" {
+		return ""
+	}
+	nodes, err := parseFragment(s)
+	if err != nil {
+		return html.EscapeString(s)
+	}
+	baseUrl, err := url.Parse(base)
+	if err != nil || !baseUrl.IsAbs() {
+		baseUrl = nil
+	}
+
+	var sb strings.Builder
+	for _, n := range nodes {
+		renderSanitized(&sb, n, baseUrl)
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+func renderSanitized(sb *strings.Builder, n *html.Node, base *url.URL) {
+	switch n.Type {
+	case html.TextNode:
+		sb.WriteString(html.EscapeString(n.Data))
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	if droppedElements[n.DataAtom] || isTrackingPixel(n) {
+		return
+	}
+
+	allowedAttrs, ok := allowedElements[n.DataAtom]
+	if !ok {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			renderSanitized(sb, c, base)
+		}
+		return
+	}
+
+	attrs := sanitizeAttributes(n, allowedAttrs, base)
+	if n.DataAtom == atom.Img && !hasAttribute(attrs, "src") {
+		return
+	}
+
+	sb.WriteByte('<')
+	sb.WriteString(n.Data)
+	for _, a := range attrs {
+		sb.WriteByte(' ')
+		sb.WriteString(a.Key)
+		sb.WriteString(`="`)
+		sb.WriteString(html.EscapeString(a.Val))
+		sb.WriteByte('"')
+	}
+	if n.DataAtom == atom.A && hasAttribute(attrs, "href") {
+		sb.WriteString(` rel="nofollow noopener noreferrer"`)
+	}
+	sb.WriteByte('>')
+
+	if n.DataAtom == atom.Br || n.DataAtom == atom.Hr || n.DataAtom == atom.Img {
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		renderSanitized(sb, c, base)
+	}
+	sb.WriteString("</")
+	sb.WriteString(n.Data)
+	sb.WriteByte('>')
+}
+
+func sanitizeAttributes(n *html.Node, allowed []string, base *url.URL) []html.Attribute {
+	var attrs []html.Attribute
+	for _, a := range n.Attr {
+		if a.Namespace != "" || !contains(allowed, a.Key) {
+			continue
+		}
+		if urlAttributes[a.Key] {
+			u, ok := sanitizeUrl(a.Val, base)
+			if !ok {
+				continue
+			}
+			a.Val = u
+		}
+		attrs = append(attrs, html.Attribute{Key: a.Key, Val: a.Val})
+	}
+	return attrs
+}
+
+// sanitizeUrl resolves raw against base and only accepts http(s) and mailto targets.
+func sanitizeUrl(raw string, base *url.URL) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", false
+	}
+	if !u.IsAbs() {
+		if base == nil {
+			return "", false
+		}
+		u = base.ResolveReference(u)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "mailto":
+		return u.String(), true
+	}
+	return "", false
+}
+
+// isTrackingPixel reports whether n is an image of at most 1x1 pixels.
+func isTrackingPixel(n *html.Node) bool {
+	if n.DataAtom != atom.Img {
+		return false
+	}
+	var width, height string
+	for _, a := range n.Attr {
+		switch a.Key {
+		case "width":
+			width = a.Val
+		case "height":
+			height = a.Val
+		}
+	}
+	w, werr := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(width), "px"))
+	h, herr := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(height), "px"))
+	return werr == nil && herr == nil && w <= 1 && h <= 1
+}
+
+func hasAttribute(attrs []html.Attribute, key string) bool {
+	for _, a := range attrs {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(xs []string, s string) bool {
+	for _, x := range xs {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// PlainText returns the text content of the HTML fragment s, with block
+// elements separated by newlines and runs of whitespace collapsed.
+func PlainText(s string) string {
+	if strings.TrimSpace(s) == "" {
+		return ""
+	}
+	nodes, err := parseFragment(s)
+	if err != nil {
+		return strings.TrimSpace(s)
+	}
+
+	var sb strings.Builder
+	for _, n := range nodes {
+		collectText(&sb, n)
+	}
+
+	lines := strings.Split(sb.String(), "\n")
+	out := make([]string, 0, len(lines))
+	for _, l := range lines {
+		if l = strings.Join(strings.Fields(l), " "); l != "" {
+			out = append(out, l)
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+func collectText(sb *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		sb.WriteString(n.Data)
+		return
+	case html.ElementNode:
+		if droppedElements[n.DataAtom] {
+			return
+		}
+	default:
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		collectText(sb, c)
+	}
+	if blockElements[n.DataAtom] {
+		sb.WriteByte('\n')
+	}
+}
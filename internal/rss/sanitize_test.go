@@ -0,0 +1,64 @@
+package rss
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanitizeHTML(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "drops scripts and event handlers",
+			in:   `<p onclick="steal()">Hi<script>alert(1)</script></p>`,
+			want: `<p>Hi</p>`,
+		},
+		{
+			name: "drops iframes with content",
+			in:   `<div><iframe src="http://evil.example"><p>x</p></iframe>ok</div>`,
+			want: `<div>ok</div>`,
+		},
+		{
+			name: "drops tracking pixels",
+			in:   `<p>text<img src="http://t.example/p.gif" width="1" height="1"></p>`,
+			want: `<p>text</p>`,
+		},
+		{
+			name: "rewrites relative links",
+			in:   `<a href="/about">about</a><img src="img/a.png" alt="a">`,
+			want: `<a href="http://example.com/about" rel="nofollow noopener noreferrer">about</a><img src="http://example.com/posts/img/a.png" alt="a">`,
+		},
+		{
+			name: "drops javascript urls",
+			in:   `<a href="javascript:alert(1)">click</a>`,
+			want: `<a>click</a>`,
+		},
+		{
+			name: "unwraps unknown elements",
+			in:   `<section><custom>text &amp; more</custom></section>`,
+			want: `text &amp; more`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			require.Equal(t, c.want, SanitizeHTML(c.in, "http://example.com/posts/1"))
+		})
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	in := `<p>First   paragraph with <b>bold</b>.</p><p>Second &amp; last</p><script>x()</script>`
+	require.Equal(t, "First paragraph with bold.\nSecond & last", PlainText(in))
+	require.Equal(t, "Tom & Jerry", PlainText("Tom & Jerry"))
+}
+
+func TestSanitizeUnescapesTitle(t *testing.T) {
+	item := Item{Title: "&lt;script&gt;alert(1)&lt;/script&gt;", Author: "&lt;b&gt;Jane&lt;/b&gt;"}.Sanitize()
+
+	require.Equal(t, "<script>alert(1)</script>", item.Title)
+	require.Equal(t, "<b>Jane</b>", item.Author)
+}
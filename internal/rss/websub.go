@@ -0,0 +1,122 @@
+package rss
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxPushSize is the largest content distribution body accepted from a hub.
+const MaxPushSize = DefaultMaxBodySize
+
+type AtomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+}
+
+// WebSubLinks returns the hub advertised by the channel through an
+// atom:link rel="hub", and the topic to subscribe to, which is the
+// rel="self" link if any. hub is empty when the feed doesn't support WebSub.
+func (c Channel) WebSubLinks() (hub, self string) {
+	for _, link := range c.AtomLinks {
+		href := strings.TrimSpace(link.Href)
+		switch strings.ToLower(strings.TrimSpace(link.Rel)) {
+		case "hub":
+			if hub == "" {
+				hub = href
+			}
+		case "self":
+			if self == "" {
+				self = href
+			}
+		}
+	}
+	return hub, self
+}
+
+// Subscribe asks hub to push the updates of topic to callback for lease,
+// with content signed using secret. The hub verifies the intent
+// asynchronously by calling back with a challenge.
+func (f *Fetcher) Subscribe(hub, topic, callback, secret string, lease time.Duration) error {
+	return f.hubRequest(hub, url.Values{
+		"hub.mode":          {"subscribe"},
+		"hub.topic":         {topic},
+		"hub.callback":      {callback},
+		"hub.secret":        {secret},
+		"hub.lease_seconds": {strconv.Itoa(int(lease.Seconds()))},
+	})
+}
+
+func (f *Fetcher) hubRequest(hub string, form url.Values) error {
+	client, err := f.client("")
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, hub, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", f.cfg.UserAgent)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return wrapNetError(err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newHTTPStatusError(hub, resp)
+	}
+	return nil
+}
+
+// NewWebSubSecret returns a random secret for a subscription.
+func NewWebSubSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// VerifySignature checks the X-Hub-Signature header of a content
+// distribution request, of the form method=hexdigest, against the HMAC of
+// body keyed with secret.
+func VerifySignature(secret string, body []byte, signature string) bool {
+	method, digest, ok := strings.Cut(strings.TrimSpace(signature), "=")
+	if !ok {
+		return false
+	}
+	var h func() hash.Hash
+	switch strings.ToLower(method) {
+	case "sha1":
+		h = sha1.New
+	case "sha256":
+		h = sha256.New
+	case "sha384":
+		h = sha512.New384
+	case "sha512":
+		h = sha512.New
+	default:
+		return false
+	}
+	expected, err := hex.DecodeString(digest)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(h, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
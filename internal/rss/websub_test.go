@@ -0,0 +1,135 @@
+package rss
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sp3dr4/bloggogrator/internal/database"
+	"github.com/stretchr/testify/require"
+)
+
+const hubFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom">
+<channel>
+  <title>Blog</title>
+  <link>http://example.com/</link>
+  <atom:link rel="hub" href="http://hub.example.com/"/>
+  <atom:link rel="self" href="http://example.com/feed.xml"/>
+  <item>
+    <title>Post</title>
+    <link>http://example.com/post</link>
+    <pubDate>Mon, 02 Jan 2006 15:04:05 -0700</pubDate>
+  </item>
+</channel>
+</rss>`
+
+func TestWebSubLinks(t *testing.T) {
+	feed, err := parseRss([]byte(hubFeed), "")
+	require.NoError(t, err)
+
+	hub, self := feed.Channel.WebSubLinks()
+	require.Equal(t, "http://hub.example.com/", hub)
+	require.Equal(t, "http://example.com/feed.xml", self)
+	require.Equal(t, "http://example.com/", feed.Channel.Link)
+
+	feed, err = parseRss([]byte(podcastFeed), "")
+	require.NoError(t, err)
+	hub, _ = feed.Channel.WebSubLinks()
+	require.Empty(t, hub)
+}
+
+func TestSubscribe(t *testing.T) {
+	var form url.Values
+	hub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.NoError(t, r.ParseForm())
+		form = r.PostForm
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer hub.Close()
+	fetcher := NewFetcher(testFetcherConfig())
+
+	err := fetcher.Subscribe(hub.URL, "http://example.com/feed.xml", "http://app.example.com/v1/websub/1", "s3cret", 24*time.Hour)
+
+	require.NoError(t, err)
+	require.Equal(t, "subscribe", form.Get("hub.mode"))
+	require.Equal(t, "http://example.com/feed.xml", form.Get("hub.topic"))
+	require.Equal(t, "http://app.example.com/v1/websub/1", form.Get("hub.callback"))
+	require.Equal(t, "s3cret", form.Get("hub.secret"))
+	require.Equal(t, "86400", form.Get("hub.lease_seconds"))
+
+	rejecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer rejecting.Close()
+	err = fetcher.Subscribe(rejecting.URL, "http://example.com/feed.xml", "http://app.example.com/v1/websub/1", "s3cret", time.Hour)
+	require.Equal(t, http.StatusBadRequest, StatusCode(err))
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte("<rss/>")
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(body)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	require.True(t, VerifySignature("s3cret", body, signature))
+	require.False(t, VerifySignature("other", body, signature))
+	require.False(t, VerifySignature("s3cret", []byte("<rss></rss>"), signature))
+	require.False(t, VerifySignature("s3cret", body, ""))
+	require.False(t, VerifySignature("s3cret", body, "md5=abcd"))
+}
+
+func TestProcessFeedSubscribes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(hubFeed))
+	}))
+	defer srv.Close()
+	feed := database.Feed{ID: uuid.New(), Name: "blog", Url: srv.URL}
+
+	var saved int
+	var subscribed []string
+	hooks := Hooks{
+		Mark: func(id uuid.UUID, when time.Time) (database.Feed, error) { return feed, nil },
+		Save: func(item Item, publishedAt time.Time, feedId uuid.UUID) (*database.Post, error) {
+			saved++
+			return &database.Post{ID: uuid.New()}, nil
+		},
+		RecordFetch: func(record FetchRecord) error { return nil },
+		Subscribe: func(feedId uuid.UUID, hub, topic string) error {
+			subscribed = append(subscribed, hub, topic)
+			return nil
+		},
+	}
+
+	processFeed(NewFetcher(testFetcherConfig()), hooks, feed, time.Minute)
+
+	require.Equal(t, 1, saved)
+	require.Equal(t, []string{"http://hub.example.com/", "http://example.com/feed.xml"}, subscribed)
+}
+
+func TestIngest(t *testing.T) {
+	feed := database.Feed{ID: uuid.New(), Name: "blog"}
+	var titles []string
+	hooks := Hooks{
+		Save: func(item Item, publishedAt time.Time, feedId uuid.UUID) (*database.Post, error) {
+			require.Equal(t, feed.ID, feedId)
+			titles = append(titles, item.Title)
+			return &database.Post{ID: uuid.New()}, nil
+		},
+	}
+	fetcher := NewFetcher(testFetcherConfig())
+
+	require.NoError(t, Ingest(fetcher, hooks, feed, []byte(hubFeed), "application/rss+xml"))
+	require.Equal(t, []string{"Post"}, titles)
+
+	err := Ingest(fetcher, hooks, feed, []byte("<html>"), "text/html")
+	var parseErr *ParseError
+	require.ErrorAs(t, err, &parseErr)
+}
@@ -1,6 +1,7 @@
 package rss
 
 import (
+	"errors"
 	"log"
 	"sync"
 	"time"
@@ -13,14 +14,61 @@ const pubDateLayout = "Mon, 02 Jan 2006 15:04:05 -0700"
 
 type GetNextFeeds func() ([]database.Feed, error)
 type MarkFeed func(id uuid.UUID, when time.Time) (database.Feed, error)
-type SavePost func(url, title, description string, publishedAt time.Time, feedId uuid.UUID) (*database.Post, error)
+type SavePost func(item Item, publishedAt time.Time, feedId uuid.UUID) (*database.Post, error)
+type SaveContent func(postId uuid.UUID, content string) error
+type MoveFeed func(feed database.Feed, newUrl string, statusCode int) (database.Feed, error)
+type MarkFeedDead func(feed database.Feed, when time.Time) error
+type MarkFeedFailed func(id uuid.UUID, when time.Time, err error, nextFetchAt time.Time) (database.Feed, error)
+type RecordFetch func(record FetchRecord) error
+type SubscribeFeed func(feedId uuid.UUID, hub, topic string) error
+type GetSubscriptions func() ([]database.WebsubSubscription, error)
 
-func Run(frequency time.Duration, getFeeds GetNextFeeds, mark MarkFeed, save SavePost) {
+// FetchRecord is the outcome of one poll of a feed.
+type FetchRecord struct {
+	FeedId        uuid.UUID
+	StartedAt     time.Time
+	FinishedAt    time.Time
+	StatusCode    int
+	Bytes         int64
+	ItemsSeen     int
+	PostsInserted int
+	PostsUpdated  int
+	Err           error
+}
+
+// Hooks are the persistence callbacks used by the worker.
+type Hooks struct {
+	GetFeeds    GetNextFeeds
+	Mark        MarkFeed
+	Save        SavePost
+	SaveContent SaveContent
+	// MoveFeed updates the feed url after a permanent redirect, merging the
+	// feed into the one already stored at newUrl if any.
+	MoveFeed MoveFeed
+	// MarkDead stops polling a feed that answered 410 Gone.
+	MarkDead MarkFeedDead
+	// MarkFailed records a failed fetch and when the feed should be retried.
+	MarkFailed MarkFeedFailed
+	// RecordFetch appends a poll to the feed fetch history.
+	RecordFetch RecordFetch
+	// Subscribe requests a WebSub subscription to hub for a feed. WebSub is
+	// disabled when nil and feeds are only polled.
+	Subscribe SubscribeFeed
+	// GetExpiringSubscriptions returns the subscriptions whose lease must be
+	// renewed.
+	GetExpiringSubscriptions GetSubscriptions
+}
+
+func Run(frequency time.Duration, fetcher *Fetcher, hooks Hooks) {
 	ticker := time.NewTicker(frequency)
 	for range ticker.C {
 		log.Println("tick...")
 
-		feeds, err := getFeeds()
+		if hooks.Subscribe != nil {
+			renewSubscriptions(hooks)
+		}
+
+		feeds, err := hooks.GetFeeds()
 		if err != nil {
 			log.Printf("could not retrieve feeds: %v\n", err)
 		}
@@ -32,36 +80,151 @@ func Run(frequency time.Duration, getFeeds GetNextFeeds, mark MarkFeed, save Sav
 
 			go func() {
 				defer wg.Done()
+				processFeed(fetcher, hooks, feed, frequency)
+			}()
+		}
 
-				feedContent, err := ReadRss(feed.Url)
-				if err != nil {
-					log.Printf("err reading rss %v: %v\n", feed.Name, err)
-				} else {
-					for _, item := range feedContent.Channel.Items {
-						t, err := time.Parse(pubDateLayout, item.PubDate)
-						if err != nil {
-							log.Printf("date parse err: %v\n", err)
-							continue
-						}
-						post, err := save(item.Link, item.Title, item.Description, t, feed.ID)
-						if err != nil {
-							log.Printf("save err: %v\n", err)
-							continue
-						}
-						if post != nil {
-							log.Printf("[%s] saved %v\n", feed.Name, post.Title)
-						}
-					}
-				}
+		wg.Wait()
+	}
+}
+
+func processFeed(fetcher *Fetcher, hooks Hooks, feed database.Feed, frequency time.Duration) {
+	record := FetchRecord{FeedId: feed.ID, StartedAt: time.Now()}
+	defer func() {
+		record.FinishedAt = time.Now()
+		if err := hooks.RecordFetch(record); err != nil {
+			log.Printf("err recording fetch of feed %v: %v\n", feed.Name, err)
+		}
+	}()
+
+	result, err := fetcher.ReadRss(feed.Url, feed.ProxyUrl.String)
+	if err != nil {
+		record.StatusCode = StatusCode(err)
+		record.Err = err
+	}
+	if errors.Is(err, ErrFeedGone) {
+		log.Printf("[%s] feed is gone, marking it dead\n", feed.Name)
+		if err := hooks.MarkDead(feed, time.Now()); err != nil {
+			log.Printf("err marking feed %v as dead: %v\n", feed.Name, err)
+		}
+		return
+	}
+	if err != nil {
+		log.Printf("err reading rss %v (%s): %v\n", feed.Name, ErrorKind(err), err)
+		now := time.Now()
+		next := now.Add(RetryDelay(err, feed.ConsecutiveFailures+1, frequency))
+		if _, err := hooks.MarkFailed(feed.ID, now, err, next); err != nil {
+			log.Printf("err marking feed %v as failed: %v\n", feed.Name, err)
+		}
+		return
+	}
+	record.StatusCode = result.StatusCode
+	record.Bytes = result.Bytes
+	record.ItemsSeen = len(result.Feed.Channel.Items)
 
-				_, err = mark(feed.ID, time.Now())
-				if err != nil {
-					log.Printf("err marking feed %v as fetched: %v\n", feed.Name, err)
+	if result.MovedTo != "" && result.MovedTo != feed.Url {
+		log.Printf("[%s] feed moved permanently to %v\n", feed.Name, result.MovedTo)
+		moved, err := hooks.MoveFeed(feed, result.MovedTo, result.MovedStatus)
+		if err != nil {
+			log.Printf("err moving feed %v: %v\n", feed.Name, err)
+		} else {
+			feed = moved
+			record.FeedId = moved.ID
+		}
+	}
+
+	record.PostsInserted, record.PostsUpdated = saveItems(fetcher, hooks, feed, result.Feed.Channel.Items)
+
+	if hooks.Subscribe != nil {
+		subscribe(hooks, feed, result.Feed.Channel)
+	}
+
+	if _, err := hooks.Mark(feed.ID, time.Now()); err != nil {
+		log.Printf("err marking feed %v as fetched: %v\n", feed.Name, err)
+	}
+}
+
+// saveItems saves the items of feed, fetching the full content of the new
+// posts if the feed asks for it, and returns how many posts were inserted
+// and updated.
+func saveItems(fetcher *Fetcher, hooks Hooks, feed database.Feed, items []Item) (inserted, updated int) {
+	for _, item := range items {
+		t, err := time.Parse(pubDateLayout, item.PubDate)
+		if err != nil {
+			log.Printf("date parse err: %v\n", err)
+			continue
+		}
+		post, err := hooks.Save(item.Sanitize(), t, feed.ID)
+		if err != nil {
+			log.Printf("save err: %v\n", err)
+			continue
+		}
+		if post != nil {
+			inserted++
+			log.Printf("[%s] saved %v\n", feed.Name, post.Title)
+			if feed.FetchFullContent && item.Link != "" {
+				if fetchFullContent(fetcher, feed, post.ID, item.Link, hooks.SaveContent) {
+					updated++
 				}
+			}
+		}
+	}
+	return inserted, updated
+}
 
-			}()
+// Ingest saves the feed document pushed by a WebSub hub for feed through
+// the same path as polled feeds.
+func Ingest(fetcher *Fetcher, hooks Hooks, feed database.Feed, body []byte, contentType string) error {
+	parsed, err := parseRss(body, contentType)
+	if err != nil {
+		return &ParseError{Err: err}
+	}
+	inserted, _ := saveItems(fetcher, hooks, feed, parsed.Channel.Items)
+	log.Printf("[%s] ingested %d pushed posts\n", feed.Name, inserted)
+	return nil
+}
+
+// subscribe requests a WebSub subscription for a polled feed advertising a
+// hub. The topic is the self link of the feed, or its url when missing.
+func subscribe(hooks Hooks, feed database.Feed, channel Channel) {
+	hub, topic := channel.WebSubLinks()
+	if hub == "" {
+		return
+	}
+	if topic == "" {
+		topic = feed.Url
+	}
+	if err := hooks.Subscribe(feed.ID, hub, topic); err != nil {
+		log.Printf("[%s] websub subscribe err with hub %v: %v\n", feed.Name, hub, err)
+	}
+}
+
+// renewSubscriptions renews the leases about to expire. A subscription that
+// isn't renewed in time lets its feed be polled again.
+func renewSubscriptions(hooks Hooks) {
+	subs, err := hooks.GetExpiringSubscriptions()
+	if err != nil {
+		log.Printf("could not retrieve expiring subscriptions: %v\n", err)
+		return
+	}
+	for _, sub := range subs {
+		if err := hooks.Subscribe(sub.FeedID, sub.HubUrl, sub.Topic); err != nil {
+			log.Printf("err renewing websub subscription of feed %v: %v\n", sub.FeedID, err)
 		}
+	}
+}
 
-		wg.Wait()
+// fetchFullContent stores the extracted article of a post and reports
+// whether the post was updated.
+func fetchFullContent(fetcher *Fetcher, feed database.Feed, postId uuid.UUID, url string, saveContent SaveContent) bool {
+	content, err := fetcher.FetchArticle(url, feed.ProxyUrl.String)
+	if err != nil {
+		log.Printf("[%s] full content err for %v: %v\n", feed.Name, url, err)
+		return false
+	}
+	if err := saveContent(postId, content); err != nil {
+		log.Printf("[%s] save content err for %v: %v\n", feed.Name, url, err)
+		return false
 	}
+	return true
 }
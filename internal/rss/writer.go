@@ -0,0 +1,201 @@
+package rss
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+// OutputFeed is a feed published by bloggogrator, written as RSS 2.0 by
+// WriteRSS or as Atom by WriteAtom.
+type OutputFeed struct {
+	Title       string
+	Description string
+	// SelfUrl is the url the feed is served at, which also identifies it.
+	SelfUrl string
+	Updated time.Time
+	Items   []OutputItem
+}
+
+type OutputItem struct {
+	// ID is a stable and unique URI identifying the item.
+	ID          string
+	Title       string
+	Link        string
+	Description string
+	Published   time.Time
+	Updated     time.Time
+}
+
+type rssOutput struct {
+	XMLName xml.Name         `xml:"rss"`
+	Version string           `xml:"version,attr"`
+	AtomNs  string           `xml:"xmlns:atom,attr"`
+	Channel rssOutputChannel `xml:"channel"`
+}
+
+type rssOutputChannel struct {
+	Title         string          `xml:"title"`
+	Link          string          `xml:"link"`
+	Description   string          `xml:"description"`
+	SelfLink      rssOutputLink   `xml:"atom:link"`
+	LastBuildDate string          `xml:"lastBuildDate"`
+	Generator     string          `xml:"generator"`
+	Items         []rssOutputItem `xml:"item"`
+}
+
+type rssOutputLink struct {
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+	Href string `xml:"href,attr"`
+}
+
+type rssOutputGuid struct {
+	IsPermaLink string `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssOutputItem struct {
+	Title       string        `xml:"title,omitempty"`
+	Link        string        `xml:"link"`
+	Description string        `xml:"description,omitempty"`
+	Guid        rssOutputGuid `xml:"guid"`
+	PubDate     string        `xml:"pubDate"`
+}
+
+// WriteRSS writes feed as an RSS 2.0 document.
+func WriteRSS(w io.Writer, feed OutputFeed) error {
+	out := rssOutput{
+		Version: "2.0",
+		AtomNs:  "http://www.w3.org/2005/Atom",
+		Channel: rssOutputChannel{
+			Title:         feed.Title,
+			Link:          feed.SelfUrl,
+			Description:   feed.Description,
+			SelfLink:      rssOutputLink{Rel: "self", Type: "application/rss+xml", Href: feed.SelfUrl},
+			LastBuildDate: feed.Updated.Format(time.RFC1123Z),
+			Generator:     "bloggogrator",
+		},
+	}
+	for _, item := range feed.Items {
+		out.Channel.Items = append(out.Channel.Items, rssOutputItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			Description: item.Description,
+			Guid:        rssOutputGuid{IsPermaLink: "false", Value: item.ID},
+			PubDate:     item.Published.Format(time.RFC1123Z),
+		})
+	}
+	return writeXML(w, out)
+}
+
+type atomOutput struct {
+	XMLName   xml.Name          `xml:"http://www.w3.org/2005/Atom feed"`
+	ID        string            `xml:"id"`
+	Title     string            `xml:"title"`
+	Subtitle  string            `xml:"subtitle,omitempty"`
+	Updated   string            `xml:"updated"`
+	Link      atomOutputLink    `xml:"link"`
+	Generator string            `xml:"generator"`
+	Entries   []atomOutputEntry `xml:"entry"`
+}
+
+type atomOutputLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomOutputContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type atomOutputEntry struct {
+	ID        string             `xml:"id"`
+	Title     string             `xml:"title"`
+	Link      atomOutputLink     `xml:"link"`
+	Published string             `xml:"published"`
+	Updated   string             `xml:"updated"`
+	Summary   *atomOutputContent `xml:"summary,omitempty"`
+}
+
+// WriteAtom writes feed as an Atom document.
+func WriteAtom(w io.Writer, feed OutputFeed) error {
+	out := atomOutput{
+		ID:        feed.SelfUrl,
+		Title:     feed.Title,
+		Subtitle:  feed.Description,
+		Updated:   feed.Updated.UTC().Format(time.RFC3339),
+		Link:      atomOutputLink{Rel: "self", Type: "application/atom+xml", Href: feed.SelfUrl},
+		Generator: "bloggogrator",
+	}
+	for _, item := range feed.Items {
+		entry := atomOutputEntry{
+			ID:        item.ID,
+			Title:     item.Title,
+			Link:      atomOutputLink{Rel: "alternate", Href: item.Link},
+			Published: item.Published.UTC().Format(time.RFC3339),
+			Updated:   item.Updated.UTC().Format(time.RFC3339),
+		}
+		if item.Description != "" {
+			entry.Summary = &atomOutputContent{Type: "html", Value: item.Description}
+		}
+		out.Entries = append(out.Entries, entry)
+	}
+	return writeXML(w, out)
+}
+
+// Outline is a feed listed in an OPML subscription list.
+type Outline struct {
+	Title  string
+	XmlUrl string
+}
+
+type opmlOutput struct {
+	XMLName xml.Name          `xml:"opml"`
+	Version string            `xml:"version,attr"`
+	Head    opmlOutputHead    `xml:"head"`
+	Body    []opmlOutputEntry `xml:"body>outline"`
+}
+
+type opmlOutputHead struct {
+	Title       string `xml:"title"`
+	DateCreated string `xml:"dateCreated"`
+}
+
+type opmlOutputEntry struct {
+	Type   string `xml:"type,attr"`
+	Text   string `xml:"text,attr"`
+	Title  string `xml:"title,attr"`
+	XmlUrl string `xml:"xmlUrl,attr"`
+}
+
+// WriteOPML writes outlines as an OPML 2.0 subscription list.
+func WriteOPML(w io.Writer, title string, created time.Time, outlines []Outline) error {
+	out := opmlOutput{
+		Version: "2.0",
+		Head:    opmlOutputHead{Title: title, DateCreated: created.Format(time.RFC1123Z)},
+	}
+	for _, o := range outlines {
+		out.Body = append(out.Body, opmlOutputEntry{
+			Type:   "rss",
+			Text:   o.Title,
+			Title:  o.Title,
+			XmlUrl: o.XmlUrl,
+		})
+	}
+	return writeXML(w, out)
+}
+
+func writeXML(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	return enc.Close()
+}
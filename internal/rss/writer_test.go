@@ -0,0 +1,113 @@
+package rss
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var outputFeed = OutputFeed{
+	Title:       "Timeline",
+	Description: "Followed posts",
+	SelfUrl:     "http://example.com/v1/published/token/rss",
+	Updated:     time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC),
+	Items: []OutputItem{{
+		ID:          "urn:uuid:6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+		Title:       "Fish & Chips",
+		Link:        "http://example.com/post",
+		Description: "<p>Hello <b>world</b></p>",
+		Published:   time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC),
+		Updated:     time.Date(2024, 1, 2, 16, 4, 5, 0, time.UTC),
+	}},
+}
+
+func TestWriteRSS(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteRSS(&buf, outputFeed))
+
+	feed, err := parseRss(buf.Bytes(), "application/rss+xml")
+	require.NoError(t, err)
+	require.Equal(t, "Timeline", feed.Channel.Title)
+	_, self := feed.Channel.WebSubLinks()
+	require.Equal(t, outputFeed.SelfUrl, self)
+	require.Len(t, feed.Channel.Items, 1)
+	item := feed.Channel.Items[0]
+	require.Equal(t, "Fish & Chips", item.Title)
+	require.Equal(t, "http://example.com/post", item.Link)
+	require.Equal(t, "<p>Hello <b>world</b></p>", item.Description)
+	_, err = time.Parse(pubDateLayout, item.PubDate)
+	require.NoError(t, err)
+}
+
+func TestWriteEscapesTitle(t *testing.T) {
+	feed := outputFeed
+	feed.Items = []OutputItem{{ID: "urn:x", Title: "<script>alert(1)</script>", Link: "http://example.com/post"}}
+
+	for name, write := range map[string]func(io.Writer, OutputFeed) error{"rss": WriteRSS, "atom": WriteAtom} {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, write(&buf, feed))
+
+			require.NotContains(t, buf.String(), "<script>")
+			require.Contains(t, buf.String(), "&lt;script&gt;alert(1)&lt;/script&gt;")
+		})
+	}
+}
+
+func TestWriteAtom(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteAtom(&buf, outputFeed))
+
+	var feed struct {
+		ID      string `xml:"http://www.w3.org/2005/Atom id"`
+		Updated string `xml:"updated"`
+		Entries []struct {
+			ID      string `xml:"id"`
+			Title   string `xml:"title"`
+			Updated string `xml:"updated"`
+			Link    struct {
+				Href string `xml:"href,attr"`
+			} `xml:"link"`
+			Summary string `xml:"summary"`
+		} `xml:"entry"`
+	}
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &feed))
+	require.Equal(t, outputFeed.SelfUrl, feed.ID)
+	require.Equal(t, "2024-01-02T15:04:05Z", feed.Updated)
+	require.Len(t, feed.Entries, 1)
+	require.Equal(t, outputFeed.Items[0].ID, feed.Entries[0].ID)
+	require.Equal(t, "Fish & Chips", feed.Entries[0].Title)
+	require.Equal(t, "2024-01-02T16:04:05Z", feed.Entries[0].Updated)
+	require.Equal(t, "http://example.com/post", feed.Entries[0].Link.Href)
+	require.Equal(t, "<p>Hello <b>world</b></p>", feed.Entries[0].Summary)
+}
+
+func TestWriteOPML(t *testing.T) {
+	var buf bytes.Buffer
+	created := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	outlines := []Outline{
+		{Title: "Fish & Chips", XmlUrl: "http://example.com/rss"},
+		{Title: "Blog", XmlUrl: "http://blog.example.com/atom"},
+	}
+	require.NoError(t, WriteOPML(&buf, "Subscriptions", created, outlines))
+
+	var doc struct {
+		Version string `xml:"version,attr"`
+		Title   string `xml:"head>title"`
+		Entries []struct {
+			Text   string `xml:"text,attr"`
+			XmlUrl string `xml:"xmlUrl,attr"`
+		} `xml:"body>outline"`
+	}
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &doc))
+	require.Equal(t, "2.0", doc.Version)
+	require.Equal(t, "Subscriptions", doc.Title)
+	require.Len(t, doc.Entries, 2)
+	require.Equal(t, "Fish & Chips", doc.Entries[0].Text)
+	require.Equal(t, "http://example.com/rss", doc.Entries[0].XmlUrl)
+	require.Equal(t, "http://blog.example.com/atom", doc.Entries[1].XmlUrl)
+}
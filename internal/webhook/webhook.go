@@ -0,0 +1,193 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sp3dr4/bloggogrator/internal/database"
+)
+
+// Events sent to webhooks.
+const (
+	EventPostCreated = "post.created"
+	EventTest        = "test"
+)
+
+// Statuses of a delivery.
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+const (
+	// SignatureHeader carries the HMAC-SHA256 of the body keyed with the
+	// webhook secret, as sha256=hexdigest.
+	SignatureHeader = "X-Bloggogrator-Signature"
+	EventHeader     = "X-Bloggogrator-Event"
+	DeliveryHeader  = "X-Bloggogrator-Delivery"
+
+	// MaxAttempts is the number of attempts after which a delivery fails.
+	MaxAttempts = 8
+
+	minRetryDelay = time.Minute
+	maxRetryDelay = 6 * time.Hour
+	maxErrorSize  = 1024
+)
+
+type Post struct {
+	ID              uuid.UUID `json:"id"`
+	FeedID          uuid.UUID `json:"feed_id"`
+	Url             string    `json:"url"`
+	Title           *string   `json:"title"`
+	DescriptionText *string   `json:"description_text"`
+	PublishedAt     time.Time `json:"published_at"`
+}
+
+// Payload is the JSON body POSTed to webhooks.
+type Payload struct {
+	ID        uuid.UUID `json:"id"`
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"created_at"`
+	WebhookID uuid.UUID `json:"webhook_id"`
+	Post      *Post     `json:"post,omitempty"`
+}
+
+// NewPayload returns the payload of delivery, about post if not nil.
+func NewPayload(delivery database.WebhookDelivery, post *database.Post) Payload {
+	payload := Payload{
+		ID:        delivery.ID,
+		Event:     delivery.Event,
+		CreatedAt: delivery.CreatedAt,
+		WebhookID: delivery.WebhookID,
+	}
+	if post != nil {
+		p := Post{ID: post.ID, FeedID: post.FeedID, Url: post.Url, PublishedAt: post.PublishedAt}
+		if post.Title.Valid {
+			p.Title = &post.Title.String
+		}
+		if post.DescriptionText.Valid {
+			p.DescriptionText = &post.DescriptionText.String
+		}
+		payload.Post = &p
+	}
+	return payload
+}
+
+// NewSecret returns a random signing secret for a webhook.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Sign returns the value of the SignatureHeader for body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sender POSTs signed payloads to webhooks.
+type Sender struct {
+	client    *http.Client
+	userAgent string
+}
+
+func NewSender(userAgent string, timeout time.Duration) *Sender {
+	return &Sender{
+		client: &http.Client{
+			Timeout: timeout,
+			// a redirect would resend the payload to a url the user didn't register
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		userAgent: userAgent,
+	}
+}
+
+// Send POSTs payload to url and returns the response status. Any non-2xx
+// response is an error.
+func (s *Sender) Send(url, secret string, payload Payload) (int, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", s.userAgent)
+	req.Header.Set(SignatureHeader, Sign(secret, body))
+	req.Header.Set(EventHeader, payload.Event)
+	req.Header.Set(DeliveryHeader, payload.ID.String())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	return resp.StatusCode, nil
+}
+
+// RetryDelay returns how long to wait before the next attempt of a delivery
+// that failed attempts times.
+func RetryDelay(attempts int32) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	delay := float64(minRetryDelay) * math.Pow(2, float64(attempts-1))
+	if delay > float64(maxRetryDelay) {
+		return maxRetryDelay
+	}
+	return time.Duration(delay)
+}
+
+// AttemptParams records an attempt of delivery that got statusCode and err.
+// A failed attempt is retried later when retry is set and the delivery has
+// attempts left.
+func AttemptParams(delivery database.WebhookDelivery, statusCode int, err error, retry bool, now time.Time) database.RecordWebhookDeliveryAttemptParams {
+	params := database.RecordWebhookDeliveryAttemptParams{
+		ID:             delivery.ID,
+		Status:         StatusDelivered,
+		LastAttemptAt:  sql.NullTime{Time: now, Valid: true},
+		ResponseStatus: sql.NullInt32{Int32: int32(statusCode), Valid: statusCode != 0},
+	}
+	if err == nil {
+		return params
+	}
+
+	msg := err.Error()
+	if len(msg) > maxErrorSize {
+		msg = msg[:maxErrorSize]
+	}
+	params.Error = sql.NullString{String: msg, Valid: true}
+	attempts := delivery.Attempts + 1
+	if retry && attempts < MaxAttempts {
+		params.Status = StatusPending
+		params.NextAttemptAt = sql.NullTime{Time: now.Add(RetryDelay(attempts)), Valid: true}
+	} else {
+		params.Status = StatusFailed
+	}
+	return params
+}
@@ -0,0 +1,133 @@
+package webhook
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sp3dr4/bloggogrator/internal/database"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSend(t *testing.T) {
+	var received Payload
+	var headers http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.Equal(t, Sign("s3cret", body), r.Header.Get(SignatureHeader))
+		require.NoError(t, json.Unmarshal(body, &received))
+		headers = r.Header
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	delivery := database.WebhookDelivery{ID: uuid.New(), CreatedAt: time.Now(), WebhookID: uuid.New(), Event: EventPostCreated}
+	post := database.Post{ID: uuid.New(), FeedID: uuid.New(), Url: "http://example.com/post", Title: sql.NullString{String: "Post", Valid: true}}
+
+	status, err := NewSender("test-agent/1.0", time.Second).Send(srv.URL, "s3cret", NewPayload(delivery, &post))
+
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, status)
+	require.Equal(t, delivery.ID, received.ID)
+	require.Equal(t, EventPostCreated, received.Event)
+	require.Equal(t, post.ID, received.Post.ID)
+	require.Equal(t, "Post", *received.Post.Title)
+	require.Nil(t, received.Post.DescriptionText)
+	require.Equal(t, "test-agent/1.0", headers.Get("User-Agent"))
+	require.Equal(t, EventPostCreated, headers.Get(EventHeader))
+	require.Equal(t, delivery.ID.String(), headers.Get(DeliveryHeader))
+}
+
+func TestSendEscapesTitle(t *testing.T) {
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	delivery := database.WebhookDelivery{ID: uuid.New(), Event: EventPostCreated}
+	post := database.Post{ID: uuid.New(), Title: sql.NullString{String: "<script>alert(1)</script>", Valid: true}}
+
+	_, err := NewSender("test-agent/1.0", time.Second).Send(srv.URL, "s3cret", NewPayload(delivery, &post))
+
+	require.NoError(t, err)
+	require.NotContains(t, string(body), "<script>")
+	var received Payload
+	require.NoError(t, json.Unmarshal(body, &received))
+	require.Equal(t, "<script>alert(1)</script>", *received.Post.Title)
+}
+
+func TestSendFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://internal.example.com/", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	status, err := NewSender("test-agent/1.0", time.Second).Send(srv.URL, "s3cret", Payload{Event: EventTest})
+
+	require.Error(t, err)
+	require.Equal(t, http.StatusFound, status)
+}
+
+func TestAttemptParams(t *testing.T) {
+	now := time.Now()
+	delivery := database.WebhookDelivery{ID: uuid.New(), Attempts: 2}
+
+	params := AttemptParams(delivery, http.StatusOK, nil, true, now)
+	require.Equal(t, StatusDelivered, params.Status)
+	require.False(t, params.Error.Valid)
+	require.Equal(t, int32(http.StatusOK), params.ResponseStatus.Int32)
+
+	params = AttemptParams(delivery, http.StatusBadGateway, errors.New("unexpected status"), true, now)
+	require.Equal(t, StatusPending, params.Status)
+	require.Equal(t, now.Add(4*time.Minute), params.NextAttemptAt.Time)
+	require.Equal(t, "unexpected status", params.Error.String)
+
+	params = AttemptParams(delivery, 0, errors.New("connection refused"), false, now)
+	require.Equal(t, StatusFailed, params.Status)
+	require.False(t, params.ResponseStatus.Valid)
+
+	delivery.Attempts = MaxAttempts - 1
+	params = AttemptParams(delivery, http.StatusBadGateway, errors.New("unexpected status"), true, now)
+	require.Equal(t, StatusFailed, params.Status)
+	require.False(t, params.NextAttemptAt.Valid)
+}
+
+func TestRetryDelay(t *testing.T) {
+	require.Equal(t, time.Minute, RetryDelay(1))
+	require.Equal(t, 8*time.Minute, RetryDelay(4))
+	require.Equal(t, maxRetryDelay, RetryDelay(20))
+}
+
+func TestDeliver(t *testing.T) {
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+	hook := database.Webhook{ID: uuid.New(), Url: srv.URL, Secret: "s3cret"}
+	post := database.Post{ID: uuid.New()}
+	delivery := database.WebhookDelivery{ID: uuid.New(), WebhookID: hook.ID, PostID: uuid.NullUUID{UUID: post.ID, Valid: true}, Event: EventPostCreated}
+	var recorded database.RecordWebhookDeliveryAttemptParams
+	hooks := Hooks{
+		GetWebhook: func(id uuid.UUID) (database.Webhook, error) { return hook, nil },
+		GetPost:    func(id uuid.UUID) (database.Post, error) { return post, nil },
+		RecordAttempt: func(params database.RecordWebhookDeliveryAttemptParams) (database.WebhookDelivery, error) {
+			recorded = params
+			return delivery, nil
+		},
+	}
+
+	deliver(NewSender("test-agent/1.0", time.Second), hooks, delivery)
+
+	require.Equal(t, 1, attempts)
+	require.Equal(t, delivery.ID, recorded.ID)
+	require.Equal(t, StatusDelivered, recorded.Status)
+}
@@ -0,0 +1,76 @@
+package webhook
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sp3dr4/bloggogrator/internal/database"
+)
+
+type ClaimDeliveries func(lockedUntil time.Time) ([]database.WebhookDelivery, error)
+type GetWebhook func(id uuid.UUID) (database.Webhook, error)
+type GetPost func(id uuid.UUID) (database.Post, error)
+type RecordAttempt func(params database.RecordWebhookDeliveryAttemptParams) (database.WebhookDelivery, error)
+
+// lockDuration is how long a claimed delivery is hidden from the other
+// workers, which must exceed the time of an attempt.
+const lockDuration = 5 * time.Minute
+
+// Hooks are the persistence callbacks used by the delivery worker.
+type Hooks struct {
+	// Claim returns the due deliveries, hiding them from other workers until
+	// lockedUntil.
+	Claim         ClaimDeliveries
+	GetWebhook    GetWebhook
+	GetPost       GetPost
+	RecordAttempt RecordAttempt
+}
+
+// Run attempts the due deliveries every frequency.
+func Run(frequency time.Duration, sender *Sender, hooks Hooks) {
+	ticker := time.NewTicker(frequency)
+	for range ticker.C {
+		deliveries, err := hooks.Claim(time.Now().Add(lockDuration))
+		if err != nil {
+			log.Printf("could not claim webhook deliveries: %v\n", err)
+			continue
+		}
+
+		var wg sync.WaitGroup
+		for _, delivery := range deliveries {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				deliver(sender, hooks, delivery)
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+func deliver(sender *Sender, hooks Hooks, delivery database.WebhookDelivery) {
+	hook, err := hooks.GetWebhook(delivery.WebhookID)
+	if err != nil {
+		log.Printf("err retrieving webhook %v: %v\n", delivery.WebhookID, err)
+		return
+	}
+	var post *database.Post
+	if delivery.PostID.Valid {
+		p, err := hooks.GetPost(delivery.PostID.UUID)
+		if err != nil {
+			log.Printf("err retrieving post %v: %v\n", delivery.PostID.UUID, err)
+			return
+		}
+		post = &p
+	}
+
+	status, sendErr := sender.Send(hook.Url, hook.Secret, NewPayload(delivery, post))
+	if sendErr != nil {
+		log.Printf("webhook %v delivery %v failed: %v\n", hook.ID, delivery.ID, sendErr)
+	}
+	if _, err := hooks.RecordAttempt(AttemptParams(delivery, status, sendErr, true, time.Now())); err != nil {
+		log.Printf("err recording webhook delivery %v: %v\n", delivery.ID, err)
+	}
+}
@@ -3,17 +3,25 @@ package main
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"log"
+	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 	"github.com/lib/pq"
 	"github.com/sp3dr4/bloggogrator/api"
+	"github.com/sp3dr4/bloggogrator/api/middleware"
 	"github.com/sp3dr4/bloggogrator/internal/database"
+	"github.com/sp3dr4/bloggogrator/internal/digest"
+	"github.com/sp3dr4/bloggogrator/internal/filter"
 	"github.com/sp3dr4/bloggogrator/internal/rss"
+	"github.com/sp3dr4/bloggogrator/internal/webhook"
 )
 
 func main() {
@@ -29,6 +37,22 @@ func main() {
 
 	dbQueries := database.New(db)
 
+	if adminEmails := os.Getenv("ADMIN_EMAILS"); adminEmails != "" {
+		emails := strings.Split(strings.ToLower(adminEmails), ",")
+		for i := range emails {
+			emails[i] = strings.TrimSpace(emails[i])
+		}
+		// only the first admins come from the environment, so that a
+		// demotion through the API survives restarts
+		promoted, err := dbQueries.BootstrapAdmins(context.Background(), emails)
+		if err != nil {
+			log.Fatalf("admins promotion error: %v", err)
+		}
+		if promoted > 0 {
+			log.Printf("promoted %d users to admin\n", promoted)
+		}
+	}
+
 	var pollActive bool = true
 	pollActiveStr := os.Getenv("POLL_ENABLED")
 	if pollActiveStr != "" {
@@ -38,6 +62,191 @@ func main() {
 		}
 	}
 
+	fetcherCfg := fetcherConfig()
+	fetcher := rss.NewFetcher(fetcherCfg)
+
+	feedMarker := func(id uuid.UUID, when time.Time) (database.Feed, error) {
+		params := database.MarkFeedFetchedParams{ID: id, LastFetchedAt: sql.NullTime{Time: when, Valid: true}}
+		return dbQueries.MarkFeedFetched(context.Background(), params)
+	}
+
+	postSaver := func(item rss.Item, publishedAt time.Time, feedId uuid.UUID) (*database.Post, error) {
+		tx, err := db.BeginTx(context.Background(), nil)
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback()
+		qtx := dbQueries.WithTx(tx)
+
+		descriptionText := rss.PlainText(item.Description)
+		params := database.CreatePostParams{
+			ID:              uuid.New(),
+			CreatedAt:       time.Now(),
+			UpdatedAt:       time.Now(),
+			Url:             item.Link,
+			Title:           sql.NullString{String: item.Title, Valid: item.Title != ""},
+			Description:     sql.NullString{String: item.Description, Valid: item.Description != ""},
+			PublishedAt:     publishedAt,
+			FeedID:          feedId,
+			DescriptionText: sql.NullString{String: descriptionText, Valid: descriptionText != ""},
+			Author:          sql.NullString{String: item.Author, Valid: item.Author != ""},
+			Categories:      item.Categories,
+		}
+		if params.Categories == nil {
+			params.Categories = []string{}
+		}
+		p, err := qtx.CreatePost(context.Background(), params)
+		if errors.Is(err, sql.ErrNoRows) {
+			// the post was deleted by an admin
+			return nil, nil
+		}
+		if err != nil {
+			pqErr, ok := err.(*pq.Error)
+			if ok && pqErr.Code.Name() == "unique_violation" {
+				return nil, nil
+			}
+			return nil, err
+		}
+
+		if ep := item.Episode(); ep != nil {
+			if _, err := qtx.CreatePostEpisode(context.Background(), episodeParams(p.ID, ep)); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := applyFilterRules(qtx, p); err != nil {
+			return nil, err
+		}
+
+		enqueueParams := database.EnqueuePostWebhookDeliveriesParams{Event: webhook.EventPostCreated, PostID: p.ID}
+		if err := qtx.EnqueuePostWebhookDeliveries(context.Background(), enqueueParams); err != nil {
+			return nil, err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		return &p, nil
+	}
+
+	contentSaver := func(postId uuid.UUID, content string) error {
+		params := database.UpdatePostContentParams{
+			ID:        postId,
+			Content:   sql.NullString{String: content, Valid: content != ""},
+			UpdatedAt: time.Now(),
+		}
+		return dbQueries.UpdatePostContent(context.Background(), params)
+	}
+
+	feedMover := func(feed database.Feed, newUrl string, statusCode int) (database.Feed, error) {
+		return moveFeed(db, dbQueries, feed, newUrl, statusCode)
+	}
+
+	feedKiller := func(feed database.Feed, when time.Time) error {
+		tx, err := db.BeginTx(context.Background(), nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		qtx := dbQueries.WithTx(tx)
+
+		if _, err := qtx.MarkFeedDead(context.Background(), database.MarkFeedDeadParams{
+			ID:     feed.ID,
+			DeadAt: sql.NullTime{Time: when, Valid: true},
+		}); err != nil {
+			return err
+		}
+		if _, err := qtx.CreateFeedUrlHistory(context.Background(), database.CreateFeedUrlHistoryParams{
+			ID:         uuid.New(),
+			CreatedAt:  when,
+			FeedID:     feed.ID,
+			OldUrl:     feed.Url,
+			StatusCode: http.StatusGone,
+		}); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	feedFailer := func(id uuid.UUID, when time.Time, fetchErr error, nextFetchAt time.Time) (database.Feed, error) {
+		status := rss.StatusCode(fetchErr)
+		params := database.MarkFeedFailedParams{
+			ID:              id,
+			LastFetchedAt:   sql.NullTime{Time: when, Valid: true},
+			LastError:       sql.NullString{String: fetchErr.Error(), Valid: true},
+			LastErrorKind:   sql.NullString{String: rss.ErrorKind(fetchErr), Valid: true},
+			LastErrorStatus: sql.NullInt32{Int32: int32(status), Valid: status != 0},
+			NextFetchAt:     sql.NullTime{Time: nextFetchAt, Valid: true},
+		}
+		return dbQueries.MarkFeedFailed(context.Background(), params)
+	}
+
+	fetchesKeep := 50
+	if fetchesKeepStr := os.Getenv("FEED_FETCHES_KEEP"); fetchesKeepStr != "" {
+		fetchesKeep, err = strconv.Atoi(fetchesKeepStr)
+		if err != nil || fetchesKeep < 1 {
+			log.Fatalf("invalid feed fetches keep %v: %v", fetchesKeepStr, err)
+		}
+	}
+
+	fetchRecorder := func(record rss.FetchRecord) error {
+		tx, err := db.BeginTx(context.Background(), nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		qtx := dbQueries.WithTx(tx)
+
+		params := database.CreateFeedFetchParams{
+			ID:            uuid.New(),
+			FeedID:        record.FeedId,
+			StartedAt:     record.StartedAt,
+			FinishedAt:    record.FinishedAt,
+			DurationMs:    int32(record.FinishedAt.Sub(record.StartedAt).Milliseconds()),
+			StatusCode:    sql.NullInt32{Int32: int32(record.StatusCode), Valid: record.StatusCode != 0},
+			Bytes:         record.Bytes,
+			ItemsSeen:     int32(record.ItemsSeen),
+			PostsInserted: int32(record.PostsInserted),
+			PostsUpdated:  int32(record.PostsUpdated),
+		}
+		if record.Err != nil {
+			params.Error = sql.NullString{String: record.Err.Error(), Valid: true}
+		}
+		if _, err := qtx.CreateFeedFetch(context.Background(), params); err != nil {
+			return err
+		}
+		pruneParams := database.PruneFeedFetchesParams{FeedID: record.FeedId, Keep: int32(fetchesKeep)}
+		if err := qtx.PruneFeedFetches(context.Background(), pruneParams); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	hooks := rss.Hooks{
+		Mark:        feedMarker,
+		Save:        postSaver,
+		SaveContent: contentSaver,
+		MoveFeed:    feedMover,
+		MarkDead:    feedKiller,
+		MarkFailed:  feedFailer,
+		RecordFetch: fetchRecorder,
+	}
+
+	if callbackUrl := os.Getenv("WEBSUB_CALLBACK_URL"); callbackUrl != "" {
+		lease := envSeconds("WEBSUB_LEASE_SECONDS", 10*24*time.Hour)
+		hooks.Subscribe = func(feedId uuid.UUID, hub, topic string) error {
+			return subscribeFeed(dbQueries, fetcher, callbackUrl, lease, feedId, hub, topic)
+		}
+		hooks.GetExpiringSubscriptions = func() ([]database.WebsubSubscription, error) {
+			now := time.Now()
+			params := database.GetExpiringWebsubSubscriptionsParams{
+				ExpiresBefore:   sql.NullTime{Time: now.Add(lease / 10), Valid: true},
+				RequestedBefore: now.Add(-websubRetryInterval),
+			}
+			return dbQueries.GetExpiringWebsubSubscriptions(context.Background(), params)
+		}
+	}
+
 	if pollActive {
 		pollFrequencyStr := os.Getenv("POLL_FREQUENCY_SECONDS")
 		pollFrequencySec, err := strconv.Atoi(pollFrequencyStr)
@@ -55,35 +264,427 @@ func main() {
 			return dbQueries.GetNextFeedsToFetch(context.Background(), int32(pollAmount))
 		}
 
-		feedMarker := func(id uuid.UUID, when time.Time) (database.Feed, error) {
-			params := database.MarkFeedFetchedParams{ID: id, LastFetchedAt: sql.NullTime{Time: when, Valid: true}}
-			return dbQueries.MarkFeedFetched(context.Background(), params)
+		hooks.GetFeeds = feedsFetcher
+		go rss.Run(time.Duration(pollFrequencySec)*time.Second, fetcher, hooks)
+	}
+
+	webhookSender := webhook.NewSender(fetcherCfg.UserAgent, envSeconds("WEBHOOK_TIMEOUT_SECONDS", 10*time.Second))
+
+	var webhooksActive bool = true
+	if webhooksActiveStr := os.Getenv("WEBHOOKS_ENABLED"); webhooksActiveStr != "" {
+		webhooksActive, err = strconv.ParseBool(webhooksActiveStr)
+		if err != nil {
+			log.Fatalf("invalid webhooks feature flag %v: %v", webhooksActiveStr, err)
+		}
+	}
+
+	if webhooksActive {
+		webhookHooks := webhook.Hooks{
+			Claim: func(lockedUntil time.Time) ([]database.WebhookDelivery, error) {
+				params := database.ClaimWebhookDeliveriesParams{
+					LockedUntil: sql.NullTime{Time: lockedUntil, Valid: true},
+					Amount:      100,
+				}
+				return dbQueries.ClaimWebhookDeliveries(context.Background(), params)
+			},
+			GetWebhook: func(id uuid.UUID) (database.Webhook, error) {
+				return dbQueries.GetWebhook(context.Background(), id)
+			},
+			GetPost: func(id uuid.UUID) (database.Post, error) {
+				return dbQueries.GetPost(context.Background(), id)
+			},
+			RecordAttempt: func(params database.RecordWebhookDeliveryAttemptParams) (database.WebhookDelivery, error) {
+				return dbQueries.RecordWebhookDeliveryAttempt(context.Background(), params)
+			},
 		}
+		go webhook.Run(envSeconds("WEBHOOK_POLL_SECONDS", 5*time.Second), webhookSender, webhookHooks)
+	}
 
-		postSaver := func(url, title, description string, publishedAt time.Time, feedId uuid.UUID) (*database.Post, error) {
-			params := database.CreatePostParams{
-				ID:          uuid.New(),
-				CreatedAt:   time.Now(),
-				UpdatedAt:   time.Now(),
-				Url:         url,
-				Title:       sql.NullString{String: title, Valid: title != ""},
-				Description: sql.NullString{String: description, Valid: description != ""},
-				PublishedAt: publishedAt,
-				FeedID:      feedId,
-			}
-			p, err := dbQueries.CreatePost(context.Background(), params)
+	if smtpHost := os.Getenv("SMTP_HOST"); smtpHost != "" {
+		smtpCfg := digest.SMTPConfig{
+			Host:     smtpHost,
+			Port:     587,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("SMTP_FROM"),
+		}
+		if smtpPortStr := os.Getenv("SMTP_PORT"); smtpPortStr != "" {
+			smtpCfg.Port, err = strconv.Atoi(smtpPortStr)
 			if err != nil {
-				pqErr, ok := err.(*pq.Error)
-				if ok && pqErr.Code.Name() == "unique_violation" {
-					return nil, nil
+				log.Fatalf("invalid smtp port %v: %v", smtpPortStr, err)
+			}
+		}
+		if smtpCfg.From == "" {
+			log.Fatal("SMTP_FROM environment variable is not set")
+		}
+		publicUrl := strings.TrimSuffix(os.Getenv("PUBLIC_URL"), "/")
+		if publicUrl == "" {
+			log.Fatal("PUBLIC_URL environment variable is not set")
+		}
+
+		digestHooks := digest.Hooks{
+			List: func() ([]database.DigestSubscription, error) {
+				return dbQueries.ListDigestSubscriptions(context.Background())
+			},
+			ClaimConfirmation: func(sub database.DigestSubscription, sentAt time.Time) error {
+				params := database.ClaimDigestConfirmationParams{
+					SentAt:            sentAt,
+					UserID:            sub.UserID,
+					ConfirmationToken: sub.ConfirmationToken,
 				}
-				return nil, err
+				claimed, err := dbQueries.ClaimDigestConfirmation(context.Background(), params)
+				if err != nil {
+					return err
+				}
+				if claimed == 0 {
+					return digest.ErrAlreadyClaimed
+				}
+				return nil
+			},
+			Claim: func(userId uuid.UUID, periodStart, scheduled time.Time) (database.DigestRun, error) {
+				params := database.ClaimDigestRunParams{
+					ID:           uuid.New(),
+					CreatedAt:    time.Now(),
+					UserID:       userId,
+					PeriodStart:  periodStart,
+					ScheduledFor: scheduled,
+					Status:       digest.StatusSending,
+				}
+				run, err := dbQueries.ClaimDigestRun(context.Background(), params)
+				if errors.Is(err, sql.ErrNoRows) {
+					return run, digest.ErrAlreadyClaimed
+				}
+				return run, err
+			},
+			GetPosts: func(userId uuid.UUID, after, until time.Time) ([]database.Post, error) {
+				params := database.GetFollowedPostsBetweenParams{
+					UserID: userId,
+					After:  after,
+					Until:  until,
+					Amount: digest.MaxPosts,
+				}
+				return dbQueries.GetFollowedPostsBetween(context.Background(), params)
+			},
+			Finish: func(params database.FinishDigestRunParams) error {
+				_, err := dbQueries.FinishDigestRun(context.Background(), params)
+				return err
+			},
+		}
+		go digest.Run(time.Minute, digest.NewMailer(smtpCfg), publicUrl, digestHooks)
+	}
+
+	pushIngester := func(feed database.Feed, body []byte, contentType string) error {
+		return rss.Ingest(fetcher, hooks, feed, body, contentType)
+	}
+
+	registration := api.RegistrationOpen
+	if registrationStr := os.Getenv("REGISTRATION_MODE"); registrationStr != "" {
+		switch registrationStr {
+		case api.RegistrationOpen, api.RegistrationInvite, api.RegistrationClosed:
+			registration = registrationStr
+		default:
+			log.Fatalf("invalid registration mode %v", registrationStr)
+		}
+	}
+
+	api.Run(dbQueries, pushIngester, listenNewPosts(dbURL), webhookSender, registration, rateLimitConfig())
+}
+
+func rateLimitConfig() api.RateLimitConfig {
+	cfg := api.RateLimitConfig{
+		Public: envRateLimit("RATE_LIMIT_PUBLIC", middleware.RateLimit{Limit: 30, Period: time.Minute}),
+		Auth:   envRateLimit("RATE_LIMIT_AUTH", middleware.RateLimit{Limit: 600, Period: time.Minute}),
+		Read:   envRateLimit("RATE_LIMIT_READ", middleware.RateLimit{Limit: 300, Period: time.Minute}),
+		Write:  envRateLimit("RATE_LIMIT_WRITE", middleware.RateLimit{Limit: 60, Period: time.Minute}),
+		Admin:  envRateLimit("RATE_LIMIT_ADMIN", middleware.RateLimit{Limit: 120, Period: time.Minute}),
+	}
+	switch store := os.Getenv("RATE_LIMIT_STORE"); store {
+	case "", "memory":
+	case "postgres":
+		cfg.Shared = true
+	default:
+		log.Fatalf("invalid rate limit store %v", store)
+	}
+	if trustProxyStr := os.Getenv("RATE_LIMIT_TRUST_PROXY"); trustProxyStr != "" {
+		trustProxy, err := strconv.ParseBool(trustProxyStr)
+		if err != nil {
+			log.Fatalf("invalid rate limit trust proxy flag %v: %v", trustProxyStr, err)
+		}
+		cfg.TrustProxy = trustProxy
+	}
+	return cfg
+}
+
+func envRateLimit(name string, def middleware.RateLimit) middleware.RateLimit {
+	str := os.Getenv(name)
+	if str == "" {
+		return def
+	}
+	limit, err := middleware.ParseRateLimit(str)
+	if err != nil {
+		log.Fatalf("invalid %v %v: %v", name, str, err)
+	}
+	return limit
+}
+
+// newPostsChannel is the channel notified by the posts insert trigger.
+const newPostsChannel = "new_posts"
+
+// listenNewPosts relays the notifications of inserted posts, whichever
+// instance inserted them. Notifications sent while the listener is
+// reconnecting are lost; stream clients catch up with Last-Event-ID.
+func listenNewPosts(dbURL string) <-chan api.PostEvent {
+	listener := pq.NewListener(dbURL, 10*time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("new posts listener error: %v\n", err)
+		}
+	})
+	if err := listener.Listen(newPostsChannel); err != nil {
+		log.Fatalf("new posts listen error: %v", err)
+	}
+
+	events := make(chan api.PostEvent, 100)
+	go func() {
+		for n := range listener.Notify {
+			// a nil notification signals a reconnection
+			if n == nil {
+				continue
+			}
+			var ev api.PostEvent
+			if err := json.Unmarshal([]byte(n.Extra), &ev); err != nil {
+				log.Printf("invalid new post notification %q: %v\n", n.Extra, err)
+				continue
 			}
-			return &p, nil
+			events <- ev
 		}
+	}()
+	return events
+}
+
+// feedMoveQueries are the queries of moveFeedTx.
+type feedMoveQueries interface {
+	GetFeedByUrl(context.Context, string) (database.Feed, error)
+	UpdateFeedUrl(context.Context, database.UpdateFeedUrlParams) (database.Feed, error)
+	MoveFeedFollows(context.Context, database.MoveFeedFollowsParams) error
+	MovePosts(context.Context, database.MovePostsParams) error
+	MoveFeedUrlHistory(context.Context, database.MoveFeedUrlHistoryParams) error
+	MoveFilterRules(context.Context, database.MoveFilterRulesParams) error
+	MoveWebhookFeeds(context.Context, database.MoveWebhookFeedsParams) error
+	MoveSavedSearchFeeds(context.Context, database.MoveSavedSearchFeedsParams) error
+	DeleteFeed(context.Context, uuid.UUID) error
+	CreateFeedUrlHistory(context.Context, database.CreateFeedUrlHistoryParams) (database.FeedUrlHistory, error)
+}
+
+// moveFeed points feed to newUrl after a permanent redirect, in a
+// transaction.
+func moveFeed(db *sql.DB, dbQueries *database.Queries, feed database.Feed, newUrl string, statusCode int) (database.Feed, error) {
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return database.Feed{}, err
+	}
+	defer tx.Rollback()
+
+	target, err := moveFeedTx(dbQueries.WithTx(tx), feed, newUrl, statusCode)
+	if err != nil {
+		return database.Feed{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return database.Feed{}, err
+	}
+	return target, nil
+}
 
-		go rss.Run(time.Duration(pollFrequencySec)*time.Second, feedsFetcher, feedMarker, postSaver)
+// moveFeedTx points feed to newUrl. When another feed is already stored at
+// newUrl, feed is merged into it: its follows, posts, url history, filter
+// rules and the webhooks and saved searches scoped to it are moved over and
+// feed is deleted.
+func moveFeedTx(qtx feedMoveQueries, feed database.Feed, newUrl string, statusCode int) (database.Feed, error) {
+	ctx := context.Background()
+	target, err := qtx.GetFeedByUrl(ctx, newUrl)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		target, err = qtx.UpdateFeedUrl(ctx, database.UpdateFeedUrlParams{ID: feed.ID, Url: newUrl, UpdatedAt: time.Now()})
+		if err != nil {
+			return database.Feed{}, err
+		}
+	case err != nil:
+		return database.Feed{}, err
+	case target.ID != feed.ID:
+		if err := qtx.MoveFeedFollows(ctx, database.MoveFeedFollowsParams{FromFeedID: feed.ID, ToFeedID: target.ID}); err != nil {
+			return database.Feed{}, err
+		}
+		if err := qtx.MovePosts(ctx, database.MovePostsParams{FromFeedID: feed.ID, ToFeedID: target.ID}); err != nil {
+			return database.Feed{}, err
+		}
+		if err := qtx.MoveFeedUrlHistory(ctx, database.MoveFeedUrlHistoryParams{FromFeedID: feed.ID, ToFeedID: target.ID}); err != nil {
+			return database.Feed{}, err
+		}
+		if err := qtx.MoveFilterRules(ctx, database.MoveFilterRulesParams{FromFeedID: feed.ID, ToFeedID: target.ID}); err != nil {
+			return database.Feed{}, err
+		}
+		if err := qtx.MoveWebhookFeeds(ctx, database.MoveWebhookFeedsParams{FromFeedID: feed.ID, ToFeedID: target.ID}); err != nil {
+			return database.Feed{}, err
+		}
+		if err := qtx.MoveSavedSearchFeeds(ctx, database.MoveSavedSearchFeedsParams{FromFeedID: feed.ID, ToFeedID: target.ID}); err != nil {
+			return database.Feed{}, err
+		}
+		if err := qtx.DeleteFeed(ctx, feed.ID); err != nil {
+			return database.Feed{}, err
+		}
 	}
 
-	api.Run(dbQueries)
+	if _, err := qtx.CreateFeedUrlHistory(ctx, database.CreateFeedUrlHistoryParams{
+		ID:         uuid.New(),
+		CreatedAt:  time.Now(),
+		FeedID:     target.ID,
+		OldUrl:     feed.Url,
+		NewUrl:     sql.NullString{String: newUrl, Valid: true},
+		StatusCode: int32(statusCode),
+	}); err != nil {
+		return database.Feed{}, err
+	}
+	return target, nil
+}
+
+// websubRetryInterval is the minimum delay between two subscription
+// requests for the same feed while the hub hasn't verified the previous one.
+const websubRetryInterval = time.Hour
+
+// subscribeFeed requests a WebSub subscription of feedId to hub, unless one
+// was requested recently. The secret of the subscription is kept across
+// renewals to the same hub so that pushes signed before the renewal is
+// verified are still accepted.
+func subscribeFeed(dbQueries *database.Queries, fetcher *rss.Fetcher, callbackUrl string, lease time.Duration, feedId uuid.UUID, hub, topic string) error {
+	ctx := context.Background()
+	sub, err := dbQueries.GetWebsubSubscription(ctx, feedId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	found := err == nil
+	if found && sub.HubUrl == hub && sub.Topic == topic && time.Since(sub.RequestedAt) < websubRetryInterval {
+		return nil
+	}
+
+	secret := sub.Secret
+	if !found || sub.HubUrl != hub {
+		if secret, err = rss.NewWebSubSecret(); err != nil {
+			return err
+		}
+	}
+	// a new token for each request, checked when the hub verifies it
+	token, err := rss.NewWebSubSecret()
+	if err != nil {
+		return err
+	}
+	if _, err := dbQueries.UpsertWebsubSubscription(ctx, database.UpsertWebsubSubscriptionParams{
+		FeedID:        feedId,
+		RequestedAt:   time.Now(),
+		HubUrl:        hub,
+		Topic:         topic,
+		Secret:        secret,
+		CallbackToken: token,
+	}); err != nil {
+		return err
+	}
+	callback := strings.TrimSuffix(callbackUrl, "/") + "/" + feedId.String() + "?token=" + token
+	return fetcher.Subscribe(hub, topic, callback, secret, lease)
+}
+
+// applyFilterRules saves the state of a new post for the followers of its
+// feed with matching filter rules.
+func applyFilterRules(dbQueries *database.Queries, post database.Post) error {
+	dbRules, err := dbQueries.ListFeedFilterRules(context.Background(), post.FeedID)
+	if err != nil || len(dbRules) == 0 {
+		return err
+	}
+	feed, err := dbQueries.GetFeed(context.Background(), post.FeedID)
+	if err != nil {
+		return err
+	}
+	rules, err := filter.Compile(dbRules)
+	if err != nil {
+		// rules are validated when created, a broken one must not block ingestion
+		log.Printf("invalid filter rules of feed %v: %v\n", post.FeedID, err)
+		return nil
+	}
+	now := time.Now()
+	for userId, state := range filter.Evaluate(rules, filter.NewTarget(post, feed.Name)) {
+		if err := dbQueries.UpsertPostState(context.Background(), state.Params(userId, post.ID, now)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fetcherConfig() rss.FetcherConfig {
+	cfg := rss.DefaultFetcherConfig()
+	if userAgent := os.Getenv("FETCH_USER_AGENT"); userAgent != "" {
+		cfg.UserAgent = userAgent
+	}
+	cfg.ConnectTimeout = envSeconds("FETCH_CONNECT_TIMEOUT_SECONDS", cfg.ConnectTimeout)
+	cfg.ReadTimeout = envSeconds("FETCH_READ_TIMEOUT_SECONDS", cfg.ReadTimeout)
+	cfg.HostInterval = envSeconds("FETCH_HOST_INTERVAL_SECONDS", cfg.HostInterval)
+	if maxRedirectsStr := os.Getenv("FETCH_MAX_REDIRECTS"); maxRedirectsStr != "" {
+		maxRedirects, err := strconv.Atoi(maxRedirectsStr)
+		if err != nil || maxRedirects < 0 {
+			log.Fatalf("invalid fetch max redirects %v: %v", maxRedirectsStr, err)
+		}
+		cfg.MaxRedirects = maxRedirects
+	}
+	if maxBodySizeStr := os.Getenv("FEED_MAX_BODY_BYTES"); maxBodySizeStr != "" {
+		maxBodySize, err := strconv.ParseInt(maxBodySizeStr, 10, 64)
+		if err != nil || maxBodySize <= 0 {
+			log.Fatalf("invalid feed max body bytes %v: %v", maxBodySizeStr, err)
+		}
+		cfg.MaxBodySize = maxBodySize
+	}
+	if respectRobotsStr := os.Getenv("FETCH_RESPECT_ROBOTS"); respectRobotsStr != "" {
+		respectRobots, err := strconv.ParseBool(respectRobotsStr)
+		if err != nil {
+			log.Fatalf("invalid fetch respect robots %v: %v", respectRobotsStr, err)
+		}
+		cfg.RespectRobots = respectRobots
+	}
+	return cfg
+}
+
+func envSeconds(name string, def time.Duration) time.Duration {
+	str := os.Getenv(name)
+	if str == "" {
+		return def
+	}
+	sec, err := strconv.ParseFloat(str, 64)
+	if err != nil || sec < 0 {
+		log.Fatalf("invalid %v %v: %v", name, str, err)
+	}
+	return time.Duration(sec * float64(time.Second))
+}
+
+func episodeParams(postId uuid.UUID, ep *rss.Episode) database.CreatePostEpisodeParams {
+	params := database.CreatePostEpisodeParams{
+		ID:            uuid.New(),
+		CreatedAt:     time.Now(),
+		PostID:        postId,
+		EnclosureUrl:  ep.EnclosureUrl,
+		EnclosureType: ep.EnclosureType,
+	}
+	if ep.EnclosureLength != nil {
+		params.EnclosureLength = sql.NullInt64{Int64: *ep.EnclosureLength, Valid: true}
+	}
+	if ep.DurationSeconds != nil {
+		params.DurationSeconds = sql.NullInt32{Int32: *ep.DurationSeconds, Valid: true}
+	}
+	if ep.ImageUrl != nil {
+		params.ImageUrl = sql.NullString{String: *ep.ImageUrl, Valid: true}
+	}
+	if ep.Episode != nil {
+		params.Episode = sql.NullInt32{Int32: *ep.Episode, Valid: true}
+	}
+	if ep.Season != nil {
+		params.Season = sql.NullInt32{Int32: *ep.Season, Valid: true}
+	}
+	if ep.Explicit != nil {
+		params.Explicit = sql.NullBool{Bool: *ep.Explicit, Valid: true}
+	}
+	return params
 }
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/sp3dr4/bloggogrator/internal/database"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockedFeedMoveQueries struct {
+	mock.Mock
+}
+
+func (m *mockedFeedMoveQueries) GetFeedByUrl(ctx context.Context, url string) (database.Feed, error) {
+	args := m.Called(ctx, url)
+	return args.Get(0).(database.Feed), args.Error(1)
+}
+
+func (m *mockedFeedMoveQueries) UpdateFeedUrl(ctx context.Context, params database.UpdateFeedUrlParams) (database.Feed, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(database.Feed), args.Error(1)
+}
+
+func (m *mockedFeedMoveQueries) MoveFeedFollows(ctx context.Context, params database.MoveFeedFollowsParams) error {
+	return m.Called(ctx, params).Error(0)
+}
+
+func (m *mockedFeedMoveQueries) MovePosts(ctx context.Context, params database.MovePostsParams) error {
+	return m.Called(ctx, params).Error(0)
+}
+
+func (m *mockedFeedMoveQueries) MoveFeedUrlHistory(ctx context.Context, params database.MoveFeedUrlHistoryParams) error {
+	return m.Called(ctx, params).Error(0)
+}
+
+func (m *mockedFeedMoveQueries) MoveFilterRules(ctx context.Context, params database.MoveFilterRulesParams) error {
+	return m.Called(ctx, params).Error(0)
+}
+
+func (m *mockedFeedMoveQueries) MoveWebhookFeeds(ctx context.Context, params database.MoveWebhookFeedsParams) error {
+	return m.Called(ctx, params).Error(0)
+}
+
+func (m *mockedFeedMoveQueries) MoveSavedSearchFeeds(ctx context.Context, params database.MoveSavedSearchFeedsParams) error {
+	return m.Called(ctx, params).Error(0)
+}
+
+func (m *mockedFeedMoveQueries) DeleteFeed(ctx context.Context, id uuid.UUID) error {
+	return m.Called(ctx, id).Error(0)
+}
+
+func (m *mockedFeedMoveQueries) CreateFeedUrlHistory(ctx context.Context, params database.CreateFeedUrlHistoryParams) (database.FeedUrlHistory, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(database.FeedUrlHistory), args.Error(1)
+}
+
+func TestMoveFeedTx(t *testing.T) {
+	t.Run("merge into the existing feed", func(t *testing.T) {
+		q := new(mockedFeedMoveQueries)
+		feed := database.Feed{ID: uuid.New(), Url: "http://example.com/old"}
+		target := database.Feed{ID: uuid.New(), Url: "http://example.com/new"}
+		q.On("GetFeedByUrl", mock.Anything, target.Url).Return(target, nil)
+		q.On("MoveFeedFollows", mock.Anything, database.MoveFeedFollowsParams{FromFeedID: feed.ID, ToFeedID: target.ID}).Return(nil)
+		q.On("MovePosts", mock.Anything, database.MovePostsParams{FromFeedID: feed.ID, ToFeedID: target.ID}).Return(nil)
+		q.On("MoveFeedUrlHistory", mock.Anything, database.MoveFeedUrlHistoryParams{FromFeedID: feed.ID, ToFeedID: target.ID}).Return(nil)
+		q.On("MoveFilterRules", mock.Anything, database.MoveFilterRulesParams{FromFeedID: feed.ID, ToFeedID: target.ID}).Return(nil)
+		q.On("MoveWebhookFeeds", mock.Anything, database.MoveWebhookFeedsParams{FromFeedID: feed.ID, ToFeedID: target.ID}).Return(nil)
+		q.On("MoveSavedSearchFeeds", mock.Anything, database.MoveSavedSearchFeedsParams{FromFeedID: feed.ID, ToFeedID: target.ID}).Return(nil)
+		q.On("DeleteFeed", mock.Anything, feed.ID).Return(nil)
+		q.On("CreateFeedUrlHistory", mock.Anything, mock.MatchedBy(func(p database.CreateFeedUrlHistoryParams) bool {
+			return p.FeedID == target.ID && p.OldUrl == feed.Url && p.StatusCode == 301
+		})).Return(database.FeedUrlHistory{}, nil)
+
+		moved, err := moveFeedTx(q, feed, target.Url, 301)
+
+		require.NoError(t, err)
+		require.Equal(t, target.ID, moved.ID)
+		q.AssertExpectations(t)
+	})
+
+	t.Run("update the url", func(t *testing.T) {
+		q := new(mockedFeedMoveQueries)
+		feed := database.Feed{ID: uuid.New(), Url: "http://example.com/old"}
+		updated := database.Feed{ID: feed.ID, Url: "http://example.com/new"}
+		q.On("GetFeedByUrl", mock.Anything, updated.Url).Return(database.Feed{}, sql.ErrNoRows)
+		q.On("UpdateFeedUrl", mock.Anything, mock.MatchedBy(func(p database.UpdateFeedUrlParams) bool {
+			return p.ID == feed.ID && p.Url == updated.Url
+		})).Return(updated, nil)
+		q.On("CreateFeedUrlHistory", mock.Anything, mock.Anything).Return(database.FeedUrlHistory{}, nil)
+
+		moved, err := moveFeedTx(q, feed, updated.Url, 308)
+
+		require.NoError(t, err)
+		require.Equal(t, updated, moved)
+		q.AssertExpectations(t)
+		q.AssertNotCalled(t, "MoveFilterRules", mock.Anything, mock.Anything)
+	})
+}
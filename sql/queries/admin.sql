@@ -0,0 +1,67 @@
+-- name: ListUsers :many
+SELECT * FROM users
+ORDER BY created_at ASC
+LIMIT $1 OFFSET $2;
+
+-- name: SetUserDisabled :one
+UPDATE users SET disabled_at = $2, updated_at = $3
+WHERE id = $1
+RETURNING *;
+
+-- name: SetUserAdmin :one
+UPDATE users SET is_admin = $2, updated_at = $3
+WHERE id = $1
+RETURNING *;
+
+-- name: BootstrapAdmins :execrows
+-- Emails are not verified, so the users are only promoted while there is no
+-- admin yet. Later admins are managed through the admin API.
+UPDATE users SET is_admin = true
+WHERE email = ANY(sqlc.arg(emails)::text[])
+  AND NOT EXISTS (SELECT 1 FROM users a WHERE a.is_admin);
+
+-- name: ListFeedsWithStats :many
+SELECT sqlc.embed(f),
+  (SELECT count(*) FROM feed_follows fw WHERE fw.feed_id = f.id) AS followers,
+  count(ff.id) AS fetches,
+  count(ff.error) AS failed_fetches,
+  coalesce(avg(ff.duration_ms), 0)::integer AS avg_duration_ms,
+  coalesce(sum(ff.posts_inserted), 0)::bigint AS posts_inserted
+FROM feeds f
+  LEFT JOIN feed_fetches ff ON ff.feed_id = f.id
+GROUP BY f.id
+ORDER BY f.created_at ASC
+LIMIT $1 OFFSET $2;
+
+-- name: SetFeedDisabled :one
+UPDATE feeds SET disabled_at = $2, updated_at = $3
+WHERE id = $1
+RETURNING *;
+
+-- name: ScheduleFeedFetch :one
+UPDATE feeds SET next_fetch_at = $2, refresh_requested_at = $2, dead_at = NULL, updated_at = $2
+WHERE id = $1
+RETURNING *;
+
+-- name: DeletePost :execrows
+WITH deleted AS (
+  DELETE FROM posts WHERE id = $1
+  RETURNING url
+)
+INSERT INTO deleted_post_urls (url, deleted_at)
+SELECT url, sqlc.arg(deleted_at) FROM deleted
+ON CONFLICT (url) DO UPDATE SET deleted_at = EXCLUDED.deleted_at;
+
+-- name: GetWorkerStats :one
+SELECT
+  (SELECT count(*) FROM feeds) AS feeds,
+  (SELECT count(*) FROM feeds WHERE dead_at IS NOT NULL) AS dead_feeds,
+  (SELECT count(*) FROM feeds WHERE disabled_at IS NOT NULL) AS disabled_feeds,
+  (SELECT count(*) FROM feeds
+    WHERE dead_at IS NULL AND disabled_at IS NULL
+      AND (next_fetch_at IS NULL OR next_fetch_at <= now())) AS due_feeds,
+  (SELECT count(*) FROM feed_fetches rf WHERE rf.started_at > sqlc.arg(since)) AS recent_fetches,
+  (SELECT count(*) FROM feed_fetches ef WHERE ef.started_at > sqlc.arg(since) AND ef.error IS NOT NULL) AS recent_failed_fetches,
+  (SELECT count(*) FROM webhook_deliveries WHERE status = 'pending') AS pending_webhook_deliveries,
+  (SELECT lf.last_fetched_at FROM feeds lf
+    ORDER BY lf.last_fetched_at DESC NULLS LAST LIMIT 1) AS last_fetched_at;
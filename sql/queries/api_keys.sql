@@ -0,0 +1,31 @@
+-- name: CreateApiKey :one
+INSERT INTO api_keys (id, created_at, user_id, label, key_hash, key_prefix, scopes, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING *;
+
+-- name: GetApiKey :one
+SELECT * FROM api_keys
+WHERE id = $1;
+
+-- name: ListUserApiKeys :many
+SELECT * FROM api_keys
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: RevokeApiKey :exec
+UPDATE api_keys SET revoked_at = $2
+WHERE id = $1 AND revoked_at IS NULL;
+
+-- name: GetUserByScopedApiKeyHash :one
+SELECT sqlc.embed(u), sqlc.embed(k)
+FROM api_keys k
+  INNER JOIN users u ON u.id = k.user_id
+WHERE k.key_hash = $1
+  AND k.revoked_at IS NULL
+  AND (k.expires_at IS NULL OR k.expires_at > now())
+  AND u.disabled_at IS NULL;
+
+-- name: TouchApiKey :exec
+UPDATE api_keys SET last_used_at = sqlc.arg(used_at)
+WHERE id = $1
+  AND (last_used_at IS NULL OR last_used_at < sqlc.arg(used_before));
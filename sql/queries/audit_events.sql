@@ -0,0 +1,10 @@
+-- name: CreateAuditEvent :one
+INSERT INTO audit_events (id, created_at, user_id, action, remote_addr, details)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: ListUserAuditEvents :many
+SELECT * FROM audit_events
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT $2;
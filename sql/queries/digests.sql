@@ -0,0 +1,62 @@
+-- name: UpsertDigestSubscription :one
+-- A new address has to be confirmed again.
+INSERT INTO digest_subscriptions (user_id, created_at, updated_at, email, frequency, send_hour, weekday, timezone, unsubscribe_token, confirmation_token)
+VALUES ($1, $2, $2, $3, $4, $5, $6, $7, $8, $9)
+ON CONFLICT (user_id) DO UPDATE SET
+  updated_at = EXCLUDED.updated_at, email = EXCLUDED.email, frequency = EXCLUDED.frequency,
+  send_hour = EXCLUDED.send_hour, weekday = EXCLUDED.weekday, timezone = EXCLUDED.timezone,
+  confirmation_token = CASE WHEN digest_subscriptions.email = EXCLUDED.email
+    THEN digest_subscriptions.confirmation_token ELSE EXCLUDED.confirmation_token END,
+  confirmation_sent_at = CASE WHEN digest_subscriptions.email = EXCLUDED.email
+    THEN digest_subscriptions.confirmation_sent_at END,
+  confirmed_at = CASE WHEN digest_subscriptions.email = EXCLUDED.email
+    THEN digest_subscriptions.confirmed_at END
+RETURNING *;
+
+-- name: GetDigestSubscription :one
+SELECT * FROM digest_subscriptions
+WHERE user_id = $1;
+
+-- name: ListDigestSubscriptions :many
+SELECT * FROM digest_subscriptions;
+
+-- name: DeleteDigestSubscription :exec
+DELETE FROM digest_subscriptions
+WHERE user_id = $1;
+
+-- name: DeleteDigestSubscriptionByToken :execrows
+DELETE FROM digest_subscriptions
+WHERE unsubscribe_token = $1;
+
+-- name: ClaimDigestConfirmation :execrows
+UPDATE digest_subscriptions SET confirmation_sent_at = sqlc.arg(sent_at)::timestamptz
+WHERE user_id = sqlc.arg(user_id) AND confirmation_token = sqlc.arg(confirmation_token)
+  AND confirmation_sent_at IS NULL AND confirmed_at IS NULL;
+
+-- name: ConfirmDigestSubscription :execrows
+UPDATE digest_subscriptions SET confirmed_at = COALESCE(confirmed_at, sqlc.arg(confirmed_at)::timestamptz)
+WHERE confirmation_token = sqlc.arg(confirmation_token);
+
+-- name: ClaimDigestRun :one
+INSERT INTO digest_runs (id, created_at, user_id, period_start, scheduled_for, status)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (user_id, scheduled_for) DO NOTHING
+RETURNING *;
+
+-- name: FinishDigestRun :one
+UPDATE digest_runs SET status = $2, posts = $3, finished_at = $4, error = $5
+WHERE id = $1
+RETURNING *;
+
+-- name: GetFollowedPostsBetween :many
+SELECT p.*
+FROM posts p
+  INNER JOIN feed_follows ff ON p.feed_id = ff.feed_id
+WHERE ff.user_id = sqlc.arg(user_id)
+  AND p.created_at > sqlc.arg(after) AND p.created_at <= sqlc.arg(until)
+  AND NOT EXISTS (
+    SELECT 1 FROM post_states ps
+    WHERE ps.post_id = p.id AND ps.user_id = sqlc.arg(user_id) AND (ps.hidden OR ps.read)
+  )
+ORDER BY p.published_at DESC
+LIMIT sqlc.arg(amount);
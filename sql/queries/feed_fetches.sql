@@ -0,0 +1,20 @@
+-- name: CreateFeedFetch :one
+INSERT INTO feed_fetches (id, feed_id, started_at, finished_at, duration_ms, status_code, bytes, items_seen, posts_inserted, posts_updated, error)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+RETURNING *;
+
+-- name: ListFeedFetches :many
+SELECT * FROM feed_fetches
+WHERE feed_id = $1
+ORDER BY started_at DESC
+LIMIT $2;
+
+-- name: PruneFeedFetches :exec
+DELETE FROM feed_fetches ff
+WHERE ff.feed_id = sqlc.arg(feed_id)
+  AND ff.id NOT IN (
+    SELECT kept.id FROM feed_fetches kept
+    WHERE kept.feed_id = sqlc.arg(feed_id)
+    ORDER BY kept.started_at DESC
+    LIMIT sqlc.arg(keep)
+  );
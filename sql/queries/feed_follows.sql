@@ -14,3 +14,13 @@ WHERE user_id = $1;
 -- name: DeleteFeedFollow :exec
 DELETE FROM feed_follows
 WHERE id = $1;
+
+-- name: MoveFeedFollows :exec
+INSERT INTO feed_follows (id, created_at, user_id, feed_id)
+SELECT gen_random_uuid(), ff.created_at, ff.user_id, sqlc.arg(to_feed_id)
+FROM feed_follows ff
+WHERE ff.feed_id = sqlc.arg(from_feed_id)
+  AND NOT EXISTS (
+    SELECT 1 FROM feed_follows existing
+    WHERE existing.feed_id = sqlc.arg(to_feed_id) AND existing.user_id = ff.user_id
+  );
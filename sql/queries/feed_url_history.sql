@@ -0,0 +1,8 @@
+-- name: CreateFeedUrlHistory :one
+INSERT INTO feed_url_history (id, created_at, feed_id, old_url, new_url, status_code)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: MoveFeedUrlHistory :exec
+UPDATE feed_url_history SET feed_id = sqlc.arg(to_feed_id)
+WHERE feed_id = sqlc.arg(from_feed_id);
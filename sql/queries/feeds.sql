@@ -12,10 +12,56 @@ WHERE id = $1;
 
 -- name: GetNextFeedsToFetch :many
 SELECT * FROM feeds
+WHERE dead_at IS NULL
+  AND disabled_at IS NULL
+  AND (next_fetch_at IS NULL OR next_fetch_at <= now())
+  AND (refresh_requested_at IS NOT NULL OR NOT EXISTS (
+    SELECT 1 FROM websub_subscriptions ws
+    WHERE ws.feed_id = feeds.id AND ws.lease_expires_at > now()
+  ))
 ORDER BY last_fetched_at ASC NULLS FIRST
 LIMIT $1;
 
 -- name: MarkFeedFetched :one
-UPDATE feeds SET last_fetched_at = $2, updated_at = $2
+UPDATE feeds SET last_fetched_at = $2, updated_at = $2,
+  consecutive_failures = 0, last_error = NULL, last_error_kind = NULL,
+  last_error_status = NULL, last_error_at = NULL, next_fetch_at = NULL,
+  refresh_requested_at = NULL
 WHERE id = $1
 RETURNING *;
+
+-- name: MarkFeedFailed :one
+UPDATE feeds SET last_fetched_at = $2, updated_at = $2,
+  consecutive_failures = consecutive_failures + 1, last_error = $3, last_error_kind = $4,
+  last_error_status = $5, last_error_at = $2, next_fetch_at = $6,
+  refresh_requested_at = NULL
+WHERE id = $1
+RETURNING *;
+
+-- name: SetFeedFetchFullContent :one
+UPDATE feeds SET fetch_full_content = $2, updated_at = $3
+WHERE id = $1
+RETURNING *;
+
+-- name: SetFeedProxyUrl :one
+UPDATE feeds SET proxy_url = $2, updated_at = $3
+WHERE id = $1
+RETURNING *;
+
+-- name: GetFeedByUrl :one
+SELECT * FROM feeds
+WHERE url = $1;
+
+-- name: UpdateFeedUrl :one
+UPDATE feeds SET url = $2, updated_at = $3
+WHERE id = $1
+RETURNING *;
+
+-- name: MarkFeedDead :one
+UPDATE feeds SET dead_at = $2, updated_at = $2
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteFeed :exec
+DELETE FROM feeds
+WHERE id = $1;
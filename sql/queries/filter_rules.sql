@@ -0,0 +1,43 @@
+-- name: CreateFilterRule :one
+INSERT INTO filter_rules (id, created_at, updated_at, user_id, feed_id, field, match, pattern, action, tag)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+RETURNING *;
+
+-- name: GetFilterRule :one
+SELECT * FROM filter_rules
+WHERE id = $1;
+
+-- name: ListUserFilterRules :many
+SELECT * FROM filter_rules
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: DeleteFilterRule :exec
+DELETE FROM filter_rules
+WHERE id = $1;
+
+-- name: ListFeedFilterRules :many
+SELECT r.*
+FROM filter_rules r
+  INNER JOIN feed_follows ff ON ff.user_id = r.user_id
+WHERE ff.feed_id = $1
+  AND (r.feed_id IS NULL OR r.feed_id = $1)
+ORDER BY r.created_at ASC;
+
+-- name: UpsertPostState :exec
+INSERT INTO post_states (user_id, post_id, created_at, updated_at, hidden, read, starred, tags)
+VALUES ($1, $2, $3, $3, $4, $5, $6, $7)
+ON CONFLICT (user_id, post_id) DO UPDATE SET
+  updated_at = EXCLUDED.updated_at,
+  hidden = post_states.hidden OR EXCLUDED.hidden,
+  read = post_states.read OR EXCLUDED.read,
+  starred = post_states.starred OR EXCLUDED.starred,
+  tags = ARRAY(SELECT DISTINCT unnest(post_states.tags || EXCLUDED.tags) ORDER BY 1);
+
+-- name: ListPostStates :many
+SELECT * FROM post_states
+WHERE user_id = $1 AND post_id = ANY(sqlc.arg(post_ids)::uuid[]);
+
+-- name: MoveFilterRules :exec
+UPDATE filter_rules SET feed_id = sqlc.arg(to_feed_id)::uuid
+WHERE feed_id = sqlc.arg(from_feed_id)::uuid;
@@ -0,0 +1,24 @@
+-- name: CreateInviteCode :one
+INSERT INTO invite_codes (id, created_at, created_by, code_hash, code_prefix, max_uses, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING *;
+
+-- name: ListInviteCodes :many
+SELECT * FROM invite_codes
+ORDER BY created_at DESC;
+
+-- name: RevokeInviteCode :execrows
+UPDATE invite_codes SET revoked_at = $2
+WHERE id = $1 AND revoked_at IS NULL;
+
+-- name: UseInviteCode :one
+UPDATE invite_codes SET uses = uses + 1
+WHERE code_hash = $1
+  AND revoked_at IS NULL
+  AND (expires_at IS NULL OR expires_at > now())
+  AND uses < max_uses
+RETURNING *;
+
+-- name: ReleaseInviteCode :exec
+UPDATE invite_codes SET uses = uses - 1
+WHERE id = $1 AND uses > 0;
@@ -0,0 +1,18 @@
+-- name: CreatePostEpisode :one
+INSERT INTO post_episodes (id, created_at, post_id, enclosure_url, enclosure_type, enclosure_length, duration_seconds, image_url, episode, season, explicit)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+RETURNING *;
+
+-- name: GetUserPostsWithEnclosure :many
+SELECT sqlc.embed(p), sqlc.embed(e)
+FROM posts p
+  INNER JOIN feeds f ON p.feed_id = f.id
+  INNER JOIN post_episodes e ON e.post_id = p.id
+WHERE NOT EXISTS (
+    SELECT 1 FROM post_states ps
+    WHERE ps.post_id = p.id AND ps.user_id = $1 AND ps.hidden
+  )
+  AND f.user_id = $1
+  AND e.enclosure_type LIKE sqlc.arg(media_type)::text || '/%'
+ORDER BY p.published_at DESC
+LIMIT $2;
@@ -1,12 +1,88 @@
 -- name: CreatePost :one
-INSERT INTO posts (id, created_at, updated_at, url, title, description, published_at, feed_id)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+INSERT INTO posts (id, created_at, updated_at, url, title, description, published_at, feed_id, description_text, author, categories)
+SELECT $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
+WHERE NOT EXISTS (SELECT 1 FROM deleted_post_urls d WHERE d.url = $4)
 RETURNING *;
 
 -- name: GetUserPosts :many
 SELECT p.*
 FROM posts p
   INNER JOIN feeds f ON p.feed_id = f.id
-WHERE f.user_id = $1
+WHERE NOT EXISTS (
+    SELECT 1 FROM post_states ps
+    WHERE ps.post_id = p.id AND ps.user_id = $1 AND ps.hidden
+  )
+  AND f.user_id = $1
 ORDER BY p.published_at DESC
 LIMIT $2;
+
+-- name: UpdatePostContent :exec
+UPDATE posts SET content = $2, updated_at = $3
+WHERE id = $1;
+
+-- name: MovePosts :exec
+UPDATE posts SET feed_id = sqlc.arg(to_feed_id)
+WHERE feed_id = sqlc.arg(from_feed_id);
+
+-- name: GetPost :one
+SELECT * FROM posts
+WHERE id = $1;
+
+-- name: GetFollowedPostsAfter :many
+SELECT p.*
+FROM posts p
+  INNER JOIN feed_follows ff ON p.feed_id = ff.feed_id
+WHERE ff.user_id = $1 AND p.seq > $2
+  AND NOT EXISTS (
+    SELECT 1 FROM post_states ps
+    WHERE ps.post_id = p.id AND ps.user_id = $1 AND ps.hidden
+  )
+ORDER BY p.seq ASC
+LIMIT $3;
+
+-- name: GetStreamResumeSeq :one
+-- seq is taken when a post is inserted, but the worker transactions commit
+-- out of order: a post may become visible after one with a higher seq was
+-- streamed. Streams resume after the last post created overlap_seconds
+-- before the last streamed one, replaying the posts in between.
+SELECT COALESCE((
+  SELECT p.seq FROM posts p
+  WHERE p.seq <= sqlc.arg(seq)::bigint
+    AND p.created_at < (
+      SELECT l.created_at FROM posts l
+      WHERE l.seq <= sqlc.arg(seq)::bigint
+      ORDER BY l.seq DESC
+      LIMIT 1
+    ) - make_interval(secs => sqlc.arg(overlap_seconds)::float8)
+  ORDER BY p.seq DESC
+  LIMIT 1
+), 0)::bigint AS seq;
+
+-- name: GetVisiblePost :one
+SELECT p.* FROM posts p
+WHERE p.id = sqlc.arg(id)
+  AND NOT EXISTS (
+    SELECT 1 FROM post_states ps
+    WHERE ps.post_id = p.id AND ps.user_id = sqlc.arg(user_id) AND ps.hidden
+  );
+
+-- name: GetFollowedPosts :many
+SELECT p.*
+FROM posts p
+  INNER JOIN feed_follows ff ON p.feed_id = ff.feed_id
+WHERE ff.user_id = $1
+  AND NOT EXISTS (
+    SELECT 1 FROM post_states ps
+    WHERE ps.post_id = p.id AND ps.user_id = $1 AND ps.hidden
+  )
+ORDER BY p.published_at DESC
+LIMIT $2;
+
+-- name: GetFollowedPostsWithFeedAfter :many
+SELECT sqlc.embed(p), f.name AS feed_name
+FROM posts p
+  INNER JOIN feed_follows ff ON p.feed_id = ff.feed_id
+  INNER JOIN feeds f ON p.feed_id = f.id
+WHERE ff.user_id = $1 AND p.seq > $2
+ORDER BY p.seq ASC
+LIMIT $3;
@@ -0,0 +1,21 @@
+-- name: CreatePublishedFeed :one
+INSERT INTO published_feeds (id, created_at, user_id, kind, token, saved_search_id)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetPublishedFeed :one
+SELECT * FROM published_feeds
+WHERE id = $1;
+
+-- name: GetPublishedFeedByToken :one
+SELECT * FROM published_feeds
+WHERE token = $1;
+
+-- name: ListUserPublishedFeeds :many
+SELECT * FROM published_feeds
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: DeletePublishedFeed :exec
+DELETE FROM published_feeds
+WHERE id = $1;
@@ -0,0 +1,19 @@
+-- name: TakeRateLimitToken :one
+-- Refills the bucket of key since its last update, at rate tokens per
+-- second up to capacity, then takes a token from it when there is one. The
+-- database clock is used so that the replicas agree on the elapsed time.
+INSERT INTO rate_limit_buckets AS b (key, tokens, allowed, updated_at)
+VALUES (sqlc.arg(key), sqlc.arg(capacity)::float8 - 1, true, now())
+ON CONFLICT (key) DO UPDATE SET
+  tokens = CASE
+    WHEN LEAST(sqlc.arg(capacity)::float8, b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at)::float8 * sqlc.arg(rate)::float8) >= 1
+    THEN LEAST(sqlc.arg(capacity)::float8, b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at)::float8 * sqlc.arg(rate)::float8) - 1
+    ELSE LEAST(sqlc.arg(capacity)::float8, b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at)::float8 * sqlc.arg(rate)::float8)
+  END,
+  allowed = LEAST(sqlc.arg(capacity)::float8, b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at)::float8 * sqlc.arg(rate)::float8) >= 1,
+  updated_at = now()
+RETURNING tokens, allowed;
+
+-- name: DeleteIdleRateLimitBuckets :execrows
+DELETE FROM rate_limit_buckets
+WHERE updated_at < now() - make_interval(secs => sqlc.arg(idle_seconds)::float8);
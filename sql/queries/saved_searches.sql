@@ -0,0 +1,62 @@
+-- name: CreateSavedSearch :one
+INSERT INTO saved_searches (id, created_at, updated_at, user_id, name, keywords, feed_ids, since, until)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING *;
+
+-- name: GetSavedSearch :one
+SELECT * FROM saved_searches
+WHERE id = $1;
+
+-- name: ListUserSavedSearches :many
+SELECT sqlc.embed(s), (
+  SELECT count(*)
+  FROM posts p
+    INNER JOIN feed_follows ff ON p.feed_id = ff.feed_id
+  WHERE ff.user_id = s.user_id
+    AND (cardinality(s.feed_ids) = 0 OR p.feed_id = ANY(s.feed_ids))
+    AND (s.since IS NULL OR p.published_at >= s.since)
+    AND (s.until IS NULL OR p.published_at < s.until)
+    AND NOT EXISTS (
+      SELECT 1 FROM unnest(s.keywords) k
+      -- position, unlike ILIKE, gives no meaning to % and _ in keywords
+      WHERE position(lower(k) in lower(concat_ws(' ', p.title, p.description_text))) = 0
+    )
+    AND NOT EXISTS (
+      SELECT 1 FROM post_states ps
+      WHERE ps.post_id = p.id AND ps.user_id = s.user_id AND (ps.hidden OR ps.read)
+    )
+) AS unread
+FROM saved_searches s
+WHERE s.user_id = $1
+ORDER BY s.name ASC;
+
+-- name: DeleteSavedSearch :exec
+DELETE FROM saved_searches
+WHERE id = $1;
+
+-- name: GetSavedSearchPosts :many
+SELECT p.*
+FROM saved_searches s
+  INNER JOIN feed_follows ff ON ff.user_id = s.user_id
+  INNER JOIN posts p ON p.feed_id = ff.feed_id
+WHERE s.id = $1
+  AND (cardinality(s.feed_ids) = 0 OR p.feed_id = ANY(s.feed_ids))
+  AND (s.since IS NULL OR p.published_at >= s.since)
+  AND (s.until IS NULL OR p.published_at < s.until)
+  AND NOT EXISTS (
+    SELECT 1 FROM unnest(s.keywords) k
+    WHERE position(lower(k) in lower(concat_ws(' ', p.title, p.description_text))) = 0
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM post_states ps
+    WHERE ps.post_id = p.id AND ps.user_id = s.user_id AND ps.hidden
+  )
+ORDER BY p.published_at DESC
+LIMIT $2;
+
+-- name: MoveSavedSearchFeeds :exec
+UPDATE saved_searches SET feed_ids = CASE
+    WHEN sqlc.arg(to_feed_id)::uuid = ANY(feed_ids) THEN array_remove(feed_ids, sqlc.arg(from_feed_id)::uuid)
+    ELSE array_replace(feed_ids, sqlc.arg(from_feed_id)::uuid, sqlc.arg(to_feed_id)::uuid)
+  END
+WHERE sqlc.arg(from_feed_id)::uuid = ANY(feed_ids);
@@ -0,0 +1,41 @@
+-- name: CreateSession :one
+INSERT INTO sessions (id, created_at, user_id, access_token_hash, access_expires_at, refresh_token_hash, refresh_expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING *;
+
+-- name: GetSession :one
+SELECT * FROM sessions
+WHERE id = $1;
+
+-- name: ListUserSessions :many
+SELECT * FROM sessions
+WHERE user_id = $1
+  AND revoked_at IS NULL
+  AND refresh_expires_at > now()
+ORDER BY created_at ASC;
+
+-- name: GetUserBySessionAccessHash :one
+SELECT sqlc.embed(u), sqlc.embed(s)
+FROM sessions s
+  INNER JOIN users u ON u.id = s.user_id
+WHERE s.access_token_hash = $1
+  AND s.revoked_at IS NULL
+  AND s.access_expires_at > now()
+  AND u.disabled_at IS NULL;
+
+-- name: RefreshSession :one
+UPDATE sessions SET access_token_hash = $1, access_expires_at = $2,
+  refresh_token_hash = $3, refresh_expires_at = $4, refreshed_at = $5
+WHERE refresh_token_hash = sqlc.arg(previous_refresh_token_hash)
+  AND revoked_at IS NULL
+  AND refresh_expires_at > now()
+RETURNING *;
+
+-- name: RevokeSession :exec
+UPDATE sessions SET revoked_at = $2
+WHERE id = $1 AND revoked_at IS NULL;
+
+-- name: RevokeSessionByRefreshHash :one
+UPDATE sessions SET revoked_at = $2
+WHERE refresh_token_hash = $1 AND revoked_at IS NULL
+RETURNING *;
@@ -1,8 +1,54 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, name, api_key)
-VALUES ($1, $2, $3, $4, encode(sha256(random()::text::bytea), 'hex'))
+INSERT INTO users (id, created_at, updated_at, name, api_key_hash, api_key_prefix, email, password_hash, invite_code_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 RETURNING *;
 
--- name: GetUserByApiKey :one
+-- name: GetUserByApiKeyHash :one
 SELECT * FROM users
-WHERE api_key = $1 LIMIT 1;
+WHERE (api_key_hash = $1
+    OR (previous_api_key_hash = $1 AND previous_api_key_expires_at > now()))
+  AND disabled_at IS NULL
+LIMIT 1;
+
+-- name: GetUserByEmail :one
+SELECT * FROM users
+WHERE email = $1;
+
+-- name: GetUser :one
+SELECT * FROM users
+WHERE id = $1;
+
+-- name: RotateUserApiKey :one
+UPDATE users SET api_key_hash = $2, api_key_prefix = $3, updated_at = $4,
+  previous_api_key_hash = $5, previous_api_key_expires_at = $6
+WHERE id = $1
+RETURNING *;
+
+-- name: UpdateUser :one
+UPDATE users SET name = $2, email = $3, preferences = $4, updated_at = $5
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteUser :execrows
+-- The feeds left without an owner or other followers go with the user, in
+-- the same statement. Both deletes see the rows as they were before it.
+WITH orphan_feeds AS (
+  DELETE FROM feeds f
+  WHERE (f.user_id IS NULL OR f.user_id = sqlc.arg(id)::uuid)
+    AND NOT EXISTS (SELECT 1 FROM feed_follows ff WHERE ff.feed_id = f.id AND ff.user_id <> sqlc.arg(id)::uuid)
+)
+DELETE FROM users u
+WHERE u.id = sqlc.arg(id)::uuid;
+
+-- name: ListUserFollowedFeeds :many
+SELECT f.* FROM feeds f
+  INNER JOIN feed_follows ff ON ff.feed_id = f.id
+WHERE ff.user_id = $1
+ORDER BY ff.created_at ASC;
+
+-- name: ListStarredPosts :many
+SELECT sqlc.embed(p), sqlc.embed(ps)
+FROM post_states ps
+  INNER JOIN posts p ON p.id = ps.post_id
+WHERE ps.user_id = $1 AND ps.starred
+ORDER BY p.published_at DESC;
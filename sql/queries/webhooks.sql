@@ -0,0 +1,71 @@
+-- name: CreateWebhook :one
+INSERT INTO webhooks (id, created_at, updated_at, user_id, url, secret, feed_ids, keywords)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING *;
+
+-- name: GetWebhook :one
+SELECT * FROM webhooks
+WHERE id = $1;
+
+-- name: ListUserWebhooks :many
+SELECT * FROM webhooks
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: DeleteWebhook :exec
+DELETE FROM webhooks
+WHERE id = $1;
+
+-- name: EnqueuePostWebhookDeliveries :exec
+INSERT INTO webhook_deliveries (id, created_at, webhook_id, post_id, event, next_attempt_at)
+SELECT gen_random_uuid(), now(), w.id, p.id, sqlc.arg(event), now()
+FROM posts p
+  INNER JOIN feed_follows ff ON ff.feed_id = p.feed_id
+  INNER JOIN webhooks w ON w.user_id = ff.user_id
+WHERE p.id = sqlc.arg(post_id)
+  AND (cardinality(w.feed_ids) = 0 OR p.feed_id = ANY(w.feed_ids))
+  AND (cardinality(w.keywords) = 0 OR EXISTS (
+    SELECT 1 FROM unnest(w.keywords) k
+    -- position, unlike ILIKE, gives no meaning to % and _ in keywords
+    WHERE position(lower(k) in lower(concat_ws(' ', p.title, p.description_text))) > 0
+  ))
+  -- the filter rules of the owner run first, in the same transaction
+  AND NOT EXISTS (
+    SELECT 1 FROM post_states ps
+    WHERE ps.post_id = p.id AND ps.user_id = w.user_id AND ps.hidden
+  );
+
+-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries (id, created_at, webhook_id, post_id, event, next_attempt_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: ClaimWebhookDeliveries :many
+UPDATE webhook_deliveries SET next_attempt_at = sqlc.arg(locked_until)
+WHERE id IN (
+  SELECT d.id FROM webhook_deliveries d
+  WHERE d.status = 'pending' AND d.next_attempt_at <= now()
+  ORDER BY d.next_attempt_at ASC
+  LIMIT sqlc.arg(amount)
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: RecordWebhookDeliveryAttempt :one
+UPDATE webhook_deliveries SET status = $2, attempts = attempts + 1, last_attempt_at = $3,
+  next_attempt_at = $4, response_status = $5, error = $6
+WHERE id = $1
+RETURNING *;
+
+-- name: ListWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE webhook_id = $1
+ORDER BY created_at DESC
+LIMIT $2;
+
+-- name: MoveWebhookFeeds :exec
+UPDATE webhooks SET feed_ids = CASE
+    WHEN sqlc.arg(to_feed_id)::uuid = ANY(feed_ids) THEN array_remove(feed_ids, sqlc.arg(from_feed_id)::uuid)
+    ELSE array_replace(feed_ids, sqlc.arg(from_feed_id)::uuid, sqlc.arg(to_feed_id)::uuid)
+  END
+WHERE sqlc.arg(from_feed_id)::uuid = ANY(feed_ids);
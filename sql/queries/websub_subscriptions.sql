@@ -0,0 +1,30 @@
+-- name: UpsertWebsubSubscription :one
+INSERT INTO websub_subscriptions (feed_id, created_at, updated_at, hub_url, topic, secret, requested_at, callback_token)
+VALUES (sqlc.arg(feed_id), sqlc.arg(requested_at), sqlc.arg(requested_at), sqlc.arg(hub_url), sqlc.arg(topic), sqlc.arg(secret), sqlc.arg(requested_at), sqlc.arg(callback_token))
+ON CONFLICT (feed_id) DO UPDATE SET
+  updated_at = EXCLUDED.updated_at, hub_url = EXCLUDED.hub_url, topic = EXCLUDED.topic,
+  secret = EXCLUDED.secret, requested_at = EXCLUDED.requested_at, callback_token = EXCLUDED.callback_token
+RETURNING *;
+
+-- name: GetWebsubSubscription :one
+SELECT * FROM websub_subscriptions
+WHERE feed_id = $1;
+
+-- name: VerifyWebsubSubscription :one
+UPDATE websub_subscriptions
+SET verified_at = sqlc.arg(verified_at), updated_at = sqlc.arg(verified_at), lease_expires_at = sqlc.arg(lease_expires_at)
+WHERE feed_id = sqlc.arg(feed_id)
+  AND callback_token = sqlc.arg(callback_token)
+  AND (verified_at IS NULL OR verified_at < requested_at)
+  AND requested_at >= sqlc.arg(requested_after)
+RETURNING *;
+
+-- name: DeleteWebsubSubscription :exec
+DELETE FROM websub_subscriptions
+WHERE feed_id = $1;
+
+-- name: GetExpiringWebsubSubscriptions :many
+SELECT * FROM websub_subscriptions
+WHERE lease_expires_at <= sqlc.arg(expires_before)
+  AND requested_at <= sqlc.arg(requested_before)
+ORDER BY lease_expires_at ASC;
@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS post_episodes (
+    id                  UUID PRIMARY KEY,
+    created_at          TIMESTAMP WITH TIME ZONE NOT NULL,
+    post_id             UUID NOT NULL UNIQUE,
+    FOREIGN KEY(post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    enclosure_url       TEXT NOT NULL,
+    enclosure_type      VARCHAR(255) NOT NULL,
+    enclosure_length    BIGINT,
+    duration_seconds    INTEGER,
+    image_url           TEXT,
+    episode             INTEGER,
+    season              INTEGER,
+    explicit            BOOLEAN
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS post_episodes;
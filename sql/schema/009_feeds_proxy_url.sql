@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE feeds
+    ADD COLUMN proxy_url TEXT;
+
+-- +goose Down
+ALTER TABLE feeds
+    DROP COLUMN proxy_url;
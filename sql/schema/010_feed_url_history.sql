@@ -0,0 +1,18 @@
+-- +goose Up
+ALTER TABLE feeds
+    ADD COLUMN dead_at TIMESTAMP WITH TIME ZONE;
+
+CREATE TABLE IF NOT EXISTS feed_url_history (
+    id          UUID PRIMARY KEY,
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL,
+    feed_id     UUID NOT NULL,
+    FOREIGN KEY(feed_id) REFERENCES feeds(id) ON DELETE CASCADE,
+    old_url     TEXT NOT NULL,
+    new_url     TEXT,
+    status_code INTEGER NOT NULL
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS feed_url_history;
+ALTER TABLE feeds
+    DROP COLUMN dead_at;
@@ -0,0 +1,17 @@
+-- +goose Up
+ALTER TABLE feeds
+    ADD COLUMN consecutive_failures INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN last_error           TEXT,
+    ADD COLUMN last_error_kind      VARCHAR(32),
+    ADD COLUMN last_error_status    INTEGER,
+    ADD COLUMN last_error_at        TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN next_fetch_at        TIMESTAMP WITH TIME ZONE;
+
+-- +goose Down
+ALTER TABLE feeds
+    DROP COLUMN consecutive_failures,
+    DROP COLUMN last_error,
+    DROP COLUMN last_error_kind,
+    DROP COLUMN last_error_status,
+    DROP COLUMN last_error_at,
+    DROP COLUMN next_fetch_at;
@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS feed_fetches (
+    id              UUID PRIMARY KEY,
+    feed_id         UUID NOT NULL,
+    FOREIGN KEY(feed_id) REFERENCES feeds(id) ON DELETE CASCADE,
+    started_at      TIMESTAMP WITH TIME ZONE NOT NULL,
+    finished_at     TIMESTAMP WITH TIME ZONE NOT NULL,
+    duration_ms     INTEGER NOT NULL,
+    status_code     INTEGER,
+    bytes           BIGINT NOT NULL DEFAULT 0,
+    items_seen      INTEGER NOT NULL DEFAULT 0,
+    posts_inserted  INTEGER NOT NULL DEFAULT 0,
+    posts_updated   INTEGER NOT NULL DEFAULT 0,
+    error           TEXT
+);
+
+CREATE INDEX IF NOT EXISTS feed_fetches_feed_id_started_at_idx ON feed_fetches (feed_id, started_at DESC);
+
+-- +goose Down
+DROP TABLE IF EXISTS feed_fetches;
@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS websub_subscriptions (
+    feed_id          UUID PRIMARY KEY,
+    FOREIGN KEY(feed_id) REFERENCES feeds(id) ON DELETE CASCADE,
+    created_at       TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at       TIMESTAMP WITH TIME ZONE NOT NULL,
+    hub_url          TEXT NOT NULL,
+    topic            TEXT NOT NULL,
+    secret           TEXT NOT NULL,
+    requested_at     TIMESTAMP WITH TIME ZONE NOT NULL,
+    verified_at      TIMESTAMP WITH TIME ZONE,
+    lease_expires_at TIMESTAMP WITH TIME ZONE
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS websub_subscriptions;
@@ -0,0 +1,24 @@
+-- +goose Up
+ALTER TABLE posts
+    ADD COLUMN seq BIGSERIAL NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS posts_seq_idx ON posts (seq);
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION notify_new_post() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('new_posts', json_build_object('id', NEW.id, 'feed_id', NEW.feed_id, 'seq', NEW.seq)::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER posts_notify_new AFTER INSERT ON posts
+    FOR EACH ROW EXECUTE FUNCTION notify_new_post();
+
+-- +goose Down
+DROP TRIGGER IF EXISTS posts_notify_new ON posts;
+DROP FUNCTION IF EXISTS notify_new_post();
+DROP INDEX IF EXISTS posts_seq_idx;
+ALTER TABLE posts
+    DROP COLUMN seq;
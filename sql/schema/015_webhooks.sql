@@ -0,0 +1,35 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS webhooks (
+    id          UUID PRIMARY KEY,
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at  TIMESTAMP WITH TIME ZONE NOT NULL,
+    user_id     UUID NOT NULL,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    url         TEXT NOT NULL,
+    secret      TEXT NOT NULL,
+    feed_ids    UUID[] NOT NULL DEFAULT '{}',
+    keywords    TEXT[] NOT NULL DEFAULT '{}'
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id              UUID PRIMARY KEY,
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL,
+    webhook_id      UUID NOT NULL,
+    FOREIGN KEY(webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE,
+    post_id         UUID,
+    FOREIGN KEY(post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    event           VARCHAR(32) NOT NULL,
+    status          VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE,
+    last_attempt_at TIMESTAMP WITH TIME ZONE,
+    response_status INTEGER,
+    error           TEXT
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_pending_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_id_created_at_idx ON webhook_deliveries (webhook_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS published_feeds (
+    id          UUID PRIMARY KEY,
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL,
+    user_id     UUID NOT NULL,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    kind        VARCHAR(32) NOT NULL,
+    token       VARCHAR(64) NOT NULL UNIQUE
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS published_feeds;
@@ -0,0 +1,31 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS digest_subscriptions (
+    user_id           UUID PRIMARY KEY,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    created_at        TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at        TIMESTAMP WITH TIME ZONE NOT NULL,
+    email             VARCHAR(320) NOT NULL,
+    frequency         VARCHAR(16) NOT NULL,
+    send_hour         INTEGER NOT NULL,
+    weekday           INTEGER NOT NULL DEFAULT 1,
+    timezone          VARCHAR(64) NOT NULL,
+    unsubscribe_token VARCHAR(64) NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS digest_runs (
+    id            UUID PRIMARY KEY,
+    created_at    TIMESTAMP WITH TIME ZONE NOT NULL,
+    user_id       UUID NOT NULL,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    period_start  TIMESTAMP WITH TIME ZONE NOT NULL,
+    scheduled_for TIMESTAMP WITH TIME ZONE NOT NULL,
+    status        VARCHAR(16) NOT NULL,
+    posts         INTEGER NOT NULL DEFAULT 0,
+    finished_at   TIMESTAMP WITH TIME ZONE,
+    error         TEXT,
+    UNIQUE(user_id, scheduled_for)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS digest_runs;
+DROP TABLE IF EXISTS digest_subscriptions;
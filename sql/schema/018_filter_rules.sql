@@ -0,0 +1,42 @@
+-- +goose Up
+ALTER TABLE posts
+    ADD COLUMN author     VARCHAR(255),
+    ADD COLUMN categories TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE TABLE IF NOT EXISTS filter_rules (
+    id          UUID PRIMARY KEY,
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at  TIMESTAMP WITH TIME ZONE NOT NULL,
+    user_id     UUID NOT NULL,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    feed_id     UUID,
+    FOREIGN KEY(feed_id) REFERENCES feeds(id) ON DELETE CASCADE,
+    field       VARCHAR(16) NOT NULL,
+    match       VARCHAR(16) NOT NULL,
+    pattern     TEXT NOT NULL,
+    action      VARCHAR(16) NOT NULL,
+    tag         VARCHAR(64)
+);
+
+CREATE INDEX IF NOT EXISTS filter_rules_user_id_idx ON filter_rules (user_id);
+
+CREATE TABLE IF NOT EXISTS post_states (
+    user_id     UUID NOT NULL,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    post_id     UUID NOT NULL,
+    FOREIGN KEY(post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at  TIMESTAMP WITH TIME ZONE NOT NULL,
+    hidden      BOOLEAN NOT NULL DEFAULT FALSE,
+    read        BOOLEAN NOT NULL DEFAULT FALSE,
+    starred     BOOLEAN NOT NULL DEFAULT FALSE,
+    tags        TEXT[] NOT NULL DEFAULT '{}',
+    PRIMARY KEY(user_id, post_id)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS post_states;
+DROP TABLE IF EXISTS filter_rules;
+ALTER TABLE posts
+    DROP COLUMN categories,
+    DROP COLUMN author;
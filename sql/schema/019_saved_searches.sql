@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS saved_searches (
+    id          UUID PRIMARY KEY,
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at  TIMESTAMP WITH TIME ZONE NOT NULL,
+    user_id     UUID NOT NULL,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    name        VARCHAR(255) NOT NULL,
+    keywords    TEXT[] NOT NULL DEFAULT '{}',
+    feed_ids    UUID[] NOT NULL DEFAULT '{}',
+    since       TIMESTAMP WITH TIME ZONE,
+    until       TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS saved_searches_user_id_idx ON saved_searches (user_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS saved_searches;
@@ -0,0 +1,45 @@
+-- +goose Up
+-- Keys are random, so an unsalted SHA-256 digest is enough to store them.
+-- Existing keys are converted in place and stay valid.
+ALTER TABLE users
+    ADD COLUMN api_key_hash   VARCHAR(64),
+    ADD COLUMN api_key_prefix VARCHAR(16);
+UPDATE users
+    SET api_key_hash = encode(sha256(api_key::bytea), 'hex'),
+        api_key_prefix = left(api_key, 8);
+ALTER TABLE users
+    ALTER COLUMN api_key_hash SET NOT NULL,
+    ALTER COLUMN api_key_prefix SET NOT NULL,
+    ADD CONSTRAINT users_api_key_hash_key UNIQUE (api_key_hash),
+    DROP COLUMN api_key;
+
+ALTER TABLE api_keys
+    ADD COLUMN key_hash   VARCHAR(64),
+    ADD COLUMN key_prefix VARCHAR(16);
+UPDATE api_keys
+    SET key_hash = encode(sha256(key::bytea), 'hex'),
+        key_prefix = left(key, 8);
+ALTER TABLE api_keys
+    ALTER COLUMN key_hash SET NOT NULL,
+    ALTER COLUMN key_prefix SET NOT NULL,
+    ADD CONSTRAINT api_keys_key_hash_key UNIQUE (key_hash),
+    DROP COLUMN key;
+
+-- +goose Down
+-- The plaintext keys can't be recovered: users get new keys and the other
+-- keys are dropped.
+DELETE FROM api_keys;
+ALTER TABLE api_keys
+    ADD COLUMN key VARCHAR(64) NOT NULL UNIQUE,
+    DROP COLUMN key_prefix,
+    DROP COLUMN key_hash;
+
+-- +goose StatementBegin
+ALTER TABLE users
+    ADD COLUMN api_key VARCHAR(64) NOT NULL UNIQUE DEFAULT encode(sha256(random()::text::bytea), 'hex');
+ALTER TABLE users
+    ALTER COLUMN api_key DROP DEFAULT;
+-- +goose StatementEnd
+ALTER TABLE users
+    DROP COLUMN api_key_prefix,
+    DROP COLUMN api_key_hash;
@@ -0,0 +1,27 @@
+-- +goose Up
+ALTER TABLE users
+    ADD COLUMN previous_api_key_hash       VARCHAR(64),
+    ADD COLUMN previous_api_key_expires_at TIMESTAMP WITH TIME ZONE;
+
+-- keys are looked up by their current or previous hash
+CREATE UNIQUE INDEX IF NOT EXISTS users_previous_api_key_hash_idx ON users (previous_api_key_hash)
+    WHERE previous_api_key_hash IS NOT NULL;
+
+-- audit_events outlive their user, so user_id isn't a foreign key.
+CREATE TABLE IF NOT EXISTS audit_events (
+    id          UUID PRIMARY KEY,
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL,
+    user_id     UUID NOT NULL,
+    action      VARCHAR(64) NOT NULL,
+    remote_addr VARCHAR(64),
+    details     JSONB NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX IF NOT EXISTS audit_events_user_id_created_at_idx ON audit_events (user_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE IF EXISTS audit_events;
+DROP INDEX IF EXISTS users_previous_api_key_hash_idx;
+ALTER TABLE users
+    DROP COLUMN previous_api_key_expires_at,
+    DROP COLUMN previous_api_key_hash;
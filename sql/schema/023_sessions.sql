@@ -0,0 +1,29 @@
+-- +goose Up
+-- Users created with an email sign in with their password. Emails are
+-- stored lowercased.
+ALTER TABLE users
+    ADD COLUMN email         VARCHAR(255) UNIQUE,
+    ADD COLUMN password_hash VARCHAR(60);
+
+-- A session is opened by a login. Its access token is short-lived and both
+-- tokens are replaced each time the refresh token is used.
+CREATE TABLE IF NOT EXISTS sessions (
+    id                 UUID PRIMARY KEY,
+    created_at         TIMESTAMP WITH TIME ZONE NOT NULL,
+    user_id            UUID NOT NULL,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    access_token_hash  VARCHAR(64) NOT NULL UNIQUE,
+    access_expires_at  TIMESTAMP WITH TIME ZONE NOT NULL,
+    refresh_token_hash VARCHAR(64) NOT NULL UNIQUE,
+    refresh_expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    refreshed_at       TIMESTAMP WITH TIME ZONE,
+    revoked_at         TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS sessions_user_id_idx ON sessions (user_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS sessions;
+ALTER TABLE users
+    DROP COLUMN password_hash,
+    DROP COLUMN email;
@@ -0,0 +1,24 @@
+-- +goose Up
+ALTER TABLE users
+    ADD COLUMN is_admin    BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN disabled_at TIMESTAMP WITH TIME ZONE;
+
+-- Disabled feeds aren't fetched until an admin enables them again, unlike
+-- dead feeds which the worker gives up on.
+ALTER TABLE feeds
+    ADD COLUMN disabled_at TIMESTAMP WITH TIME ZONE;
+
+-- The urls of the posts deleted by an admin, so the next fetches don't
+-- insert them again.
+CREATE TABLE IF NOT EXISTS deleted_post_urls (
+    url        TEXT PRIMARY KEY,
+    deleted_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS deleted_post_urls;
+ALTER TABLE feeds
+    DROP COLUMN disabled_at;
+ALTER TABLE users
+    DROP COLUMN disabled_at,
+    DROP COLUMN is_admin;
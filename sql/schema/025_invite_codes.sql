@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS invite_codes (
+    id          UUID PRIMARY KEY,
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_by  UUID,
+    FOREIGN KEY(created_by) REFERENCES users(id) ON DELETE SET NULL,
+    code_hash   VARCHAR(64) NOT NULL UNIQUE,
+    code_prefix VARCHAR(16) NOT NULL,
+    max_uses    INTEGER NOT NULL,
+    uses        INTEGER NOT NULL DEFAULT 0,
+    expires_at  TIMESTAMP WITH TIME ZONE,
+    revoked_at  TIMESTAMP WITH TIME ZONE
+);
+
+ALTER TABLE users
+    ADD COLUMN invite_code_id UUID REFERENCES invite_codes(id) ON DELETE SET NULL;
+
+-- +goose Down
+ALTER TABLE users
+    DROP COLUMN invite_code_id;
+DROP TABLE IF EXISTS invite_codes;
@@ -0,0 +1,18 @@
+-- +goose Up
+ALTER TABLE users
+    ADD COLUMN preferences JSONB NOT NULL DEFAULT '{}';
+
+-- Feeds are shared by their followers and outlive the user who added them.
+ALTER TABLE feeds
+    ALTER COLUMN user_id DROP NOT NULL,
+    DROP CONSTRAINT feeds_user_id_fkey,
+    ADD CONSTRAINT feeds_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL;
+
+-- +goose Down
+DELETE FROM feeds WHERE user_id IS NULL;
+ALTER TABLE feeds
+    DROP CONSTRAINT feeds_user_id_fkey,
+    ADD CONSTRAINT feeds_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    ALTER COLUMN user_id SET NOT NULL;
+ALTER TABLE users
+    DROP COLUMN preferences;
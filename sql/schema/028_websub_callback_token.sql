@@ -0,0 +1,9 @@
+-- +goose Up
+-- callback_token is sent in the callback url of each subscription request,
+-- so that only the hub can verify it.
+ALTER TABLE websub_subscriptions
+    ADD COLUMN callback_token TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE websub_subscriptions
+    DROP COLUMN callback_token;
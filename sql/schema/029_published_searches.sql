@@ -0,0 +1,8 @@
+-- +goose Up
+ALTER TABLE published_feeds
+    ADD COLUMN saved_search_id UUID REFERENCES saved_searches(id) ON DELETE CASCADE;
+
+-- +goose Down
+DELETE FROM published_feeds WHERE saved_search_id IS NOT NULL;
+ALTER TABLE published_feeds
+    DROP COLUMN saved_search_id;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- refresh_requested_at is set by the admins to poll a feed even while it has
+-- an active WebSub lease. The next fetch clears it.
+ALTER TABLE feeds
+    ADD COLUMN refresh_requested_at TIMESTAMP WITH TIME ZONE;
+
+-- +goose Down
+ALTER TABLE feeds
+    DROP COLUMN refresh_requested_at;
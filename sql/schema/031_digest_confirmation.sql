@@ -0,0 +1,23 @@
+-- +goose Up
+-- Digests are only sent once their address followed the link of the
+-- confirmation email, sent by the digest worker.
+ALTER TABLE digest_subscriptions
+    ADD COLUMN confirmation_token   VARCHAR(64),
+    ADD COLUMN confirmation_sent_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN confirmed_at         TIMESTAMP WITH TIME ZONE;
+
+UPDATE digest_subscriptions SET confirmation_token = replace(gen_random_uuid()::text, '-', '');
+-- the subscriptions to the address of the account keep being sent
+UPDATE digest_subscriptions d SET confirmed_at = d.created_at
+FROM users u
+WHERE u.id = d.user_id AND u.email = d.email;
+
+ALTER TABLE digest_subscriptions
+    ALTER COLUMN confirmation_token SET NOT NULL,
+    ADD CONSTRAINT digest_subscriptions_confirmation_token_key UNIQUE (confirmation_token);
+
+-- +goose Down
+ALTER TABLE digest_subscriptions
+    DROP COLUMN confirmation_token,
+    DROP COLUMN confirmation_sent_at,
+    DROP COLUMN confirmed_at;